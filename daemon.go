@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// the daemon is jdwpfs started again with this variable set; it tells
+// the parent whether mounting worked through the inherited pipe
+const daemonEnv = "JDWPFS_DAEMON"
+const daemonReadyFd = 3
+
+func isDaemon() bool {
+	return os.Getenv(daemonEnv) != ""
+}
+
+// daemonize starts jdwpfs again in the background, in its own session,
+// and returns once it mounted, or with the reason it failed. A forked
+// Go process can't be trusted, so the whole program is run again
+func daemonize() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("unable to find the jdwpfs executable: %s", err)
+	}
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("unable to create the daemon pipe: %s", err)
+	}
+	defer readyReader.Close()
+
+	// the daemon logs here until it mounted
+	daemon := exec.Command(executable, os.Args[1:]...)
+	daemon.Env = append(os.Environ(), daemonEnv + "=1")
+	daemon.Stderr = os.Stderr
+	daemon.ExtraFiles = []*os.File{readyWriter}
+	daemon.SysProcAttr = &syscall.SysProcAttr {
+		Setsid: true,
+	}
+
+	err = daemon.Start()
+	readyWriter.Close()
+	if err != nil {
+		return fmt.Errorf("unable to start the daemon: %s", err)
+	}
+
+	return readDaemonStatus(readyReader)
+}
+
+// readDaemonStatus reads the line written by the daemon once it
+// mounted; it writes nothing if it fails
+func readDaemonStatus(status io.Reader) error {
+	line, err := bufio.NewReader(status).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("the daemon exited before mounting")
+	}
+
+	line = strings.TrimSpace(line)
+	if line != "ok" {
+		return fmt.Errorf("the daemon failed: %s", line)
+	}
+
+	return nil
+}
+
+// daemonReady tells the parent the daemon mounted, then detaches the
+// daemon from the parent's terminal; until then, a failure shows on it
+func daemonReady() {
+	status := os.NewFile(daemonReadyFd, "daemon-ready")
+	if status == nil {
+		return
+	}
+	fmt.Fprintf(status, "ok\n")
+	status.Close()
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		log.Printf("unable to open %s: %s\n", os.DevNull, err)
+		return
+	}
+	defer devNull.Close()
+
+	for _, fd := range []int{0, 1, 2} {
+		err = syscall.Dup3(int(devNull.Fd()), fd, 0)
+		if err != nil {
+			log.Printf("unable to detach fd %d: %s\n", fd, err)
+		}
+	}
+}
+
+// writePidfile writes the pid of the process, so that `kill $(cat
+// pidfile)` unmounts it
+func writePidfile(path string, pid int) error {
+	return os.WriteFile(path, []byte(strconv.Itoa(pid) + "\n"), 0644)
+}
+
+// readPidfile reads a pid written by writePidfile
+func readPidfile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid file %s", path)
+	}
+
+	return pid, nil
+}
+
+// removePidfile removes the pid file, unless another process wrote its
+// own pid there since
+func removePidfile(path string, pid int) {
+	writtenPid, err := readPidfile(path)
+	if err != nil || writtenPid != pid {
+		return
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		log.Printf("unable to remove the pid file: %s\n", err)
+	}
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPidfile(t *testing.T) {
+	var tests = []struct {
+		name string
+		contents string
+		pid int
+		fails bool
+	}{
+		{ name: "written by jdwpfs", contents: "4242\n", pid: 4242 },
+		{ name: "without newline", contents: "4242", pid: 4242 },
+		{ name: "empty", contents: "", fails: true },
+		{ name: "not a pid", contents: "jdwpfs\n", fails: true },
+		{ name: "negative", contents: "-1\n", fails: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jdwpfs.pid")
+			if err := os.WriteFile(path, []byte(test.contents), 0644); err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			pid, err := readPidfile(path)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("read pid %d", pid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if pid != test.pid {
+				t.Fatalf("read pid %d, expected %d", pid, test.pid)
+			}
+		})
+	}
+}
+
+func TestRemovePidfile(t *testing.T) {
+	var tests = []struct {
+		name string
+		writtenPid int
+		removed bool
+	}{
+		{ name: "own pid", writtenPid: 4242, removed: true },
+		{ name: "rewritten by another jdwpfs", writtenPid: 4343, removed: false },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jdwpfs.pid")
+			if err := writePidfile(path, test.writtenPid); err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			removePidfile(path, 4242)
+			_, err := os.Stat(path)
+			if removed := os.IsNotExist(err); removed != test.removed {
+				t.Fatalf("removed: %v", removed)
+			}
+		})
+	}
+}
+
+func TestPidfileShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jdwpfs.pid")
+	if err := writePidfile(path, os.Getpid()); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// as jdwpfs waits for a signal to unmount
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	// kill $(cat pidfile)
+	pid, err := readPidfile(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	select {
+	case <-sigs:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the signal didn't reach the process of the pid file")
+	}
+
+	removePidfile(path, os.Getpid())
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the pid file is left after shutting down: %v", err)
+	}
+}
+
+func TestReadDaemonStatus(t *testing.T) {
+	var tests = []struct {
+		name string
+		status string
+		fails bool
+	}{
+		{ name: "mounted", status: "ok\n" },
+		{ name: "mounted, pipe closed early", status: "ok" },
+		{ name: "exited", status: "", fails: true },
+		{ name: "failed", status: "mount failed\n", fails: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := readDaemonStatus(strings.NewReader(test.status))
+			if failed := err != nil; failed != test.fails {
+				t.Fatalf("got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandSetVirtualMachine = 1
+
+	commandCapabilities = 12
+	commandCapabilitiesNew = 17
+)
+
+var (
+	// in reply order; the legacy Capabilities command only
+	// replies with the first seven
+	capabilityNames = []string {
+		"canWatchFieldModification",
+		"canWatchFieldAccess",
+		"canGetBytecodes",
+		"canGetSyntheticAttribute",
+		"canGetOwnedMonitorInfo",
+		"canGetCurrentContendedMonitor",
+		"canGetMonitorInfo",
+		"canRedefineClasses",
+		"canAddMethod",
+		"canUnrestrictedlyRedefineClasses",
+		"canPopFrames",
+		"canUseInstanceFilters",
+		"canGetSourceDebugExtension",
+		"canRequestVMDeathEvent",
+		"canSetDefaultStratum",
+		"canGetInstanceInfo",
+		"canRequestMonitorEvents",
+		"canGetMonitorFrameInfo",
+		"canUseSourceNameFilters",
+		"canGetConstantPool",
+		"canForceEarlyReturn",
+	}
+
+	legacyCapabilityCount = 7
+
+	// event kinds which can only be requested if the VM has
+	// the capability; the rest are always available
+	eventKindCapabilities = map[jdwp.EventKind]string {
+		jdwp.FieldAccess: "canWatchFieldAccess",
+		jdwp.FieldModification: "canWatchFieldModification",
+		jdwp.VMDeath: "canRequestVMDeathEvent",
+	}
+)
+
+//
+// Capabilities of the VM, by their JDWP name
+//
+type Capabilities map[string]bool
+
+func (c Capabilities) Has(name string) bool {
+	return c[name]
+}
+
+// EventKindCapability returns the capability needed to request an
+// event kind, if there is one
+func EventKindCapability(kind jdwp.EventKind) (string, bool) {
+	capability, ok := eventKindCapabilities[kind]
+	return capability, ok
+}
+
+func (c *Connection) getCapabilities(command uint8, count int) (Capabilities, error) {
+	capabilities := Capabilities{}
+	err := c.command(commandSetVirtualMachine, command, nil, func(r *packetReader) {
+		for _, name := range capabilityNames[:count] {
+			capabilities[name] = r.Bool()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return capabilities, nil
+}
+
+// GetCapabilities returns the VM capabilities; they are queried once
+// and cached afterwards
+func (c *Connection) GetCapabilities() (Capabilities, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capabilities != nil {
+		return c.capabilities, nil
+	}
+
+	var capabilities Capabilities
+	var err error
+	legacy := c.isLegacy()
+	if !legacy {
+		capabilities, err = c.getCapabilities(commandCapabilitiesNew, len(capabilityNames))
+		legacy = err == jdwp.ErrNotImplemented
+	}
+	if legacy {
+		capabilities, err = c.getCapabilities(commandCapabilities, legacyCapabilityCount)
+	}
+	if err != nil {
+		return nil, JdwpConnectionError { err: err }
+	}
+
+	c.capabilities = capabilities
+
+	return capabilities, nil
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"fmt"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandReferenceTypeClassFileVersion = 17
+)
+
+//
+// Class file version
+// The version of the class file format a class was loaded from; arrays
+// and primitive types have none
+//
+type ClassFileVersion struct {
+	Major int32
+	Minor int32
+}
+
+func (v ClassFileVersion) String() string {
+	return fmt.Sprintf("major: %d\nminor: %d\n", v.Major, v.Minor)
+}
+
+func (c *Connection) GetClassFileVersion(id jdwp.ReferenceTypeID) (ClassFileVersion, error) {
+	var version ClassFileVersion
+	err := c.command(commandSetReferenceType, commandReferenceTypeClassFileVersion, func(w *packetWriter) {
+		w.ReferenceTypeID(uint64(id))
+	}, func(r *packetReader) {
+		version.Major = r.Int32()
+		version.Minor = r.Int32()
+	})
+	if err != nil {
+		return ClassFileVersion{}, err
+	}
+
+	return version, nil
+}
@@ -0,0 +1,319 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	handshakeLength = 14
+	packetHeaderLength = 11
+	packetIsReply = 0x80
+
+	// gojdb numbers its packets upwards from 0, ours are numbered
+	// from the upper half, so the replies can be told apart
+	commandIdBase = 0x80000000
+
+	commandTimeout = 120 * time.Second
+)
+
+//
+// Command channel errors
+//
+type CommandChannelError struct {
+	err error
+	message string
+	timeout bool
+}
+
+func (e CommandChannelError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("command channel error: %s", e.err)
+	}
+
+	return fmt.Sprintf("command channel error: %s", e.message)
+}
+
+func (e CommandChannelError) Unwrap() error {
+	return e.err
+}
+
+// Timeout tells if the VM didn't answer in time
+func (e CommandChannelError) Timeout() bool {
+	return e.timeout
+}
+
+type commandReply struct {
+	errorCode jdwp.Error
+	data []byte
+}
+
+//
+// Command channel
+// gojdb only implements a subset of JDWP; the command channel sits between
+// the socket and the gojdb connection, passing its traffic through while
+// allowing jdwpfs to send the commands gojdb doesn't know about.
+//
+type CommandChannel struct {
+	conn io.ReadWriteCloser
+
+	// whole packets only are written, under wmu
+	wmu sync.Mutex
+	outHandshake int
+	outPending []byte
+
+	rmu sync.Mutex
+	inHandshake int
+	inPending []byte
+
+	mu sync.Mutex
+	closed error
+	nextId uint32
+	replies map[uint32]chan commandReply
+	idSizes jdwp.IDSizes
+
+	// the EventRequest.Set of gojdb whose reply is waited for
+	eventRequest chan jdwp.Error
+	eventRequestId uint32
+	eventRequestSent bool
+}
+
+func NewCommandChannel(conn io.ReadWriteCloser) *CommandChannel {
+	return &CommandChannel {
+		conn: conn,
+		replies: map[uint32]chan commandReply{},
+	}
+}
+
+func (c *CommandChannel) SetIDSizes(idSizes jdwp.IDSizes) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.idSizes = idSizes
+}
+
+func (c *CommandChannel) GetIDSizes() jdwp.IDSizes {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.idSizes
+}
+
+// Write is used by gojdb; partial packets are held back until complete,
+// so they never interleave with the packets sent by Command
+func (c *CommandChannel) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	var written = 0
+	if c.outHandshake < handshakeLength {
+		handshakePart := p
+		if len(handshakePart) > handshakeLength - c.outHandshake {
+			handshakePart = handshakePart[:handshakeLength - c.outHandshake]
+		}
+
+		n, err := c.conn.Write(handshakePart)
+		c.outHandshake += n
+		if err != nil {
+			return n, err
+		}
+
+		written = n
+		p = p[n:]
+	}
+
+	c.outPending = append(c.outPending, p...)
+	for len(c.outPending) >= 4 {
+		length := int(binary.BigEndian.Uint32(c.outPending))
+		if length < packetHeaderLength {
+			return written, CommandChannelError {
+				message: fmt.Sprintf("outgoing packet length too short (%d)", length),
+			}
+		}
+
+		if len(c.outPending) < length {
+			break
+		}
+
+		// before the reply can come
+		c.noteSent(c.outPending[:length])
+		_, err := c.conn.Write(c.outPending[:length])
+		if err != nil {
+			return written, err
+		}
+
+		c.outPending = c.outPending[length:]
+	}
+
+	if len(c.outPending) == 0 {
+		c.outPending = nil
+	}
+
+	return written + len(p), nil
+}
+
+// Read is used by gojdb; the replies to Command are consumed here and
+// never reach it
+func (c *CommandChannel) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if c.inHandshake < handshakeLength {
+		if len(p) > handshakeLength - c.inHandshake {
+			p = p[:handshakeLength - c.inHandshake]
+		}
+
+		n, err := c.conn.Read(p)
+		c.inHandshake += n
+		return n, err
+	}
+
+	for len(c.inPending) == 0 {
+		packet, err := c.readPacket()
+		if err != nil {
+			c.fail(err)
+			return 0, err
+		}
+
+		if !c.dispatch(packet) {
+			c.noteReceived(packet)
+			c.inPending = packet
+		}
+	}
+
+	n := copy(p, c.inPending)
+	c.inPending = c.inPending[n:]
+
+	return n, nil
+}
+
+func (c *CommandChannel) Close() error {
+	c.fail(io.EOF)
+	return c.conn.Close()
+}
+
+func (c *CommandChannel) readPacket() ([]byte, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(c.conn, header)
+	if err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length < packetHeaderLength {
+		return nil, CommandChannelError {
+			message: fmt.Sprintf("incoming packet length too short (%d)", length),
+		}
+	}
+
+	packet := make([]byte, length)
+	copy(packet, header)
+	_, err = io.ReadFull(c.conn, packet[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	return packet, nil
+}
+
+// dispatch hands the replies to Command to their waiters, and reports
+// whether the packet was consumed
+func (c *CommandChannel) dispatch(packet []byte) bool {
+	id := binary.BigEndian.Uint32(packet[4:8])
+	flags := packet[8]
+	if flags & packetIsReply == 0 || id < commandIdBase {
+		return false
+	}
+
+	c.mu.Lock()
+	replyChan, ok := c.replies[id]
+	delete(c.replies, id)
+	c.mu.Unlock()
+
+	if ok {
+		replyChan <- commandReply {
+			errorCode: jdwp.Error(binary.BigEndian.Uint16(packet[9:11])),
+			data: packet[packetHeaderLength:],
+		}
+	}
+
+	return true
+}
+
+// fail releases all the pending commands once the socket is unusable
+func (c *CommandChannel) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed != nil {
+		return
+	}
+
+	c.closed = err
+	for id, replyChan := range c.replies {
+		close(replyChan)
+		delete(c.replies, id)
+	}
+}
+
+func (c *CommandChannel) forget(id uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.replies, id)
+}
+
+// Command sends a command packet and waits for its reply; JDWP errors
+// are returned as jdwp.Error, the same way gojdb does
+func (c *CommandChannel) Command(commandSet uint8, command uint8, data []byte) ([]byte, error) {
+	c.mu.Lock()
+	if c.closed != nil {
+		c.mu.Unlock()
+		return nil, CommandChannelError { err: c.closed }
+	}
+
+	id := commandIdBase + c.nextId
+	c.nextId = (c.nextId + 1) % commandIdBase
+	replyChan := make(chan commandReply, 1)
+	c.replies[id] = replyChan
+	c.mu.Unlock()
+
+	packet := make([]byte, packetHeaderLength, packetHeaderLength + len(data))
+	binary.BigEndian.PutUint32(packet[0:4], uint32(packetHeaderLength + len(data)))
+	binary.BigEndian.PutUint32(packet[4:8], id)
+	packet[9] = commandSet
+	packet[10] = command
+	packet = append(packet, data...)
+
+	c.wmu.Lock()
+	_, err := c.conn.Write(packet)
+	c.wmu.Unlock()
+	if err != nil {
+		c.forget(id)
+		return nil, CommandChannelError { err: err }
+	}
+
+	select {
+	case reply, ok := <-replyChan:
+		if !ok {
+			return nil, CommandChannelError { message: "connection closed" }
+		}
+
+		if reply.errorCode != jdwp.ErrNone {
+			return nil, reply.errorCode
+		}
+
+		return reply.data, nil
+	case <-time.After(commandTimeout):
+		c.forget(id)
+		return nil, CommandChannelError { message: "timeout", timeout: true }
+	}
+}
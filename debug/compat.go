@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"log"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// the JDWP version which brought CapabilitiesNew; older VMs only get the
+// legacy Capabilities command
+const (
+	compatJdwpMajor = 1
+	compatJdwpMinor = 4
+)
+
+// SetCompat makes the connection use the legacy commands only, without
+// trying the newer ones first; older VMs are detected without it
+func (c *Connection) SetCompat(compat bool) {
+	c.vmu.Lock()
+	defer c.vmu.Unlock()
+
+	c.compat = compat
+}
+
+// GetVersion returns the JDWP and VM versions; they are queried once
+// and cached afterwards. The VM isn't waited on under vmu, so callers
+// racing on the first query may each ask it; versions asked of the VM
+// before it was swapped for another aren't kept
+func (c *Connection) GetVersion() (jdwp.Version, error) {
+	c.vmu.Lock()
+	cached := c.version
+	generation := c.versionGeneration
+	c.vmu.Unlock()
+
+	if cached != nil {
+		return *cached, nil
+	}
+
+	var version jdwp.Version
+	var err error
+	func() {
+		defer c.acquire()()
+		version, err = c.jdwp().GetVersion()
+	}()
+	if err != nil {
+		return jdwp.Version{}, JdwpConnectionError { err: err }
+	}
+
+	c.vmu.Lock()
+	if c.versionGeneration == generation {
+		c.version = &version
+	}
+	c.vmu.Unlock()
+
+	return version, nil
+}
+
+// isLegacy tells if the legacy commands have to be used, either because
+// of --compat, or because the VM speaks an older JDWP; if the version
+// can't be told, the newer commands are tried, and fall back themselves
+func (c *Connection) isLegacy() bool {
+	c.vmu.Lock()
+	compat := c.compat
+	c.vmu.Unlock()
+
+	if compat {
+		return true
+	}
+
+	version, err := c.GetVersion()
+	if err != nil {
+		log.Printf("unable to get the JDWP version: %s\n", err)
+		return false
+	}
+
+	if version.JDWPMajor != compatJdwpMajor {
+		return version.JDWPMajor < compatJdwpMajor
+	}
+
+	return version.JDWPMinor < compatJdwpMinor
+}
+
+// findClassesBySignature goes through all the classes, for VMs which
+// answer ClassesBySignature (command 2, there since JDWP 1.0) with
+// NOT_IMPLEMENTED
+func (c *Connection) findClassesBySignature(signature string) ([]jdwp.ClassInfo, error) {
+	classes, err := c.GetAllClasses()
+	if err != nil {
+		return nil, err
+	}
+
+	var found = []jdwp.ClassInfo{}
+	for _, class := range classes {
+		if class.Signature == signature {
+			found = append(found, class)
+		}
+	}
+
+	return found, nil
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"testing"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func fakeVersion(major uint32, minor uint32) []byte {
+	return fakeConcat(fakeString("fake"), fakeInt(major), fakeInt(minor), fakeString("fake"), fakeString("fake"))
+}
+
+func TestCapabilitiesCompat(t *testing.T) {
+	var tests = []struct {
+		name string
+		major uint32
+		minor uint32
+		compat bool
+		newUnimplemented bool
+		legacy bool
+	}{
+		{ name: "JDWP 11", major: 11, minor: 0, legacy: false },
+		{ name: "JDWP 1.4", major: 1, minor: 4, legacy: false },
+		{ name: "JDWP 1.3", major: 1, minor: 3, legacy: true },
+		{ name: "compat", major: 11, minor: 0, compat: true, legacy: true },
+		{ name: "CapabilitiesNew not implemented", major: 11, minor: 0, newUnimplemented: true, legacy: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(commandSetVirtualMachine, 1, 0, fakeVersion(test.major, test.minor))
+			if test.newUnimplemented {
+				vm.Answer(commandSetVirtualMachine, commandCapabilitiesNew, uint16(jdwp.ErrNotImplemented), nil)
+			}
+			conn.SetCompat(test.compat)
+
+			if _, err := conn.GetCapabilities(); err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			legacySent := vm.Received(commandSetVirtualMachine, commandCapabilities)
+			if (legacySent == 1) != test.legacy || legacySent > 1 {
+				t.Fatalf("Capabilities sent %d times, legacy %v", legacySent, test.legacy)
+			}
+		})
+	}
+}
+
+func TestClassesBySignatureFallback(t *testing.T) {
+	const signature = "Lcom/example/Main;"
+
+	var tests = []struct {
+		name string
+		major uint32
+		minor uint32
+		compat bool
+		unimplemented bool
+		listed bool
+	}{
+		{ name: "JDWP 11", major: 11, minor: 0 },
+		// ClassesBySignature has been there since JDWP 1.0
+		{ name: "JDWP 1.3", major: 1, minor: 3 },
+		{ name: "compat", major: 11, minor: 0, compat: true },
+		{ name: "not implemented", major: 11, minor: 0, unimplemented: true, listed: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(commandSetVirtualMachine, 1, 0, fakeVersion(test.major, test.minor))
+			if test.unimplemented {
+				vm.Answer(commandSetVirtualMachine, 2, uint16(jdwp.ErrNotImplemented), nil)
+			} else {
+				vm.Answer(commandSetVirtualMachine, 2, 0,
+					fakeConcat(fakeInt(1), []byte{1}, fakeLong(42), fakeInt(7)))
+			}
+			vm.Answer(commandSetVirtualMachine, 3, 0, fakeConcat(
+				fakeInt(2),
+				[]byte{1}, fakeLong(41), fakeString("Lcom/example/Other;"), fakeInt(7),
+				[]byte{1}, fakeLong(42), fakeString(signature), fakeInt(7)))
+			conn.SetCompat(test.compat)
+
+			classes, err := conn.GetClassesBySignature(signature)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if len(classes) != 1 || classes[0].TypeID != 42 {
+				t.Fatalf("got classes %+v", classes)
+			}
+
+			if sent := vm.Received(commandSetVirtualMachine, 2); sent != 1 {
+				t.Fatalf("ClassesBySignature sent %d times", sent)
+			}
+			if listed := vm.Received(commandSetVirtualMachine, 3) != 0; listed != test.listed {
+				t.Fatalf("all the classes listed: %v", listed)
+			}
+		})
+	}
+}
+
+func TestGetVersionUnlocked(t *testing.T) {
+	conn, vm := startFakeVM(t, nil)
+	before := vm.Received(commandSetVirtualMachine, 1)
+	// the version is answered once the compat flag is set
+	answer := make(chan struct{})
+	vm.Hold(commandSetVirtualMachine, 1, answer)
+
+	read := make(chan error)
+	go func() {
+		_, err := conn.GetVersion()
+		read <- err
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for vm.Received(commandSetVirtualMachine, 1) == before {
+		if time.Now().After(deadline) {
+			t.Fatalf("the version wasn't asked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	set := make(chan struct{})
+	go func() {
+		conn.SetCompat(true)
+		close(set)
+	}()
+	select {
+	case <-set:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the compat flag waited on the VM")
+	}
+	close(answer)
+	if err := <-read; err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if _, err := conn.GetVersion(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if sent := vm.Received(commandSetVirtualMachine, 1); sent != before + 1 {
+		t.Fatalf("asked the version %d times", sent - before)
+	}
+}
@@ -0,0 +1,458 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Connection errors
+//
+type JdwpConnectionError struct {
+	err error
+	message string
+}
+
+func (e JdwpConnectionError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("jdwp connection error: %s", e.err)
+	}
+
+	return fmt.Sprintf("jdwp connection error: %s", e.message)
+}
+
+//
+// Connection
+// Wraps the gojdb connection, together with the command channel used
+// for what gojdb can't express, and the state cached about the VM
+//
+type Connection struct {
+	// swapped on reconnection, under cmu
+	cmu sync.RWMutex
+	conn *jdwp.Connection
+	commands *CommandChannel
+
+	// bounds the JDWP calls in flight, nil if unbounded
+	limiter chan struct{}
+
+	// the calls waiting for a slot, and the ones in flight, under pmu
+	pmu sync.Mutex
+	queued int
+	inFlight int
+
+	// retries of the reads which can be repeated safely
+	retries int
+
+	mu sync.Mutex
+	capabilities Capabilities
+
+	// the versions of the VM, how many times they were dropped, and
+	// whether to only use the legacy commands, under vmu
+	vmu sync.Mutex
+	version *jdwp.Version
+	versionGeneration uint64
+	compat bool
+
+	// suspensions made through the connection, under smu
+	smu sync.Mutex
+	suspensions map[jdwp.ThreadID]int
+	allSuspensions int
+	suspendChanges map[jdwp.ThreadID]time.Time
+	suspendAllChange time.Time
+
+	// methods and fields of the classes, plain and with their generic
+	// signatures, under kmu
+	kmu sync.Mutex
+	methods map[jdwp.ReferenceTypeID]jdwp.Methods
+	fields map[jdwp.ReferenceTypeID]jdwp.Fields
+	genericMethods map[jdwp.ReferenceTypeID][]GenericMethod
+	genericFields map[jdwp.ReferenceTypeID][]GenericField
+
+	// recently read thread statuses, and the last status seen of each
+	// thread, under tmu; the generations count the statuses dropped, of
+	// each thread and of all of them
+	tmu sync.Mutex
+	threadStatuses map[jdwp.ThreadID]cachedThreadStatus
+	observedStatuses map[jdwp.ThreadID]observedThreadStatus
+	statusGenerations map[jdwp.ThreadID]uint64
+	statusesGeneration uint64
+
+	// when the VM was last used, and whether the connection was closed
+	// for being idle, under imu; wmu serializes opening it again
+	imu sync.Mutex
+	lastActivity time.Time
+	disconnected bool
+	redial func() (*Connection, error)
+	wmu sync.Mutex
+
+	// held while an event request is being set, so the reply to it can
+	// be told apart
+	emu sync.Mutex
+
+	// the objects kept for the suspending events, under omu
+	omu sync.Mutex
+	inspectEvents bool
+	inspected map[jdwp.ObjectID]InspectedObject
+
+	// the events waiting to be inspected, and whether a worker is at
+	// them, under omu; the wait group is done once they all were
+	inspections []pendingInspection
+	inspecting bool
+	pendingInspections sync.WaitGroup
+}
+
+func OpenConnection(ctx context.Context, rwc io.ReadWriteCloser) (*Connection, error) {
+	commands := NewCommandChannel(rwc)
+
+	jdwpConnection, err := jdwp.Open(ctx, commands)
+	if err != nil {
+		return nil, JdwpConnectionError { err: err }
+	}
+
+	idSizes, err := jdwpConnection.GetIDSizes()
+	if err != nil {
+		return nil, JdwpConnectionError { err: err }
+	}
+	commands.SetIDSizes(idSizes)
+
+	connection := &Connection {
+		conn: jdwpConnection,
+		commands: commands,
+		lastActivity: time.Now(),
+	}
+
+	return connection, nil
+}
+
+// Swap moves the connection other was opened with into c, which keeps
+// being used by everyone holding it; the previous connection is closed
+func (c *Connection) Swap(other *Connection) error {
+	other.cmu.Lock()
+	conn := other.conn
+	commands := other.commands
+	other.cmu.Unlock()
+
+	c.cmu.Lock()
+	oldCommands := c.commands
+	c.conn = conn
+	c.commands = commands
+	c.cmu.Unlock()
+
+	// the capabilities may differ, e.g. if the VM was restarted
+	c.mu.Lock()
+	c.capabilities = nil
+	c.mu.Unlock()
+
+	c.vmu.Lock()
+	c.version = nil
+	c.versionGeneration++
+	c.vmu.Unlock()
+
+	// and the suspensions went away with the old connection
+	c.smu.Lock()
+	c.suspensions = nil
+	c.allSuspensions = 0
+	c.smu.Unlock()
+	c.forgetInspected()
+	c.forgetObservedStatuses()
+
+	// the ids are only valid for the connection they came from
+	c.FlushCaches()
+
+	c.imu.Lock()
+	c.disconnected = false
+	c.imu.Unlock()
+
+	return oldCommands.Close()
+}
+
+// SetMaxConcurrent bounds the number of JDWP calls in flight; the rest
+// wait for their turn. 0 means unbounded. Watching events isn't bounded,
+// as it lasts as long as the event runs
+func (c *Connection) SetMaxConcurrent(max int) {
+	c.cmu.Lock()
+	defer c.cmu.Unlock()
+
+	if max <= 0 {
+		c.limiter = nil
+		return
+	}
+
+	c.limiter = make(chan struct{}, max)
+}
+
+// acquire waits for a slot, and returns the function releasing it
+func (c *Connection) acquire() func() {
+	c.cmu.RLock()
+	limiter := c.limiter
+	c.cmu.RUnlock()
+
+	c.pmu.Lock()
+	c.queued++
+	c.pmu.Unlock()
+
+	if limiter != nil {
+		limiter <- struct{}{}
+	}
+
+	c.pmu.Lock()
+	c.queued--
+	c.inFlight++
+	c.pmu.Unlock()
+
+	return func() {
+		c.pmu.Lock()
+		c.inFlight--
+		c.pmu.Unlock()
+
+		if limiter != nil {
+			<-limiter
+		}
+	}
+}
+
+// GetPendingCalls tells how many JDWP calls wait for a slot, and how
+// many are in flight; watching events isn't counted
+func (c *Connection) GetPendingCalls() (int, int) {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	return c.queued, c.inFlight
+}
+
+// Close closes the connection for good, without opening it again
+func (c *Connection) Close() error {
+	c.cmu.RLock()
+	commands := c.commands
+	c.cmu.RUnlock()
+
+	return commands.Close()
+}
+
+func (c *Connection) jdwp() *jdwp.Connection {
+	c.wake()
+
+	c.cmu.RLock()
+	defer c.cmu.RUnlock()
+
+	return c.conn
+}
+
+func (c *Connection) channel() *CommandChannel {
+	c.wake()
+
+	c.cmu.RLock()
+	defer c.cmu.RUnlock()
+
+	return c.commands
+}
+
+// command sends a command through the command channel, decoding the
+// reply with decode
+func (c *Connection) command(commandSet uint8, command uint8, encode func(*packetWriter), decode func(*packetReader)) error {
+	defer c.acquire()()
+
+	commands := c.channel()
+	idSizes := commands.GetIDSizes()
+
+	writer := newPacketWriter(idSizes)
+	if encode != nil {
+		encode(writer)
+	}
+	if writer.Error() != nil {
+		return writer.Error()
+	}
+
+	data, err := commands.Command(commandSet, command, writer.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if decode != nil {
+		reader := newPacketReader(idSizes, data)
+		decode(reader)
+		return reader.Error()
+	}
+
+	return nil
+}
+
+//
+// gojdb commands
+//
+func (c *Connection) GetAllThreads() ([]jdwp.ThreadID, error) {
+	var threads []jdwp.ThreadID
+	err := c.retry(func() (err error) {
+		defer c.acquire()()
+		threads, err = c.jdwp().GetAllThreads()
+		return err
+	})
+	return threads, err
+}
+
+func (c *Connection) GetThreadName(id jdwp.ThreadID) (string, error) {
+	var name string
+	err := c.retry(func() (err error) {
+		defer c.acquire()()
+		name, err = c.jdwp().GetThreadName(id)
+		return err
+	})
+	return name, err
+}
+
+func (c *Connection) Suspend(id jdwp.ThreadID) error {
+	defer c.acquire()()
+	defer c.invalidateThreadStatus(id)
+	err := c.jdwp().Suspend(id)
+	if err == nil {
+		c.ownSuspension(id)
+	}
+	return err
+}
+
+func (c *Connection) Resume(id jdwp.ThreadID) error {
+	// after the slot is given back
+	defer c.releaseInspected(id)
+	defer c.acquire()()
+	defer c.invalidateThreadStatus(id)
+	err := c.jdwp().Resume(id)
+	if err == nil {
+		c.disownSuspension(id)
+	}
+	return err
+}
+
+func (c *Connection) GetFrames(id jdwp.ThreadID, start int, count int) ([]jdwp.FrameInfo, error) {
+	defer c.acquire()()
+	return c.jdwp().GetFrames(id, start, count)
+}
+
+func (c *Connection) SuspendAll() error {
+	defer c.acquire()()
+	defer c.flushThreadStatuses()
+	err := c.jdwp().SuspendAll()
+	if err == nil {
+		c.ownAllSuspension()
+	}
+	return err
+}
+
+func (c *Connection) ResumeAll() error {
+	defer c.releaseInspected()
+	defer c.acquire()()
+	defer c.flushThreadStatuses()
+	err := c.jdwp().ResumeAll()
+	if err == nil {
+		c.disownAllSuspension()
+	}
+	return err
+}
+
+func (c *Connection) GetAllClasses() ([]jdwp.ClassInfo, error) {
+	var classes []jdwp.ClassInfo
+	err := c.retry(func() (err error) {
+		defer c.acquire()()
+		classes, err = c.jdwp().GetAllClasses()
+		return err
+	})
+	return classes, err
+}
+
+// GetClassesBySignature asks the VM for the classes with the signature,
+// and goes through all of them if it can't
+func (c *Connection) GetClassesBySignature(signature string) ([]jdwp.ClassInfo, error) {
+	var classes []jdwp.ClassInfo
+	err := c.retry(func() (err error) {
+		defer c.acquire()()
+		classes, err = c.jdwp().GetClassesBySignature(signature)
+		return err
+	})
+	if errors.Is(err, jdwp.ErrNotImplemented) {
+		return c.findClassesBySignature(signature)
+	}
+	return classes, err
+}
+
+func (c *Connection) GetTypeSignature(ty jdwp.ReferenceTypeID) (string, error) {
+	defer c.acquire()()
+	return c.jdwp().GetTypeSignature(ty)
+}
+
+func (c *Connection) GetLineTable(ty jdwp.ReferenceTypeID, method jdwp.MethodID) (jdwp.LineTable, error) {
+	defer c.acquire()()
+	return c.jdwp().LineTable(ty, method)
+}
+
+func (c *Connection) GetSuperClass(class jdwp.ClassID) (jdwp.ClassID, error) {
+	defer c.acquire()()
+	return c.jdwp().GetSuperClass(class)
+}
+
+func (c *Connection) GetObjectType(object jdwp.ObjectID) (jdwp.ObjectType, error) {
+	defer c.acquire()()
+	return c.jdwp().GetObjectType(object)
+}
+
+func (c *Connection) GetFieldValues(object jdwp.ObjectID, fields ...jdwp.FieldID) ([]jdwp.Value, error) {
+	defer c.acquire()()
+	return c.jdwp().GetFieldValues(object, fields...)
+}
+
+func (c *Connection) GetStaticFieldValues(ty jdwp.ReferenceTypeID, fields ...jdwp.FieldID) ([]jdwp.Value, error) {
+	defer c.acquire()()
+	return c.jdwp().GetStaticFieldValues(ty, fields...)
+}
+
+// WatchEvents sets an event request and hands its events to handler
+// until ctx is done; registered is told when the VM accepted the
+// request, and when it's gone
+func (c *Connection) WatchEvents(
+	ctx context.Context,
+	kind jdwp.EventKind,
+	suspendPolicy jdwp.SuspendPolicy,
+	handler func(jdwp.Event) bool,
+	registered func(bool),
+	modifiers ...jdwp.EventModifier) error {
+	observingHandler := func(event jdwp.Event) bool {
+		c.observeThreadStatus(event, suspendPolicy)
+		return handler(event)
+	}
+
+	conn := c.jdwp()
+	commands := c.channel()
+
+	c.emu.Lock()
+	replied := commands.expectEventRequest()
+	finished := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+
+		select {
+		case errorCode := <-replied:
+			c.emu.Unlock()
+			if errorCode == jdwp.ErrNone {
+				registered(true)
+			}
+		case <-finished:
+			commands.forgetEventRequest()
+			c.emu.Unlock()
+		}
+	}()
+
+	err := conn.WatchEvents(ctx, kind, suspendPolicy, observingHandler, modifiers...)
+	close(finished)
+	<-watched
+	registered(false)
+
+	return err
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandSetReferenceType = 2
+
+	commandReferenceTypeConstantPool = 18
+)
+
+//
+// Constant pool
+// The raw constant pool of a class, as in the class file, without its
+// count; it needs the canGetConstantPool capability
+//
+type ConstantPool struct {
+	Count int32
+	Bytes []byte
+}
+
+// String renders the count and the length, followed by a hexdump
+func (p ConstantPool) String() string {
+	return fmt.Sprintf("count: %d\nbytes: %d\n%s", p.Count, len(p.Bytes), hex.Dump(p.Bytes))
+}
+
+func (c *Connection) GetConstantPool(id jdwp.ReferenceTypeID) (ConstantPool, error) {
+	var pool ConstantPool
+	err := c.command(commandSetReferenceType, commandReferenceTypeConstantPool, func(w *packetWriter) {
+		w.ReferenceTypeID(uint64(id))
+	}, func(r *packetReader) {
+		pool.Count = r.Int32()
+		pool.Bytes = r.Bytes(r.Int32())
+	})
+	if err != nil {
+		return ConstantPool{}, err
+	}
+
+	return pool, nil
+}
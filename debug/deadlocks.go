@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"sort"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// FindDeadlocks finds the cycles of a wait-for graph, in which every
+// thread waits for at most one other thread; each cycle starts with its
+// lowest thread id, and the cycles are sorted the same way
+func FindDeadlocks(waitsFor map[jdwp.ThreadID]jdwp.ThreadID) [][]jdwp.ThreadID {
+	var threads []jdwp.ThreadID
+	for thread := range waitsFor {
+		threads = append(threads, thread)
+	}
+	sort.Slice(threads, func(i, j int) bool { return threads[i] < threads[j] })
+
+	// threads already known to be, or not to be, part of a cycle
+	var visited = map[jdwp.ThreadID]bool{}
+
+	var deadlocks [][]jdwp.ThreadID
+	for _, start := range threads {
+		if visited[start] {
+			continue
+		}
+
+		var path []jdwp.ThreadID
+		var position = map[jdwp.ThreadID]int{}
+		current := start
+		for {
+			if visited[current] {
+				break
+			}
+
+			if i, ok := position[current]; ok {
+				deadlocks = append(deadlocks, path[i:])
+				break
+			}
+
+			position[current] = len(path)
+			path = append(path, current)
+
+			next, ok := waitsFor[current]
+			if !ok {
+				break
+			}
+			current = next
+		}
+
+		for _, thread := range path {
+			visited[thread] = true
+		}
+	}
+
+	for _, deadlock := range deadlocks {
+		lowest := 0
+		for i, thread := range deadlock {
+			if thread < deadlock[lowest] {
+				lowest = i
+			}
+		}
+		rotated := append(append([]jdwp.ThreadID{}, deadlock[lowest:]...), deadlock[:lowest]...)
+		copy(deadlock, rotated)
+	}
+	sort.Slice(deadlocks, func(i, j int) bool { return deadlocks[i][0] < deadlocks[j][0] })
+
+	return deadlocks
+}
+
+// GetDeadlocks builds the wait-for graph of the given threads out of
+// their owned and contended monitors; the threads must be suspended
+func (c *Connection) GetDeadlocks(threads []jdwp.ThreadID) ([][]jdwp.ThreadID, error) {
+	var owners = map[jdwp.ObjectID]jdwp.ThreadID{}
+	var contended = map[jdwp.ThreadID]jdwp.ObjectID{}
+
+	for _, thread := range threads {
+		monitors, err := c.GetOwnedMonitors(thread)
+		if err != nil {
+			return nil, err
+		}
+		for _, monitor := range monitors {
+			owners[monitor] = thread
+		}
+
+		monitor, err := c.GetCurrentContendedMonitor(thread)
+		if err != nil {
+			return nil, err
+		}
+		if monitor != 0 {
+			contended[thread] = monitor
+		}
+	}
+
+	var waitsFor = map[jdwp.ThreadID]jdwp.ThreadID{}
+	for thread, monitor := range contended {
+		owner, ok := owners[monitor]
+		if ok && owner != thread {
+			waitsFor[thread] = owner
+		}
+	}
+
+	return FindDeadlocks(waitsFor), nil
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"reflect"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestFindDeadlocks(t *testing.T) {
+	var tests = []struct {
+		name string
+		waitsFor map[jdwp.ThreadID]jdwp.ThreadID
+		deadlocks [][]jdwp.ThreadID
+	}{
+		{ name: "nobody waits", waitsFor: map[jdwp.ThreadID]jdwp.ThreadID{} },
+		{ name: "chain", waitsFor: map[jdwp.ThreadID]jdwp.ThreadID { 1: 2, 2: 3 } },
+		{
+			name: "two threads",
+			waitsFor: map[jdwp.ThreadID]jdwp.ThreadID { 2: 1, 1: 2 },
+			deadlocks: [][]jdwp.ThreadID { { 1, 2 } },
+		},
+		{
+			name: "three threads, from the lowest",
+			waitsFor: map[jdwp.ThreadID]jdwp.ThreadID { 5: 3, 3: 7, 7: 5 },
+			deadlocks: [][]jdwp.ThreadID { { 3, 7, 5 } },
+		},
+		{
+			name: "chain into a cycle",
+			waitsFor: map[jdwp.ThreadID]jdwp.ThreadID { 1: 4, 4: 5, 5: 4 },
+			deadlocks: [][]jdwp.ThreadID { { 4, 5 } },
+		},
+		{
+			name: "two cycles",
+			waitsFor: map[jdwp.ThreadID]jdwp.ThreadID { 9: 8, 8: 9, 3: 2, 2: 3, 6: 2 },
+			deadlocks: [][]jdwp.ThreadID { { 2, 3 }, { 8, 9 } },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deadlocks := FindDeadlocks(test.waitsFor)
+			if !reflect.DeepEqual(deadlocks, test.deadlocks) {
+				t.Fatalf("found %v, expected %v", deadlocks, test.deadlocks)
+			}
+		})
+	}
+}
+
+// monitorCycleHandler has thread 31 owning monitor 41 and waiting for
+// 42, which 32 owns while waiting for 41; thread 33 owns nothing
+func monitorCycleHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	if set != commandSetThreadReference {
+		return 0, nil
+	}
+
+	var owned, contended = map[byte]uint64 { 31: 41, 32: 42 }, map[byte]uint64 { 31: 42, 32: 41 }
+	switch cmd {
+	case commandThreadOwnedMonitors:
+		if monitor, ok := owned[data[7]]; ok {
+			return 0, fakeConcat(fakeInt(1), []byte{'L'}, fakeLong(monitor))
+		}
+		return 0, fakeInt(0)
+	case commandThreadCurrentContendedMonitor:
+		return 0, fakeConcat([]byte{'L'}, fakeLong(contended[data[7]]))
+	default:
+		return 0, nil
+	}
+}
+
+func TestGetDeadlocks(t *testing.T) {
+	conn, _ := startFakeVM(t, monitorCycleHandler)
+
+	deadlocks, err := conn.GetDeadlocks([]jdwp.ThreadID { 33, 32, 31 })
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !reflect.DeepEqual(deadlocks, [][]jdwp.ThreadID { { 31, 32 } }) {
+		t.Fatalf("found %v", deadlocks)
+	}
+
+	// the cycle needs both threads
+	deadlocks, err = conn.GetDeadlocks([]jdwp.ThreadID { 31, 33 })
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(deadlocks) != 0 {
+		t.Fatalf("found %v among threads not waiting for each other", deadlocks)
+	}
+}
@@ -5,9 +5,11 @@ package debug
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	jdwp "github.com/omerye/gojdb/jdwp"
 )
@@ -16,17 +18,58 @@ import (
 // Modifier descriptor
 //
 type ModifierDescriptor struct {
-	Name string
-	Kind jdwp.TypeTag
-	IsField bool
-	ClassId uint64
-	ObjectId uint64
+	Name string `json:"name"`
+	Kind jdwp.TypeTag `json:"typeTag"`
+	IsField bool `json:"isField"`
+	ClassId uint64 `json:"classId"`
+	ObjectId uint64 `json:"objectId"`
+
+	// resolved when the modifier is created, so the ids can be
+	// matched again against another VM
+	ClassSignature string `json:"classSignature"`
+	ObjectName string `json:"objectName"`
+	ObjectSignature string `json:"objectSignature"`
+
+	// for methods, where in the method the location is; a line of 0
+	// means the code index wasn't picked from the line table
+	CodeIndex uint64 `json:"codeIndex"`
+	Line int `json:"line"`
+
+	// for modifiers limiting the events to a thread, the thread; 0 for
+	// fields and methods
+	ThreadId uint64 `json:"threadId"`
+
+	// created through mkdir, with no target yet; it isn't applied
+	// when the event is run
+	Pending bool `json:"pending"`
 }
 
 func (d ModifierDescriptor) ToModifier() jdwp.EventModifier {
 	return nil
 }
 
+//
+// Debugging event status, read in one go
+//
+type EventStatus struct {
+	Running bool
+	Registered bool
+	Modifiers int
+	Hooks int
+}
+
+//
+// Event run
+// What a run shares with the goroutine watching it: the goroutine closes
+// done once the watch is over, the hooks are handed one event at a time,
+// and users counts the others still handing events to the plugins
+//
+type eventRun struct {
+	done chan struct{}
+	hooks sync.Mutex
+	users sync.WaitGroup
+}
+
 //
 // Debugging Event
 //
@@ -36,12 +79,28 @@ type DebuggingEvent struct {
 	suspendPolicy jdwp.SuspendPolicy
 	modifierDescriptors map[string]ModifierDescriptor
 	hookDescriptors map[string]string
+	eventLog *EventLog
+	eventStream *EventStream
 	
 	mu sync.RWMutex
 	registered bool
+	enabled bool
 	ctx context.Context
-	conn *jdwp.Connection
+	conn *Connection
 	cancel context.CancelFunc
+	runner *PluginRunner
+
+	// the current run, until it's cancelled
+	currentRun *eventRun
+
+	// the suspend policy of the current run, if it was overridden
+	policyOverride *jdwp.SuspendPolicy
+
+	// the method entries seen by the current run, for MethodEntry events
+	entryCounts *EntryCounts
+
+	// when the event was last run or cancelled
+	changedAt time.Time
 }
 
 func NewStubDebuggingEvent(name string) *DebuggingEvent {
@@ -51,12 +110,16 @@ func NewStubDebuggingEvent(name string) *DebuggingEvent {
 		suspendPolicy: jdwp.SuspendNone,
 		modifierDescriptors: map[string]ModifierDescriptor{},
 		hookDescriptors: map[string]string{},
+		eventLog: NewEventLog(defaultEventBufferSize),
+		eventStream: NewEventStream(defaultEventBufferSize),
 
 		mu: sync.RWMutex{},
 		registered: false,
+		enabled: true,
 		ctx: nil, // iff it's running
 		conn: nil,
 		cancel: nil,
+		runner: nil,
 	}
 }
 
@@ -103,6 +166,38 @@ func (e *DebuggingEvent) RemoveHookDescriptor(name string) bool {
 	return true
 }
 
+// AddModifier sets the modifier unless the name is taken; the check and
+// the set are under one lock, so of two adds of a name, one wins
+func (e *DebuggingEvent) AddModifier(name string, modifierDescriptor ModifierDescriptor) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, ok := e.modifierDescriptors[name]
+	if ok {
+		return false
+	}
+
+	e.modifierDescriptors[name] = modifierDescriptor
+
+	return true
+}
+
+// CompletePendingModifier replaces a pending modifier with its target;
+// false if the modifier isn't there anymore, or got a target already
+func (e *DebuggingEvent) CompletePendingModifier(name string, modifierDescriptor ModifierDescriptor) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	modifier, ok := e.modifierDescriptors[name]
+	if !ok || !modifier.Pending {
+		return false
+	}
+
+	e.modifierDescriptors[name] = modifierDescriptor
+
+	return true
+}
+
 func (e *DebuggingEvent) SetModifier(name string, modifierDescriptor ModifierDescriptor) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -119,6 +214,15 @@ func (e *DebuggingEvent) SetRegistered(registered bool) {
 	e.registered = registered
 }
 
+// SetEnabled arms or disarms the event; a disabled event keeps its
+// configuration, but can't be run
+func (e *DebuggingEvent) SetEnabled(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.enabled = enabled
+}
+
 func (e *DebuggingEvent) SetCtx(ctx context.Context) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -126,7 +230,7 @@ func (e *DebuggingEvent) SetCtx(ctx context.Context) {
 	e.ctx = ctx
 }
 
-func (e *DebuggingEvent) SetConn(conn *jdwp.Connection) {
+func (e *DebuggingEvent) SetConn(conn *Connection) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -154,6 +258,19 @@ func (e *DebuggingEvent) GetSuspendPolicy() jdwp.SuspendPolicy {
 	return e.suspendPolicy
 }
 
+// GetEffectiveSuspendPolicy returns the policy the event runs with, and
+// whether it overrides the configured one; it only does while running
+func (e *DebuggingEvent) GetEffectiveSuspendPolicy() (jdwp.SuspendPolicy, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.ctx != nil && e.policyOverride != nil {
+		return *e.policyOverride, true
+	}
+
+	return e.suspendPolicy, false
+}
+
 func (e *DebuggingEvent) GetHookDescriptors() map[string]string {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -186,9 +303,61 @@ func (e *DebuggingEvent) GetRegistered() bool {
 	return e.registered
 }
 
-func (e *DebuggingEvent) DeleteModifier(name string) error {
+func (e *DebuggingEvent) IsEnabled() bool {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
+
+	return e.enabled
+}
+
+// SetBufferSize changes how many fired events the log and the stream
+// keep; the oldest are dropped first
+func (e *DebuggingEvent) SetBufferSize(size int) {
+	e.eventLog.SetCapacity(size)
+	e.eventStream.SetCapacity(size)
+}
+
+// GetDropped returns how many fired events were dropped from the log and
+// the stream, which keep the same number of them
+func (e *DebuggingEvent) GetDropped() uint64 {
+	return e.eventLog.Dropped()
+}
+
+// GetLog returns the log of the fired events; it's kept across runs
+func (e *DebuggingEvent) GetLog() *EventLog {
+	return e.eventLog
+}
+
+// GetStream returns the fired events as binary records; like the log,
+// it's kept across runs
+func (e *DebuggingEvent) GetStream() *EventStream {
+	return e.eventStream
+}
+
+// GetChangedAt returns when the event was last run or cancelled, the
+// zero time if it never was
+func (e *DebuggingEvent) GetChangedAt() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.changedAt
+}
+
+func (e *DebuggingEvent) GetStatus() EventStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return EventStatus {
+		Running: e.ctx != nil,
+		Registered: e.registered,
+		Modifiers: len(e.modifierDescriptors),
+		Hooks: len(e.hookDescriptors),
+	}
+}
+
+func (e *DebuggingEvent) DeleteModifier(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	
 	_, ok := e.modifierDescriptors[name]
 	if !ok {
@@ -203,16 +372,50 @@ func (e *DebuggingEvent) DeleteModifier(name string) error {
 	return nil
 }
 
+// Run registers the event with the VM; the modifiers, hooks, kind and
+// policy are all read under the event lock, so changes made from the
+// filesystem at the same time are either fully in or fully out
 func (e *DebuggingEvent) Run() (context.Context, error) {
+	return e.run(nil)
+}
+
+// RunWithSuspendPolicy runs the event with another suspend policy, for
+// this run only; the configured policy is left as it is
+func (e *DebuggingEvent) RunWithSuspendPolicy(policy jdwp.SuspendPolicy) (context.Context, error) {
+	return e.run(&policy)
+}
+
+func (e *DebuggingEvent) run(policyOverride *jdwp.SuspendPolicy) (context.Context, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	
-	eventContext, contextCancel := context.WithCancel(context.Background())
-	e.ctx = eventContext
-	e.cancel = contextCancel
+
+	if !e.enabled {
+		return nil, JdwpDebuggingEventError{
+			message: fmt.Sprintf("event %s is disabled", e.Name),
+		}
+	}
+
+	err := e.checkCapabilities()
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.checkKindRequirements()
+	if err != nil {
+		return nil, err
+	}
 
 	var modifiers []jdwp.EventModifier
 	for _, descriptor := range e.modifierDescriptors {
+		if descriptor.Pending {
+			continue
+		}
+
+		if descriptor.ThreadId != 0 {
+			modifiers = append(modifiers, jdwp.ThreadOnlyEventModifier(descriptor.ThreadId))
+			continue
+		}
+
 		var newModifier jdwp.EventModifier
 		switch descriptor.IsField {
 		case true:
@@ -225,7 +428,7 @@ func (e *DebuggingEvent) Run() (context.Context, error) {
 				Type: descriptor.Kind,
 				Class: jdwp.ClassID(descriptor.ClassId),
 				Method: jdwp.MethodID(descriptor.ObjectId),
-				Location: 0,
+				Location: descriptor.CodeIndex,
 			})
 		}
 
@@ -245,9 +448,46 @@ func (e *DebuggingEvent) Run() (context.Context, error) {
 		log.Printf("unable to load plugins: %s", err)
 		return nil, err
 	}
-	
+
+	eventContext, contextCancel := context.WithCancel(context.Background())
+	current := &eventRun {
+		done: make(chan struct{}),
+	}
+	e.ctx = eventContext
+	e.cancel = contextCancel
+	e.runner = runner
+	e.currentRun = current
+	e.policyOverride = policyOverride
+	e.changedAt = time.Now()
+
+	// the goroutine outlives the lock, so it only gets copies
+	name := e.Name
+	eventLog := e.eventLog
+	eventStream := e.eventStream
+	conn := e.conn
+	kind := e.kind
+	suspendPolicy := e.suspendPolicy
+	if policyOverride != nil {
+		suspendPolicy = *policyOverride
+	}
+
+	var entryCounts *EntryCounts
+	if kind == jdwp.MethodEntry {
+		entryCounts = NewEntryCounts()
+	}
+	e.entryCounts = entryCounts
+
 	hook := func(event jdwp.Event) bool {
+		eventLog.AppendEvent(event)
+		eventStream.AppendEvent(event)
+		if entryCounts != nil {
+			entryCounts.Observe(event)
+		}
+		conn.inspectEvent(name, event, suspendPolicy)
+
+		current.hooks.Lock()
 		err := runner.Entrypoint(event)
+		current.hooks.Unlock()
 		if err != nil {
 			log.Printf("running for event %v caused errors: %s\n", event, err)
 			return false
@@ -255,47 +495,145 @@ func (e *DebuggingEvent) Run() (context.Context, error) {
 		return true
 	}
 
+	// the request is set by the goroutine; a run which was cancelled
+	// or replaced doesn't change the flag anymore
+	registered := func(registered bool) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		if e.ctx == eventContext {
+			e.registered = registered
+		}
+	}
+
 	go func() {
-		err := e.conn.WatchEvents(
+		defer close(current.done)
+
+		err := conn.WatchEvents(
 			eventContext,
-			e.kind,
-			e.suspendPolicy,
+			kind,
+			suspendPolicy,
 			hook,
+			registered,
 			modifiers...)
 		if err != nil {
-			log.Printf("event %s finished with error: %s\n", e.Name, err)
+			log.Printf("event %s finished with error: %s\n", name, err)
 		} else {
-			log.Printf("event %s finished successfully\n", e.Name)
+			log.Printf("event %s finished successfully\n", name)
 		}
 	}()
 
 	return eventContext, nil
 }
 
+// checkCapabilities fails early if the VM can't deliver the event kind,
+// naming the missing capability; it's called with the lock held
+func (e *DebuggingEvent) checkCapabilities() error {
+	capability, ok := EventKindCapability(e.kind)
+	if !ok || e.conn == nil {
+		return nil
+	}
+
+	capabilities, err := e.conn.GetCapabilities()
+	if err != nil {
+		return err
+	}
+
+	if !capabilities.Has(capability) {
+		return JdwpDebuggingEventError{
+			message: fmt.Sprintf("event %s: %s events need the %s capability, which the VM lacks",
+				e.Name, e.kind, capability),
+		}
+	}
+
+	return nil
+}
+
+// Cancel stops the event; the run is taken off the event under the lock,
+// and torn down without it: the plugins are shut down once the watch is
+// over and nothing hands them events anymore, and waiting for that
+// doesn't hold up the event
 func (e *DebuggingEvent) Cancel() error {
 	e.mu.Lock()
-	defer e.mu.Unlock()
-	
 	if e.ctx == nil {
+		e.mu.Unlock()
 		return JdwpDebuggingEventError{
 			message: fmt.Sprintf("e %s not running\n", e.Name),
 		}
 	}
 
+	eventContext := e.ctx
+	contextCancel := e.cancel
+	runner := e.runner
+	current := e.currentRun
+
+	e.ctx = nil
+	e.cancel = nil
+	e.runner = nil
+	e.currentRun = nil
+	e.registered = false
+	e.policyOverride = nil
+	e.entryCounts = nil
+	e.changedAt = time.Now()
+	e.mu.Unlock()
+
 	log.Printf("cancelling e %s\n", e.Name)
-	e.cancel()
+	contextCancel()
 
-	<-e.ctx.Done()
+	<-current.done
+	current.users.Wait()
 	log.Printf("e %s cancelled successfully\n", e.Name)
 
-	cancelError := e.ctx.Err()
+	// the cancellation is ours, so it isn't an error
+	cancelError := eventContext.Err()
+	if errors.Is(cancelError, context.Canceled) {
+		cancelError = nil
+	}
 
-	e.ctx = nil
-	e.cancel = nil
+	if runner != nil {
+		err := runner.Shutdown()
+		if err != nil {
+			log.Printf("e %s plugins failed to shut down: %s\n", e.Name, err)
+		}
+	}
 
 	return cancelError
 }
 
+// GetEntryCount returns how many times the current run saw the method
+// entered; 0 unless it's a running MethodEntry event
+func (e *DebuggingEvent) GetEntryCount(class jdwp.ClassID, method jdwp.MethodID) uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.entryCounts == nil {
+		return 0
+	}
+
+	return e.entryCounts.Get(class, method)
+}
+
+// ReloadHook reloads the plugin of a hook of the running event; an event
+// which isn't running loads its plugins when it's run anyway
+func (e *DebuggingEvent) ReloadHook(name string) error {
+	e.mu.RLock()
+	_, exists := e.hookDescriptors[name]
+	runner := e.runner
+	e.mu.RUnlock()
+
+	if !exists {
+		return JdwpDebuggingEventError{
+			message: fmt.Sprintf("event %s has no hook %s", e.Name, name),
+		}
+	}
+
+	if runner == nil {
+		return nil
+	}
+
+	return runner.Reload(name)
+}
+
 func (e *DebuggingEvent) IsRunning() bool {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
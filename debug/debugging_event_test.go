@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// eventRequestHandler accepts every event request
+func eventRequestHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	if set == 15 && cmd == 1 {
+		return 0, fakeInt(1)
+	}
+	return 0, nil
+}
+
+func TestDebuggingEventCancel(t *testing.T) {
+	const runs = 50
+
+	var suspendAll = jdwp.SuspendAll
+	var tests = []struct {
+		name string
+		policy *jdwp.SuspendPolicy
+	}{
+		{ name: "configured policy", policy: nil },
+		{ name: "overridden policy", policy: &suspendAll },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, eventRequestHandler)
+			event := NewStubDebuggingEvent("cancel")
+			event.SetKind(jdwp.ThreadStart)
+			event.SetConn(conn)
+
+			for i := 1; i <= runs; i++ {
+				var err error
+				if test.policy != nil {
+					_, err = event.RunWithSuspendPolicy(*test.policy)
+				} else {
+					_, err = event.Run()
+				}
+				if err != nil {
+					t.Fatalf("run %d: %s", i, err)
+				}
+
+				cancelled := make(chan error, 1)
+				go func() {
+					cancelled <- event.Cancel()
+				}()
+
+				select {
+				case err = <-cancelled:
+				case <-time.After(10 * time.Second):
+					t.Fatalf("run %d: cancel didn't return", i)
+				}
+				if err != nil {
+					t.Fatalf("run %d: cancel: %s", i, err)
+				}
+
+				// the request is cleared by the watch, before it's over
+				if cleared := vm.Received(15, 2); cleared != i {
+					t.Fatalf("run %d: cancel returned with %d requests cleared", i, cleared)
+				}
+				if event.IsRunning() || event.GetRegistered() {
+					t.Fatalf("run %d: the event is still running after cancel", i)
+				}
+			}
+		})
+	}
+}
+
+func TestDebuggingEventCancelNotRunning(t *testing.T) {
+	event := NewStubDebuggingEvent("idle")
+	if err := event.Cancel(); err == nil {
+		t.Fatalf("cancelling an idle event should fail")
+	}
+}
+
+func TestRunChecksCapabilities(t *testing.T) {
+	var tests = []struct {
+		name string
+		kind jdwp.EventKind
+		capabilities []int
+		missing string
+	}{
+		{ name: "access, watchable", kind: jdwp.FieldAccess, capabilities: []int { 1 } },
+		{ name: "access, not watchable", kind: jdwp.FieldAccess, capabilities: []int { 0 }, missing: "canWatchFieldAccess" },
+		{ name: "modification, watchable", kind: jdwp.FieldModification, capabilities: []int { 0 } },
+		{ name: "modification, not watchable", kind: jdwp.FieldModification, capabilities: []int { 1 }, missing: "canWatchFieldModification" },
+		{ name: "no capability needed", kind: jdwp.ThreadStart },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, eventRequestHandler)
+			var capabilities = make([]byte, 32)
+			for _, position := range test.capabilities {
+				capabilities[position] = 1
+			}
+			vm.Answer(commandSetVirtualMachine, commandCapabilitiesNew, 0, capabilities)
+
+			event := NewStubDebuggingEvent("watch")
+			event.SetKind(test.kind)
+			event.SetConn(conn)
+			if test.kind == jdwp.FieldAccess || test.kind == jdwp.FieldModification {
+				event.SetModifier("count", ModifierDescriptor { Name: "count", IsField: true, ClassId: 100, ObjectId: 1 })
+			}
+
+			_, err := event.Run()
+			if test.missing != "" {
+				if err == nil || !strings.Contains(err.Error(), test.missing) {
+					t.Fatalf("got %v, expected an error naming %s", err, test.missing)
+				}
+				if event.IsRunning() {
+					t.Fatalf("the event runs without the capability")
+				}
+				if requested := vm.Received(15, 1); requested != 0 {
+					t.Fatalf("the event was requested %d times", requested)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if err := event.Cancel(); err != nil {
+				t.Fatalf("cancel: %s", err)
+			}
+			if requested := vm.Received(15, 1); requested != 1 {
+				t.Fatalf("the event was requested %d times", requested)
+			}
+		})
+	}
+}
+
+// waitRegistered waits for the event to be registered or not, as the
+// reply to the event request comes in after Run returns
+func waitRegistered(t *testing.T, event *DebuggingEvent, registered bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for event.GetRegistered() != registered {
+		if time.Now().After(deadline) {
+			t.Fatalf("registered stayed %v", !registered)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEventRegistered(t *testing.T) {
+	var tests = []struct {
+		name string
+		errorCode uint16
+		registered bool
+	}{
+		{ name: "accepted", registered: true },
+		{ name: "rejected", errorCode: uint16(jdwp.ErrInvalidEventType), registered: false },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, test.errorCode, fakeInt(1))
+			event := NewStubDebuggingEvent("registered")
+			event.SetKind(jdwp.ThreadStart)
+			event.SetConn(conn)
+
+			if event.GetRegistered() {
+				t.Fatalf("registered before running")
+			}
+
+			if _, err := event.Run(); err != nil {
+				t.Fatalf("run: %s", err)
+			}
+			waitRegistered(t, event, test.registered)
+
+			// until the request is cleared
+			if test.registered {
+				if !event.IsRunning() {
+					t.Fatalf("registered, but not running")
+				}
+				if err := event.Cancel(); err != nil {
+					t.Fatalf("cancel: %s", err)
+				}
+				if event.GetRegistered() {
+					t.Fatalf("still registered once cancelled")
+				}
+				return
+			}
+
+			// the rejected request goes away with the watch
+			deadline := time.Now().Add(10 * time.Second)
+			for vm.Received(15, 1) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("the event request wasn't sent")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(10 * time.Millisecond)
+			if event.GetRegistered() {
+				t.Fatalf("registered once rejected")
+			}
+		})
+	}
+}
+
+func TestModifierSetIfAbsent(t *testing.T) {
+	var pending = ModifierDescriptor { Name: "m", Pending: true }
+	var linked = ModifierDescriptor { Name: "m", ThreadId: 7 }
+
+	var tests = []struct {
+		name string
+		existing *ModifierDescriptor
+		set func(e *DebuggingEvent) bool
+		wasSet bool
+		after *ModifierDescriptor
+	}{
+		{ name: "add", set: func(e *DebuggingEvent) bool { return e.AddModifier("m", pending) }, wasSet: true, after: &pending },
+		{
+			name: "add taken",
+			existing: &linked,
+			set: func(e *DebuggingEvent) bool { return e.AddModifier("m", pending) },
+			wasSet: false,
+			after: &linked,
+		},
+		{
+			name: "complete pending",
+			existing: &pending,
+			set: func(e *DebuggingEvent) bool { return e.CompletePendingModifier("m", linked) },
+			wasSet: true,
+			after: &linked,
+		},
+		{
+			name: "complete removed",
+			set: func(e *DebuggingEvent) bool { return e.CompletePendingModifier("m", linked) },
+			wasSet: false,
+		},
+		{
+			name: "complete linked",
+			existing: &linked,
+			set: func(e *DebuggingEvent) bool { return e.CompletePendingModifier("m", ModifierDescriptor { Name: "m", ThreadId: 8 }) },
+			wasSet: false,
+			after: &linked,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := NewStubDebuggingEvent("modified")
+			if test.existing != nil {
+				event.SetModifier("m", *test.existing)
+			}
+
+			if set := test.set(event); set != test.wasSet {
+				t.Fatalf("set: %v, expected %v", set, test.wasSet)
+			}
+			modifier, ok := event.GetModifiers()["m"]
+			if ok != (test.after != nil) || (ok && modifier != *test.after) {
+				t.Fatalf("left %+v (%v), expected %+v", modifier, ok, test.after)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"sync"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+type methodKey struct {
+	class jdwp.ClassID
+	method jdwp.MethodID
+}
+
+//
+// Entry counts
+// The entries into each method a running MethodEntry event observed
+//
+type EntryCounts struct {
+	mu sync.Mutex
+	counts map[methodKey]uint64
+}
+
+func NewEntryCounts() *EntryCounts {
+	return &EntryCounts {
+		counts: map[methodKey]uint64{},
+	}
+}
+
+// Observe counts the method of a MethodEntry event; other events are
+// ignored
+func (c *EntryCounts) Observe(event jdwp.Event) {
+	entry, ok := event.(*jdwp.EventMethodEntry)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[methodKey { entry.Location.Class, entry.Location.Method }]++
+}
+
+func (c *EntryCounts) Get(class jdwp.ClassID, method jdwp.MethodID) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.counts[methodKey { class, method }]
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"context"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// methodEntry is a MethodEntry event into a method of class 42
+func methodEntry(method jdwp.MethodID) jdwp.Event {
+	return &jdwp.EventMethodEntry {
+		Thread: 31,
+		Location: jdwp.Location { Type: jdwp.Class, Class: 42, Method: method },
+	}
+}
+
+func TestEntryCounts(t *testing.T) {
+	var tests = []struct {
+		name string
+		events []jdwp.Event
+		counts map[jdwp.MethodID]uint64
+	}{
+		{ name: "no entries", counts: map[jdwp.MethodID]uint64 { 7: 0, 8: 0 } },
+		{
+			name: "entries",
+			events: []jdwp.Event { methodEntry(7), methodEntry(8), methodEntry(7) },
+			counts: map[jdwp.MethodID]uint64 { 7: 2, 8: 1, 9: 0 },
+		},
+		{
+			name: "other kinds",
+			events: []jdwp.Event {
+				methodEntry(7),
+				&jdwp.EventMethodExit { Location: jdwp.Location { Class: 42, Method: 7 } },
+				&jdwp.EventBreakpoint { Location: jdwp.Location { Class: 42, Method: 7 } },
+			},
+			counts: map[jdwp.MethodID]uint64 { 7: 1 },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counts := NewEntryCounts()
+			for _, event := range test.events {
+				counts.Observe(event)
+			}
+
+			for method, expected := range test.counts {
+				if count := counts.Get(42, method); count != expected {
+					t.Fatalf("method %d: counted %d, expected %d", method, count, expected)
+				}
+			}
+			if count := counts.Get(43, 7); count != 0 {
+				t.Fatalf("counted %d entries into another class", count)
+			}
+		})
+	}
+}
+
+func TestEventManagerEntryCount(t *testing.T) {
+	conn, _ := startFakeVM(t, eventRequestHandler)
+	manager, err := NewEventManager(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	var events []*DebuggingEvent
+	for _, name := range []string { "entries", "more_entries" } {
+		event, err := manager.CreateEvent(name)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		event.SetKind(jdwp.MethodEntry)
+		if _, err := event.Run(); err != nil {
+			t.Fatalf("unable to run %s: %s", name, err)
+		}
+		events = append(events, event)
+	}
+
+	// both runs see the entries the VM would send them
+	for _, event := range events {
+		event.mu.RLock()
+		event.entryCounts.Observe(methodEntry(7))
+		event.entryCounts.Observe(methodEntry(7))
+		event.mu.RUnlock()
+	}
+
+	if count := events[0].GetEntryCount(42, 7); count != 2 {
+		t.Fatalf("the event counted %d entries, expected 2", count)
+	}
+	if count := manager.GetEntryCount(42, 7); count != 4 {
+		t.Fatalf("the events counted %d entries, expected 4", count)
+	}
+
+	// a cancelled run doesn't count anymore
+	if err := events[0].Cancel(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if count := events[0].GetEntryCount(42, 7); count != 0 {
+		t.Fatalf("the cancelled event counted %d entries", count)
+	}
+	if count := manager.GetEntryCount(42, 7); count != 2 {
+		t.Fatalf("the events counted %d entries once one was cancelled, expected 2", count)
+	}
+	if err := events[1].Cancel(); err != nil {
+		t.Fatalf("%s", err)
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//
+// Event definition
+// The whole configuration of an event, in a form which can be
+// serialized and shared
+//
+type EventDefinition struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	SuspendPolicy string `json:"suspendPolicy"`
+	Enabled bool `json:"enabled"`
+	Modifiers []ModifierDescriptor `json:"modifiers"`
+	Hooks map[string]string `json:"hooks"`
+}
+
+// GetDefinition takes the event configuration in one go; the modifiers
+// are sorted by name, so the output is stable
+func (e *DebuggingEvent) GetDefinition() EventDefinition {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var modifiers = []ModifierDescriptor{}
+	for _, modifier := range e.modifierDescriptors {
+		modifiers = append(modifiers, modifier)
+	}
+	sort.Slice(modifiers, func(i, j int) bool {
+		return modifiers[i].Name < modifiers[j].Name
+	})
+
+	var hooks = map[string]string{}
+	for name, target := range e.hookDescriptors {
+		hooks[name] = target
+	}
+
+	return EventDefinition {
+		Name: e.Name,
+		Kind: e.kind.String(),
+		SuspendPolicy: e.suspendPolicy.String(),
+		Enabled: e.enabled,
+		Modifiers: modifiers,
+		Hooks: hooks,
+	}
+}
+
+// Summary describes the event in one line, such as
+// "Breakpoint @ com.example.Foo.bar, suspend=EventThread, hooks=1"; the
+// fields and methods it's limited to are named from their signatures,
+// and pending modifiers are left out
+func (d EventDefinition) Summary() string {
+	var targets = []string{}
+	for _, modifier := range d.Modifiers {
+		if modifier.Pending || modifier.ThreadId != 0 {
+			continue
+		}
+
+		target := fmt.Sprintf("%s.%s",
+			ClassNameFromSignature(modifier.ClassSignature), modifier.ObjectName)
+		if !modifier.IsField && modifier.Line > 0 {
+			target = fmt.Sprintf("%s:%d", target, modifier.Line)
+		}
+		targets = append(targets, target)
+	}
+
+	summary := d.Kind
+	if len(targets) > 0 {
+		summary = fmt.Sprintf("%s @ %s", summary, strings.Join(targets, " | "))
+	}
+
+	return fmt.Sprintf("%s, suspend=%s, hooks=%d",
+		summary, strings.TrimPrefix(d.SuspendPolicy, "Suspend"), len(d.Hooks))
+}
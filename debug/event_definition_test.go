@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestEventSummary(t *testing.T) {
+	var bar = ModifierDescriptor {
+		Name: "bar",
+		ClassId: 41,
+		ObjectId: 7,
+		ClassSignature: "Lcom/example/Foo;",
+		ObjectName: "bar",
+		ObjectSignature: "()V",
+	}
+	var barLine = bar
+	barLine.Name = "bar_line"
+	barLine.CodeIndex = 12
+	barLine.Line = 30
+	var count = ModifierDescriptor {
+		Name: "count",
+		IsField: true,
+		ClassId: 41,
+		ObjectId: 8,
+		ClassSignature: "Lcom/example/Foo;",
+		ObjectName: "count",
+		ObjectSignature: "I",
+	}
+	var thread = ModifierDescriptor { Name: "thread", ThreadId: 5 }
+	var pending = ModifierDescriptor { Name: "pending", Pending: true }
+
+	var tests = []struct {
+		name string
+		kind jdwp.EventKind
+		policy jdwp.SuspendPolicy
+		modifiers []ModifierDescriptor
+		hooks []string
+		summary string
+	}{
+		{
+			name: "method breakpoint",
+			kind: jdwp.Breakpoint,
+			policy: jdwp.SuspendEventThread,
+			modifiers: []ModifierDescriptor { bar },
+			hooks: []string { "log" },
+			summary: "Breakpoint @ com.example.Foo.bar, suspend=EventThread, hooks=1",
+		},
+		{
+			name: "at a line",
+			kind: jdwp.Breakpoint,
+			policy: jdwp.SuspendAll,
+			modifiers: []ModifierDescriptor { barLine },
+			summary: "Breakpoint @ com.example.Foo.bar:30, suspend=All, hooks=0",
+		},
+		{
+			name: "threads and pending left out",
+			kind: jdwp.FieldModification,
+			policy: jdwp.SuspendNone,
+			modifiers: []ModifierDescriptor { count, thread, pending },
+			hooks: []string { "log", "trace" },
+			summary: "FieldModification @ com.example.Foo.count, suspend=None, hooks=2",
+		},
+		{
+			name: "nothing to limit to",
+			kind: jdwp.ThreadStart,
+			policy: jdwp.SuspendNone,
+			summary: "ThreadStart, suspend=None, hooks=0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := NewStubDebuggingEvent("summary")
+			event.SetKind(test.kind)
+			event.SetSuspendPolicy(test.policy)
+			for _, modifier := range test.modifiers {
+				if err := event.SetModifier(modifier.Name, modifier); err != nil {
+					t.Fatalf("unable to set modifier %s: %s", modifier.Name, err)
+				}
+			}
+			for _, hook := range test.hooks {
+				event.SetHookDescriptor(hook, "/plugins/" + hook + ".so")
+			}
+
+			if summary := event.GetDefinition().Summary(); summary != test.summary {
+				t.Fatalf("got %q, expected %q", summary, test.summary)
+			}
+		})
+	}
+}
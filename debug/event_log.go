@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	// the events kept by the log and the stream of an event
+	defaultEventBufferSize = 1024
+)
+
+// a read from an offset which was dropped, e.g. by a reader which fell
+// behind; what's left starts at start
+type EventsDroppedError struct {
+	offset int64
+	start int64
+}
+
+func (e EventsDroppedError) Error() string {
+	return fmt.Sprintf("events dropped: offset %d is before the oldest kept, at %d", e.offset, e.start)
+}
+
+//
+// Event log
+// An append only log of the fired events, one per line. Only the last
+// capacity lines are kept, but the offsets keep growing, so a reader can
+// carry on from where it stopped, the way `tail -f` does
+//
+type EventLog struct {
+	mu sync.RWMutex
+	capacity int
+
+	// data starts at the absolute offset start, and holds lines lines
+	data []byte
+	start int64
+	lines int
+
+	// the lines dropped to stay within capacity
+	dropped uint64
+}
+
+func NewEventLog(capacity int) *EventLog {
+	return &EventLog {
+		capacity: capacity,
+	}
+}
+
+// Append adds a line, dropping the oldest lines if over capacity
+func (l *EventLog) Append(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.data = append(l.data, line...)
+	l.data = append(l.data, '\n')
+	l.lines++
+
+	l.trim()
+}
+
+// SetCapacity changes how many lines are kept, dropping the oldest ones
+// if there are more
+func (l *EventLog) SetCapacity(capacity int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.capacity = capacity
+	l.trim()
+}
+
+func (l *EventLog) trim() {
+	for l.lines > l.capacity {
+		newline := bytes.IndexByte(l.data, '\n')
+		if newline < 0 {
+			break
+		}
+
+		l.start += int64(newline + 1)
+		l.data = l.data[newline + 1:]
+		l.lines--
+		l.dropped++
+	}
+}
+
+// Dropped is how many lines were dropped to stay within capacity
+func (l *EventLog) Dropped() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.dropped
+}
+
+// AppendEvent logs an event, with the time it was received, followed by
+// all its contents
+func (l *EventLog) AppendEvent(event jdwp.Event) {
+	record := NewEventRecord(event, time.Now())
+	l.Append(fmt.Sprintf("%s %s %+v",
+		record.Time.Format(time.RFC3339Nano), record.Kind, event))
+}
+
+// Size is the offset the next line will be written at
+func (l *EventLog) Size() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.start + int64(len(l.data))
+}
+
+// ReadAt copies the log from offset; reading an offset of lines which
+// have been dropped fails with EventsDroppedError, rather than skipping
+// them unnoticed
+func (l *EventLog) ReadAt(dest []byte, offset int64) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if offset < l.start {
+		return 0, EventsDroppedError{ offset: offset, start: l.start }
+	}
+
+	position := offset - l.start
+	if position >= int64(len(l.data)) {
+		return 0, nil
+	}
+
+	return copy(dest, l.data[position:]), nil
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"errors"
+	"testing"
+)
+
+// readLog reads a log from offset in one go, the way direct I/O hands
+// the whole buffer of `tail -f` to the log; dropped tells if the offset
+// was dropped
+func readLog(t *testing.T, l *EventLog, offset int64) (read string, dropped bool) {
+	t.Helper()
+
+	dest := make([]byte, 4096)
+	n, err := l.ReadAt(dest, offset)
+	if errors.As(err, &EventsDroppedError{}) {
+		return "", true
+	}
+	if err != nil {
+		t.Fatalf("unable to read from %d: %s", offset, err)
+	}
+	return string(dest[:n]), false
+}
+
+func TestEventLogReadAt(t *testing.T) {
+	var tests = []struct {
+		name string
+		capacity int
+		before []string
+		after []string
+		read string
+		readDropped bool
+		followed string
+		followedDropped bool
+		dropped uint64
+	}{
+		{
+			name: "new lines only",
+			capacity: 8,
+			before: []string { "one", "two" },
+			after: []string { "three" },
+			read: "one\ntwo\n",
+			followed: "three\n",
+		},
+		{
+			name: "nothing new",
+			capacity: 8,
+			before: []string { "one" },
+			read: "one\n",
+			followed: "",
+		},
+		{
+			name: "dropped behind the reader",
+			capacity: 2,
+			before: []string { "one", "two" },
+			after: []string { "three" },
+			read: "one\ntwo\n",
+			followed: "three\n",
+			dropped: 1,
+		},
+		{
+			name: "dropped while following",
+			capacity: 2,
+			before: []string { "one", "two" },
+			after: []string { "three", "four", "five" },
+			read: "one\ntwo\n",
+			followedDropped: true,
+			dropped: 3,
+		},
+		{
+			name: "dropped before reading",
+			capacity: 1,
+			before: []string { "one", "two" },
+			after: []string { "three" },
+			readDropped: true,
+			followed: "three\n",
+			dropped: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewEventLog(test.capacity)
+			for _, line := range test.before {
+				l.Append(line)
+			}
+
+			read, dropped := readLog(t, l, 0)
+			if read != test.read || dropped != test.readDropped {
+				t.Fatalf("read %q, dropped %v; expected %q, dropped %v", read, dropped, test.read, test.readDropped)
+			}
+			offset := l.Size()
+
+			for _, line := range test.after {
+				l.Append(line)
+			}
+
+			followed, dropped := readLog(t, l, offset)
+			if followed != test.followed || dropped != test.followedDropped {
+				t.Fatalf("read %q from %d, dropped %v; expected %q, dropped %v",
+					followed, offset, dropped, test.followed, test.followedDropped)
+			}
+			if dropped := l.Dropped(); dropped != test.dropped {
+				t.Fatalf("dropped %d lines, expected %d", dropped, test.dropped)
+			}
+		})
+	}
+}
+
+func TestEventLogSize(t *testing.T) {
+	l := NewEventLog(1)
+
+	var size int64
+	for _, line := range []string { "one", "two", "three" } {
+		l.Append(line)
+		size += int64(len(line) + 1)
+
+		// the size keeps growing, even with lines dropped
+		if l.Size() != size {
+			t.Fatalf("size %d after %q, expected %d", l.Size(), line, size)
+		}
+	}
+
+	l.SetCapacity(0)
+	if read, dropped := readLog(t, l, size); l.Size() != size || read != "" || dropped {
+		t.Fatalf("emptied log: size %d, read %q, dropped %v", l.Size(), read, dropped)
+	}
+	if _, dropped := readLog(t, l, size - 1); !dropped {
+		t.Fatalf("read a dropped line of the emptied log")
+	}
+}
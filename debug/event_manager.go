@@ -8,7 +8,7 @@ import (
 	"sync"
 	"log"
 	"fmt"
-	
+
 	jdwp "github.com/omerye/gojdb/jdwp"
 )
 
@@ -33,13 +33,27 @@ func (e JdwpDebuggingEventError) Error() string {
 //
 type EventManager struct {		
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *Connection
 
 	mu sync.RWMutex
 	registeredEvents []*DebuggingEvent
+
+	// the fired events kept by each event, 0 for the default
+	bufferSize int
+
+	// the names held by LockName, under nmu
+	nmu sync.Mutex
+	nameLocks map[string]*nameLock
 }
 
-func NewEventManager(ctx context.Context, conn *jdwp.Connection) (*EventManager, error) {
+// nameLock is held by a caller of LockName, and waited on by the others
+// locking the same name
+type nameLock struct {
+	mu sync.Mutex
+	users int
+}
+
+func NewEventManager(ctx context.Context, conn *Connection) (*EventManager, error) {
 	manager := &EventManager {
 		JdwpContext: ctx,
 		JdwpConnection: conn,
@@ -49,6 +63,46 @@ func NewEventManager(ctx context.Context, conn *jdwp.Connection) (*EventManager,
 	return manager, nil
 }
 
+// SetEventBufferSize sets how many fired events the events created from
+// then on keep in their log and stream; 0 keeps the default
+func (m *EventManager) SetEventBufferSize(size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bufferSize = size
+}
+
+// LockName serializes the callers working on the event of a name, whether
+// it exists yet or not, e.g. to create and run it unless it's there; it
+// returns the function unlocking the name
+func (m *EventManager) LockName(name string) func() {
+	m.nmu.Lock()
+	if m.nameLocks == nil {
+		m.nameLocks = map[string]*nameLock{}
+	}
+	lock, ok := m.nameLocks[name]
+	if !ok {
+		lock = &nameLock{}
+		m.nameLocks[name] = lock
+	}
+	lock.users++
+	m.nmu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		m.nmu.Lock()
+		defer m.nmu.Unlock()
+
+		lock.users--
+		if lock.users == 0 {
+			delete(m.nameLocks, name)
+		}
+	}
+}
+
 func (m *EventManager) CreateEvent(name string) (*DebuggingEvent, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -68,6 +122,9 @@ func (m *EventManager) CreateEvent(name string) (*DebuggingEvent, error) {
 	}
 
 	event := NewStubDebuggingEvent(name)
+	if m.bufferSize > 0 {
+		event.SetBufferSize(m.bufferSize)
+	}
 	
 	event.SetConn(m.JdwpConnection)	
 	m.registeredEvents = append(m.registeredEvents, event)
@@ -102,11 +159,25 @@ func (m *EventManager) GetAllEvents() ([]*DebuggingEvent, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	returnedEvents := append(m.registeredEvents, []*DebuggingEvent{}...)
+	returnedEvents := append([]*DebuggingEvent{}, m.registeredEvents...)
 
 	return returnedEvents, nil
 }
 
+// GetEntryCount sums the entries into a method seen by the running
+// MethodEntry events
+func (m *EventManager) GetEntryCount(class jdwp.ClassID, method jdwp.MethodID) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count uint64
+	for _, event := range m.registeredEvents {
+		count += event.GetEntryCount(class, method)
+	}
+
+	return count
+}
+
 func (m *EventManager) RunEvent(name string) error {
 	m.mu.RLock()
 
@@ -178,6 +249,7 @@ func (m *EventManager) DeregisterEvent(name string) error {
 	}
 
 	event.mu.Lock()
+	defer event.mu.Unlock()
 	
 	if event.ctx != nil {
 		return JdwpDebuggingEventError{
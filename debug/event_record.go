@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"encoding/json"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Event record
+// A fired event in a form which doesn't change with gojdb, for plugins
+// and for the files showing events; the thread and the location are
+// left out for the kinds which have none
+//
+type EventRecord struct {
+	Kind string `json:"kind"`
+	Time time.Time `json:"time"`
+	Thread uint64 `json:"thread,omitempty"`
+	Location *LocationRecord `json:"location,omitempty"`
+
+	// the class, for class preparation and unloading
+	Signature string `json:"signature,omitempty"`
+}
+
+type LocationRecord struct {
+	Class uint64 `json:"class"`
+	Method uint64 `json:"method"`
+	CodeIndex uint64 `json:"codeIndex"`
+}
+
+func newLocationRecord(location jdwp.Location) *LocationRecord {
+	return &LocationRecord {
+		Class: uint64(location.Class),
+		Method: uint64(location.Method),
+		CodeIndex: location.Location,
+	}
+}
+
+// NewEventRecord takes what's common to the events out of one received
+// at the given time
+func NewEventRecord(event jdwp.Event, at time.Time) EventRecord {
+	record := EventRecord {
+		Kind: event.Kind().String(),
+		Time: at,
+	}
+
+	switch e := event.(type) {
+	case *jdwp.EventVMStart:
+		record.Thread = uint64(e.Thread)
+	case *jdwp.EventSingleStep:
+		record.Thread = uint64(e.Thread)
+		record.Location = newLocationRecord(e.Location)
+	case *jdwp.EventBreakpoint:
+		record.Thread = uint64(e.Thread)
+		record.Location = newLocationRecord(e.Location)
+	case *jdwp.EventMethodEntry:
+		record.Thread = uint64(e.Thread)
+		record.Location = newLocationRecord(e.Location)
+	case *jdwp.EventMethodExit:
+		record.Thread = uint64(e.Thread)
+		record.Location = newLocationRecord(e.Location)
+	case *jdwp.EventException:
+		record.Thread = uint64(e.Thread)
+		record.Location = newLocationRecord(e.Location)
+	case *jdwp.EventThreadStart:
+		record.Thread = uint64(e.Thread)
+	case *jdwp.EventThreadDeath:
+		record.Thread = uint64(e.Thread)
+	case *jdwp.EventClassPrepare:
+		record.Thread = uint64(e.Thread)
+		record.Signature = e.Signature
+	case *jdwp.EventClassUnload:
+		record.Signature = e.Signature
+	case *jdwp.EventFieldAccess:
+		record.Thread = uint64(e.Thread)
+		record.Location = newLocationRecord(e.Location)
+	case *jdwp.EventFieldModification:
+		record.Thread = uint64(e.Thread)
+		record.Location = newLocationRecord(e.Location)
+	}
+
+	return record
+}
+
+func (r EventRecord) JSON() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"testing"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestEventRecordJSON(t *testing.T) {
+	at := time.Date(2022, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name string
+		event jdwp.Event
+		json string
+	}{
+		{
+			name: "breakpoint",
+			event: &jdwp.EventBreakpoint {
+				Request: 3,
+				Thread: 31,
+				Location: jdwp.Location { Type: jdwp.Class, Class: 42, Method: 7, Location: 12 },
+			},
+			json: `{"kind":"Breakpoint","time":"2022-03-01T12:30:00Z","thread":31,` +
+				`"location":{"class":42,"method":7,"codeIndex":12}}`,
+		},
+		{
+			name: "breakpoint at the start of a method",
+			event: &jdwp.EventBreakpoint {
+				Thread: 31,
+				Location: jdwp.Location { Type: jdwp.Class, Class: 42, Method: 7 },
+			},
+			json: `{"kind":"Breakpoint","time":"2022-03-01T12:30:00Z","thread":31,` +
+				`"location":{"class":42,"method":7,"codeIndex":0}}`,
+		},
+		{
+			name: "thread start",
+			event: &jdwp.EventThreadStart { Thread: 31 },
+			json: `{"kind":"ThreadStart","time":"2022-03-01T12:30:00Z","thread":31}`,
+		},
+		{
+			name: "class unload",
+			event: &jdwp.EventClassUnload { Signature: "Lcom/example/Main;" },
+			json: `{"kind":"ClassUnload","time":"2022-03-01T12:30:00Z","signature":"Lcom/example/Main;"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := NewEventRecord(test.event, at).JSON()
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if string(data) != test.json {
+				t.Fatalf("got %s, expected %s", data, test.json)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"encoding/binary"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandSetEventRequest = 15
+	commandEventRequestSet = 1
+)
+
+//
+// Event request registration
+// gojdb doesn't tell when the event request of WatchEvents is set; the
+// command channel sees its EventRequest.Set go by, and reports the reply
+// to it. One registration is expected at a time
+//
+
+// expectEventRequest makes the reply to the next EventRequest.Set sent
+// by gojdb be reported on the returned channel, until forgotten
+func (c *CommandChannel) expectEventRequest() <-chan jdwp.Error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	replied := make(chan jdwp.Error, 1)
+	c.eventRequest = replied
+	c.eventRequestId = 0
+	c.eventRequestSent = false
+
+	return replied
+}
+
+func (c *CommandChannel) forgetEventRequest() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.eventRequest = nil
+	c.eventRequestSent = false
+}
+
+// noteSent picks the id of the expected EventRequest.Set, out of the
+// packets gojdb writes
+func (c *CommandChannel) noteSent(packet []byte) {
+	if packet[8] & packetIsReply != 0 ||
+		packet[9] != commandSetEventRequest || packet[10] != commandEventRequestSet {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.eventRequest == nil || c.eventRequestSent {
+		return
+	}
+	c.eventRequestId = binary.BigEndian.Uint32(packet[4:8])
+	c.eventRequestSent = true
+}
+
+// noteReceived reports the reply to the expected EventRequest.Set, out
+// of the packets passed on to gojdb
+func (c *CommandChannel) noteReceived(packet []byte) {
+	if packet[8] & packetIsReply == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.eventRequest == nil || !c.eventRequestSent ||
+		binary.BigEndian.Uint32(packet[4:8]) != c.eventRequestId {
+		return
+	}
+
+	c.eventRequest <- jdwp.Error(binary.BigEndian.Uint16(packet[9:11]))
+	c.eventRequest = nil
+	c.eventRequestSent = false
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	// kind, thread, class, method and code index
+	eventStreamRecordSize = 1 + 4 * 8
+)
+
+//
+// Event stream
+// The fired events as binary records, each one a big endian uint32
+// length followed by that many bytes: the JDWP event kind (1 byte),
+// then the thread, class, method ids and the code index (8 bytes each,
+// big endian, 0 when the event has none). Like the event log, only the
+// last capacity records are kept, and the offsets keep growing
+//
+type EventStream struct {
+	mu sync.RWMutex
+	capacity int
+
+	// data starts at the absolute offset start, on a record
+	data []byte
+	start int64
+
+	// the records dropped to stay within capacity
+	dropped uint64
+}
+
+func NewEventStream(capacity int) *EventStream {
+	return &EventStream {
+		capacity: capacity,
+	}
+}
+
+// EncodeEventStreamRecord frames an event as a stream record
+func EncodeEventStreamRecord(event jdwp.Event) []byte {
+	record := NewEventRecord(event, time.Time{})
+
+	var location LocationRecord
+	if record.Location != nil {
+		location = *record.Location
+	}
+
+	framed := make([]byte, 4 + eventStreamRecordSize)
+	binary.BigEndian.PutUint32(framed, eventStreamRecordSize)
+	framed[4] = byte(event.Kind())
+	for i, value := range []uint64 { record.Thread, location.Class, location.Method, location.CodeIndex } {
+		binary.BigEndian.PutUint64(framed[5 + i * 8:], value)
+	}
+
+	return framed
+}
+
+// AppendEvent adds the record of an event, dropping the oldest records
+// if over capacity
+func (s *EventStream) AppendEvent(event jdwp.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = append(s.data, EncodeEventStreamRecord(event)...)
+	s.trim()
+}
+
+// SetCapacity changes how many records are kept, dropping the oldest
+// ones if there are more
+func (s *EventStream) SetCapacity(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.capacity = capacity
+	s.trim()
+}
+
+// trim relies on the records having the same size
+func (s *EventStream) trim() {
+	for len(s.data) > s.capacity * (4 + eventStreamRecordSize) {
+		length := 4 + int(binary.BigEndian.Uint32(s.data))
+		if length > len(s.data) {
+			break
+		}
+
+		s.start += int64(length)
+		s.data = s.data[length:]
+		s.dropped++
+	}
+}
+
+// Dropped is how many records were dropped to stay within capacity
+func (s *EventStream) Dropped() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.dropped
+}
+
+// Size is the offset the next record will be written at
+func (s *EventStream) Size() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.start + int64(len(s.data))
+}
+
+// ReadAt copies the stream from offset; as for the log, reading an
+// offset of records which have been dropped fails with
+// EventsDroppedError
+func (s *EventStream) ReadAt(dest []byte, offset int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if offset < s.start {
+		return 0, EventsDroppedError{ offset: offset, start: s.start }
+	}
+
+	position := offset - s.start
+	if position >= int64(len(s.data)) {
+		return 0, nil
+	}
+
+	return copy(dest, s.data[position:]), nil
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// streamRecord is a decoded record of an event stream
+type streamRecord struct {
+	kind jdwp.EventKind
+	thread uint64
+	class uint64
+	method uint64
+	codeIndex uint64
+}
+
+// decodeStream splits a stream into its records, checking their frames
+func decodeStream(t *testing.T, stream []byte) []streamRecord {
+	t.Helper()
+
+	var records []streamRecord
+	for len(stream) > 0 {
+		if len(stream) < 4 {
+			t.Fatalf("%d bytes left, short of a length", len(stream))
+		}
+		length := int(binary.BigEndian.Uint32(stream))
+		if length != eventStreamRecordSize || len(stream) < 4 + length {
+			t.Fatalf("record of %d bytes, %d left", length, len(stream) - 4)
+		}
+
+		record := stream[4:4 + length]
+		records = append(records, streamRecord {
+			kind: jdwp.EventKind(record[0]),
+			thread: binary.BigEndian.Uint64(record[1:]),
+			class: binary.BigEndian.Uint64(record[9:]),
+			method: binary.BigEndian.Uint64(record[17:]),
+			codeIndex: binary.BigEndian.Uint64(record[25:]),
+		})
+		stream = stream[4 + length:]
+	}
+
+	return records
+}
+
+func TestEventStreamRecords(t *testing.T) {
+	s := NewEventStream(8)
+	s.AppendEvent(&jdwp.EventBreakpoint {
+		Thread: 31,
+		Location: jdwp.Location { Type: jdwp.Class, Class: 42, Method: 7, Location: 12 },
+	})
+	s.AppendEvent(&jdwp.EventThreadStart { Thread: 32 })
+
+	if size := s.Size(); size != 2 * (4 + eventStreamRecordSize) {
+		t.Fatalf("the stream is %d bytes", size)
+	}
+
+	dest := make([]byte, 4096)
+	n, _ := s.ReadAt(dest, 0)
+	records := decodeStream(t, dest[:n])
+
+	var expected = []streamRecord {
+		{ kind: jdwp.Breakpoint, thread: 31, class: 42, method: 7, codeIndex: 12 },
+		{ kind: jdwp.ThreadStart, thread: 32 },
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("got %d records, expected %d", len(records), len(expected))
+	}
+	for i := range expected {
+		if records[i] != expected[i] {
+			t.Fatalf("record %d is %+v, expected %+v", i, records[i], expected[i])
+		}
+	}
+
+	// a reader following the stream gets the second record alone
+	n, _ = s.ReadAt(dest, 4 + eventStreamRecordSize)
+	if !bytes.Equal(dest[:n], EncodeEventStreamRecord(&jdwp.EventThreadStart { Thread: 32 })) {
+		t.Fatalf("read %x from the second record", dest[:n])
+	}
+}
+
+func TestEventStreamDropped(t *testing.T) {
+	const recordSize = 4 + eventStreamRecordSize
+
+	var tests = []struct {
+		name string
+		capacity int
+		events int
+		dropped uint64
+		kept int
+	}{
+		{ name: "within capacity", capacity: 4, events: 3, dropped: 0, kept: 3 },
+		{ name: "at capacity", capacity: 4, events: 4, dropped: 0, kept: 4 },
+		{ name: "overflowing", capacity: 4, events: 10, dropped: 6, kept: 4 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewEventStream(test.capacity)
+			for i := 1; i <= test.events; i++ {
+				s.AppendEvent(&jdwp.EventThreadStart { Thread: jdwp.ThreadID(i) })
+			}
+
+			if dropped := s.Dropped(); dropped != test.dropped {
+				t.Fatalf("dropped %d records, expected %d", dropped, test.dropped)
+			}
+			if size := s.Size(); size != int64(test.events * recordSize) {
+				t.Fatalf("the stream is %d bytes", size)
+			}
+
+			// reading from 0 fails once a record is dropped, and the
+			// oldest record kept starts where the dropped ones end
+			dest := make([]byte, 4096)
+			_, err := s.ReadAt(dest, 0)
+			if dropped := errors.As(err, &EventsDroppedError{}); dropped != (test.dropped > 0) {
+				t.Fatalf("reading from 0 failed with %v", err)
+			}
+			n, err := s.ReadAt(dest, int64(test.dropped) * recordSize)
+			if err != nil {
+				t.Fatalf("unable to read the records kept: %s", err)
+			}
+			records := decodeStream(t, dest[:n])
+			if len(records) != test.kept {
+				t.Fatalf("kept %d records, expected %d", len(records), test.kept)
+			}
+			if first := records[0].thread; first != test.dropped + 1 {
+				t.Fatalf("the oldest record kept is of thread %d", first)
+			}
+		})
+	}
+}
+
+func TestEventBufferSize(t *testing.T) {
+	var tests = []struct {
+		name string
+		size int
+		events int
+		dropped uint64
+	}{
+		{ name: "default", size: 0, events: 10, dropped: 0 },
+		{ name: "overflowing", size: 4, events: 10, dropped: 6 },
+		{ name: "at the size", size: 4, events: 4, dropped: 0 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager, err := NewEventManager(context.Background(), nil)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			manager.SetEventBufferSize(test.size)
+			event, err := manager.CreateEvent("buffered")
+			if err != nil {
+				t.Fatalf("unable to create the event: %s", err)
+			}
+
+			// as the watch hands them over
+			for i := 1; i <= test.events; i++ {
+				fired := &jdwp.EventThreadStart { Thread: jdwp.ThreadID(i) }
+				event.eventLog.AppendEvent(fired)
+				event.eventStream.AppendEvent(fired)
+			}
+
+			if dropped := event.GetDropped(); dropped != test.dropped {
+				t.Fatalf("dropped %d events, expected %d", dropped, test.dropped)
+			}
+			if dropped := event.eventStream.Dropped(); dropped != test.dropped {
+				t.Fatalf("the stream dropped %d events, expected %d", dropped, test.dropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"fmt"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Frame locals
+// The variables of a frame, out of the variable table of its method;
+// a slot holds different variables over the method, so only the ones
+// live at the location of the frame are taken
+//
+type LocalVariable struct {
+	Name string
+	Signature string
+	Slot int
+}
+
+// GetLocalVariables returns the variables live at a location, arguments
+// included; it fails for methods compiled without a variable table
+func (c *Connection) GetLocalVariables(location jdwp.Location) ([]LocalVariable, error) {
+	table, err := c.GetVariableTable(jdwp.ReferenceTypeID(location.Class), location.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	var variables []LocalVariable
+	for _, variable := range table.Slots {
+		if location.Location < variable.CodeIndex ||
+			location.Location >= variable.CodeIndex + uint64(variable.Length) {
+			continue
+		}
+
+		variables = append(variables, LocalVariable {
+			Name: variable.Name,
+			Signature: variable.Signature,
+			Slot: variable.Slot,
+		})
+	}
+
+	return variables, nil
+}
+
+// GetLocalValue reads a variable of a frame of a suspended thread
+func (c *Connection) GetLocalValue(thread jdwp.ThreadID, frame jdwp.FrameID, variable LocalVariable) (jdwp.Value, error) {
+	var tag uint8 = 'L'
+	if variable.Signature != "" {
+		tag = variable.Signature[0]
+	}
+
+	values, err := c.getValues(thread, frame, []jdwp.VariableRequest {
+		jdwp.VariableRequest {
+			Index: variable.Slot,
+			Tag: tag,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, JdwpConnectionError {
+			message: fmt.Sprintf("%d values read for slot %d", len(values), variable.Slot),
+		}
+	}
+
+	return values[0], nil
+}
+
+// SetLocalValue sets a variable of a frame of a suspended thread
+func (c *Connection) SetLocalValue(thread jdwp.ThreadID, frame jdwp.FrameID, variable LocalVariable, value jdwp.Value) error {
+	defer c.acquire()()
+	return c.jdwp().SetValues(thread, frame, []jdwp.VariableAssignmentRequest {
+		jdwp.VariableAssignmentRequest {
+			Index: variable.Slot,
+			Value: value,
+		},
+	})
+}
+
+// GetThisObject returns the object a frame runs on; 0 for static and
+// native methods
+func (c *Connection) GetThisObject(thread jdwp.ThreadID, frame jdwp.FrameID) (jdwp.ObjectID, error) {
+	return c.getThisObject(thread, frame)
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandReferenceTypeSignatureWithGeneric = 13
+	commandReferenceTypeFieldsWithGeneric = 14
+	commandReferenceTypeMethodsWithGeneric = 15
+)
+
+//
+// Generic signatures
+// The ...WithGeneric variants return the same as the plain commands,
+// plus the generic signature, empty when there is none
+//
+type GenericField struct {
+	jdwp.Field
+	GenericSignature string
+}
+
+type GenericMethod struct {
+	jdwp.Method
+	GenericSignature string
+}
+
+func (c *Connection) GetSignatureWithGeneric(id jdwp.ReferenceTypeID) (string, string, error) {
+	var signature, genericSignature string
+	err := c.command(commandSetReferenceType, commandReferenceTypeSignatureWithGeneric, func(w *packetWriter) {
+		w.ReferenceTypeID(uint64(id))
+	}, func(r *packetReader) {
+		signature = r.String()
+		genericSignature = r.String()
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return signature, genericSignature, nil
+}
+
+// fetchFieldsWithGeneric asks the VM; GetFieldsWithGeneric caches it
+func (c *Connection) fetchFieldsWithGeneric(id jdwp.ReferenceTypeID) ([]GenericField, error) {
+	var fields []GenericField
+	err := c.command(commandSetReferenceType, commandReferenceTypeFieldsWithGeneric, func(w *packetWriter) {
+		w.ReferenceTypeID(uint64(id))
+	}, func(r *packetReader) {
+		count := r.Int32()
+		for i := int32(0); i < count && r.Error() == nil; i++ {
+			var field GenericField
+			field.ID = jdwp.FieldID(r.FieldID())
+			field.Name = r.String()
+			field.Signature = r.String()
+			field.GenericSignature = r.String()
+			field.ModBits = jdwp.ModBits(r.Int32())
+			fields = append(fields, field)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// fetchMethodsWithGeneric asks the VM; GetMethodsWithGeneric caches it
+func (c *Connection) fetchMethodsWithGeneric(id jdwp.ReferenceTypeID) ([]GenericMethod, error) {
+	var methods []GenericMethod
+	err := c.command(commandSetReferenceType, commandReferenceTypeMethodsWithGeneric, func(w *packetWriter) {
+		w.ReferenceTypeID(uint64(id))
+	}, func(r *packetReader) {
+		count := r.Int32()
+		for i := int32(0); i < count && r.Error() == nil; i++ {
+			var method GenericMethod
+			method.ID = jdwp.MethodID(r.MethodID())
+			method.Name = r.String()
+			method.Signature = r.String()
+			method.GenericSignature = r.String()
+			method.ModBits = jdwp.ModBits(r.Int32())
+			methods = append(methods, method)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return methods, nil
+}
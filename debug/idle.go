@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"time"
+)
+
+// SetRedial sets how the connection is opened again after Disconnect;
+// without it, the calls fail once disconnected
+func (c *Connection) SetRedial(redial func() (*Connection, error)) {
+	c.imu.Lock()
+	defer c.imu.Unlock()
+
+	c.redial = redial
+}
+
+// LastActivity tells when the VM was last asked something
+func (c *Connection) LastActivity() time.Time {
+	c.imu.Lock()
+	defer c.imu.Unlock()
+
+	return c.lastActivity
+}
+
+// DisconnectIfIdle closes the connection to the VM until the next call,
+// which opens it again through the redial function, if it wasn't used
+// for the timeout and no call waits or is in flight. A call marks the
+// connection as used under imu after counting itself under pmu, so
+// holding both, a call is either counted or finds it disconnected
+func (c *Connection) DisconnectIfIdle(now time.Time, timeout time.Duration) (bool, error) {
+	c.imu.Lock()
+	c.pmu.Lock()
+	busy := c.queued > 0 || c.inFlight > 0
+	c.pmu.Unlock()
+
+	if c.disconnected || busy || now.Sub(c.lastActivity) < timeout {
+		c.imu.Unlock()
+		return false, nil
+	}
+	c.disconnected = true
+
+	c.cmu.RLock()
+	commands := c.commands
+	c.cmu.RUnlock()
+	c.imu.Unlock()
+
+	return true, commands.Close()
+}
+
+func (c *Connection) IsDisconnected() bool {
+	c.imu.Lock()
+	defer c.imu.Unlock()
+
+	return c.disconnected
+}
+
+// wake marks the connection as used, and opens it again if it was
+// disconnected; the calls waking it together wait for one redial
+func (c *Connection) wake() {
+	c.imu.Lock()
+	c.lastActivity = time.Now()
+	disconnected := c.disconnected
+	redial := c.redial
+	c.imu.Unlock()
+
+	if !disconnected || redial == nil {
+		return
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if !c.IsDisconnected() {
+		return
+	}
+
+	other, err := redial()
+	if err != nil {
+		return
+	}
+
+	// the previous channel was closed when disconnecting
+	c.Swap(other)
+}
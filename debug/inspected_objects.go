@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Inspected objects
+// When an event suspends the thread it fired on, the this object and the
+// object arguments of the frame it fired in are kept from being
+// collected, so they can be looked at while the thread stays suspended;
+// they're let go once it's resumed through the connection
+//
+type InspectedObject struct {
+	Id jdwp.ObjectID
+	Thread jdwp.ThreadID
+
+	// the event which fired, and what the object was to the frame:
+	// this, or the name of the argument
+	Event string
+	Role string
+}
+
+// SetInspectEvents turns keeping the objects of the suspending events
+// on or off
+func (c *Connection) SetInspectEvents(inspect bool) {
+	c.omu.Lock()
+	defer c.omu.Unlock()
+
+	c.inspectEvents = inspect
+}
+
+// GetInspectedObjects returns the objects kept, ordered by id
+func (c *Connection) GetInspectedObjects() []InspectedObject {
+	c.omu.Lock()
+	defer c.omu.Unlock()
+
+	objects := make([]InspectedObject, 0, len(c.inspected))
+	for _, object := range c.inspected {
+		objects = append(objects, object)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Id < objects[j].Id
+	})
+
+	return objects
+}
+
+// IsInspected tells if the object is kept for an event
+func (c *Connection) IsInspected(id jdwp.ObjectID) bool {
+	c.omu.Lock()
+	defer c.omu.Unlock()
+
+	_, ok := c.inspected[id]
+	return ok
+}
+
+// how many events may wait to be inspected; past it, events go
+// uninspected rather than holding up the ones after them
+const inspectionQueueSize = 64
+
+type pendingInspection struct {
+	name string
+	thread jdwp.ThreadID
+}
+
+// inspectEvent queues the event for its objects to be kept, if it
+// suspended its thread; it's called from the event handler, which has
+// to go back to receiving for the replies to come in, so the reading is
+// left to a worker
+func (c *Connection) inspectEvent(name string, event jdwp.Event, suspendPolicy jdwp.SuspendPolicy) {
+	c.omu.Lock()
+	inspect := c.inspectEvents
+	c.omu.Unlock()
+
+	if !inspect || suspendPolicy == jdwp.SuspendNone {
+		return
+	}
+
+	thread := jdwp.ThreadID(NewEventRecord(event, time.Time{}).Thread)
+	if thread == 0 {
+		return
+	}
+
+	c.omu.Lock()
+	if len(c.inspections) >= inspectionQueueSize {
+		c.omu.Unlock()
+		log.Printf("too many events to inspect, event %s isn't\n", name)
+		return
+	}
+	c.inspections = append(c.inspections, pendingInspection {
+		name: name,
+		thread: thread,
+	})
+	c.pendingInspections.Add(1)
+	start := !c.inspecting
+	c.inspecting = true
+	c.omu.Unlock()
+
+	if start {
+		go c.inspectQueued()
+	}
+}
+
+// inspectQueued inspects the events queued, until there are none left
+func (c *Connection) inspectQueued() {
+	for {
+		c.omu.Lock()
+		if len(c.inspections) == 0 {
+			c.inspecting = false
+			c.omu.Unlock()
+			return
+		}
+		next := c.inspections[0]
+		c.inspections = c.inspections[1:]
+		c.omu.Unlock()
+
+		c.inspectThread(next.name, next.thread)
+		c.pendingInspections.Done()
+	}
+}
+
+// inspectThread keeps the objects of the frame the thread is suspended
+// in; what can't be read is skipped, e.g. if it was resumed since
+func (c *Connection) inspectThread(name string, thread jdwp.ThreadID) {
+	frames, err := c.GetFrames(thread, 0, 1)
+	if err != nil || len(frames) == 0 {
+		log.Printf("could not read the frame event %s fired in: %v\n", name, err)
+		return
+	}
+	frame := frames[0]
+
+	roles := map[jdwp.ObjectID]string{}
+
+	this, err := c.getThisObject(thread, frame.Frame)
+	if err != nil {
+		log.Printf("could not read this for event %s: %s\n", name, err)
+	} else if this != 0 {
+		roles[this] = "this"
+	}
+
+	arguments, err := c.getObjectArguments(thread, frame)
+	if err != nil {
+		log.Printf("could not read the arguments for event %s: %s\n", name, err)
+	}
+	for id, argument := range arguments {
+		if _, ok := roles[id]; !ok {
+			roles[id] = argument
+		}
+	}
+
+	for id, role := range roles {
+		// kept already, by an earlier event
+		if c.IsInspected(id) {
+			continue
+		}
+
+		err := c.disableGC(id)
+		if err != nil {
+			log.Printf("could not keep object %d for event %s: %s\n", id, name, err)
+			continue
+		}
+
+		c.omu.Lock()
+		if c.inspected == nil {
+			c.inspected = map[jdwp.ObjectID]InspectedObject{}
+		}
+		c.inspected[id] = InspectedObject {
+			Id: id,
+			Thread: thread,
+			Event: name,
+			Role: role,
+		}
+		c.omu.Unlock()
+	}
+}
+
+// getObjectArguments reads the arguments of a frame holding objects, by
+// name; it needs the variable table
+func (c *Connection) getObjectArguments(thread jdwp.ThreadID, frame jdwp.FrameInfo) (map[jdwp.ObjectID]string, error) {
+	table, err := c.GetVariableTable(jdwp.ReferenceTypeID(frame.Location.Class), frame.Location.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []jdwp.VariableRequest
+	var names []string
+	for _, variable := range table.Slots {
+		if variable.Slot >= table.ArgCount || variable.Name == "this" || variable.Signature == "" {
+			continue
+		}
+		tag := variable.Signature[0]
+		if tag != 'L' && tag != '[' {
+			continue
+		}
+
+		requests = append(requests, jdwp.VariableRequest {
+			Index: variable.Slot,
+			Tag: tag,
+		})
+		names = append(names, variable.Name)
+	}
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	values, err := c.getValues(thread, frame.Frame, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	arguments := map[jdwp.ObjectID]string{}
+	for i, value := range values {
+		object, ok := value.(jdwp.Object)
+		if !ok || object.ID() == 0 || i >= len(names) {
+			continue
+		}
+		arguments[object.ID()] = names[i]
+	}
+
+	return arguments, nil
+}
+
+// releaseInspected lets go of the objects of the threads which aren't
+// suspended anymore, out of the given ones, or of all of them if none
+// are given
+func (c *Connection) releaseInspected(threads ...jdwp.ThreadID) {
+	c.omu.Lock()
+	candidates := map[jdwp.ThreadID]bool{}
+	for _, object := range c.inspected {
+		candidates[object.Thread] = len(threads) == 0
+	}
+	for _, thread := range threads {
+		if _, ok := candidates[thread]; ok {
+			candidates[thread] = true
+		}
+	}
+	c.omu.Unlock()
+
+	for thread, candidate := range candidates {
+		if !candidate {
+			continue
+		}
+
+		// a thread which is gone can't hold on to them anymore either
+		count, err := c.GetSuspendCount(thread)
+		if err == nil && count > 0 {
+			continue
+		}
+
+		c.omu.Lock()
+		var released []jdwp.ObjectID
+		for id, object := range c.inspected {
+			if object.Thread == thread {
+				released = append(released, id)
+				delete(c.inspected, id)
+			}
+		}
+		c.omu.Unlock()
+
+		for _, id := range released {
+			err := c.enableGC(id)
+			if err != nil {
+				log.Printf("could not let go of object %d: %s\n", id, err)
+			}
+		}
+	}
+}
+
+// forgetInspected drops the objects without telling the VM, for when
+// they came from a previous connection
+func (c *Connection) forgetInspected() {
+	c.omu.Lock()
+	defer c.omu.Unlock()
+
+	c.inspected = nil
+}
+
+func (c *Connection) getThisObject(thread jdwp.ThreadID, frame jdwp.FrameID) (jdwp.ObjectID, error) {
+	defer c.acquire()()
+	this, err := c.jdwp().GetThisObject(thread, frame)
+	return this.Object, err
+}
+
+func (c *Connection) getValues(thread jdwp.ThreadID, frame jdwp.FrameID, requests []jdwp.VariableRequest) ([]jdwp.Value, error) {
+	defer c.acquire()()
+	return c.jdwp().GetValues(thread, frame, requests)
+}
+
+func (c *Connection) disableGC(id jdwp.ObjectID) error {
+	defer c.acquire()()
+	return c.jdwp().DisableGC(id)
+}
+
+func (c *Connection) enableGC(id jdwp.ObjectID) error {
+	defer c.acquire()()
+	return c.jdwp().EnableGC(id)
+}
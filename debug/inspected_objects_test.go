@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// inspectHandler has thread 5 suspended once, in a frame of method 7
+// whose this is object 42; the method has no variable table
+func inspectHandler() fakeHandler {
+	var mu sync.Mutex
+	suspended := 1
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case set == 11 && cmd == 6:
+			return 0, fakeConcat(fakeInt(1), fakeLong(1),
+				[]byte { byte(jdwp.Class) }, fakeLong(41), fakeLong(7), fakeLong(0))
+		case set == 16 && cmd == 3:
+			return 0, fakeConcat([]byte { 'L' }, fakeLong(42))
+		case set == 6:
+			return uint16(jdwp.ErrAbsentInformation), nil
+		case set == 11 && cmd == 3:
+			if suspended > 0 {
+				suspended--
+			}
+		case set == 11 && cmd == 12:
+			return 0, fakeInt(uint32(suspended))
+		}
+		return 0, nil
+	}
+}
+
+func TestInspectEvent(t *testing.T) {
+	var breakpoint = &jdwp.EventBreakpoint {
+		Thread: 5,
+		Location: jdwp.Location { Type: jdwp.Class, Class: 41, Method: 7 },
+	}
+
+	var tests = []struct {
+		name string
+		inspect bool
+		policy jdwp.SuspendPolicy
+		inspected []InspectedObject
+	}{
+		{
+			name: "suspending",
+			inspect: true,
+			policy: jdwp.SuspendEventThread,
+			inspected: []InspectedObject {
+				{ Id: 42, Thread: 5, Event: "bp", Role: "this" },
+			},
+		},
+		{ name: "not suspending", inspect: true, policy: jdwp.SuspendNone, inspected: []InspectedObject{} },
+		{ name: "not inspecting", inspect: false, policy: jdwp.SuspendAll, inspected: []InspectedObject{} },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, inspectHandler())
+			conn.SetInspectEvents(test.inspect)
+
+			conn.inspectEvent("bp", breakpoint, test.policy)
+			conn.pendingInspections.Wait()
+			if inspected := conn.GetInspectedObjects(); !reflect.DeepEqual(inspected, test.inspected) {
+				t.Fatalf("inspected %+v, expected %+v", inspected, test.inspected)
+			}
+			if kept := vm.Received(9, 7); kept != len(test.inspected) {
+				t.Fatalf("kept %d objects from being collected", kept)
+			}
+			for _, object := range test.inspected {
+				if !conn.IsInspected(object.Id) {
+					t.Fatalf("object %d isn't inspected", object.Id)
+				}
+			}
+
+			// until the thread is resumed
+			if err := conn.Resume(5); err != nil {
+				t.Fatalf("resume: %s", err)
+			}
+			if inspected := conn.GetInspectedObjects(); len(inspected) != 0 {
+				t.Fatalf("still inspected once resumed: %+v", inspected)
+			}
+			if released := vm.Received(9, 8); released != len(test.inspected) {
+				t.Fatalf("let go of %d objects, expected %d", released, len(test.inspected))
+			}
+		})
+	}
+}
+
+func TestInspectEventQueued(t *testing.T) {
+	var breakpoint = &jdwp.EventBreakpoint {
+		Thread: 5,
+		Location: jdwp.Location { Type: jdwp.Class, Class: 41, Method: 7 },
+	}
+
+	// the frames only come once released, as if the VM was busy
+	release := make(chan struct{})
+	handler := inspectHandler()
+	conn, _ := startFakeVM(t, func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		if set == 11 && cmd == 6 {
+			<-release
+		}
+		return handler(set, cmd, data)
+	})
+	conn.SetInspectEvents(true)
+
+	queued := make(chan struct{})
+	go func() {
+		conn.inspectEvent("bp", breakpoint, jdwp.SuspendEventThread)
+		close(queued)
+	}()
+	select {
+	case <-queued:
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatalf("the event handler waited for the inspection")
+	}
+
+	close(release)
+	conn.pendingInspections.Wait()
+	if !conn.IsInspected(42) {
+		t.Fatalf("object 42 isn't inspected")
+	}
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"fmt"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	threadClassSignature = "Ljava/lang/Thread;"
+	systemClassSignature = "Ljava/lang/System;"
+	objectClassSignature = "Ljava/lang/Object;"
+)
+
+//
+// Invocations
+// These run code inside the VM, on a thread suspended by an event
+//
+
+// SetThreadName renames a thread by invoking Thread.setName on it
+func (c *Connection) SetThreadName(thread jdwp.ThreadID, name string) error {
+	defer c.acquire()()
+	conn := c.jdwp()
+
+	threadClass, err := conn.GetClassBySignature(threadClassSignature)
+	if err != nil {
+		return err
+	}
+
+	setName, err := conn.GetClassMethod(threadClass.ClassID(), "setName", "(Ljava/lang/String;)V")
+	if err != nil {
+		return err
+	}
+
+	nameString, err := conn.CreateString(name)
+	if err != nil {
+		return err
+	}
+
+	result, err := conn.InvokeMethod(
+		jdwp.ObjectID(thread),
+		threadClass.ClassID(),
+		setName.ID,
+		thread,
+		jdwp.InvokeSingleThreaded,
+		nameString)
+	if err != nil {
+		return err
+	}
+
+	if result.Exception.Object != 0 {
+		return JdwpConnectionError {
+			message: fmt.Sprintf("setName threw exception %d", result.Exception.Object),
+		}
+	}
+
+	return nil
+}
+
+// IdentityHashCode invokes System.identityHashCode on an object, which
+// stays the same for as long as the object lives, from a thread
+// suspended by an event
+func (c *Connection) IdentityHashCode(object jdwp.ObjectID, thread jdwp.ThreadID) (int32, error) {
+	defer c.acquire()()
+	conn := c.jdwp()
+
+	systemClass, err := conn.GetClassBySignature(systemClassSignature)
+	if err != nil {
+		return 0, err
+	}
+
+	identityHashCode, err := conn.GetClassMethod(systemClass.ClassID(), "identityHashCode", "(Ljava/lang/Object;)I")
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := conn.InvokeStaticMethod(
+		systemClass.ClassID(),
+		identityHashCode.ID,
+		thread,
+		jdwp.InvokeSingleThreaded,
+		object)
+	if err != nil {
+		return 0, err
+	}
+
+	if result.Exception.Object != 0 {
+		return 0, JdwpConnectionError {
+			message: fmt.Sprintf("identityHashCode threw exception %d", result.Exception.Object),
+		}
+	}
+
+	hashCode, ok := result.Result.(int32)
+	if !ok {
+		return 0, JdwpConnectionError {
+			message: fmt.Sprintf("identityHashCode returned %v", result.Result),
+		}
+	}
+
+	return hashCode, nil
+}
+
+// ToString invokes toString on an object, from a thread suspended by an
+// event; a null result is rendered as "null"
+func (c *Connection) ToString(object jdwp.ObjectID, thread jdwp.ThreadID) (string, error) {
+	defer c.acquire()()
+	conn := c.jdwp()
+
+	objectClass, err := conn.GetClassBySignature(objectClassSignature)
+	if err != nil {
+		return "", err
+	}
+
+	toString, err := conn.GetClassMethod(objectClass.ClassID(), "toString", "()Ljava/lang/String;")
+	if err != nil {
+		return "", err
+	}
+
+	result, err := conn.InvokeMethod(
+		object,
+		objectClass.ClassID(),
+		toString.ID,
+		thread,
+		jdwp.InvokeSingleThreaded)
+	if err != nil {
+		return "", err
+	}
+
+	if result.Exception.Object != 0 {
+		return "", JdwpConnectionError {
+			message: fmt.Sprintf("toString threw exception %d", result.Exception.Object),
+		}
+	}
+
+	switch text := result.Result.(type) {
+	case nil:
+		return "null", nil
+	case jdwp.StringID:
+		if text == 0 {
+			return "null", nil
+		}
+		return conn.GetString(text)
+	default:
+		return "", JdwpConnectionError {
+			message: fmt.Sprintf("toString returned %v", result.Result),
+		}
+	}
+}
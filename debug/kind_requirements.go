@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"fmt"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Kind requirement
+// A modifier an event kind can't be requested without
+//
+type KindRequirement struct {
+	Description string
+
+	// false for modifiers jdwpfs can't create
+	met func(descriptors map[string]ModifierDescriptor) bool
+}
+
+func hasModifier(descriptors map[string]ModifierDescriptor, matches func(ModifierDescriptor) bool) bool {
+	for _, descriptor := range descriptors {
+		if !descriptor.Pending && matches(descriptor) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	methodRequirement = KindRequirement {
+		Description: "a method location modifier",
+		met: func(descriptors map[string]ModifierDescriptor) bool {
+			return hasModifier(descriptors, func(d ModifierDescriptor) bool {
+				return !d.IsField && d.ThreadId == 0
+			})
+		},
+	}
+
+	fieldRequirement = KindRequirement {
+		Description: "a field modifier",
+		met: func(descriptors map[string]ModifierDescriptor) bool {
+			return hasModifier(descriptors, func(d ModifierDescriptor) bool {
+				return d.IsField
+			})
+		},
+	}
+
+	threadRequirement = KindRequirement {
+		Description: "a thread modifier",
+		met: func(descriptors map[string]ModifierDescriptor) bool {
+			return hasModifier(descriptors, func(d ModifierDescriptor) bool {
+				return d.ThreadId != 0
+			})
+		},
+	}
+
+	stepRequirement = KindRequirement {
+		Description: "a step modifier, which jdwpfs can't create yet",
+		met: func(descriptors map[string]ModifierDescriptor) bool {
+			return false
+		},
+	}
+
+	// the kinds the VM refuses, or requests for every location, field
+	// or thread, without these modifiers
+	kindRequirements = map[jdwp.EventKind][]KindRequirement {
+		jdwp.Breakpoint: { methodRequirement },
+		jdwp.SingleStep: { stepRequirement, threadRequirement },
+		jdwp.FieldAccess: { fieldRequirement },
+		jdwp.FieldModification: { fieldRequirement },
+	}
+)
+
+// KindRequirements returns the modifiers an event kind needs; most kinds
+// need none
+func KindRequirements(kind jdwp.EventKind) []KindRequirement {
+	return kindRequirements[kind]
+}
+
+func (e *DebuggingEvent) checkKindRequirements() error {
+	for _, requirement := range kindRequirements[e.kind] {
+		if !requirement.met(e.modifierDescriptors) {
+			return JdwpDebuggingEventError{
+				message: fmt.Sprintf("event %s: %s events need %s",
+					e.Name, e.kind, requirement.Description),
+			}
+		}
+	}
+
+	return nil
+}
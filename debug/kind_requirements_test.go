@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestKindRequirements(t *testing.T) {
+	var method = ModifierDescriptor { Name: "run", ClassId: 42, ObjectId: 7 }
+	var field = ModifierDescriptor { Name: "count", ClassId: 42, ObjectId: 3, IsField: true }
+	var thread = ModifierDescriptor { Name: "main", ThreadId: 31 }
+	var pending = ModifierDescriptor { Name: "later", Pending: true }
+
+	var tests = []struct {
+		name string
+		kind jdwp.EventKind
+		modifiers []ModifierDescriptor
+		met bool
+	}{
+		{ name: "thread start, no modifiers", kind: jdwp.ThreadStart, met: true },
+		{ name: "single step, no modifiers", kind: jdwp.SingleStep, met: false },
+		{ name: "single step, thread only", kind: jdwp.SingleStep, modifiers: []ModifierDescriptor { thread }, met: false },
+		{ name: "field access, no modifiers", kind: jdwp.FieldAccess, met: false },
+		{ name: "field access, method only", kind: jdwp.FieldAccess, modifiers: []ModifierDescriptor { method }, met: false },
+		{ name: "field access, field", kind: jdwp.FieldAccess, modifiers: []ModifierDescriptor { field }, met: true },
+		{ name: "field modification, field", kind: jdwp.FieldModification, modifiers: []ModifierDescriptor { field, thread }, met: true },
+		{ name: "breakpoint, thread only", kind: jdwp.Breakpoint, modifiers: []ModifierDescriptor { thread }, met: false },
+		{ name: "breakpoint, pending only", kind: jdwp.Breakpoint, modifiers: []ModifierDescriptor { pending }, met: false },
+		{ name: "breakpoint, method", kind: jdwp.Breakpoint, modifiers: []ModifierDescriptor { method, pending }, met: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := NewStubDebuggingEvent("required")
+			event.SetKind(test.kind)
+			for _, modifier := range test.modifiers {
+				event.SetModifier(modifier.Name, modifier)
+			}
+
+			err := event.checkKindRequirements()
+			if met := err == nil; met != test.met {
+				t.Fatalf("met: %v (%v)", met, err)
+			}
+		})
+	}
+}
+
+func TestRunUnmetKindRequirements(t *testing.T) {
+	conn, vm := startFakeVM(t, eventRequestHandler)
+	event := NewStubDebuggingEvent("unmet")
+	event.SetKind(jdwp.FieldAccess)
+	event.SetConn(conn)
+
+	if _, err := event.Run(); err == nil {
+		event.Cancel()
+		t.Fatalf("ran a field access event without a field")
+	}
+	if event.IsRunning() {
+		t.Fatalf("the event runs")
+	}
+	if requested := vm.Received(15, 1); requested != 0 {
+		t.Fatalf("requested the event %d times", requested)
+	}
+}
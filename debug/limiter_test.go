@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestLimiterAcquire(t *testing.T) {
+	const callers = 16
+
+	var tests = []struct {
+		name string
+		limit int
+		max int32
+	}{
+		{ name: "unbounded", limit: 0, max: callers },
+		{ name: "one at a time", limit: 1, max: 1 },
+		{ name: "bounded", limit: 4, max: 4 },
+		{ name: "bound over the callers", limit: 2 * callers, max: callers },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, nil)
+			conn.SetMaxConcurrent(test.limit)
+
+			var held, max int32
+			release := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer conn.acquire()()
+
+					now := atomic.AddInt32(&held, 1)
+					for {
+						seen := atomic.LoadInt32(&max)
+						if now <= seen || atomic.CompareAndSwapInt32(&max, seen, now) {
+							break
+						}
+					}
+					<-release
+					atomic.AddInt32(&held, -1)
+				}()
+			}
+
+			// wait for every caller to either hold a slot or queue for one
+			deadline := time.Now().Add(10 * time.Second)
+			for {
+				queued, inFlight := conn.GetPendingCalls()
+				if queued + inFlight == callers && int32(inFlight) == atomic.LoadInt32(&held) {
+					if int32(inFlight) != test.max {
+						t.Fatalf("%d calls in flight, expected %d", inFlight, test.max)
+					}
+					if queued != callers - int(test.max) {
+						t.Fatalf("%d calls queued, expected %d", queued, callers - int(test.max))
+					}
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("got %d queued and %d in flight", queued, inFlight)
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			close(release)
+			wg.Wait()
+
+			if seen := atomic.LoadInt32(&max); seen != test.max {
+				t.Fatalf("at most %d calls held a slot, expected %d", seen, test.max)
+			}
+			if queued, inFlight := conn.GetPendingCalls(); queued != 0 || inFlight != 0 {
+				t.Fatalf("%d calls queued and %d in flight once done", queued, inFlight)
+			}
+		})
+	}
+}
+
+func TestLimiterCommands(t *testing.T) {
+	const limit = 2
+	const callers = 8
+
+	var conn *Connection
+	var exceeded int32
+	conn, vm := startFakeVM(t, func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		if _, inFlight := conn.GetPendingCalls(); inFlight > limit {
+			atomic.StoreInt32(&exceeded, int32(inFlight))
+		}
+		return 0, fakeString("main")
+	})
+	conn.SetMaxConcurrent(limit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := conn.GetThreadName(jdwp.ThreadID(1)); err != nil {
+				t.Errorf("%s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if inFlight := atomic.LoadInt32(&exceeded); inFlight != 0 {
+		t.Fatalf("%d calls in flight, over the limit of %d", inFlight, limit)
+	}
+	if sent := vm.Received(11, 1); sent != callers {
+		t.Fatalf("sent %d commands, expected %d", sent, callers)
+	}
+}
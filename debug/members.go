@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// GetMethods returns the methods of a class; they're cached, as a class
+// can't change them without being redefined
+func (c *Connection) GetMethods(ty jdwp.ReferenceTypeID) (jdwp.Methods, error) {
+	c.kmu.Lock()
+	methods, ok := c.methods[ty]
+	c.kmu.Unlock()
+	if ok {
+		return methods, nil
+	}
+
+	err := c.retry(func() (err error) {
+		defer c.acquire()()
+		methods, err = c.jdwp().GetMethods(ty)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.kmu.Lock()
+	if c.methods == nil {
+		c.methods = make(map[jdwp.ReferenceTypeID]jdwp.Methods)
+	}
+	c.methods[ty] = methods
+	c.kmu.Unlock()
+
+	return methods, nil
+}
+
+// GetFields returns the fields of a class, cached like the methods
+func (c *Connection) GetFields(ty jdwp.ReferenceTypeID) (jdwp.Fields, error) {
+	c.kmu.Lock()
+	fields, ok := c.fields[ty]
+	c.kmu.Unlock()
+	if ok {
+		return fields, nil
+	}
+
+	err := c.retry(func() (err error) {
+		defer c.acquire()()
+		fields, err = c.jdwp().GetFields(ty)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.kmu.Lock()
+	if c.fields == nil {
+		c.fields = make(map[jdwp.ReferenceTypeID]jdwp.Fields)
+	}
+	c.fields[ty] = fields
+	c.kmu.Unlock()
+
+	return fields, nil
+}
+
+// GetMethodsWithGeneric returns the methods of a class along with their
+// generic signatures, cached like the plain ones
+func (c *Connection) GetMethodsWithGeneric(ty jdwp.ReferenceTypeID) ([]GenericMethod, error) {
+	c.kmu.Lock()
+	methods, ok := c.genericMethods[ty]
+	c.kmu.Unlock()
+	if ok {
+		return methods, nil
+	}
+
+	err := c.retry(func() (err error) {
+		methods, err = c.fetchMethodsWithGeneric(ty)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.kmu.Lock()
+	if c.genericMethods == nil {
+		c.genericMethods = make(map[jdwp.ReferenceTypeID][]GenericMethod)
+	}
+	c.genericMethods[ty] = methods
+	c.kmu.Unlock()
+
+	return methods, nil
+}
+
+// GetFieldsWithGeneric returns the fields of a class along with their
+// generic signatures, cached like the plain ones
+func (c *Connection) GetFieldsWithGeneric(ty jdwp.ReferenceTypeID) ([]GenericField, error) {
+	c.kmu.Lock()
+	fields, ok := c.genericFields[ty]
+	c.kmu.Unlock()
+	if ok {
+		return fields, nil
+	}
+
+	err := c.retry(func() (err error) {
+		fields, err = c.fetchFieldsWithGeneric(ty)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.kmu.Lock()
+	if c.genericFields == nil {
+		c.genericFields = make(map[jdwp.ReferenceTypeID][]GenericField)
+	}
+	c.genericFields[ty] = fields
+	c.kmu.Unlock()
+
+	return fields, nil
+}
+
+// IsMembersCached tells if both the methods and the fields of a class
+// are cached
+func (c *Connection) IsMembersCached(ty jdwp.ReferenceTypeID) bool {
+	c.kmu.Lock()
+	defer c.kmu.Unlock()
+
+	_, methodsCached := c.methods[ty]
+	_, fieldsCached := c.fields[ty]
+	return methodsCached && fieldsCached
+}
+
+// FlushMemberCache forgets the cached methods and fields, with their
+// generic signatures or not
+func (c *Connection) FlushMemberCache() {
+	c.kmu.Lock()
+	defer c.kmu.Unlock()
+
+	c.methods = nil
+	c.fields = nil
+	c.genericMethods = nil
+	c.genericFields = nil
+}
+
+// FlushCaches forgets everything cached about the VM state: the members
+// of the classes, and the thread statuses; the capabilities and the
+// versions can't change while connected, so they are kept
+func (c *Connection) FlushCaches() {
+	c.FlushMemberCache()
+	c.flushThreadStatuses()
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"sync"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// genericMembersHandler answers the WithGeneric commands with one member
+// each, after failing the first replies with errorCode
+func genericMembersHandler(failures int, errorCode uint16) fakeHandler {
+	var mu sync.Mutex
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		if set != commandSetReferenceType {
+			return 0, nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return errorCode, nil
+		}
+
+		switch cmd {
+		case commandReferenceTypeMethodsWithGeneric, commandReferenceTypeFieldsWithGeneric:
+			return 0, fakeConcat(
+				fakeInt(1),
+				fakeLong(7),
+				fakeString("get"),
+				fakeString("()Ljava/lang/Object;"),
+				fakeString("()TT;"),
+				fakeInt(1))
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestGenericMembersCache(t *testing.T) {
+	var tests = []struct {
+		name string
+		failures int
+		errorCode uint16
+		retries int
+		fails bool
+		sent int
+	}{
+		{ name: "answered", sent: 1 },
+		{ name: "retried", failures: 1, errorCode: uint16(jdwp.ErrInternal), retries: 2, sent: 2 },
+		{ name: "out of retries", failures: 3, errorCode: uint16(jdwp.ErrInternal), retries: 2, fails: true, sent: 3 },
+		{ name: "invalid class", failures: 1, errorCode: uint16(jdwp.ErrInvalidClass), retries: 2, fails: true, sent: 1 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, genericMembersHandler(test.failures, test.errorCode))
+			conn.SetReadRetries(test.retries)
+
+			methods, err := conn.GetMethodsWithGeneric(jdwp.ReferenceTypeID(1))
+			if sent := vm.Received(commandSetReferenceType, commandReferenceTypeMethodsWithGeneric); sent != test.sent {
+				t.Fatalf("sent %d commands, expected %d", sent, test.sent)
+			}
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if len(methods) != 1 || methods[0].GenericSignature != "()TT;" {
+				t.Fatalf("got methods %+v", methods)
+			}
+
+			// cached from then on, until flushed
+			if _, err := conn.GetMethodsWithGeneric(jdwp.ReferenceTypeID(1)); err != nil {
+				t.Fatalf("%s", err)
+			}
+			if sent := vm.Received(commandSetReferenceType, commandReferenceTypeMethodsWithGeneric); sent != test.sent {
+				t.Fatalf("sent %d commands once cached, expected %d", sent, test.sent)
+			}
+
+			conn.FlushCaches()
+			if _, err := conn.GetMethodsWithGeneric(jdwp.ReferenceTypeID(1)); err != nil {
+				t.Fatalf("%s", err)
+			}
+			if sent := vm.Received(commandSetReferenceType, commandReferenceTypeMethodsWithGeneric); sent != test.sent + 1 {
+				t.Fatalf("sent %d commands once flushed, expected %d", sent, test.sent + 1)
+			}
+		})
+	}
+}
+
+func TestGenericFieldsCache(t *testing.T) {
+	conn, vm := startFakeVM(t, genericMembersHandler(0, 0))
+
+	for i := 0; i < 3; i++ {
+		fields, err := conn.GetFieldsWithGeneric(jdwp.ReferenceTypeID(1))
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if len(fields) != 1 || fields[0].Name != "get" {
+			t.Fatalf("got fields %+v", fields)
+		}
+	}
+	if sent := vm.Received(commandSetReferenceType, commandReferenceTypeFieldsWithGeneric); sent != 1 {
+		t.Fatalf("sent %d commands, expected 1", sent)
+	}
+
+	conn.FlushMemberCache()
+	if _, err := conn.GetFieldsWithGeneric(jdwp.ReferenceTypeID(1)); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if sent := vm.Received(commandSetReferenceType, commandReferenceTypeFieldsWithGeneric); sent != 2 {
+		t.Fatalf("sent %d commands once flushed, expected 2", sent)
+	}
+}
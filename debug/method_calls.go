@@ -0,0 +1,420 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Method calls
+// A best effort look at the calls a method makes, from its bytecode:
+// the invoke instructions point at method references in the constant
+// pool of the class, which can then be looked up among the loaded
+// classes. invokedynamic has no fixed target, so it's left out
+//
+type MethodRef struct {
+	// the internal name of the class, e.g. java/lang/String, or the
+	// signature of an array class
+	Class string
+	Name string
+	Signature string
+}
+
+func (r MethodRef) String() string {
+	return fmt.Sprintf("%s.%s%s", r.Class, r.Name, r.Signature)
+}
+
+type MethodCallsError struct {
+	message string
+}
+
+func (e MethodCallsError) Error() string {
+	return fmt.Sprintf("method calls error: %s", e.message)
+}
+
+const (
+	constantUtf8 = 1
+	constantInteger = 3
+	constantFloat = 4
+	constantLong = 5
+	constantDouble = 6
+	constantClass = 7
+	constantString = 8
+	constantFieldref = 9
+	constantMethodref = 10
+	constantInterfaceMethodref = 11
+	constantNameAndType = 12
+	constantMethodHandle = 15
+	constantMethodType = 16
+	constantDynamic = 17
+	constantInvokeDynamic = 18
+	constantModule = 19
+	constantPackage = 20
+)
+
+type poolEntry struct {
+	tag byte
+	text string
+	first uint16
+	second uint16
+}
+
+// parseMethodRefs reads the method references out of a constant pool,
+// by their index; the count is a u2 in the class file, so a VM sending
+// anything else is not believed
+func parseMethodRefs(pool ConstantPool) (map[uint16]MethodRef, error) {
+	if pool.Count <= 0 || pool.Count > 65535 {
+		return nil, MethodCallsError {
+			message: fmt.Sprintf("constant pool count %d out of range", pool.Count),
+		}
+	}
+
+	entries := make([]poolEntry, pool.Count)
+	data := pool.Bytes
+
+	for index := 1; index < int(pool.Count); index++ {
+		if len(data) < 1 {
+			return nil, MethodCallsError { message: "constant pool is cut short" }
+		}
+		tag := data[0]
+		data = data[1:]
+
+		var size int
+		switch tag {
+		case constantUtf8:
+			if len(data) < 2 {
+				return nil, MethodCallsError { message: "constant pool is cut short" }
+			}
+			size = 2 + int(binary.BigEndian.Uint16(data))
+		case constantClass, constantString, constantMethodType, constantModule, constantPackage:
+			size = 2
+		case constantMethodHandle:
+			size = 3
+		case constantInteger, constantFloat, constantFieldref, constantMethodref,
+			constantInterfaceMethodref, constantNameAndType, constantDynamic, constantInvokeDynamic:
+			size = 4
+		case constantLong, constantDouble:
+			size = 8
+		default:
+			return nil, MethodCallsError {
+				message: fmt.Sprintf("unknown constant pool tag %d at %d", tag, index),
+			}
+		}
+		if len(data) < size {
+			return nil, MethodCallsError { message: "constant pool is cut short" }
+		}
+
+		entry := poolEntry { tag: tag }
+		switch {
+		case tag == constantUtf8:
+			entry.text = string(data[2:size])
+		case size >= 2:
+			entry.first = binary.BigEndian.Uint16(data)
+			if size >= 4 {
+				entry.second = binary.BigEndian.Uint16(data[2:])
+			}
+		}
+		entries[index] = entry
+		data = data[size:]
+
+		// the 8 byte constants take two entries
+		if tag == constantLong || tag == constantDouble {
+			index++
+		}
+	}
+
+	text := func(index uint16) string {
+		if int(index) >= len(entries) || entries[index].tag != constantUtf8 {
+			return ""
+		}
+		return entries[index].text
+	}
+
+	refs := map[uint16]MethodRef{}
+	for index, entry := range entries {
+		if entry.tag != constantMethodref && entry.tag != constantInterfaceMethodref {
+			continue
+		}
+		if int(entry.first) >= len(entries) || int(entry.second) >= len(entries) {
+			continue
+		}
+
+		class := entries[entry.first]
+		nameAndType := entries[entry.second]
+		if class.tag != constantClass || nameAndType.tag != constantNameAndType {
+			continue
+		}
+
+		refs[uint16(index)] = MethodRef {
+			Class: text(class.first),
+			Name: text(nameAndType.first),
+			Signature: text(nameAndType.second),
+		}
+	}
+
+	return refs, nil
+}
+
+const (
+	opcodeTableswitch = 0xaa
+	opcodeLookupswitch = 0xab
+	opcodeInvokevirtual = 0xb6
+	opcodeInvokespecial = 0xb7
+	opcodeInvokestatic = 0xb8
+	opcodeInvokeinterface = 0xb9
+	opcodeWide = 0xc4
+	opcodeIinc = 0x84
+)
+
+// operandLengths holds the operand bytes of the opcodes of a fixed
+// length; -1 is an unknown opcode
+var operandLengths = func() [256]int {
+	var lengths [256]int
+	for i := range lengths {
+		lengths[i] = -1
+	}
+
+	set := func(from int, to int, length int) {
+		for opcode := from; opcode <= to; opcode++ {
+			lengths[opcode] = length
+		}
+	}
+	set(0x00, 0x0f, 0)
+	set(0x10, 0x10, 1) // bipush
+	set(0x11, 0x11, 2) // sipush
+	set(0x12, 0x12, 1) // ldc
+	set(0x13, 0x14, 2) // ldc_w, ldc2_w
+	set(0x15, 0x19, 1) // loads
+	set(0x1a, 0x35, 0)
+	set(0x36, 0x3a, 1) // stores
+	set(0x3b, 0x83, 0)
+	set(0x84, 0x84, 2) // iinc
+	set(0x85, 0x98, 0)
+	set(0x99, 0xa8, 2) // branches, jsr
+	set(0xa9, 0xa9, 1) // ret
+	set(0xac, 0xb1, 0) // returns
+	set(0xb2, 0xb8, 2) // fields, invokes
+	set(0xb9, 0xba, 4) // invokeinterface, invokedynamic
+	set(0xbb, 0xbb, 2) // new
+	set(0xbc, 0xbc, 1) // newarray
+	set(0xbd, 0xbd, 2) // anewarray
+	set(0xbe, 0xbf, 0)
+	set(0xc0, 0xc1, 2) // checkcast, instanceof
+	set(0xc2, 0xc3, 0)
+	set(0xc5, 0xc5, 3) // multianewarray
+	set(0xc6, 0xc7, 2) // ifnull, ifnonnull
+	set(0xc8, 0xc9, 4) // goto_w, jsr_w
+	set(0xca, 0xca, 0) // breakpoint
+	set(0xfe, 0xff, 0) // impdep
+
+	return lengths
+}()
+
+// scanInvokes returns the constant pool indexes the invoke instructions
+// of a bytecode refer to, in order, each once
+func scanInvokes(bytecodes []byte) ([]uint16, error) {
+	var indexes []uint16
+	seen := map[uint16]bool{}
+
+	for pc := 0; pc < len(bytecodes); {
+		opcode := bytecodes[pc]
+
+		var length int
+		switch opcode {
+		case opcodeTableswitch, opcodeLookupswitch:
+			// the operands are aligned on 4 bytes from the start
+			operands := pc + 1 + (4 - (pc + 1) % 4) % 4
+			if operands + 12 > len(bytecodes) {
+				return indexes, MethodCallsError { message: fmt.Sprintf("switch cut short at %d", pc) }
+			}
+			if opcode == opcodeTableswitch {
+				low := int32(binary.BigEndian.Uint32(bytecodes[operands + 4:]))
+				high := int32(binary.BigEndian.Uint32(bytecodes[operands + 8:]))
+				length = operands - pc + 12 + int(high - low + 1) * 4
+			} else {
+				pairs := int32(binary.BigEndian.Uint32(bytecodes[operands + 4:]))
+				length = operands - pc + 8 + int(pairs) * 8
+			}
+		case opcodeWide:
+			if pc + 1 >= len(bytecodes) {
+				return indexes, MethodCallsError { message: fmt.Sprintf("wide cut short at %d", pc) }
+			}
+			length = 4
+			if bytecodes[pc + 1] == opcodeIinc {
+				length = 6
+			}
+		default:
+			if operandLengths[opcode] < 0 {
+				return indexes, MethodCallsError {
+					message: fmt.Sprintf("unknown opcode 0x%02x at %d", opcode, pc),
+				}
+			}
+			length = 1 + operandLengths[opcode]
+		}
+		if length <= 0 || pc + length > len(bytecodes) {
+			return indexes, MethodCallsError { message: fmt.Sprintf("instruction cut short at %d", pc) }
+		}
+
+		switch opcode {
+		case opcodeInvokevirtual, opcodeInvokespecial, opcodeInvokestatic, opcodeInvokeinterface:
+			index := binary.BigEndian.Uint16(bytecodes[pc + 1:])
+			if !seen[index] {
+				seen[index] = true
+				indexes = append(indexes, index)
+			}
+		}
+
+		pc += length
+	}
+
+	return indexes, nil
+}
+
+// GetCallees returns the methods the method invokes, as named in the
+// constant pool of its class; it needs the canGetBytecodes and the
+// canGetConstantPool capabilities
+func (c *Connection) GetCallees(typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID) ([]MethodRef, error) {
+	pool, err := c.GetConstantPool(typeId)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := parseMethodRefs(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.getCallees(typeId, methodId, refs)
+}
+
+func (c *Connection) getCallees(typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID, refs map[uint16]MethodRef) ([]MethodRef, error) {
+	bytecodes, err := c.GetBytecodes(typeId, methodId)
+	if err != nil {
+		return nil, err
+	}
+
+	// what could be read before an unknown instruction is kept
+	indexes, err := scanInvokes(bytecodes)
+
+	var callees []MethodRef
+	for _, index := range indexes {
+		ref, ok := refs[index]
+		if ok {
+			callees = append(callees, ref)
+		}
+	}
+
+	return callees, err
+}
+
+// ResolveMethodRef finds the class and the method a reference points to,
+// among the loaded classes; the method may be inherited, in which case
+// the class declaring it is returned. Methods of arrays aren't resolved
+func (c *Connection) ResolveMethodRef(ref MethodRef) (jdwp.ReferenceTypeID, jdwp.MethodID, error) {
+	if len(ref.Class) == 0 || ref.Class[0] == '[' {
+		return 0, 0, MethodCallsError { message: fmt.Sprintf("%s can't be resolved", ref) }
+	}
+
+	classes, err := c.GetClassesBySignature("L" + ref.Class + ";")
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(classes) == 0 {
+		return 0, 0, MethodCallsError { message: fmt.Sprintf("class of %s isn't loaded", ref) }
+	}
+
+	class := jdwp.ClassID(classes[0].TypeID)
+	for class != 0 {
+		methods, err := c.GetMethods(jdwp.ReferenceTypeID(class))
+		if err != nil {
+			return 0, 0, err
+		}
+
+		for _, method := range methods {
+			if method.Name == ref.Name && method.Signature == ref.Signature {
+				return jdwp.ReferenceTypeID(class), method.ID, nil
+			}
+		}
+
+		class, err = c.GetSuperClass(class)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return 0, 0, MethodCallsError { message: fmt.Sprintf("%s isn't declared", ref) }
+}
+
+// GetCallers goes through the given classes for the methods invoking the
+// method; only the classes whose constant pool names the method are
+// scanned. Classes which can't be read are skipped
+func (c *Connection) GetCallers(typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID, classes []jdwp.ClassInfo) ([]jdwp.Location, error) {
+	var name, signature string
+	methods, err := c.GetMethods(typeId)
+	if err != nil {
+		return nil, err
+	}
+	for _, method := range methods {
+		if method.ID == methodId {
+			name = method.Name
+			signature = method.Signature
+		}
+	}
+	if name == "" {
+		return nil, MethodCallsError { message: fmt.Sprintf("method %d isn't in class %d", methodId, typeId) }
+	}
+
+	var callers []jdwp.Location
+	for _, class := range classes {
+		pool, err := c.GetConstantPool(class.TypeID)
+		if err != nil {
+			continue
+		}
+		refs, err := parseMethodRefs(pool)
+		if err != nil {
+			continue
+		}
+
+		// a reference through a subclass resolves to the declaring
+		// class, so the ones with the same name are resolved
+		calling := map[uint16]MethodRef{}
+		for index, ref := range refs {
+			if ref.Name != name || ref.Signature != signature {
+				continue
+			}
+			refType, refMethod, err := c.ResolveMethodRef(ref)
+			if err == nil && refType == typeId && refMethod == methodId {
+				calling[index] = ref
+			}
+		}
+		if len(calling) == 0 {
+			continue
+		}
+
+		classMethods, err := c.GetMethods(class.TypeID)
+		if err != nil {
+			continue
+		}
+		for _, method := range classMethods {
+			if method.ModBits.Native() || method.ModBits.Abstract() {
+				continue
+			}
+
+			callees, _ := c.getCallees(class.TypeID, method.ID, calling)
+			if len(callees) > 0 {
+				callers = append(callers, jdwp.Location {
+					Type: class.Kind,
+					Class: jdwp.ClassID(class.TypeID),
+					Method: method.ID,
+				})
+			}
+		}
+	}
+
+	return callers, nil
+}
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"testing"
+)
+
+func TestParseMethodRefsCount(t *testing.T) {
+	var tests = []struct {
+		name string
+		count int32
+		valid bool
+	}{
+		{ name: "negative", count: -1, valid: false },
+		{ name: "zero", count: 0, valid: false },
+		{ name: "past a u2", count: 65536, valid: false },
+		{ name: "huge", count: 0x7fffffff, valid: false },
+		{ name: "empty pool", count: 1, valid: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseMethodRefs(ConstantPool { Count: test.count })
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("parsed: %v, expected %v (%v)", valid, test.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandMethodBytecodes = 3
+)
+
+// GetBytecodes returns the bytecode of a method; it needs the
+// canGetBytecodes capability
+func (c *Connection) GetBytecodes(typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID) ([]byte, error) {
+	var bytecodes []byte
+	err := c.command(commandSetMethod, commandMethodBytecodes, func(w *packetWriter) {
+		w.ReferenceTypeID(uint64(typeId))
+		w.MethodID(uint64(methodId))
+	}, func(r *packetReader) {
+		bytecodes = r.Bytes(r.Int32())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bytecodes, nil
+}
+
+// GetVariableTable returns the arguments and the local variables of a
+// method, if it was compiled with them
+func (c *Connection) GetVariableTable(typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID) (jdwp.VariableTable, error) {
+	defer c.acquire()()
+	return c.jdwp().VariableTable(typeId, methodId)
+}
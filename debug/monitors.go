@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"sort"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandSetThreadReference = 11
+
+	commandThreadOwnedMonitors = 8
+	commandThreadCurrentContendedMonitor = 9
+)
+
+//
+// Monitors
+//
+
+// GetOwnedMonitors returns the monitors owned by a suspended thread
+func (c *Connection) GetOwnedMonitors(thread jdwp.ThreadID) ([]jdwp.ObjectID, error) {
+	var monitors []jdwp.ObjectID
+	err := c.command(commandSetThreadReference, commandThreadOwnedMonitors, func(w *packetWriter) {
+		w.ObjectID(uint64(thread))
+	}, func(r *packetReader) {
+		count := r.Int32()
+		for i := int32(0); i < count && r.Error() == nil; i++ {
+			// tagged object id
+			r.Uint8()
+			monitors = append(monitors, jdwp.ObjectID(r.ObjectID()))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return monitors, nil
+}
+
+// GetCurrentContendedMonitor returns the monitor a suspended thread
+// waits for, or 0
+func (c *Connection) GetCurrentContendedMonitor(thread jdwp.ThreadID) (jdwp.ObjectID, error) {
+	var monitor jdwp.ObjectID
+	err := c.command(commandSetThreadReference, commandThreadCurrentContendedMonitor, func(w *packetWriter) {
+		w.ObjectID(uint64(thread))
+	}, func(r *packetReader) {
+		r.Uint8()
+		monitor = jdwp.ObjectID(r.ObjectID())
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return monitor, nil
+}
+
+//
+// Monitor usage, as seen from the threads
+//
+type MonitorUsage struct {
+	// 0 if none of the threads owns it
+	Owner jdwp.ThreadID
+	Waiters []jdwp.ThreadID
+}
+
+// GetMonitorUsages finds the monitors the given threads own or wait for,
+// and who owns and who waits for each; the threads must be suspended,
+// and monitors used by other threads are only partly known
+func (c *Connection) GetMonitorUsages(threads []jdwp.ThreadID) (map[jdwp.ObjectID]*MonitorUsage, error) {
+	var usages = map[jdwp.ObjectID]*MonitorUsage{}
+	usage := func(monitor jdwp.ObjectID) *MonitorUsage {
+		found, ok := usages[monitor]
+		if !ok {
+			found = &MonitorUsage{}
+			usages[monitor] = found
+		}
+		return found
+	}
+
+	for _, thread := range threads {
+		monitors, err := c.GetOwnedMonitors(thread)
+		if err != nil {
+			return nil, err
+		}
+		for _, monitor := range monitors {
+			usage(monitor).Owner = thread
+		}
+
+		monitor, err := c.GetCurrentContendedMonitor(thread)
+		if err != nil {
+			return nil, err
+		}
+		if monitor != 0 {
+			waiting := usage(monitor)
+			waiting.Waiters = append(waiting.Waiters, thread)
+		}
+	}
+
+	for _, found := range usages {
+		waiters := found.Waiters
+		sort.Slice(waiters, func(i, j int) bool { return waiters[i] < waiters[j] })
+	}
+
+	return usages, nil
+}
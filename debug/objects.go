@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Object field, together with the type declaring it
+//
+type ObjectField struct {
+	Declaring jdwp.ReferenceTypeID
+	Field jdwp.Field
+}
+
+// GetObjectFields returns the fields of an object by name, walking up
+// its class hierarchy; a name declared more than once resolves to the
+// most derived declaration
+func (c *Connection) GetObjectFields(object jdwp.ObjectID) (map[string]ObjectField, error) {
+	objectType, err := c.GetObjectType(object)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields = map[string]ObjectField{}
+	var class = jdwp.ClassID(objectType.Type)
+	for class != 0 {
+		classFields, err := c.GetFields(jdwp.ReferenceTypeID(class))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range classFields {
+			_, shadowed := fields[field.Name]
+			if shadowed {
+				continue
+			}
+
+			fields[field.Name] = ObjectField {
+				Declaring: jdwp.ReferenceTypeID(class),
+				Field: field,
+			}
+		}
+
+		// arrays and interfaces have no superclass to walk
+		if objectType.Kind != jdwp.Class {
+			break
+		}
+
+		class, err = c.GetSuperClass(class)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"errors"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandSetMethod = 6
+
+	commandMethodIsObsolete = 4
+)
+
+// IsObsolete tells if the method was replaced by redefining its class;
+// VMs which can't redefine classes may not implement it, in which case
+// no method is obsolete
+func (c *Connection) IsObsolete(typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID) (bool, error) {
+	var obsolete bool
+	err := c.command(commandSetMethod, commandMethodIsObsolete, func(w *packetWriter) {
+		w.ReferenceTypeID(uint64(typeId))
+		w.MethodID(uint64(methodId))
+	}, func(r *packetReader) {
+		obsolete = r.Bool()
+	})
+	if errors.Is(err, jdwp.ErrNotImplemented) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return obsolete, nil
+}
@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Packet writer
+// Encodes command data the way JDWP expects it: big endian, with
+// identifiers sized as negotiated with the VM
+//
+type packetWriter struct {
+	idSizes jdwp.IDSizes
+	data []byte
+	err error
+}
+
+func newPacketWriter(idSizes jdwp.IDSizes) *packetWriter {
+	return &packetWriter {
+		idSizes: idSizes,
+	}
+}
+
+func (w *packetWriter) Bytes() []byte {
+	return w.data
+}
+
+// Error returns the first encoding error, if any
+func (w *packetWriter) Error() error {
+	return w.err
+}
+
+func (w *packetWriter) Uint8(v uint8) {
+	w.data = append(w.data, v)
+}
+
+func (w *packetWriter) Bool(v bool) {
+	if v {
+		w.Uint8(1)
+	} else {
+		w.Uint8(0)
+	}
+}
+
+func (w *packetWriter) Int32(v int32) {
+	w.id(4, uint64(uint32(v)))
+}
+
+func (w *packetWriter) Uint64(v uint64) {
+	w.id(8, v)
+}
+
+func (w *packetWriter) String(v string) {
+	w.Int32(int32(len(v)))
+	w.data = append(w.data, []byte(v)...)
+}
+
+func (w *packetWriter) id(size int32, v uint64) {
+	for i := size - 1; i >= 0; i-- {
+		w.data = append(w.data, byte(v >> (8 * uint(i))))
+	}
+}
+
+func (w *packetWriter) ObjectID(v uint64) {
+	w.id(w.idSizes.ObjectIDSize, v)
+}
+
+func (w *packetWriter) ReferenceTypeID(v uint64) {
+	w.id(w.idSizes.ReferenceTypeIDSize, v)
+}
+
+func (w *packetWriter) MethodID(v uint64) {
+	w.id(w.idSizes.MethodIDSize, v)
+}
+
+func (w *packetWriter) FieldID(v uint64) {
+	w.id(w.idSizes.FieldIDSize, v)
+}
+
+func (w *packetWriter) FrameID(v uint64) {
+	w.id(w.idSizes.FrameIDSize, v)
+}
+
+// UntaggedValue writes a value without its tag, as where the type is
+// known from the field or the variable it goes to
+func (w *packetWriter) UntaggedValue(value jdwp.Value) {
+	switch v := value.(type) {
+	case bool:
+		w.Bool(v)
+	case uint8:
+		w.Uint8(v)
+	case jdwp.Char:
+		w.id(2, uint64(v))
+	case int16:
+		w.id(2, uint64(uint16(v)))
+	case int:
+		w.Int32(int32(v))
+	case int64:
+		w.Uint64(uint64(v))
+	case float32:
+		w.id(4, uint64(math.Float32bits(v)))
+	case float64:
+		w.Uint64(math.Float64bits(v))
+	case jdwp.ObjectID:
+		w.ObjectID(uint64(v))
+	case jdwp.ArrayID:
+		w.ObjectID(uint64(v))
+	case jdwp.StringID:
+		w.ObjectID(uint64(v))
+	default:
+		if w.err == nil {
+			w.err = CommandChannelError {
+				message: fmt.Sprintf("unable to encode a value of type %T", value),
+			}
+		}
+	}
+}
+
+//
+// Packet reader
+//
+type packetReader struct {
+	idSizes jdwp.IDSizes
+	data []byte
+	err error
+}
+
+func newPacketReader(idSizes jdwp.IDSizes, data []byte) *packetReader {
+	return &packetReader {
+		idSizes: idSizes,
+		data: data,
+	}
+}
+
+// Error returns the first decoding error, if any
+func (r *packetReader) Error() error {
+	return r.err
+}
+
+// take returns the next n bytes, or nil once the reply is found short;
+// n may come from the VM, so nothing is allocated after it
+func (r *packetReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if n < 0 || len(r.data) < n {
+		r.err = CommandChannelError {
+			message: fmt.Sprintf("reply too short, wanted %d bytes, %d left", n, len(r.data)),
+		}
+		return nil
+	}
+
+	taken := r.data[:n]
+	r.data = r.data[n:]
+
+	return taken
+}
+
+// fixed takes a value of a known size, zeroed if the reply is short
+func (r *packetReader) fixed(n int) []byte {
+	taken := r.take(n)
+	if taken == nil {
+		return make([]byte, n)
+	}
+
+	return taken
+}
+
+func (r *packetReader) Uint8() uint8 {
+	return r.fixed(1)[0]
+}
+
+func (r *packetReader) Bool() bool {
+	return r.Uint8() != 0
+}
+
+func (r *packetReader) Int32() int32 {
+	return int32(binary.BigEndian.Uint32(r.fixed(4)))
+}
+
+func (r *packetReader) Uint64() uint64 {
+	return binary.BigEndian.Uint64(r.fixed(8))
+}
+
+func (r *packetReader) Bytes(n int32) []byte {
+	return r.take(int(n))
+}
+
+func (r *packetReader) String() string {
+	return string(r.take(int(r.Int32())))
+}
+
+func (r *packetReader) id(size int32) uint64 {
+	var v uint64
+	for _, b := range r.take(int(size)) {
+		v = (v << 8) | uint64(b)
+	}
+
+	return v
+}
+
+func (r *packetReader) ObjectID() uint64 {
+	return r.id(r.idSizes.ObjectIDSize)
+}
+
+func (r *packetReader) ReferenceTypeID() uint64 {
+	return r.id(r.idSizes.ReferenceTypeIDSize)
+}
+
+func (r *packetReader) MethodID() uint64 {
+	return r.id(r.idSizes.MethodIDSize)
+}
+
+func (r *packetReader) FieldID() uint64 {
+	return r.id(r.idSizes.FieldIDSize)
+}
+
+func (r *packetReader) FrameID() uint64 {
+	return r.id(r.idSizes.FrameIDSize)
+}
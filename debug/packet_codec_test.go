@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestPacketReaderShort(t *testing.T) {
+	var tests = []struct {
+		name string
+		data []byte
+		read func(r *packetReader)
+	}{
+		{ name: "negative string", data: fakeInt(0xffffffff), read: func(r *packetReader) { _ = r.String() } },
+		{ name: "negative bytes", data: fakeInt(0x80000000), read: func(r *packetReader) { r.Bytes(r.Int32()) } },
+		{ name: "huge string", data: fakeInt(0x7fffffff), read: func(r *packetReader) { _ = r.String() } },
+		{
+			name: "reads after the end",
+			data: []byte{1},
+			read: func(r *packetReader) {
+				r.Uint64()
+				r.Uint8()
+				r.Int32()
+				r.ObjectID()
+				_ = r.String()
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newPacketReader(jdwp.IDSizes { ObjectIDSize: 8 }, test.data)
+			test.read(r)
+			if r.Error() == nil {
+				t.Fatalf("short reply read without an error")
+			}
+		})
+	}
+}
@@ -7,12 +7,14 @@ import (
 	"os"
 	"fmt"
 	"plugin"
+	"sync"
 
 	jdwp "github.com/omerye/gojdb/jdwp"
 )
 
 const (
 	PluginEntrypoint = "JdwpfsPluginEntrypoint"
+	PluginShutdown = "JdwpfsPluginShutdown"
 )
 
 //
@@ -44,6 +46,15 @@ func (e PluginError) Error() string {
 	return fmt.Sprintf("plugin error: %s", e.message)
 }
 
+// a hook is reloaded while another reload is still going on
+type PluginBusyError struct {
+	message string
+}
+
+func (e PluginBusyError) Error() string {
+	return fmt.Sprintf("plugin busy: %s", e.message)
+}
+
 type PluginErrors struct {
 	errors []PluginError
 }
@@ -81,19 +92,67 @@ type PluginInstance struct {
 	pluginPath string
 	plugin *plugin.Plugin
 	entrypoint func(string, jdwp.Event) error
+	// optional, nil if the plugin doesn't export it
+	shutdown func(string) error
+}
+
+// openPluginInstance opens a plugin, and resolves its functions
+func openPluginInstance(pluginName string, pluginPath string) (*PluginInstance, error) {
+	newPlugin, err := plugin.Open(pluginPath)
+	if err != nil {
+		return nil, PluginBuilderError{ message: "unable to open plugin", err: err }
+	}
+
+	entrypointSymbol, err := newPlugin.Lookup(PluginEntrypoint)
+	if err != nil {
+		return nil, PluginBuilderError{ message: "unable to find symbol in plugin", err: err }
+	}
+
+	entrypoint, ok := entrypointSymbol.(func(string, jdwp.Event) error)
+	if !ok {
+		return nil, PluginBuilderError{ message: "entrypoint symbol has the wrong type", err: nil }
+	}
+
+	var shutdown func(string) error = nil
+	shutdownSymbol, err := newPlugin.Lookup(PluginShutdown)
+	if err == nil {
+		shutdown, ok = shutdownSymbol.(func(string) error)
+		if !ok {
+			return nil, PluginBuilderError{ message: "shutdown symbol has the wrong type", err: nil }
+		}
+	}
+
+	return &PluginInstance {
+		name: pluginName,
+		pluginPath: pluginPath,
+		plugin: newPlugin,
+		entrypoint: entrypoint,
+		shutdown: shutdown,
+	}, nil
 }
 
 //
 // PluginRunner
+// The plugins are swapped rather than changed, so the events being
+// handled keep the instances they started with
 //
 type PluginRunner struct {
+	mu sync.RWMutex
 	plugins []*PluginInstance
+	reloading bool
+}
+
+func (r *PluginRunner) instances() []*PluginInstance {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.plugins
 }
 
-func (r PluginRunner) Entrypoint(event jdwp.Event) error {
+func (r *PluginRunner) Entrypoint(event jdwp.Event) error {
 	var finalResult = NewPluginErrors()
 
-	for _, pluginInstance := range r.plugins {
+	for _, pluginInstance := range r.instances() {
 		err := pluginInstance.entrypoint(pluginInstance.name, event)
 		if err != nil {
 			pluginErr := PluginError {
@@ -111,6 +170,79 @@ func (r PluginRunner) Entrypoint(event jdwp.Event) error {
 	return nil
 }
 
+// Shutdown lets every plugin exporting a shutdown function release
+// its resources; it's called once the event is cancelled
+func (r *PluginRunner) Shutdown() error {
+	var finalResult = NewPluginErrors()
+
+	for _, pluginInstance := range r.instances() {
+		if pluginInstance.shutdown == nil {
+			continue
+		}
+
+		err := pluginInstance.shutdown(pluginInstance.name)
+		if err != nil {
+			pluginErr := PluginError {
+				message: "error shutting down plugin",
+				err: err,
+			}
+			finalResult.AddError(pluginErr)
+		}
+	}
+
+	if finalResult.HasErrors() {
+		return finalResult
+	}
+
+	return nil
+}
+
+// Reload opens the plugin of a hook again, and swaps it in. Go plugins
+// can't be unloaded, so the same file gives back the same plugin; only
+// its functions are resolved again. One hook is reloaded at a time
+func (r *PluginRunner) Reload(name string) error {
+	r.mu.Lock()
+	if r.reloading {
+		r.mu.Unlock()
+		return PluginBusyError{ message: fmt.Sprintf("already reloading a hook, not %s", name) }
+	}
+
+	var current *PluginInstance
+	for _, pluginInstance := range r.plugins {
+		if pluginInstance.name == name {
+			current = pluginInstance
+			break
+		}
+	}
+	if current == nil {
+		r.mu.Unlock()
+		return PluginError{ message: fmt.Sprintf("no hook named %s", name) }
+	}
+	r.reloading = true
+	r.mu.Unlock()
+
+	reloaded, err := openPluginInstance(current.name, current.pluginPath)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reloading = false
+
+	if err != nil {
+		return err
+	}
+
+	var plugins = []*PluginInstance{}
+	for _, pluginInstance := range r.plugins {
+		if pluginInstance == current {
+			pluginInstance = reloaded
+		}
+		plugins = append(plugins, pluginInstance)
+	}
+	r.plugins = plugins
+
+	return nil
+}
+
 //
 // PluginRunnerBuilder
 //
@@ -143,23 +275,9 @@ func (b *PluginRunnerBuilder) Build() (*PluginRunner, error) {
 	var newInstances = []*PluginInstance {}
 	
 	for pluginName, pluginPath := range b.pluginPaths {
-		newPlugin, err := plugin.Open(pluginPath)
-		if err != nil {
-			return nil, PluginBuilderError{ message: "unable to open plugin", err: err }
-		}
-
-		entrypointSymbol, err := newPlugin.Lookup(PluginEntrypoint)
+		newInstance, err := openPluginInstance(pluginName, pluginPath)
 		if err != nil {
-			return nil, PluginBuilderError{ message: "unable to find symbol in plugin", err: err }
-		}
-
-		entrypoint := entrypointSymbol.(func(string, jdwp.Event) error)
-
-		newInstance := &PluginInstance {
-			name: pluginName,
-			pluginPath: pluginPath,
-			plugin: newPlugin,
-			entrypoint: entrypoint,
+			return nil, err
 		}
 
 		newInstances = append(newInstances, newInstance)
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// shutdownRecorder records the plugins shut down
+type shutdownRecorder struct {
+	mu sync.Mutex
+	names []string
+}
+
+func (r *shutdownRecorder) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := append([]string{}, r.names...)
+	sort.Strings(names)
+	return names
+}
+
+// stub makes a plugin which records its shutdown, failing it with err,
+// or a plugin not exporting a shutdown function at all
+func (r *shutdownRecorder) stub(name string, exported bool, err error) *PluginInstance {
+	instance := &PluginInstance {
+		name: name,
+		entrypoint: func(string, jdwp.Event) error { return nil },
+	}
+	if exported {
+		instance.shutdown = func(name string) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			r.names = append(r.names, name)
+			return err
+		}
+	}
+	return instance
+}
+
+func TestPluginRunnerShutdown(t *testing.T) {
+	type stubPlugin struct {
+		name string
+		exported bool
+		err error
+	}
+
+	var tests = []struct {
+		name string
+		plugins []stubPlugin
+		shutDown []string
+		fails bool
+	}{
+		{ name: "no plugins", shutDown: []string{} },
+		{
+			name: "exported",
+			plugins: []stubPlugin {
+				{ name: "first", exported: true },
+				{ name: "second", exported: true },
+			},
+			shutDown: []string { "first", "second" },
+		},
+		{
+			name: "not exported",
+			plugins: []stubPlugin {
+				{ name: "first", exported: true },
+				{ name: "legacy", exported: false },
+			},
+			shutDown: []string { "first" },
+		},
+		{
+			name: "failed shutdown",
+			plugins: []stubPlugin {
+				{ name: "failing", exported: true, err: errors.New("unable to flush") },
+				{ name: "second", exported: true },
+			},
+			shutDown: []string { "failing", "second" },
+			fails: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &shutdownRecorder{}
+			runner := &PluginRunner{}
+			for _, plugin := range test.plugins {
+				runner.plugins = append(runner.plugins, recorder.stub(plugin.name, plugin.exported, plugin.err))
+			}
+
+			err := runner.Shutdown()
+			if (err != nil) != test.fails {
+				t.Fatalf("got error %v, expected a failure: %v", err, test.fails)
+			}
+			if names := recorder.Names(); !reflect.DeepEqual(names, test.shutDown) {
+				t.Fatalf("shut down %v, expected %v", names, test.shutDown)
+			}
+		})
+	}
+}
+
+func TestCancelShutsPluginsDown(t *testing.T) {
+	conn, _ := startFakeVM(t, eventRequestHandler)
+	event := NewStubDebuggingEvent("shutdown")
+	event.SetKind(jdwp.ThreadStart)
+	event.SetConn(conn)
+
+	if _, err := event.Run(); err != nil {
+		t.Fatalf("run: %s", err)
+	}
+
+	recorder := &shutdownRecorder{}
+	event.mu.RLock()
+	runner := event.runner
+	event.mu.RUnlock()
+	runner.mu.Lock()
+	runner.plugins = []*PluginInstance {
+		recorder.stub("hook", true, nil),
+		recorder.stub("legacy", false, nil),
+	}
+	runner.mu.Unlock()
+
+	if len(recorder.Names()) != 0 {
+		t.Fatalf("shut down while running")
+	}
+	if err := event.Cancel(); err != nil {
+		t.Fatalf("cancel: %s", err)
+	}
+	if names := recorder.Names(); !reflect.DeepEqual(names, []string { "hook" }) {
+		t.Fatalf("shut down %v on cancel", names)
+	}
+}
+
+// runningWithHooks runs an event whose runner holds a stub for each hook;
+// the stubs point at plugins which can't be opened
+func runningWithHooks(t *testing.T, names ...string) (*DebuggingEvent, *PluginRunner) {
+	t.Helper()
+
+	conn, _ := startFakeVM(t, eventRequestHandler)
+	event := NewStubDebuggingEvent("reload")
+	event.SetKind(jdwp.ThreadStart)
+	event.SetConn(conn)
+
+	if _, err := event.Run(); err != nil {
+		t.Fatalf("run: %s", err)
+	}
+	t.Cleanup(func() { event.Cancel() })
+
+	recorder := &shutdownRecorder{}
+	event.mu.RLock()
+	runner := event.runner
+	event.mu.RUnlock()
+	runner.mu.Lock()
+	runner.plugins = []*PluginInstance{}
+	for _, name := range names {
+		instance := recorder.stub(name, false, nil)
+		instance.pluginPath = t.TempDir() + "/" + name + ".so"
+		runner.plugins = append(runner.plugins, instance)
+		event.SetHookDescriptor(name, instance.pluginPath)
+	}
+	runner.mu.Unlock()
+
+	return event, runner
+}
+
+func TestReloadHook(t *testing.T) {
+	var tests = []struct {
+		name string
+		hook string
+		reloading bool
+		err error
+	}{
+		{ name: "unknown hook", hook: "missing", err: JdwpDebuggingEventError{} },
+		{ name: "while reloading", hook: "first", reloading: true, err: PluginBusyError{} },
+		{ name: "plugin gone", hook: "first", err: PluginBuilderError{} },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, runner := runningWithHooks(t, "first", "second")
+			before := runner.instances()
+			runner.mu.Lock()
+			runner.reloading = test.reloading
+			runner.mu.Unlock()
+
+			err := event.ReloadHook(test.hook)
+			if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+				t.Fatalf("got error %T (%v), expected %T", err, err, test.err)
+			}
+
+			// a failed reload keeps every hook as it was
+			if after := runner.instances(); !reflect.DeepEqual(after, before) {
+				t.Fatalf("the hooks changed: %v, before %v", after, before)
+			}
+			runner.mu.RLock()
+			reloading := runner.reloading
+			runner.mu.RUnlock()
+			if reloading != test.reloading {
+				t.Fatalf("reloading left at %v", reloading)
+			}
+		})
+	}
+}
+
+func TestReloadHookNotRunning(t *testing.T) {
+	event := NewStubDebuggingEvent("stopped")
+	event.SetHookDescriptor("hook", "/nonexistent/hook.so")
+
+	// loaded when the event is run anyway
+	if err := event.ReloadHook("hook"); err != nil {
+		t.Fatalf("reloading the hook of a stopped event: %s", err)
+	}
+	if err := event.ReloadHook("missing"); err == nil {
+		t.Fatalf("reloaded a missing hook")
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"syscall"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// SetReadRetries sets how many times a read which can be repeated
+// safely, such as listing the threads, is retried before its error is
+// returned; 0 means no retries
+func (c *Connection) SetReadRetries(retries int) {
+	c.cmu.Lock()
+	defer c.cmu.Unlock()
+
+	if retries < 0 {
+		retries = 0
+	}
+	c.retries = retries
+}
+
+// retry runs read again as long as it fails with an error which may go
+// away, such as the VM being busy, and there are retries left
+func (c *Connection) retry(read func() error) error {
+	c.cmu.RLock()
+	retries := c.retries
+	c.cmu.RUnlock()
+
+	err := read()
+	for attempt := 0; err != nil && attempt < retries && isTransient(err); attempt++ {
+		log.Printf("retrying after a JDWP error: %s\n", err)
+		err = read()
+	}
+
+	return err
+}
+
+// isTransient tells if an error may go away when retrying: the VM
+// answering with an error, unless it tells an id is invalid, or that
+// it's gone, or the call not reaching it, or not being answered in time.
+// Other errors, such as replies which can't be decoded, won't change
+func isTransient(err error) bool {
+	var code jdwp.Error
+	if !errors.As(err, &code) {
+		return isIoError(err)
+	}
+
+	switch code {
+	case jdwp.ErrInvalidThread,
+		jdwp.ErrInvalidThreadGroup,
+		jdwp.ErrInvalidObject,
+		jdwp.ErrInvalidClass,
+		jdwp.ErrInvalidMethodID,
+		jdwp.ErrInvalidFieldID,
+		jdwp.ErrAbsentInformation,
+		jdwp.ErrNotImplemented,
+		jdwp.ErrVMDead:
+		return false
+	}
+
+	return true
+}
+
+// isIoError tells if a call failed on the connection to the VM, or
+// timed out waiting for its answer
+func isIoError(err error) bool {
+	var timeout interface { Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	var errno syscall.Errno
+	switch {
+	case errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, io.ErrClosedPipe),
+		errors.Is(err, net.ErrClosed),
+		errors.As(err, &opErr),
+		errors.As(err, &errno):
+		return true
+	}
+
+	// gojdb gives up waiting for a reply with an error of its own
+	return err.Error() == "timeout"
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestIsTransient(t *testing.T) {
+	var tests = []struct {
+		name string
+		err error
+		transient bool
+	}{
+		{ name: "vm busy", err: jdwp.ErrInternal, transient: true },
+		{ name: "invalid thread", err: jdwp.ErrInvalidThread, transient: false },
+		{ name: "vm dead", err: jdwp.ErrVMDead, transient: false },
+		{ name: "connection closed", err: io.EOF, transient: true },
+		{ name: "short reply", err: fmt.Errorf("%v after reading %d bytes", io.ErrUnexpectedEOF, 3), transient: false },
+		{ name: "wrapped short read", err: fmt.Errorf("reading: %w", io.ErrUnexpectedEOF), transient: true },
+		{ name: "connection reset", err: &net.OpError { Op: "read", Err: syscall.ECONNRESET }, transient: true },
+		{ name: "deadline", err: os.ErrDeadlineExceeded, transient: true },
+		{ name: "channel timeout", err: CommandChannelError { message: "timeout", timeout: true }, transient: true },
+		{ name: "channel write", err: CommandChannelError { err: io.ErrClosedPipe }, transient: true },
+		{ name: "channel framing", err: CommandChannelError { message: "incoming packet length too short (3)" }, transient: false },
+		{ name: "gojdb timeout", err: errors.New("timeout"), transient: true },
+		{ name: "decoding", err: errors.New("Unhandled value type 0"), transient: false },
+	}
+
+	for _, test := range tests {
+		if transient := isTransient(test.err); transient != test.transient {
+			t.Errorf("%s: transient %v, expected %v", test.name, transient, test.transient)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"strings"
+)
+
+// ClassNameFromSignature turns a JNI class signature, such as
+// "Ljava/lang/String;", into its Java name, "java.lang.String"; other
+// signatures are returned as they are
+func ClassNameFromSignature(signature string) string {
+	if !strings.HasPrefix(signature, "L") || !strings.HasSuffix(signature, ";") {
+		return signature
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(signature, "L"), ";")
+
+	return strings.ReplaceAll(name, "/", ".")
+}
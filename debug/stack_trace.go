@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"fmt"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+//
+// Stack trace
+//
+type StackTraceElement struct {
+	ClassName string
+	MethodName string
+	CodeIndex uint64
+}
+
+func (e StackTraceElement) String() string {
+	return fmt.Sprintf("at %s.%s(%d)", e.ClassName, e.MethodName, e.CodeIndex)
+}
+
+// GetStackTrace resolves the frames of a suspended thread, top first
+func (c *Connection) GetStackTrace(thread jdwp.ThreadID) ([]StackTraceElement, error) {
+	frames, err := c.GetFrames(thread, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// frames often share their classes
+	var classNames = map[jdwp.ClassID]string{}
+	var methodNames = map[jdwp.ClassID]map[jdwp.MethodID]string{}
+
+	var elements []StackTraceElement
+	for _, frame := range frames {
+		location := frame.Location
+
+		className, ok := classNames[location.Class]
+		if !ok {
+			signature, err := c.GetTypeSignature(jdwp.ReferenceTypeID(location.Class))
+			if err != nil {
+				return nil, err
+			}
+			className = ClassNameFromSignature(signature)
+			classNames[location.Class] = className
+		}
+
+		names, ok := methodNames[location.Class]
+		if !ok {
+			methods, err := c.GetMethods(jdwp.ReferenceTypeID(location.Class))
+			if err != nil {
+				return nil, err
+			}
+
+			names = map[jdwp.MethodID]string{}
+			for _, method := range methods {
+				names[method.ID] = method.Name
+			}
+			methodNames[location.Class] = names
+		}
+
+		methodName, ok := names[location.Method]
+		if !ok {
+			methodName = fmt.Sprintf("<method %d>", location.Method)
+		}
+
+		elements = append(elements, StackTraceElement {
+			ClassName: className,
+			MethodName: methodName,
+			CodeIndex: location.Location,
+		})
+	}
+
+	return elements, nil
+}
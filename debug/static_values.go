@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandSetClassType = 3
+
+	commandClassTypeSetValues = 2
+)
+
+// SetStaticFieldValue sets a static field of a class; the value has to
+// be of the type of the field, as ParseValue gives it
+func (c *Connection) SetStaticFieldValue(class jdwp.ReferenceTypeID, field jdwp.FieldID, value jdwp.Value) error {
+	return c.command(commandSetClassType, commandClassTypeSetValues, func(w *packetWriter) {
+		w.ReferenceTypeID(uint64(class))
+		w.Int32(1)
+		w.FieldID(uint64(field))
+		w.UntaggedValue(value)
+	}, nil)
+}
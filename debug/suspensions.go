@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandThreadSuspendCount = 12
+)
+
+//
+// Suspension ownership
+// JDWP suspensions are counted; the ones made through the connection
+// are tracked, so they can be told apart from the ones made by events
+// or by other debuggers, and undone
+//
+func (c *Connection) ownSuspension(id jdwp.ThreadID) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	if c.suspensions == nil {
+		c.suspensions = map[jdwp.ThreadID]int{}
+	}
+	c.suspensions[id]++
+	c.suspendChanged(id)
+}
+
+func (c *Connection) disownSuspension(id jdwp.ThreadID) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	c.suspendChanged(id)
+
+	if c.suspensions[id] <= 1 {
+		delete(c.suspensions, id)
+		return
+	}
+	c.suspensions[id]--
+}
+
+func (c *Connection) ownAllSuspension() {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	c.allSuspensions++
+	c.suspendAllChange = time.Now()
+}
+
+// disownAllSuspension undoes a SuspendAll; without one, resuming all the
+// threads undoes one suspension of each thread instead
+func (c *Connection) disownAllSuspension() {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	c.suspendAllChange = time.Now()
+	if c.allSuspensions > 0 {
+		c.allSuspensions--
+		return
+	}
+
+	for id, count := range c.suspensions {
+		if count <= 1 {
+			delete(c.suspensions, id)
+		} else {
+			c.suspensions[id] = count - 1
+		}
+	}
+}
+
+// suspendChanged records the time of a suspension or a resumption,
+// under smu
+func (c *Connection) suspendChanged(id jdwp.ThreadID) {
+	if c.suspendChanges == nil {
+		c.suspendChanges = map[jdwp.ThreadID]time.Time{}
+	}
+	c.suspendChanges[id] = time.Now()
+}
+
+// GetSuspendChangedAt returns when the thread was last suspended or
+// resumed through the connection, on its own or with all the others;
+// the zero time if it never was
+func (c *Connection) GetSuspendChangedAt(id jdwp.ThreadID) time.Time {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	changedAt := c.suspendChanges[id]
+	if c.suspendAllChange.After(changedAt) {
+		changedAt = c.suspendAllChange
+	}
+
+	return changedAt
+}
+
+// GetSuspendAllChangedAt returns when any thread was last suspended or
+// resumed through the connection
+func (c *Connection) GetSuspendAllChangedAt() time.Time {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	changedAt := c.suspendAllChange
+	for _, threadChangedAt := range c.suspendChanges {
+		if threadChangedAt.After(changedAt) {
+			changedAt = threadChangedAt
+		}
+	}
+
+	return changedAt
+}
+
+// GetOwnedSuspensions returns how many times each thread was suspended
+// through the connection, and how many times all of them were
+func (c *Connection) GetOwnedSuspensions() (map[jdwp.ThreadID]int, int) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	var suspensions = map[jdwp.ThreadID]int{}
+	for id, count := range c.suspensions {
+		suspensions[id] = count
+	}
+
+	return suspensions, c.allSuspensions
+}
+
+// GetOwnedSuspensionCount returns how many of the suspensions of a
+// thread were made through the connection, on its own or with all the
+// others
+func (c *Connection) GetOwnedSuspensionCount(id jdwp.ThreadID) int {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	return c.suspensions[id] + c.allSuspensions
+}
+
+// GetSuspendCount returns how many times the thread is suspended, by
+// anyone; it has to be resumed as many times to run
+func (c *Connection) GetSuspendCount(id jdwp.ThreadID) (int32, error) {
+	var count int32
+	err := c.command(commandSetThreadReference, commandThreadSuspendCount, func(w *packetWriter) {
+		w.ObjectID(uint64(id))
+	}, func(r *packetReader) {
+		count = r.Int32()
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// HasOwnedSuspensions tells if any suspension made through the
+// connection is still in place
+func (c *Connection) HasOwnedSuspensions() bool {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	return c.allSuspensions > 0 || len(c.suspensions) > 0
+}
+
+// ResumeOwned undoes all the suspensions made through the connection,
+// leaving the others in place; a thread which can't be resumed (e.g. it
+// died) is forgotten, and the first error is returned
+func (c *Connection) ResumeOwned() error {
+	suspensions, allSuspensions := c.GetOwnedSuspensions()
+
+	for i := 0; i < allSuspensions; i++ {
+		err := c.ResumeAll()
+		if err != nil {
+			return err
+		}
+	}
+
+	var firstErr error
+	for id, count := range suspensions {
+		for i := 0; i < count; i++ {
+			err := c.Resume(id)
+			if err != nil {
+				c.forgetSuspensions(id)
+				if firstErr == nil {
+					firstErr = err
+				}
+				break
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func (c *Connection) forgetSuspensions(id jdwp.ThreadID) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+
+	delete(c.suspensions, id)
+}
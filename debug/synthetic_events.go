@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"fmt"
+	"log"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// SyntheticEvent makes up an event of a kind, with every id 0, for the
+// hooks to be tried without the VM firing one
+func SyntheticEvent(kind jdwp.EventKind) (jdwp.Event, error) {
+	switch kind {
+	case jdwp.VMStart:
+		return &jdwp.EventVMStart{}, nil
+	case jdwp.VMDeath:
+		return &jdwp.EventVMDeath{}, nil
+	case jdwp.SingleStep:
+		return &jdwp.EventSingleStep{}, nil
+	case jdwp.Breakpoint:
+		return &jdwp.EventBreakpoint{}, nil
+	case jdwp.MethodEntry:
+		return &jdwp.EventMethodEntry{}, nil
+	case jdwp.MethodExit:
+		return &jdwp.EventMethodExit{}, nil
+	case jdwp.Exception:
+		return &jdwp.EventException{}, nil
+	case jdwp.ThreadStart:
+		return &jdwp.EventThreadStart{}, nil
+	case jdwp.ThreadDeath:
+		return &jdwp.EventThreadDeath{}, nil
+	case jdwp.ClassPrepare:
+		return &jdwp.EventClassPrepare{}, nil
+	case jdwp.ClassUnload:
+		return &jdwp.EventClassUnload{}, nil
+	case jdwp.FieldAccess:
+		return &jdwp.EventFieldAccess{}, nil
+	case jdwp.FieldModification:
+		return &jdwp.EventFieldModification{}, nil
+	default:
+		return nil, JdwpDebuggingEventError {
+			message: fmt.Sprintf("no synthetic event of kind %s", kind),
+		}
+	}
+}
+
+// FireTest hands the hooks a synthetic event of the kind of the event;
+// neither the VM nor the log and stream of the event see it. A running
+// event uses its plugins, taking its turn with the events of the VM, and
+// the run isn't torn down before the test is over; an idle one loads the
+// hooks it has for the test, without keeping the event from running
+// meanwhile
+func (e *DebuggingEvent) FireTest() error {
+	e.mu.RLock()
+	kind := e.kind
+	runner := e.runner
+	current := e.currentRun
+	var hookDescriptors = map[string]string {}
+	for hookName, hookPath := range e.hookDescriptors {
+		hookDescriptors[hookName] = hookPath
+	}
+	if current != nil {
+		current.users.Add(1)
+		defer current.users.Done()
+	}
+	e.mu.RUnlock()
+
+	event, err := SyntheticEvent(kind)
+	if err != nil {
+		return err
+	}
+
+	if current != nil {
+		current.hooks.Lock()
+		defer current.hooks.Unlock()
+
+		log.Printf("firing a synthetic %s event for event %s\n", kind, e.Name)
+		return runner.Entrypoint(event)
+	}
+
+	var builder = NewPluginRunnerBuilder()
+	for hookName, hookPath := range hookDescriptors {
+		err := builder.AddLocation(hookName, hookPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	runner, err = builder.Build()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := runner.Shutdown()
+		if err != nil {
+			log.Printf("event %s plugins failed to shut down after the test: %s\n", e.Name, err)
+		}
+	}()
+
+	log.Printf("firing a synthetic %s event for event %s\n", kind, e.Name)
+	return runner.Entrypoint(event)
+}
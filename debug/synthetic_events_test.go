@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestSyntheticEvent(t *testing.T) {
+	var tests = []struct {
+		kind jdwp.EventKind
+		valid bool
+	}{
+		{ kind: jdwp.VMStart, valid: true },
+		{ kind: jdwp.Breakpoint, valid: true },
+		{ kind: jdwp.MethodEntry, valid: true },
+		{ kind: jdwp.ClassPrepare, valid: true },
+		{ kind: jdwp.FieldModification, valid: true },
+		{ kind: jdwp.EventKind(0), valid: false },
+		{ kind: jdwp.EventKind(42), valid: false },
+	}
+
+	for _, test := range tests {
+		event, err := SyntheticEvent(test.kind)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("kind %d: expected an error, got %T", test.kind, event)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("kind %s: %s", test.kind, err)
+			continue
+		}
+		if event.Kind() != test.kind {
+			t.Errorf("kind %s: got an event of kind %s", test.kind, event.Kind())
+		}
+	}
+}
+
+// probePlugin tells whether it was shut down while handling an event
+type probePlugin struct {
+	handling int32
+	overlapped int32
+}
+
+func (p *probePlugin) instance() *PluginInstance {
+	return &PluginInstance {
+		name: "probe",
+		entrypoint: func(name string, event jdwp.Event) error {
+			atomic.AddInt32(&p.handling, 1)
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&p.handling, -1)
+			return nil
+		},
+		shutdown: func(name string) error {
+			if atomic.LoadInt32(&p.handling) != 0 {
+				atomic.StoreInt32(&p.overlapped, 1)
+			}
+			return nil
+		},
+	}
+}
+
+func TestFireTestCancel(t *testing.T) {
+	const runs = 20
+	const testers = 4
+
+	conn, _ := startFakeVM(t, eventRequestHandler)
+	event := NewStubDebuggingEvent("fire")
+	event.SetKind(jdwp.ThreadStart)
+	event.SetConn(conn)
+
+	probe := &probePlugin{}
+	for i := 1; i <= runs; i++ {
+		if _, err := event.Run(); err != nil {
+			t.Fatalf("run %d: %s", i, err)
+		}
+
+		// the running event hands its events to the probe only
+		event.mu.RLock()
+		runner := event.runner
+		event.mu.RUnlock()
+		runner.mu.Lock()
+		runner.plugins = []*PluginInstance { probe.instance() }
+		runner.mu.Unlock()
+
+		var wg sync.WaitGroup
+		for j := 0; j < testers; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := event.FireTest(); err != nil {
+					t.Errorf("run %d: fire test: %s", i, err)
+				}
+			}()
+		}
+
+		// cancel while a test is handled
+		deadline := time.Now().Add(10 * time.Second)
+		for atomic.LoadInt32(&probe.handling) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("run %d: no test reached the plugins", i)
+			}
+			time.Sleep(10 * time.Microsecond)
+		}
+
+		if err := event.Cancel(); err != nil {
+			t.Fatalf("run %d: cancel: %s", i, err)
+		}
+		wg.Wait()
+
+		if atomic.LoadInt32(&probe.overlapped) != 0 {
+			t.Fatalf("run %d: the plugins were shut down during a test", i)
+		}
+	}
+}
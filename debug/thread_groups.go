@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	commandTopLevelThreadGroups = 5
+
+	commandSetThreadGroupReference = 12
+
+	commandThreadGroupName = 1
+	commandThreadGroupParent = 2
+	commandThreadGroupChildren = 3
+)
+
+//
+// Thread groups
+// gojdb doesn't know about them, so they're queried through the
+// command channel
+//
+func (c *Connection) GetTopLevelThreadGroups() ([]jdwp.ThreadGroupID, error) {
+	var groups []jdwp.ThreadGroupID
+	err := c.command(commandSetVirtualMachine, commandTopLevelThreadGroups, nil, func(r *packetReader) {
+		count := r.Int32()
+		for i := int32(0); i < count && r.Error() == nil; i++ {
+			groups = append(groups, jdwp.ThreadGroupID(r.ObjectID()))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func (c *Connection) GetThreadGroupName(id jdwp.ThreadGroupID) (string, error) {
+	var name string
+	err := c.command(commandSetThreadGroupReference, commandThreadGroupName, func(w *packetWriter) {
+		w.ObjectID(uint64(id))
+	}, func(r *packetReader) {
+		name = r.String()
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// GetThreadGroupParent returns the parent group, 0 for a top level group
+func (c *Connection) GetThreadGroupParent(id jdwp.ThreadGroupID) (jdwp.ThreadGroupID, error) {
+	var parent jdwp.ThreadGroupID
+	err := c.command(commandSetThreadGroupReference, commandThreadGroupParent, func(w *packetWriter) {
+		w.ObjectID(uint64(id))
+	}, func(r *packetReader) {
+		parent = jdwp.ThreadGroupID(r.ObjectID())
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return parent, nil
+}
+
+// GetThreadGroupChildren returns the live threads and the active
+// subgroups of a group
+func (c *Connection) GetThreadGroupChildren(id jdwp.ThreadGroupID) ([]jdwp.ThreadID, []jdwp.ThreadGroupID, error) {
+	var threads []jdwp.ThreadID
+	var groups []jdwp.ThreadGroupID
+	err := c.command(commandSetThreadGroupReference, commandThreadGroupChildren, func(w *packetWriter) {
+		w.ObjectID(uint64(id))
+	}, func(r *packetReader) {
+		threadCount := r.Int32()
+		for i := int32(0); i < threadCount && r.Error() == nil; i++ {
+			threads = append(threads, jdwp.ThreadID(r.ObjectID()))
+		}
+
+		groupCount := r.Int32()
+		for i := int32(0); i < groupCount && r.Error() == nil; i++ {
+			groups = append(groups, jdwp.ThreadGroupID(r.ObjectID()))
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return threads, groups, nil
+}
+
+// GetAllThreadGroups walks the thread group tree, parents first
+func (c *Connection) GetAllThreadGroups() ([]jdwp.ThreadGroupID, error) {
+	groups, err := c.GetTopLevelThreadGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(groups); i++ {
+		_, children, err := c.GetThreadGroupChildren(groups[i])
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, children...)
+	}
+
+	return groups, nil
+}
+
+// GetThreadGroupThreads returns the threads of a group, and of all its
+// subgroups if recursive is set
+func (c *Connection) GetThreadGroupThreads(id jdwp.ThreadGroupID, recursive bool) ([]jdwp.ThreadID, error) {
+	var threads []jdwp.ThreadID
+	var groups = []jdwp.ThreadGroupID { id }
+
+	for i := 0; i < len(groups); i++ {
+		groupThreads, children, err := c.GetThreadGroupChildren(groups[i])
+		if err != nil {
+			return nil, err
+		}
+
+		threads = append(threads, groupThreads...)
+		if recursive {
+			groups = append(groups, children...)
+		}
+	}
+
+	return threads, nil
+}
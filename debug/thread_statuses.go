@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// reads of a thread status this close to each other share a JDWP call
+const threadStatusTTL = 100 * time.Millisecond
+
+type cachedThreadStatus struct {
+	status jdwp.ThreadStatus
+	suspendStatus jdwp.SuspendStatus
+	readAt time.Time
+}
+
+type observedThreadStatus struct {
+	status jdwp.ThreadStatus
+	suspendStatus jdwp.SuspendStatus
+	changedAt time.Time
+}
+
+//
+// Thread status cache
+// Tools polling the status of many threads would otherwise ask the VM
+// every time; a status is kept for threadStatusTTL, and dropped sooner
+// when the thread is suspended or resumed through the connection, or an
+// event tells it started, died or was suspended. A status dropped while
+// it's read may be older than the drop, so it's returned, but not kept
+//
+func (c *Connection) GetThreadStatus(id jdwp.ThreadID) (jdwp.ThreadStatus, jdwp.SuspendStatus, error) {
+	c.tmu.Lock()
+	cached, ok := c.threadStatuses[id]
+	generation := c.statusGenerations[id]
+	statusesGeneration := c.statusesGeneration
+	c.tmu.Unlock()
+
+	if ok && time.Since(cached.readAt) < threadStatusTTL {
+		return cached.status, cached.suspendStatus, nil
+	}
+
+	status, suspendStatus, err := c.readThreadStatus(id)
+	if err != nil {
+		return status, suspendStatus, err
+	}
+
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+
+	if c.statusGenerations[id] != generation || c.statusesGeneration != statusesGeneration {
+		return status, suspendStatus, nil
+	}
+
+	if c.threadStatuses == nil {
+		c.threadStatuses = map[jdwp.ThreadID]cachedThreadStatus{}
+	}
+	c.threadStatuses[id] = cachedThreadStatus {
+		status: status,
+		suspendStatus: suspendStatus,
+		readAt: time.Now(),
+	}
+	c.observeStatus(id, status, suspendStatus)
+
+	return status, suspendStatus, nil
+}
+
+func (c *Connection) readThreadStatus(id jdwp.ThreadID) (jdwp.ThreadStatus, jdwp.SuspendStatus, error) {
+	defer c.acquire()()
+	return c.jdwp().GetThreadStatus(id)
+}
+
+func (c *Connection) invalidateThreadStatus(id jdwp.ThreadID) {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+
+	delete(c.threadStatuses, id)
+	if c.statusGenerations == nil {
+		c.statusGenerations = map[jdwp.ThreadID]uint64{}
+	}
+	c.statusGenerations[id]++
+}
+
+func (c *Connection) flushThreadStatuses() {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+
+	c.threadStatuses = nil
+	c.statusesGeneration++
+}
+
+// observeThreadStatus drops the statuses an event may have changed: the
+// one of its thread, or all of them if it suspended every thread
+func (c *Connection) observeThreadStatus(event jdwp.Event, suspendPolicy jdwp.SuspendPolicy) {
+	if suspendPolicy == jdwp.SuspendAll {
+		c.flushThreadStatuses()
+		return
+	}
+
+	record := NewEventRecord(event, time.Time{})
+	if record.Thread != 0 {
+		c.invalidateThreadStatus(jdwp.ThreadID(record.Thread))
+	}
+}
+
+//
+// Status changes
+// When a thread was last seen in another status, or suspend status,
+// than before; jdwpfs only sees the statuses it reads, so a change is
+// dated when it's read, and a change undone between two reads is missed
+//
+
+// observeStatus records a status read from the VM, under tmu
+func (c *Connection) observeStatus(id jdwp.ThreadID, status jdwp.ThreadStatus, suspendStatus jdwp.SuspendStatus) {
+	if c.observedStatuses == nil {
+		c.observedStatuses = map[jdwp.ThreadID]observedThreadStatus{}
+	}
+
+	observed, ok := c.observedStatuses[id]
+	if ok && observed.status == status && observed.suspendStatus == suspendStatus {
+		return
+	}
+
+	c.observedStatuses[id] = observedThreadStatus {
+		status: status,
+		suspendStatus: suspendStatus,
+		changedAt: time.Now(),
+	}
+}
+
+// GetStatusChangedAt returns when the status of the thread was seen
+// changing last, or first seen; the zero time if it never was read
+func (c *Connection) GetStatusChangedAt(id jdwp.ThreadID) time.Time {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+
+	return c.observedStatuses[id].changedAt
+}
+
+// forgetObservedStatuses drops the statuses seen, for when they came
+// from a previous connection
+func (c *Connection) forgetObservedStatuses() {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+
+	c.observedStatuses = nil
+}
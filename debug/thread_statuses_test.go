@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestThreadStatusCache(t *testing.T) {
+	var tests = []struct {
+		name string
+		between func(c *Connection) error
+		sent int
+	}{
+		{ name: "within the ttl", between: func(c *Connection) error { return nil }, sent: 1 },
+		{
+			name: "past the ttl",
+			between: func(c *Connection) error {
+				time.Sleep(threadStatusTTL + 10 * time.Millisecond)
+				return nil
+			},
+			sent: 2,
+		},
+		{ name: "suspended", between: func(c *Connection) error { return c.Suspend(7) }, sent: 2 },
+		{ name: "other thread suspended", between: func(c *Connection) error { return c.Suspend(8) }, sent: 1 },
+		{
+			name: "event of the thread",
+			between: func(c *Connection) error {
+				c.observeThreadStatus(&jdwp.EventBreakpoint { Thread: 7 }, jdwp.SuspendEventThread)
+				return nil
+			},
+			sent: 2,
+		},
+		{
+			name: "event of another thread",
+			between: func(c *Connection) error {
+				c.observeThreadStatus(&jdwp.EventBreakpoint { Thread: 8 }, jdwp.SuspendEventThread)
+				return nil
+			},
+			sent: 1,
+		},
+		{
+			name: "event suspending all",
+			between: func(c *Connection) error {
+				c.observeThreadStatus(&jdwp.EventBreakpoint { Thread: 8 }, jdwp.SuspendAll)
+				return nil
+			},
+			sent: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(commandSetThreadReference, 4, 0, fakeConcat(fakeInt(1), fakeInt(0)))
+
+			if _, _, err := conn.GetThreadStatus(7); err != nil {
+				t.Fatalf("%s", err)
+			}
+			if _, _, err := conn.GetThreadStatus(7); err != nil {
+				t.Fatalf("%s", err)
+			}
+			if sent := vm.Received(commandSetThreadReference, 4); sent != 1 {
+				t.Fatalf("read the status %d times in a row", sent)
+			}
+
+			if err := test.between(conn); err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			if _, _, err := conn.GetThreadStatus(7); err != nil {
+				t.Fatalf("%s", err)
+			}
+			if sent := vm.Received(commandSetThreadReference, 4); sent != test.sent {
+				t.Fatalf("asked the VM %d times, expected %d", sent, test.sent)
+			}
+		})
+	}
+}
+
+func TestThreadStatusDroppedWhileRead(t *testing.T) {
+	var tests = []struct {
+		name string
+		drop func(c *Connection)
+		sent int
+	}{
+		{ name: "not dropped", drop: func(c *Connection) {}, sent: 1 },
+		{ name: "thread dropped", drop: func(c *Connection) { c.invalidateThreadStatus(7) }, sent: 2 },
+		{ name: "other thread dropped", drop: func(c *Connection) { c.invalidateThreadStatus(8) }, sent: 1 },
+		{ name: "all dropped", drop: func(c *Connection) { c.flushThreadStatuses() }, sent: 2 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the first read is answered once the status is dropped
+			asked := make(chan struct{})
+			answer := make(chan struct{})
+			var once sync.Once
+			conn, vm := startFakeVM(t, func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+				if set == commandSetThreadReference && cmd == 4 {
+					once.Do(func() {
+						close(asked)
+						<-answer
+					})
+					return 0, fakeConcat(fakeInt(1), fakeInt(0))
+				}
+				return 0, nil
+			})
+
+			read := make(chan error)
+			go func() {
+				_, _, err := conn.GetThreadStatus(7)
+				read <- err
+			}()
+			<-asked
+			test.drop(conn)
+			close(answer)
+			if err := <-read; err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			if _, _, err := conn.GetThreadStatus(7); err != nil {
+				t.Fatalf("%s", err)
+			}
+			if sent := vm.Received(commandSetThreadReference, 4); sent != test.sent {
+				t.Fatalf("asked the VM %d times, expected %d", sent, test.sent)
+			}
+		})
+	}
+}
+
+func TestStatusChangedAt(t *testing.T) {
+	var tests = []struct {
+		name string
+		second []byte
+		changed bool
+	}{
+		{ name: "same status", second: fakeConcat(fakeInt(1), fakeInt(0)), changed: false },
+		{ name: "other status", second: fakeConcat(fakeInt(2), fakeInt(0)), changed: true },
+		{ name: "suspended", second: fakeConcat(fakeInt(1), fakeInt(1)), changed: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(commandSetThreadReference, 4, 0, fakeConcat(fakeInt(1), fakeInt(0)))
+
+			if changedAt := conn.GetStatusChangedAt(7); !changedAt.IsZero() {
+				t.Fatalf("dated %s before any read", changedAt)
+			}
+
+			if _, _, err := conn.GetThreadStatus(7); err != nil {
+				t.Fatalf("%s", err)
+			}
+			first := conn.GetStatusChangedAt(7)
+			if first.IsZero() {
+				t.Fatalf("first read not dated")
+			}
+
+			time.Sleep(time.Millisecond)
+			vm.Answer(commandSetThreadReference, 4, 0, test.second)
+			conn.invalidateThreadStatus(7)
+
+			if _, _, err := conn.GetThreadStatus(7); err != nil {
+				t.Fatalf("%s", err)
+			}
+			second := conn.GetStatusChangedAt(7)
+			if changed := second.After(first); changed != test.changed {
+				t.Fatalf("dated %s after %s, expected a change: %v", second, first, test.changed)
+			}
+		})
+	}
+}
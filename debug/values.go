@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package debug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// FormatValue renders a value read from the VM; references are rendered
+// as their object id, and null as "null"
+func FormatValue(value jdwp.Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case uint8:
+		// Java bytes are signed
+		return strconv.Itoa(int(int8(v)))
+	case jdwp.Char:
+		return strconv.QuoteRune(rune(uint16(v)))
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case jdwp.ObjectID:
+		return formatReference(uint64(v))
+	case jdwp.StringID:
+		return formatReference(uint64(v))
+	case jdwp.ArrayID:
+		return formatReference(uint64(v))
+	case jdwp.ThreadID:
+		return formatReference(uint64(v))
+	case jdwp.ThreadGroupID:
+		return formatReference(uint64(v))
+	case jdwp.ClassLoaderID:
+		return formatReference(uint64(v))
+	case jdwp.ClassObjectID:
+		return formatReference(uint64(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatReference(id uint64) string {
+	if id == 0 {
+		return "null"
+	}
+
+	return strconv.FormatUint(id, 10)
+}
+
+// ParseValue reads a value as FormatValue renders it, of the type of a
+// signature; references are given by their object id, or as "null"
+func ParseValue(signature string, text string) (jdwp.Value, error) {
+	if signature == "" {
+		return nil, JdwpConnectionError {
+			message: "no signature to parse the value by",
+		}
+	}
+
+	var value jdwp.Value
+	var err error
+	switch signature[0] {
+	case 'Z':
+		switch text {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		default:
+			err = fmt.Errorf("%q isn't a boolean", text)
+		}
+	case 'B':
+		var parsed int64
+		parsed, err = strconv.ParseInt(text, 10, 8)
+		value = uint8(int8(parsed))
+	case 'C':
+		value, err = parseChar(text)
+	case 'S':
+		var parsed int64
+		parsed, err = strconv.ParseInt(text, 10, 16)
+		value = int16(parsed)
+	case 'I':
+		var parsed int64
+		parsed, err = strconv.ParseInt(text, 10, 32)
+		value = int(parsed)
+	case 'J':
+		value, err = strconv.ParseInt(text, 10, 64)
+	case 'F':
+		var parsed float64
+		parsed, err = strconv.ParseFloat(text, 32)
+		value = float32(parsed)
+	case 'D':
+		value, err = strconv.ParseFloat(text, 64)
+	case 'L', '[':
+		var id uint64
+		if text != "null" {
+			id, err = strconv.ParseUint(text, 10, 64)
+		}
+		if signature[0] == '[' {
+			value = jdwp.ArrayID(id)
+		} else {
+			value = jdwp.ObjectID(id)
+		}
+	default:
+		err = fmt.Errorf("unknown signature %s", signature)
+	}
+
+	if err != nil {
+		return nil, JdwpConnectionError {
+			message: fmt.Sprintf("unable to parse %q as %s: %s", text, signature, err),
+		}
+	}
+
+	return value, nil
+}
+
+// parseChar reads a char either quoted, as FormatValue renders it, or
+// as the character itself
+func parseChar(text string) (jdwp.Char, error) {
+	if strings.HasPrefix(text, "'") {
+		unquoted, err := strconv.Unquote(text)
+		if err != nil {
+			return 0, err
+		}
+		text = unquoted
+	}
+
+	runes := []rune(text)
+	if len(runes) != 1 || runes[0] > 0xffff {
+		return 0, fmt.Errorf("%q isn't a single char", text)
+	}
+
+	return jdwp.Char(runes[0]), nil
+}
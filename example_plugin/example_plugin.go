@@ -2,11 +2,27 @@ package main
 
 import (
 	"log"
+	"time"
 	
 	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
 )
 
 func JdwpfsPluginEntrypoint(name string, event jdwp.Event) error {
-	log.Printf("eeey %s ran!\n", name)
+	record, err := debug.NewEventRecord(event, time.Now()).JSON()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("eeey %s ran: %s\n", name, record)
+	return nil
+}
+
+func JdwpfsPluginShutdown(name string) error {
+	log.Printf("eeey %s shut down!\n", name)
 	return nil
 }
+
+// plugins are built with -buildmode=plugin; main is never called
+func main() {}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+//
+// Build information
+// The module version and the VCS revision the binary was built from
+//
+func BuildInfo() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "version: unknown\n"
+	}
+
+	return FormatBuildInfo(info)
+}
+
+// FormatBuildInfo prints a line per known fact about the build; the
+// revision is only known for binaries built from a checkout
+func FormatBuildInfo(info *debug.BuildInfo) string {
+	var version = info.Main.Version
+	if version == "" {
+		version = "unknown"
+	}
+
+	var revision = "unknown"
+	var modified = ""
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				modified = " (modified)"
+			}
+		}
+	}
+
+	return fmt.Sprintf("version: %s\nrevision: %s%s\ngo: %s\n",
+		version, revision, modified, info.GoVersion)
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestFormatBuildInfo(t *testing.T) {
+	var tests = []struct {
+		name string
+		info debug.BuildInfo
+		formatted string
+	}{
+		{
+			name: "released",
+			info: debug.BuildInfo {
+				GoVersion: "go1.18",
+				Main: debug.Module { Version: "v0.2.0" },
+				Settings: []debug.BuildSetting {
+					{ Key: "vcs.revision", Value: "20872bf" },
+					{ Key: "vcs.modified", Value: "false" },
+				},
+			},
+			formatted: "version: v0.2.0\nrevision: 20872bf\ngo: go1.18\n",
+		},
+		{
+			name: "modified checkout",
+			info: debug.BuildInfo {
+				GoVersion: "go1.18",
+				Main: debug.Module { Version: "(devel)" },
+				Settings: []debug.BuildSetting {
+					{ Key: "vcs.revision", Value: "20872bf" },
+					{ Key: "vcs.modified", Value: "true" },
+				},
+			},
+			formatted: "version: (devel)\nrevision: 20872bf (modified)\ngo: go1.18\n",
+		},
+		{
+			name: "no vcs",
+			info: debug.BuildInfo {
+				GoVersion: "go1.18",
+			},
+			formatted: "version: unknown\nrevision: unknown\ngo: go1.18\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if formatted := FormatBuildInfo(&test.info); formatted != test.formatted {
+				t.Fatalf("got %q, expected %q", formatted, test.formatted)
+			}
+		})
+	}
+}
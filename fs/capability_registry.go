@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"log"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Capability registry
+// The files and directories which are only offered when the VM has the
+// capabilities they need, by their path under the class or the method
+// directory; the directories leave the others out, so reading them
+// doesn't end in ENOTSUP
+//
+var capabilityRegistry = map[string][]string {
+	"class/constantPool": { "canGetConstantPool" },
+	"method/bytecode": { "canGetBytecodes" },
+	"method/callees": { "canGetBytecodes", "canGetConstantPool" },
+	"method/callers": { "canGetBytecodes", "canGetConstantPool" },
+}
+
+// offered tells if the VM has the capabilities the path needs; paths
+// which aren't registered are always offered, and so are all of them
+// if the capabilities can't be read, for the reads to tell why
+func offered(conn *debug.Connection, path string) bool {
+	required, ok := capabilityRegistry[path]
+	if !ok {
+		return true
+	}
+
+	capabilities, err := conn.GetCapabilities()
+	if err != nil {
+		log.Printf("unable to get the VM capabilities: %s\n", err)
+		return true
+	}
+
+	for _, capability := range required {
+		if !capabilities.Has(capability) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+func TestCapabilityRegistry(t *testing.T) {
+	var steps = []struct {
+		name string
+		capabilities []int
+		offered map[string]bool
+	}{
+		{
+			name: "none",
+			offered: map[string]bool {
+				"class/constantPool": false,
+				"method/bytecode": false,
+				"method/callees": false,
+				"method/callers": false,
+			},
+		},
+		{
+			name: "bytecodes",
+			capabilities: []int { capabilityBytecodes },
+			offered: map[string]bool {
+				"class/constantPool": false,
+				"method/bytecode": true,
+				"method/callees": false,
+				"method/callers": false,
+			},
+		},
+		{
+			name: "bytecodes and constant pool",
+			capabilities: []int { capabilityBytecodes, capabilityConstantPool },
+			offered: map[string]bool {
+				"class/constantPool": true,
+				"method/bytecode": true,
+				"method/callees": true,
+				"method/callers": true,
+			},
+		},
+		{
+			name: "constant pool",
+			capabilities: []int { capabilityConstantPool },
+			offered: map[string]bool {
+				"class/constantPool": true,
+				"method/bytecode": false,
+				"method/callees": false,
+				"method/callers": false,
+			},
+		},
+		{
+			name: "none again",
+			offered: map[string]bool {
+				"class/constantPool": false,
+				"method/bytecode": false,
+				"method/callees": false,
+				"method/callers": false,
+			},
+		},
+	}
+
+	conn, vm := startFakeVM(t, nil)
+	vm.Answer(1, 17, 0, fakeCapabilities())
+	classDir, err := NewJdwpClassInfoDir(context.Background(), conn, 42, nil, JdwpFsOptions{})
+	if err != nil {
+		t.Fatalf("unable to make the class dir: %s", err)
+	}
+	fs.NewNodeFS(classDir, &fs.Options{})
+	methodDir, _ := NewClassMethodDir(context.Background(), conn, 42, 7, nil, JdwpFsOptions{})
+	fs.NewNodeFS(methodDir, &fs.Options{})
+
+	// the capabilities are read again from each VM connected to
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			other, otherVM := startFakeVM(t, nil)
+			otherVM.Answer(1, 17, 0, fakeCapabilities(step.capabilities...))
+			conn.Swap(other)
+
+			var dirs = map[string]fs.InodeEmbedder {
+				"class": classDir,
+				"method": methodDir,
+			}
+			for path, offered := range step.offered {
+				dirName, name, _ := strings.Cut(path, "/")
+				dir := dirs[dirName]
+
+				if listed := containsName(listNames(t, dir.(fs.NodeReaddirer)), name); listed != offered {
+					t.Fatalf("%s listed: %v, expected %v", path, listed, offered)
+				}
+				if offered {
+					continue
+				}
+				if _, errno := dir.(fs.NodeLookuper).Lookup(context.Background(), name, nil); errno != syscall.ENOENT {
+					t.Fatalf("lookup of %s: got %v, expected ENOENT", path, errno)
+				}
+			}
+		})
+	}
+}
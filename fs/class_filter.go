@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//
+// Class filter
+// Include and exclude globs over class signatures; `*` matches any
+// sequence, slashes included, and `?` any single character
+//
+type ClassFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func NewClassFilter(include []string, exclude []string) (*ClassFilter, error) {
+	filter := &ClassFilter{}
+
+	for _, pattern := range include {
+		compiled, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.include = append(filter.include, compiled)
+	}
+
+	for _, pattern := range exclude {
+		compiled, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.exclude = append(filter.exclude, compiled)
+	}
+
+	return filter, nil
+}
+
+// compileGlob turns a glob into an anchored regular expression; it's
+// used for thread names as well
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expression.WriteString("$")
+
+	compiled, err := regexp.Compile(expression.String())
+	if err != nil {
+		return nil, JdwpClassError {
+			message: fmt.Sprintf("invalid glob %s: %s", pattern, err),
+		}
+	}
+
+	return compiled, nil
+}
+
+// Matches tells if a class is visible: it has to match an include glob,
+// if there are any, and no exclude glob
+func (f *ClassFilter) Matches(signature string) bool {
+	if f == nil {
+		return true
+	}
+
+	var included = len(f.include) == 0
+	for _, include := range f.include {
+		if include.MatchString(signature) {
+			included = true
+			break
+		}
+	}
+
+	if !included {
+		return false
+	}
+
+	for _, exclude := range f.exclude {
+		if exclude.MatchString(signature) {
+			return false
+		}
+	}
+
+	return true
+}
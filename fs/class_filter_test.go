@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"net/url"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// the classes loaded in the class list VM, by id
+var stubClasses = map[uint64]string {
+	41: "Lcom/myapp/Main;",
+	42: "Lcom/myapp/internal/Cache;",
+	43: "Ljava/lang/String;",
+}
+
+// classListHandler answers with the stub classes
+func classListHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 1 && cmd == 3:
+		reply := fakeInt(uint32(len(stubClasses)))
+		for _, id := range []uint64 { 41, 42, 43 } {
+			reply = fakeConcat(reply, []byte{1}, fakeLong(id), fakeString(stubClasses[id]), fakeInt(7))
+		}
+		return 0, reply
+	case set == 1 && cmd == 2:
+		signature := string(data[4:4 + binary.BigEndian.Uint32(data)])
+		for id, stubSignature := range stubClasses {
+			if stubSignature == signature {
+				return 0, fakeConcat(fakeInt(1), []byte{1}, fakeLong(id), fakeInt(7))
+			}
+		}
+		return 0, fakeInt(0)
+	case set == 2 && cmd == 1:
+		return 0, fakeString(stubClasses[binary.BigEndian.Uint64(data)])
+	default:
+		return 0, nil
+	}
+}
+
+func TestClassFilterMatches(t *testing.T) {
+	var tests = []struct {
+		name string
+		include []string
+		exclude []string
+		matches map[string]bool
+	}{
+		{
+			name: "no globs",
+			matches: map[string]bool { "Lcom/myapp/Main;": true, "Ljava/lang/String;": true },
+		},
+		{
+			name: "include",
+			include: []string { "Lcom/myapp/*" },
+			matches: map[string]bool {
+				"Lcom/myapp/Main;": true,
+				"Lcom/myapp/internal/Cache;": true,
+				"Ljava/lang/String;": false,
+			},
+		},
+		{
+			name: "include and exclude",
+			include: []string { "Lcom/myapp/*" },
+			exclude: []string { "Lcom/myapp/internal/*" },
+			matches: map[string]bool {
+				"Lcom/myapp/Main;": true,
+				"Lcom/myapp/internal/Cache;": false,
+				"Ljava/lang/String;": false,
+			},
+		},
+		{
+			name: "exclude",
+			exclude: []string { "Ljava/*" },
+			matches: map[string]bool { "Lcom/myapp/Main;": true, "Ljava/lang/String;": false },
+		},
+		{
+			name: "single characters",
+			include: []string { "L???/*" },
+			matches: map[string]bool { "Lcom/myapp/Main;": true, "Ljava/lang/String;": false },
+		},
+		{
+			name: "quoted",
+			include: []string { "Lcom/myapp/Main$*" },
+			matches: map[string]bool { "Lcom/myapp/Main$Inner;": true, "Lcom/myapp/Main;": false },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := NewClassFilter(test.include, test.exclude)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			for signature, matches := range test.matches {
+				if filter.Matches(signature) != matches {
+					t.Errorf("%s: matches %v", signature, !matches)
+				}
+			}
+		})
+	}
+}
+
+func TestNilClassFilter(t *testing.T) {
+	var filter *ClassFilter
+	if !filter.Matches("Ljava/lang/String;") {
+		t.Fatalf("a nil filter hides classes")
+	}
+}
+
+// listNames lists a directory's entries by name
+func listNames(t *testing.T, dir fs.NodeReaddirer) []string {
+	t.Helper()
+
+	stream, errno := dir.Readdir(context.Background())
+	if errno != syscall.F_OK {
+		t.Fatalf("readdir: %v", errno)
+	}
+
+	var names = []string{}
+	for stream.HasNext() {
+		entry, _ := stream.Next()
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func TestClassDirsFiltered(t *testing.T) {
+	filter, err := NewClassFilter([]string { "Lcom/myapp/*" }, []string { "*Cache;" })
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	conn, _ := startFakeVM(t, classListHandler)
+	ctx := context.Background()
+
+	classDir, _ := NewJdwpClassMasterDir(ctx, conn, filter, nil, 0, JdwpFsOptions{})
+	fs.NewNodeFS(classDir, &fs.Options{})
+	if names := listNames(t, classDir); !reflect.DeepEqual(names, []string { "41" }) {
+		t.Fatalf("classes lists %v", names)
+	}
+
+	namedDir, _ := NewJdwpClassNamedMasterDir(ctx, conn, filter, 0, JdwpFsOptions{})
+	fs.NewNodeFS(namedDir, &fs.Options{})
+	if names := listNames(t, namedDir); !reflect.DeepEqual(names, []string { url.PathEscape("Lcom/myapp/Main;") }) {
+		t.Fatalf("classes_by_signature lists %v", names)
+	}
+
+	var tests = []struct {
+		id string
+		signature string
+		errno syscall.Errno
+	}{
+		{ id: "41", signature: "Lcom/myapp/Main;", errno: syscall.F_OK },
+		{ id: "42", signature: "Lcom/myapp/internal/Cache;", errno: syscall.ENOENT },
+		{ id: "43", signature: "Ljava/lang/String;", errno: syscall.ENOENT },
+	}
+
+	for _, test := range tests {
+		if _, errno := classDir.Lookup(ctx, test.id, &fuse.EntryOut{}); errno != test.errno {
+			t.Errorf("classes/%s: got %v, expected %v", test.id, errno, test.errno)
+		}
+		if _, errno := namedDir.Lookup(ctx, url.PathEscape(test.signature), &fuse.EntryOut{}); errno != test.errno {
+			t.Errorf("classes_by_signature/%s: got %v, expected %v", test.signature, errno, test.errno)
+		}
+	}
+}
@@ -10,11 +10,14 @@ import (
 	"log"
 	"strconv"
 	"sort"
+	"strings"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 
 	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
 )
 
 //
@@ -44,18 +47,30 @@ type JdwpClassInfoDir struct {
 	TypeId jdwp.ReferenceTypeID
 
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	manager *debug.EventManager
+
+	// the classes callers are looked for in
+	classFilter *ClassFilter
+	// how the values of static fields are shown
+	values valueRenderer
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpClassInfoDir)(nil))
 var _ = (fs.NodeReaddirer)((*JdwpClassInfoDir)(nil))
 var _ = (fs.NodeLookuper)((*JdwpClassInfoDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpClassInfoDir)(nil))
 
-func NewJdwpClassInfoDir(ctx context.Context, conn *jdwp.Connection, typeId jdwp.ReferenceTypeID) (*JdwpClassInfoDir, error) {
+func NewJdwpClassInfoDir(ctx context.Context, conn *debug.Connection, typeId jdwp.ReferenceTypeID, manager *debug.EventManager, options JdwpFsOptions) (*JdwpClassInfoDir, error) {
 	classInfo := &JdwpClassInfoDir {
 		TypeId: typeId,
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+		manager: manager,
+		Options: options,
 	}
 
 	return classInfo, nil
@@ -64,31 +79,96 @@ func NewJdwpClassInfoDir(ctx context.Context, conn *jdwp.Connection, typeId jdwp
 func (d *JdwpClassInfoDir) GetDirEntry(ctx context.Context) fuse.DirEntry {
 	return fuse.DirEntry {
 		Mode: fuse.S_IFDIR,
-		Name: strconv.FormatUint(uint64(d.TypeId), 10),
+		Name: d.Options.formatId(uint64(d.TypeId)),
 	}
 }
 
 func (c *JdwpClassInfoDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = c.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (c *JdwpClassInfoDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *JdwpClassInfoDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	classDirContents := [...]string{"signature", "methodInfo", "fieldInfo", "methods", "fields"}
+	classDirContents := [...]string{"signature", "generic", "methodInfo", "fieldInfo", "methods", "fields", "constantPool"}
 	var infoFiles []fuse.DirEntry
 	for _, infoFileName := range classDirContents {
+		if !offered(d.JdwpConnection, "class/" + infoFileName) {
+			continue
+		}
+
 		infoFileEntry := fuse.DirEntry {
 			Mode: fuse.S_IFREG,
 			Name: infoFileName,
 		}
 		infoFiles = append(infoFiles, infoFileEntry)
 	}
+
+	// only there when the VM knows it
+	_, err := d.JdwpConnection.GetClassFileVersion(d.TypeId)
+	if err == nil {
+		infoFiles = append(infoFiles, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "classFileVersion",
+		})
+	}
 	
 	return fs.NewListDirStream(infoFiles), 0
 }
 
 func (d *JdwpClassInfoDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if !offered(d.JdwpConnection, "class/" + name) {
+		return nil, syscall.ENOENT
+	}
+
 	switch name {
+	case "generic":
+		_, genericSignature, err := d.JdwpConnection.GetSignatureWithGeneric(d.TypeId)
+		if err != nil {
+			log.Printf("error getting the generic signature of class %d: %s\n", d.TypeId, err)
+			return nil, syscall.EFAULT
+		}
+
+		genericFileInode := d.NewInode(
+			ctx,
+			&fs.MemRegularFile {
+				Data: []byte(newlineTerminated(genericSignature)),
+				Attr: staticAttr(d.Options.fileMode()),
+			},
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return genericFileInode, 0
+	case "classFileVersion":
+		version, err := d.JdwpConnection.GetClassFileVersion(d.TypeId)
+		if err != nil {
+			log.Printf("no class file version for class %d: %s\n", d.TypeId, err)
+			return nil, syscall.ENOENT
+		}
+
+		versionInode := d.NewInode(
+			ctx,
+			&fs.MemRegularFile {
+				Data: []byte(version.String()),
+				Attr: staticAttr(d.Options.fileMode()),
+			},
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return versionInode, 0
+	case "constantPool":
+		constantPoolFile := NewInfoFile(d.readConstantPool, d.Options)
+		constantPoolInode := d.NewInode(
+			ctx,
+			&constantPoolFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return constantPoolInode, 0
 	case "signature":
 		classes, err := d.JdwpConnection.GetAllClasses()
 		if err != nil {
@@ -115,10 +195,8 @@ func (d *JdwpClassInfoDir) Lookup(ctx context.Context, name string, out *fuse.En
 		nameFileInode := d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(class.Signature),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(class.Signature)),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -142,9 +220,7 @@ func (d *JdwpClassInfoDir) Lookup(ctx context.Context, name string, out *fuse.En
 			ctx,
 			&fs.MemRegularFile {
 				Data: []byte(method_info),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -168,20 +244,19 @@ func (d *JdwpClassInfoDir) Lookup(ctx context.Context, name string, out *fuse.En
 			ctx,
 			&fs.MemRegularFile {
 				Data: []byte(field_info),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
 			})
 		return methodInfoFile, 0
 	case "methods":
-		methodDir, err := NewClassMethodMasterDir(d.JdwpContext, d.JdwpConnection, d.TypeId)
+		methodDir, err := NewClassMethodMasterDir(d.JdwpContext, d.JdwpConnection, d.TypeId, d.manager, d.Options)
 		if err != nil {
 			log.Printf("error creating method dir of class with id %d: %s", d.TypeId, err)
 			return nil, syscall.EFAULT
 		}
+		methodDir.classFilter = d.classFilter
 
 		methodDirFile := d.NewInode(
 			ctx,
@@ -192,11 +267,12 @@ func (d *JdwpClassInfoDir) Lookup(ctx context.Context, name string, out *fuse.En
 		)
 		return methodDirFile, fuse.F_OK
 	case "fields":
-		fieldDir, err := NewClassFieldMasterDir(d.JdwpContext, d.JdwpConnection, d.TypeId)
+		fieldDir, err := NewClassFieldMasterDir(d.JdwpContext, d.JdwpConnection, d.TypeId, d.Options)
 		if err != nil {
 			log.Printf("error creating field dir of class with id %d: %s", d.TypeId, err)
 			return nil, syscall.EFAULT
 		}
+		fieldDir.values = d.values
 
 		fieldDirFile := d.NewInode(
 			ctx,
@@ -223,28 +299,43 @@ type ClassMethodMasterDir struct {
 	TypeId jdwp.ReferenceTypeID
 
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	manager *debug.EventManager
+
+	// the classes callers are looked for in
+	classFilter *ClassFilter
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*ClassMethodMasterDir)(nil))
 var _ = (fs.NodeReaddirer)((*ClassMethodMasterDir)(nil))
 var _ = (fs.NodeLookuper)((*ClassMethodMasterDir)(nil))
+var _ = (fs.NodeMkdirer)((*ClassMethodMasterDir)(nil))
 
-func NewClassMethodMasterDir(ctx context.Context, conn *jdwp.Connection, id jdwp.ReferenceTypeID) (*ClassMethodMasterDir, error) {
+func NewClassMethodMasterDir(ctx context.Context, conn *debug.Connection, id jdwp.ReferenceTypeID, manager *debug.EventManager, options JdwpFsOptions) (*ClassMethodMasterDir, error) {
 	masterDir := &ClassMethodMasterDir {
 		TypeId: id,
 		JdwpContext: ctx,
 		JdwpConnection: conn,	
+		manager: manager,
+		Options: options,
 	}
 
 	return masterDir, nil
 }
 
 func (d *ClassMethodMasterDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *ClassMethodMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *ClassMethodMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	methods, err := d.JdwpConnection.GetMethods(d.TypeId)
 	
@@ -295,11 +386,12 @@ func (d *ClassMethodMasterDir) Lookup(ctx context.Context, name string, out *fus
 		return nil, syscall.ENOENT
 	}
 
-	methodFile, err := NewClassMethodDir(d.JdwpContext, d.JdwpConnection, d.TypeId, method.ID)
+	methodFile, err := NewClassMethodDir(d.JdwpContext, d.JdwpConnection, d.TypeId, method.ID, d.manager, d.Options)
 	if err != nil {
 		log.Printf("unable to create dir for method with id %d\n", method.ID)
 		return nil, syscall.EFAULT
 	}
+	methodFile.classFilter = d.classFilter
 
 	methodFileInode := d.NewInode(
 		ctx,
@@ -322,28 +414,39 @@ type ClassFieldMasterDir struct {
 	TypeId jdwp.ReferenceTypeID
 
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	values valueRenderer
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*ClassFieldMasterDir)(nil))
 var _ = (fs.NodeReaddirer)((*ClassFieldMasterDir)(nil))
 var _ = (fs.NodeLookuper)((*ClassFieldMasterDir)(nil))
+var _ = (fs.NodeMkdirer)((*ClassFieldMasterDir)(nil))
 
-func NewClassFieldMasterDir(ctx context.Context, conn *jdwp.Connection, id jdwp.ReferenceTypeID) (*ClassFieldMasterDir, error) {
+func NewClassFieldMasterDir(ctx context.Context, conn *debug.Connection, id jdwp.ReferenceTypeID, options JdwpFsOptions) (*ClassFieldMasterDir, error) {
 	masterDir := &ClassFieldMasterDir {
 		TypeId: id,
 		JdwpContext: ctx,
-		JdwpConnection: conn,	
+		JdwpConnection: conn,
+		Options: options,
 	}
 
 	return masterDir, nil
 }
 
 func (d *ClassFieldMasterDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *ClassFieldMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *ClassFieldMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	fields, err := d.JdwpConnection.GetFields(d.TypeId)
 	
@@ -393,7 +496,7 @@ func (d *ClassFieldMasterDir) Lookup(ctx context.Context, name string, out *fuse
 		return nil, syscall.ENOENT
 	}
 
-	fieldFile, err := NewClassFieldDir(d.JdwpContext, d.JdwpConnection, d.TypeId, field.ID)
+	fieldFile, err := NewClassFieldDir(d.JdwpContext, d.JdwpConnection, d.TypeId, field.ID, d.values, d.Options)
 	if err != nil {
 		log.Printf("unable to create dir for field with id %d\n", field.ID)
 		return nil, syscall.EFAULT
@@ -419,52 +522,87 @@ type ClassMethodDir struct {
 	MethodId jdwp.MethodID
 
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	manager *debug.EventManager
+
+	// the classes callers are looked for in
+	classFilter *ClassFilter
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*ClassMethodDir)(nil))
 var _ = (fs.NodeReaddirer)((*ClassMethodDir)(nil))
 var _ = (fs.NodeLookuper)((*ClassMethodDir)(nil))
+var _ = (fs.NodeMkdirer)((*ClassMethodDir)(nil))
 
-func NewClassMethodDir(ctx context.Context, conn *jdwp.Connection, typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID) (*ClassMethodDir, error) {
+func NewClassMethodDir(ctx context.Context, conn *debug.Connection, typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID, manager *debug.EventManager, options JdwpFsOptions) (*ClassMethodDir, error) {
 	methodDir := &ClassMethodDir {
 		TypeId: typeId,
 		MethodId: methodId,
 
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+
+		manager: manager,
+		Options: options,
 	}
 
 	return methodDir, nil
 }
 
 func (d *ClassMethodDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *ClassMethodDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *ClassMethodDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	threadDirContents := [...]string{"name", "signature", "modifiers"}
+	threadDirContents := [...]string{"name", "signature", "generic", "modifiers", "native", "abstract",
+		"obsolete", "breakpoint", "entryCount", "bytecode", "lineTable", "variableTable"}
 	var infoFiles []fuse.DirEntry
 	for _, infoFileName := range threadDirContents {
+		if !offered(d.JdwpConnection, "method/" + infoFileName) {
+			continue
+		}
+
 		infoFileEntry := fuse.DirEntry {
 			Mode: fuse.S_IFREG,
 			Name: infoFileName,
 		}
 		infoFiles = append(infoFiles, infoFileEntry)
 	}
+	for _, subdirName := range []string{"locations", "callees", "callers"} {
+		if !offered(d.JdwpConnection, "method/" + subdirName) {
+			continue
+		}
+
+		infoFiles = append(infoFiles, fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: subdirName,
+		})
+	}
 	
 	return fs.NewListDirStream(infoFiles), 0
 }
 
 func (d *ClassMethodDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	methods, err := d.JdwpConnection.GetMethods(d.TypeId)
+	if !offered(d.JdwpConnection, "method/" + name) {
+		return nil, syscall.ENOENT
+	}
+
+	methods, err := d.JdwpConnection.GetMethodsWithGeneric(d.TypeId)
 	if err != nil {
 		log.Printf("methods for class with id %d not found: %s", uint64(d.TypeId), err)
 		return nil, syscall.EFAULT
 	}
 
-	var method jdwp.Method
+	var method debug.GenericMethod
 	var methodFound bool = false
 	for _, foundMethod := range methods {
 		if foundMethod.ID == d.MethodId {
@@ -479,15 +617,93 @@ func (d *ClassMethodDir) Lookup(ctx context.Context, name string, out *fuse.Entr
 
 	var methodFile *fs.Inode
 
+	// native and abstract methods have no code, so the VM has nothing to
+	// tell about it
+	hasCode := !method.ModBits.Native() && !method.ModBits.Abstract()
+
 	switch name {
+	case "native", "abstract":
+		var flag = method.ModBits.Native()
+		if name == "abstract" {
+			flag = method.ModBits.Abstract()
+		}
+
+		methodFile = d.NewInode(
+			ctx,
+			&fs.MemRegularFile {
+				Data: []byte(newlineTerminated(strconv.FormatBool(flag))),
+				Attr: staticAttr(d.Options.fileMode()),
+			},
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+	case "bytecode", "lineTable", "variableTable":
+		var read func() ([]byte, syscall.Errno)
+		switch name {
+		case "bytecode":
+			read = d.readBytecode
+		case "lineTable":
+			read = d.readLineTable
+		case "variableTable":
+			read = d.readVariableTable
+		}
+		if !hasCode {
+			read = func() ([]byte, syscall.Errno) { return []byte{}, 0 }
+		}
+
+		codeFile := NewInfoFile(read, d.Options)
+		methodFile = d.NewInode(
+			ctx,
+			&codeFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+	case "obsolete":
+		obsoleteFile := NewInfoFile(d.readObsolete, d.Options)
+		methodFile = d.NewInode(
+			ctx,
+			&obsoleteFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+	case "entryCount":
+		entryCountFile := NewInfoFile(d.readEntryCount, d.Options)
+		methodFile = d.NewInode(
+			ctx,
+			&entryCountFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+	case "locations":
+		locationsDir := NewClassMethodLocationsDir(d.JdwpConnection, d.TypeId, d.MethodId, d.Options)
+		methodFile = d.NewInode(
+			ctx,
+			&locationsDir,
+			fs.StableAttr {
+				Mode: fuse.S_IFDIR,
+			})
+	case "callees", "callers":
+		callsDir := NewMethodCallsDir(d.JdwpConnection, d.TypeId, d.MethodId, name == "callers", d.classFilter, d.Options)
+		methodFile = d.NewInode(
+			ctx,
+			&callsDir,
+			fs.StableAttr {
+				Mode: fuse.S_IFDIR,
+			})
+	case "breakpoint":
+		breakpointFile := NewMethodBreakpointFile(d.manager, d.JdwpConnection, d.TypeId, d.MethodId, d.Options)
+		methodFile = d.NewInode(
+			ctx,
+			&breakpointFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
 	case "name":
 		methodFile = d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(method.Name),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(method.Name)),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -496,10 +712,18 @@ func (d *ClassMethodDir) Lookup(ctx context.Context, name string, out *fuse.Entr
 		methodFile = d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(method.Signature),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(method.Signature)),
+				Attr: staticAttr(d.Options.fileMode()),
+			},
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+	case "generic":
+		methodFile = d.NewInode(
+			ctx,
+			&fs.MemRegularFile {
+				Data: []byte(newlineTerminated(method.GenericSignature)),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -508,10 +732,8 @@ func (d *ClassMethodDir) Lookup(ctx context.Context, name string, out *fuse.Entr
 		methodFile = d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(method.ModBits.String()),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(method.ModBits.String())),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -522,6 +744,86 @@ func (d *ClassMethodDir) Lookup(ctx context.Context, name string, out *fuse.Entr
 	return methodFile, 0
 }
 
+// readEntryCount is how many times the running MethodEntry events saw
+// the method entered; 0 if none is running
+func (d *ClassMethodDir) readEntryCount() ([]byte, syscall.Errno) {
+	var count uint64
+	if d.manager != nil {
+		count = d.manager.GetEntryCount(jdwp.ClassID(d.TypeId), d.MethodId)
+	}
+
+	return []byte(newlineTerminated(strconv.FormatUint(count, 10))), 0
+}
+
+// readBytecode gives the bytecode of the method as it is in the class
+// file, if the VM can give it out
+func (d *ClassMethodDir) readBytecode() ([]byte, syscall.Errno) {
+	capabilities, err := d.JdwpConnection.GetCapabilities()
+	if err != nil {
+		log.Printf("unable to get the VM capabilities: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	if !capabilities.Has("canGetBytecodes") {
+		return nil, syscall.ENOTSUP
+	}
+
+	bytecodes, err := d.JdwpConnection.GetBytecodes(d.TypeId, d.MethodId)
+	if err != nil {
+		log.Printf("error getting the bytecode of method %d: %s\n", d.MethodId, err)
+		return nil, syscall.EFAULT
+	}
+
+	return bytecodes, 0
+}
+
+// readLineTable gives the code index range of the method, followed by a
+// `<codeIndex>\t<line>` line per entry of its line table
+func (d *ClassMethodDir) readLineTable() ([]byte, syscall.Errno) {
+	table, err := d.JdwpConnection.GetLineTable(d.TypeId, d.MethodId)
+	if err != nil {
+		log.Printf("error getting the line table of method %d: %s\n", d.MethodId, err)
+		return nil, syscall.EFAULT
+	}
+
+	var lines = []string{ fmt.Sprintf("start: %d\nend: %d\n", table.Start, table.End) }
+	for _, line := range table.Lines {
+		lines = append(lines, fmt.Sprintf("%d\t%d\n", line.CodeIndex, line.Number))
+	}
+
+	return []byte(strings.Join(lines, "")), 0
+}
+
+// readVariableTable gives the argument count, followed by a
+// `<slot>\t<name>\t<signature>\t<codeIndex>\t<length>` line per variable
+func (d *ClassMethodDir) readVariableTable() ([]byte, syscall.Errno) {
+	table, err := d.JdwpConnection.GetVariableTable(d.TypeId, d.MethodId)
+	if err != nil {
+		log.Printf("error getting the variable table of method %d: %s\n", d.MethodId, err)
+		return nil, syscall.EFAULT
+	}
+
+	var lines = []string{ fmt.Sprintf("arguments: %d\n", table.ArgCount) }
+	for _, slot := range table.Slots {
+		lines = append(lines, fmt.Sprintf("%d\t%s\t%s\t%d\t%d\n",
+			slot.Slot, slot.Name, slot.Signature, slot.CodeIndex, slot.Length))
+	}
+
+	return []byte(strings.Join(lines, "")), 0
+}
+
+// readObsolete tells if the method was replaced by redefining its class,
+// after which its id is stale
+func (d *ClassMethodDir) readObsolete() ([]byte, syscall.Errno) {
+	obsolete, err := d.JdwpConnection.IsObsolete(d.TypeId, d.MethodId)
+	if err != nil {
+		log.Printf("unable to tell if method %d is obsolete: %s\n", d.MethodId, err)
+		return nil, syscall.EFAULT
+	}
+
+	return []byte(newlineTerminated(strconv.FormatBool(obsolete))), 0
+}
+
 //
 // Class field directory
 //
@@ -532,32 +834,45 @@ type ClassFieldDir struct {
 	FieldId jdwp.FieldID
 
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	values valueRenderer
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*ClassFieldDir)(nil))
 var _ = (fs.NodeReaddirer)((*ClassFieldDir)(nil))
 var _ = (fs.NodeLookuper)((*ClassFieldDir)(nil))
+var _ = (fs.NodeMkdirer)((*ClassFieldDir)(nil))
 
-func NewClassFieldDir(ctx context.Context, conn *jdwp.Connection, typeId jdwp.ReferenceTypeID, fieldId jdwp.FieldID) (*ClassFieldDir, error) {
+func NewClassFieldDir(ctx context.Context, conn *debug.Connection, typeId jdwp.ReferenceTypeID, fieldId jdwp.FieldID, values valueRenderer, options JdwpFsOptions) (*ClassFieldDir, error) {
 	fieldDir := &ClassFieldDir {
 		TypeId: typeId,
 		FieldId: fieldId,
 
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+
+		values: values,
+		Options: options,
 	}
 
 	return fieldDir, nil
 }
 
 func (d *ClassFieldDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *ClassFieldDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *ClassFieldDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	threadDirContents := [...]string{"name", "signature", "modifiers"}
+	threadDirContents := [...]string{"name", "signature", "generic", "modifiers"}
 	var infoFiles []fuse.DirEntry
 	for _, infoFileName := range threadDirContents {
 		infoFileEntry := fuse.DirEntry {
@@ -566,41 +881,59 @@ func (d *ClassFieldDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errn
 		}
 		infoFiles = append(infoFiles, infoFileEntry)
 	}
+
+	// instance fields have a value per object, under objects
+	field, errno := d.field()
+	if errno == 0 && field.ModBits & jdwp.ModStatic != 0 {
+		infoFiles = append(infoFiles, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "value",
+		})
+	}
 	
 	return fs.NewListDirStream(infoFiles), 0
 }
 
-func (d *ClassFieldDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	fields, err := d.JdwpConnection.GetFields(d.TypeId)
+func (d *ClassFieldDir) field() (debug.GenericField, syscall.Errno) {
+	fields, err := d.JdwpConnection.GetFieldsWithGeneric(d.TypeId)
 	if err != nil {
 		log.Printf("fields for class with id %d not found: %s", uint64(d.TypeId), err)
-		return nil, syscall.EFAULT
+		return debug.GenericField{}, syscall.EFAULT
 	}
 
-	var field jdwp.Field
-	var fieldFound bool = false
 	for _, foundField := range fields {
 		if foundField.ID == d.FieldId {
-			field = foundField
-			fieldFound = true
+			return foundField, 0
 		}
 	}
-	if !fieldFound {
-		log.Printf("unable to find the constructed field with id %d: %s\n", d.FieldId, err)
-		return nil, syscall.EFAULT
+
+	log.Printf("unable to find the constructed field with id %d\n", d.FieldId)
+	return debug.GenericField{}, syscall.EFAULT
+}
+
+func (d *ClassFieldDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	field, errno := d.field()
+	if errno != 0 {
+		return nil, errno
 	}
 
 	var fieldFile *fs.Inode
 
 	switch name {
+	case "value":
+		valueFile := NewFieldValueFile(d, d.Options)
+		fieldFile = d.NewInode(
+			ctx,
+			&valueFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
 	case "name":
 		fieldFile = d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(field.Name),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(field.Name)),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -609,10 +942,18 @@ func (d *ClassFieldDir) Lookup(ctx context.Context, name string, out *fuse.Entry
 		fieldFile = d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(field.Signature),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(field.Signature)),
+				Attr: staticAttr(d.Options.fileMode()),
+			},
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+	case "generic":
+		fieldFile = d.NewInode(
+			ctx,
+			&fs.MemRegularFile {
+				Data: []byte(newlineTerminated(field.GenericSignature)),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -621,10 +962,8 @@ func (d *ClassFieldDir) Lookup(ctx context.Context, name string, out *fuse.Entry
 		fieldFile = d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(field.ModBits.String()),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(field.ModBits.String())),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -634,3 +973,49 @@ func (d *ClassFieldDir) Lookup(ctx context.Context, name string, out *fuse.Entry
 	}
 	return fieldFile, 0
 }
+
+// readConstantPool dumps the constant pool of the class, if the VM can
+// give it out
+func (d *JdwpClassInfoDir) readConstantPool() ([]byte, syscall.Errno) {
+	capabilities, err := d.JdwpConnection.GetCapabilities()
+	if err != nil {
+		log.Printf("unable to get the VM capabilities: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	if !capabilities.Has("canGetConstantPool") {
+		return nil, syscall.ENOTSUP
+	}
+
+	pool, err := d.JdwpConnection.GetConstantPool(d.TypeId)
+	if err != nil {
+		log.Printf("error getting the constant pool of class %d: %s\n", d.TypeId, err)
+		return nil, syscall.EFAULT
+	}
+
+	return []byte(pool.String()), 0
+}
+
+// readValue reads the value of a static field, as it is at the time of
+// the read; instance fields have no data
+func (d *ClassFieldDir) readValue() ([]byte, syscall.Errno) {
+	field, errno := d.field()
+	if errno != 0 {
+		return nil, errno
+	}
+	if field.ModBits & jdwp.ModStatic == 0 {
+		return nil, syscall.ENODATA
+	}
+
+	values, err := d.JdwpConnection.GetStaticFieldValues(d.TypeId, d.FieldId)
+	if err != nil {
+		log.Printf("error reading field %d of class %d: %s\n", d.FieldId, d.TypeId, err)
+		return nil, syscall.EFAULT
+	}
+	if len(values) != 1 {
+		log.Printf("%d values read for field %d of class %d\n", len(values), d.FieldId, d.TypeId)
+		return nil, syscall.EFAULT
+	}
+
+	return []byte(newlineTerminated(d.values.render(values[0]))), 0
+}
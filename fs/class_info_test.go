@@ -0,0 +1,335 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"strings"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// canGetConstantPool, in the reply of CapabilitiesNew
+const capabilityConstantPool = 19
+
+// constantPoolHandler has a pool of three entries, in five bytes
+func constantPoolHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	if set == 2 && cmd == 18 {
+		return 0, fakeConcat(fakeInt(3), fakeInt(5), []byte { 1, 0, 1, 'x', 7 })
+	}
+	return 0, nil
+}
+
+func TestReadConstantPool(t *testing.T) {
+	var tests = []struct {
+		name string
+		capabilities []byte
+		errno syscall.Errno
+		header string
+	}{
+		{ name: "capable", capabilities: fakeCapabilities(capabilityConstantPool), header: "count: 3\nbytes: 5\n" },
+		{ name: "not capable", capabilities: fakeCapabilities(), errno: syscall.ENOTSUP },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, constantPoolHandler)
+			vm.Answer(1, 17, 0, test.capabilities)
+			classDir := &JdwpClassInfoDir {
+				TypeId: jdwp.ReferenceTypeID(42),
+				JdwpConnection: conn,
+			}
+
+			pool, errno := classDir.readConstantPool()
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if errno != syscall.F_OK {
+				if asked := vm.Received(2, 18); asked != 0 {
+					t.Fatalf("the pool was asked %d times without the capability", asked)
+				}
+				return
+			}
+
+			if !strings.HasPrefix(string(pool), test.header) {
+				t.Fatalf("read %q, expected it to start with %q", pool, test.header)
+			}
+			if lines := strings.Split(strings.TrimSuffix(string(pool), "\n"), "\n"); len(lines) != 3 {
+				t.Fatalf("read %d lines, expected the header and a line of hexdump", len(lines))
+			}
+		})
+	}
+}
+
+// genericHandler has class 42, Box<T>, with the generic field items and
+// method get, and the plain field count and method size; class 43 isn't
+// generic
+func genericHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 2 && cmd == 13 && data[7] == 42:
+		return 0, fakeConcat(fakeString("Lcom/example/Box;"), fakeString("<T:Ljava/lang/Object;>Ljava/lang/Object;"))
+	case set == 2 && cmd == 13:
+		return 0, fakeConcat(fakeString("Lcom/example/Main;"), fakeString(""))
+	case set == 2 && cmd == 14:
+		return 0, fakeConcat(fakeInt(2),
+			fakeLong(1), fakeString("items"), fakeString("Ljava/util/List;"), fakeString("Ljava/util/List<TT;>;"), fakeInt(0),
+			fakeLong(2), fakeString("count"), fakeString("I"), fakeString(""), fakeInt(0))
+	case set == 2 && cmd == 15:
+		return 0, fakeConcat(fakeInt(2),
+			fakeLong(7), fakeString("get"), fakeString("()Ljava/lang/Object;"), fakeString("()TT;"), fakeInt(1),
+			fakeLong(8), fakeString("size"), fakeString("()I"), fakeString(""), fakeInt(1))
+	default:
+		return 0, nil
+	}
+}
+
+func TestGenericFiles(t *testing.T) {
+	var tests = []struct {
+		name string
+		dir func(conn *debug.Connection) fs.InodeEmbedder
+		generic string
+	}{
+		{
+			name: "generic class",
+			dir: func(conn *debug.Connection) fs.InodeEmbedder {
+				return &JdwpClassInfoDir { TypeId: 42, JdwpConnection: conn }
+			},
+			generic: "<T:Ljava/lang/Object;>Ljava/lang/Object;\n",
+		},
+		{
+			name: "plain class",
+			dir: func(conn *debug.Connection) fs.InodeEmbedder {
+				return &JdwpClassInfoDir { TypeId: 43, JdwpConnection: conn }
+			},
+			generic: "",
+		},
+		{
+			name: "generic field",
+			dir: func(conn *debug.Connection) fs.InodeEmbedder {
+				dir, _ := NewClassFieldDir(context.Background(), conn, 42, 1, valueRenderer{}, JdwpFsOptions{})
+				return dir
+			},
+			generic: "Ljava/util/List<TT;>;\n",
+		},
+		{
+			name: "plain field",
+			dir: func(conn *debug.Connection) fs.InodeEmbedder {
+				dir, _ := NewClassFieldDir(context.Background(), conn, 42, 2, valueRenderer{}, JdwpFsOptions{})
+				return dir
+			},
+			generic: "",
+		},
+		{
+			name: "generic method",
+			dir: func(conn *debug.Connection) fs.InodeEmbedder {
+				dir, _ := NewClassMethodDir(context.Background(), conn, 42, 7, nil, JdwpFsOptions{})
+				return dir
+			},
+			generic: "()TT;\n",
+		},
+		{
+			name: "plain method",
+			dir: func(conn *debug.Connection) fs.InodeEmbedder {
+				dir, _ := NewClassMethodDir(context.Background(), conn, 42, 8, nil, JdwpFsOptions{})
+				return dir
+			},
+			generic: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, genericHandler)
+			dir := test.dir(conn)
+			fs.NewNodeFS(dir, &fs.Options{})
+
+			if listed := listNames(t, dir.(fs.NodeReaddirer)); !containsName(listed, "generic") {
+				t.Fatalf("generic isn't listed in %v", listed)
+			}
+
+			node, errno := dir.(fs.NodeLookuper).Lookup(context.Background(), "generic", nil)
+			if errno != syscall.F_OK {
+				t.Fatalf("lookup: %v", errno)
+			}
+			if generic := readNode(t, node.Operations().(fs.NodeReader), 0); generic != test.generic {
+				t.Fatalf("read %q, expected %q", generic, test.generic)
+			}
+		})
+	}
+}
+
+func containsName(names []string, name string) bool {
+	for _, listed := range names {
+		if listed == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReadObsolete(t *testing.T) {
+	var tests = []struct {
+		name string
+		errorCode uint16
+		reply []byte
+		errno syscall.Errno
+		obsolete string
+	}{
+		{ name: "obsolete", reply: []byte{1}, obsolete: "true\n" },
+		{ name: "current", reply: []byte{0}, obsolete: "false\n" },
+		{ name: "not implemented", errorCode: uint16(jdwp.ErrNotImplemented), obsolete: "false\n" },
+		{ name: "invalid method", errorCode: uint16(jdwp.ErrInvalidMethodID), errno: syscall.EFAULT },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(6, 4, test.errorCode, test.reply)
+			methodDir, _ := NewClassMethodDir(context.Background(), conn, 42, 7, nil, JdwpFsOptions{})
+
+			obsolete, errno := methodDir.readObsolete()
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if string(obsolete) != test.obsolete {
+				t.Fatalf("read %q, expected %q", obsolete, test.obsolete)
+			}
+		})
+	}
+}
+
+func TestClassFileVersion(t *testing.T) {
+	var tests = []struct {
+		name string
+		errorCode uint16
+		reply []byte
+		version string
+	}{
+		{ name: "known", reply: fakeConcat(fakeInt(52), fakeInt(0)), version: "major: 52\nminor: 0\n" },
+		{ name: "array", errorCode: uint16(jdwp.ErrAbsentInformation) },
+		{ name: "not implemented", errorCode: uint16(jdwp.ErrNotImplemented) },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(2, 17, test.errorCode, test.reply)
+			classDir, err := NewJdwpClassInfoDir(context.Background(), conn, 42, nil, JdwpFsOptions{})
+			if err != nil {
+				t.Fatalf("unable to make the class dir: %s", err)
+			}
+			fs.NewNodeFS(classDir, &fs.Options{})
+
+			// absent rather than failing, when the VM doesn't know it
+			if listed := containsName(listNames(t, classDir), "classFileVersion"); listed != (test.version != "") {
+				t.Fatalf("classFileVersion listed: %v", listed)
+			}
+
+			node, errno := classDir.Lookup(context.Background(), "classFileVersion", nil)
+			if test.version == "" {
+				if errno != syscall.ENOENT {
+					t.Fatalf("lookup: got %v, expected ENOENT", errno)
+				}
+				return
+			}
+			if errno != syscall.F_OK {
+				t.Fatalf("lookup: %v", errno)
+			}
+			if version := readNode(t, node.Operations().(fs.NodeReader), 0); version != test.version {
+				t.Fatalf("read %q, expected %q", version, test.version)
+			}
+		})
+	}
+}
+
+// methodCodeHandler has a concrete method 7, a native method 8 and an
+// abstract method 9; the VM only has code for the concrete one
+func methodCodeHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 2 && cmd == 15:
+		return 0, fakeConcat(fakeInt(3),
+			fakeLong(7), fakeString("run"), fakeString("()V"), fakeString(""), fakeInt(0x1),
+			fakeLong(8), fakeString("hash"), fakeString("()I"), fakeString(""), fakeInt(0x101),
+			fakeLong(9), fakeString("apply"), fakeString("()V"), fakeString(""), fakeInt(0x401))
+	case set == 6 && data[15] != 7:
+		return uint16(jdwp.ErrAbsentInformation), nil
+	case set == 6 && cmd == 1:
+		return 0, fakeConcat(fakeLong(0), fakeLong(1), fakeInt(1), fakeLong(0), fakeInt(3))
+	case set == 6 && cmd == 3:
+		return 0, fakeConcat(fakeInt(2), []byte { 0xb1, 0x00 })
+	}
+	return 0, nil
+}
+
+func TestMethodCode(t *testing.T) {
+	var tests = []struct {
+		name string
+		method jdwp.MethodID
+		files map[string]string
+	}{
+		{
+			name: "concrete",
+			method: 7,
+			files: map[string]string {
+				"native": "false\n",
+				"abstract": "false\n",
+				"bytecode": "\xb1\x00",
+				"lineTable": "start: 0\nend: 1\n0\t3\n",
+			},
+		},
+		{
+			name: "native",
+			method: 8,
+			files: map[string]string {
+				"native": "true\n",
+				"abstract": "false\n",
+				"bytecode": "",
+				"lineTable": "",
+				"variableTable": "",
+			},
+		},
+		{
+			name: "abstract",
+			method: 9,
+			files: map[string]string {
+				"native": "false\n",
+				"abstract": "true\n",
+				"bytecode": "",
+				"lineTable": "",
+				"variableTable": "",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, methodCodeHandler)
+			vm.Answer(1, 17, 0, fakeCapabilities(2))
+			methodDir, _ := NewClassMethodDir(context.Background(), conn, 42, test.method, nil, JdwpFsOptions{})
+			fs.NewNodeFS(methodDir, &fs.Options{})
+
+			for name, contents := range test.files {
+				node, errno := methodDir.Lookup(context.Background(), name, nil)
+				if errno != syscall.F_OK {
+					t.Fatalf("lookup %s: %v", name, errno)
+				}
+				if read := readNode(t, node.Operations().(fs.NodeReader), 0); read != contents {
+					t.Fatalf("%s: read %q, expected %q", name, read, contents)
+				}
+			}
+
+			// without code, the VM isn't asked
+			if test.method != 7 {
+				if asked := vm.Received(6, 1) + vm.Received(6, 3) + vm.Received(6, 2); asked != 0 {
+					t.Fatalf("asked the VM for code %d times", asked)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+// prefetchClasses warms the cached methods and fields, plain and with
+// their generic signatures, of the visible classes whose signature starts
+// with one of the prefixes, at most rate classes a second, 0 meaning as
+// fast as the VM answers; it stops early once ctx is done
+func prefetchClasses(ctx context.Context, conn *debug.Connection, classFilter *ClassFilter, prefixes []string, rate int) {
+	classes, err := conn.GetAllClasses()
+	if err != nil {
+		log.Printf("unable to list the classes to prefetch: %s\n", err)
+		return
+	}
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var prefetched = 0
+	for _, class := range classes {
+		if !classFilter.Matches(class.Signature) || !hasAnyPrefix(class.Signature, prefixes) {
+			continue
+		}
+
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		_, err := conn.GetMethods(class.TypeID)
+		if err == nil {
+			_, err = conn.GetFields(class.TypeID)
+		}
+		// the class dirs read the members with their generic signatures
+		if err == nil {
+			_, err = conn.GetMethodsWithGeneric(class.TypeID)
+		}
+		if err == nil {
+			_, err = conn.GetFieldsWithGeneric(class.TypeID)
+		}
+		if err != nil {
+			// the class may have been unloaded since it was listed
+			log.Printf("unable to prefetch class %s: %s\n", class.Signature, err)
+			continue
+		}
+		prefetched++
+	}
+
+	log.Printf("prefetched %d classes\n", prefetched)
+}
+
+func hasAnyPrefix(signature string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(signature, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
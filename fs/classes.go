@@ -8,12 +8,13 @@ import (
 	"fmt"
 	"syscall"
 	"log"
-	"strconv"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 
 	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
 )
 
 //
@@ -39,17 +40,33 @@ type JdwpClassMasterDir struct {
 	fs.Inode
 
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	classFilter *ClassFilter
+	manager *debug.EventManager
+
+	// how the values of static fields are shown
+	values valueRenderer
+
+	// listings are cut at maxEntries, 0 for no limit
+	maxEntries int
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpClassMasterDir)(nil))
 var _ = (fs.NodeReaddirer)((*JdwpClassMasterDir)(nil))
 var _ = (fs.NodeLookuper)((*JdwpClassMasterDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpClassMasterDir)(nil))
 
-func NewJdwpClassMasterDir(ctx context.Context, conn *jdwp.Connection) (*JdwpClassMasterDir, error) {
+func NewJdwpClassMasterDir(ctx context.Context, conn *debug.Connection, classFilter *ClassFilter, manager *debug.EventManager, maxEntries int, options JdwpFsOptions) (*JdwpClassMasterDir, error) {
 	newClassDir := &JdwpClassMasterDir {
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+		classFilter: classFilter,
+		manager: manager,
+		maxEntries: maxEntries,
+		Options: options,
 	}
 
 	return newClassDir, nil
@@ -57,10 +74,15 @@ func NewJdwpClassMasterDir(ctx context.Context, conn *jdwp.Connection) (*JdwpCla
 
 
 func (d *JdwpClassMasterDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *JdwpClassMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *JdwpClassMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	// classes directories
 	classInfos, err := d.JdwpConnection.GetAllClasses()
@@ -71,29 +93,51 @@ func (d *JdwpClassMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall
 
 	var classInfoEntries []fuse.DirEntry
 	for _, classInfo := range classInfos {
-		newClassDir, err := NewJdwpClassInfoDir(d.JdwpContext, d.JdwpConnection, classInfo.TypeID)
+		if !d.classFilter.Matches(classInfo.Signature) {
+			continue
+		}
+
+		newClassDir, err := NewJdwpClassInfoDir(d.JdwpContext, d.JdwpConnection, classInfo.TypeID, d.manager, d.Options)
 		if err != nil {
 			log.Printf("error creating class dir for %d: %s", classInfo.TypeID, err)
 			return nil, syscall.EFAULT
 		}
+		newClassDir.classFilter = d.classFilter
+		newClassDir.values = d.values
 
 		classInfoEntries = append(classInfoEntries, newClassDir.GetDirEntry(ctx))
 	}
 	
-	return fs.NewListDirStream(classInfoEntries), 0
+	return fs.NewListDirStream(truncateEntries(classInfoEntries, d.maxEntries)), 0
 }
 
 func (d *JdwpClassMasterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {	
-	classId, err := strconv.ParseUint(name, 10, 64)
+	if name == truncatedEntryName && d.maxEntries > 0 {
+		return newTruncatedInode(ctx, &d.Inode, d.maxEntries, d.Options), syscall.F_OK
+	}
+
+	classId, err := d.Options.parseId(name)
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+
+	signature, err := d.JdwpConnection.GetTypeSignature(jdwp.ReferenceTypeID(classId))
 	if err != nil {
+		log.Printf("could not access class with id %d\n", classId)
+		return nil, syscall.ENOENT
+	}
+
+	if !d.classFilter.Matches(signature) {
 		return nil, syscall.ENOENT
 	}
 
-	classEntry, err := NewJdwpClassInfoDir(d.JdwpContext, d.JdwpConnection, jdwp.ReferenceTypeID(classId))
+	classEntry, err := NewJdwpClassInfoDir(d.JdwpContext, d.JdwpConnection, jdwp.ReferenceTypeID(classId), d.manager, d.Options)
 	if err != nil {
 		log.Printf("could not access class with id %d\n", classId)
 		return nil, syscall.ENOENT
 	}
+	classEntry.classFilter = d.classFilter
+	classEntry.values = d.values
 	
 	classEntryInode := d.NewInode(
 		ctx,
@@ -5,16 +5,16 @@ package fs
 
 import (
 	"context"
-	"path/filepath"
 	"net/url"
 	"syscall"
 	"log"
-	"strconv"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 
 	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
 )
 
 //
@@ -23,31 +23,44 @@ import (
 type JdwpClassNamedMasterDir struct {
 	fs.Inode
 
-	AbsoluteMountpoint string
-	
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	classFilter *ClassFilter
+
+	// listings are cut at maxEntries, 0 for no limit
+	maxEntries int
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpClassNamedMasterDir)(nil))
 var _ = (fs.NodeReaddirer)((*JdwpClassNamedMasterDir)(nil))
 var _ = (fs.NodeLookuper)((*JdwpClassNamedMasterDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpClassNamedMasterDir)(nil))
 
-func NewJdwpClassNamedMasterDir(ctx context.Context, conn *jdwp.Connection, absMountpoint string) (*JdwpClassNamedMasterDir, error) {
+func NewJdwpClassNamedMasterDir(ctx context.Context, conn *debug.Connection, classFilter *ClassFilter, maxEntries int, options JdwpFsOptions) (*JdwpClassNamedMasterDir, error) {
 	newClassDir := &JdwpClassNamedMasterDir {
-		AbsoluteMountpoint: absMountpoint,
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+		classFilter: classFilter,
+		maxEntries: maxEntries,
+		Options: options,
 	}
 
 	return newClassDir, nil
 }
 
 func (d *JdwpClassNamedMasterDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *JdwpClassNamedMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *JdwpClassNamedMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	// classes directories
 	classInfos, err := d.JdwpConnection.GetAllClasses()
@@ -59,6 +72,9 @@ func (d *JdwpClassNamedMasterDir) Readdir(ctx context.Context) (fs.DirStream, sy
 	var classInfoNamedEntries []fuse.DirEntry
 	for _, classInfo := range classInfos {
 		classSignature := classInfo.Signature
+		if !d.classFilter.Matches(classSignature) {
+			continue
+		}
 		
 		classNamedEntry := fuse.DirEntry {
 			Mode: fuse.S_IFLNK,
@@ -69,47 +85,59 @@ func (d *JdwpClassNamedMasterDir) Readdir(ctx context.Context) (fs.DirStream, sy
 			append(classInfoNamedEntries, classNamedEntry)
 	}
 	
-	return fs.NewListDirStream(classInfoNamedEntries), 0
+	return fs.NewListDirStream(truncateEntries(classInfoNamedEntries, d.maxEntries)), 0
+}
+
+// findClass asks the VM for the classes with the signature; 0 means
+// none was found
+func (d *JdwpClassNamedMasterDir) findClass(signature string) (jdwp.ReferenceTypeID, error) {
+	classInfos, err := d.JdwpConnection.GetClassesBySignature(signature)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, classInfo := range classInfos {
+		if classInfo.Signature == signature {
+			return classInfo.TypeID, nil
+		}
+	}
+
+	return 0, nil
 }
 
 func (d *JdwpClassNamedMasterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == truncatedEntryName && d.maxEntries > 0 {
+		return newTruncatedInode(ctx, &d.Inode, d.maxEntries, d.Options), syscall.F_OK
+	}
+
 	searchedClassSignature, err := url.PathUnescape(name)
 	if err != nil {
 		log.Printf("unable to unescape name %s\n", name)
 		return nil, syscall.EFAULT
 	}
 
-	var foundClassId jdwp.ReferenceTypeID
-	allClassInfos, err := d.JdwpConnection.GetAllClasses()
-	if err != nil {
-		log.Printf("unable to get all class infos: %s\n", err)
-		return nil, syscall.EBADF
+	if !d.classFilter.Matches(searchedClassSignature) {
+		return nil, syscall.ENOENT
 	}
 
-	for _, classInfo := range allClassInfos {
-		classSignature := classInfo.Signature
-
-		if classSignature == searchedClassSignature {
-			foundClassId = classInfo.TypeID
-		}
+	foundClassId, err := d.findClass(searchedClassSignature)
+	if err != nil {
+		log.Printf("unable to get class infos: %s\n", err)
+		return nil, syscall.EBADF
 	}
 
 	if foundClassId == 0 {
-		log.Printf("unable to find thread with name %s\n", searchedClassSignature)
+		log.Printf("unable to find class with signature %s\n", searchedClassSignature)
 		return nil, syscall.EFAULT
 	}
 
-	symlinkPath :=  filepath.Join(
-		d.AbsoluteMountpoint,
-		"classes",
-		strconv.FormatUint(uint64(foundClassId), 10),
-	)
+	symlinkPath := relativeLink(&d.Inode, "classes/" + d.Options.formatId(uint64(foundClassId)))
 	
 	classEntryInode := d.NewInode(
 		ctx,
 		&fs.MemSymlink {
 			Data: []byte(symlinkPath),
-			Attr: fuse.Attr { Mode: 0444 },
+			Attr: staticAttr(d.Options.fileMode()),
 		},
 		fs.StableAttr{
 			Mode: fuse.S_IFLNK,
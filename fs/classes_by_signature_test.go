@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestFindClass(t *testing.T) {
+	var tests = []struct {
+		signature string
+		classId jdwp.ReferenceTypeID
+	}{
+		{ signature: "Lcom/myapp/Main;", classId: 41 },
+		{ signature: "Ljava/lang/String;", classId: 43 },
+		{ signature: "Lcom/myapp/Missing;", classId: 0 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.signature, func(t *testing.T) {
+			conn, vm := startFakeVM(t, classListHandler)
+			classesDir, _ := NewJdwpClassNamedMasterDir(context.Background(), conn, nil, 0, JdwpFsOptions{})
+
+			classId, err := classesDir.findClass(test.signature)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if classId != test.classId {
+				t.Fatalf("found class %d, expected %d", classId, test.classId)
+			}
+
+			// only the class looked for is asked for
+			if sent := vm.Received(1, 2); sent != 1 {
+				t.Fatalf("sent %d ClassesBySignature commands, expected 1", sent)
+			}
+			if sent := vm.Received(1, 3); sent != 0 {
+				t.Fatalf("listed all the classes %d times", sent)
+			}
+		})
+	}
+}
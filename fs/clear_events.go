@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Clear events file
+// Writing anything to it cancels and removes every event, for a clean
+// slate; reading reports how many were removed by the last write
+//
+type ClearEventsFile struct {
+	fs.Inode
+
+	mu sync.Mutex
+	report string
+
+	manager *debug.EventManager
+	// the events directory, whose entries go away
+	eventsDir *fs.Inode
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ClearEventsFile)(nil))
+var _ = (fs.NodeSetattrer)((*ClearEventsFile)(nil))
+var _ = (fs.NodeOpener)((*ClearEventsFile)(nil))
+var _ = (fs.NodeOpendirer)((*ClearEventsFile)(nil))
+var _ = (fs.NodeReader)((*ClearEventsFile)(nil))
+var _ = (fs.NodeWriter)((*ClearEventsFile)(nil))
+
+func NewClearEventsFile(manager *debug.EventManager, eventsDir *fs.Inode, options JdwpFsOptions) ClearEventsFile {
+	return ClearEventsFile {
+		manager: manager,
+		eventsDir: eventsDir,
+		Options: options,
+	}
+}
+
+func (c *ClearEventsFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *ClearEventsFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *ClearEventsFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (c *ClearEventsFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (c *ClearEventsFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	c.mu.Lock()
+	report := c.report
+	c.mu.Unlock()
+
+	if offset > int64(len(report)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(report[offset:])), 0
+}
+
+// Write fails with EIO if some events couldn't be cancelled; those are
+// kept, and the report says which ones
+func (c *ClearEventsFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyEventCreate)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events, err := c.manager.GetAllEvents()
+	if err != nil {
+		log.Printf("unable to get events: %s\n", err)
+		return 0, syscall.EFAULT
+	}
+
+	var cleared = 0
+	var failures []string
+	for _, event := range events {
+		if event.IsRunning() {
+			err = event.Cancel()
+			if err != nil {
+				log.Printf("error cancelling event %s: %s\n", event.Name, err)
+				failures = append(failures, fmt.Sprintf("%s\t%s", event.Name, err))
+				continue
+			}
+		}
+
+		err = c.manager.DeregisterEvent(event.Name)
+		if err != nil {
+			log.Printf("unable to remove event %s: %s\n", event.Name, err)
+			failures = append(failures, fmt.Sprintf("%s\t%s", event.Name, err))
+			continue
+		}
+		cleared++
+
+		if c.eventsDir != nil {
+			c.eventsDir.NotifyEntry(event.Name)
+		}
+	}
+
+	report := fmt.Sprintf("cleared %d of %d\n", cleared, len(events))
+	for _, failure := range failures {
+		report = fmt.Sprintf("%sfailed %s\n", report, failure)
+	}
+	c.report = report
+
+	if len(failures) != 0 {
+		return 0, syscall.EIO
+	}
+
+	return uint32(len(data)), 0
+}
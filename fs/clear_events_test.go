@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestClearEvents(t *testing.T) {
+	var tests = []struct {
+		name string
+		events []stubEvent
+		running []string
+		report string
+	}{
+		{ name: "no events", report: "cleared 0 of 0\n" },
+		{
+			name: "stopped events",
+			events: []stubEvent {
+				{ name: "starts", kind: jdwp.ThreadStart },
+				{ name: "deaths", kind: jdwp.ThreadDeath },
+			},
+			report: "cleared 2 of 2\n",
+		},
+		{
+			name: "running events",
+			events: []stubEvent {
+				{ name: "starts", kind: jdwp.ThreadStart },
+				{ name: "deaths", kind: jdwp.ThreadDeath },
+				{ name: "stopped", kind: jdwp.ThreadStart },
+			},
+			running: []string { "starts", "deaths" },
+			report: "cleared 3 of 3\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, 0, fakeInt(1))
+			manager, err := debug.NewEventManager(context.Background(), conn)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			for _, stub := range test.events {
+				event, err := manager.CreateEvent(stub.name)
+				if err != nil {
+					t.Fatalf("unable to create event %s: %s", stub.name, err)
+				}
+				event.SetKind(stub.kind)
+			}
+			for _, name := range test.running {
+				event, _ := manager.GetEvent(name)
+				if _, err := event.Run(); err != nil {
+					t.Fatalf("unable to run event %s: %s", name, err)
+				}
+			}
+
+			clearFile := NewClearEventsFile(manager, nil, JdwpFsOptions{})
+			written, errno := clearFile.Write(context.Background(), nil, []byte("1\n"), 0)
+			if errno != syscall.F_OK || written != 2 {
+				t.Fatalf("write: %d bytes, %v", written, errno)
+			}
+			if report := readNode(t, &clearFile, 0); report != test.report {
+				t.Fatalf("reported %q, expected %q", report, test.report)
+			}
+
+			events, err := manager.GetAllEvents()
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if len(events) != 0 {
+				t.Fatalf("%d events left", len(events))
+			}
+			for _, stub := range test.events {
+				if _, err := manager.GetEvent(stub.name); err == nil {
+					t.Fatalf("event %s is still registered", stub.name)
+				}
+			}
+			if cleared := vm.Received(15, 2); cleared != len(test.running) {
+				t.Fatalf("cleared %d requests, expected %d", cleared, len(test.running))
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+//
+// Control handle
+// Writers may split a token over several writes; the handle of an open
+// control file gathers them, and hands the file a token once a newline
+// ends it, or once the handle is flushed, e.g. on close. The error of a
+// token applied on flush is the one close returns. The writes follow one
+// another, so each starts where the bytes taken so far end
+//
+type ControlHandle struct {
+	mu sync.Mutex
+
+	pending []byte
+	taken int64
+	apply func(token []byte) syscall.Errno
+
+	// a batch is a single token, whatever its newlines, applied once
+	// the handle is flushed
+	batch bool
+
+	// what the file read as when it was opened, for files whose reads
+	// through one handle have to agree; nil to read the file itself
+	contents []byte
+}
+
+var _ = (fs.FileFlusher)((*ControlHandle)(nil))
+var _ = (fs.FileReleaser)((*ControlHandle)(nil))
+
+func NewControlHandle(apply func(token []byte) syscall.Errno) *ControlHandle {
+	return &ControlHandle {
+		apply: apply,
+	}
+}
+
+// NewBatchHandle gathers all the writes of the handle into one token,
+// for files taking a document rather than lines
+func NewBatchHandle(apply func(token []byte) syscall.Errno) *ControlHandle {
+	return &ControlHandle {
+		apply: apply,
+		batch: true,
+	}
+}
+
+// Write takes the whole of data, applying the tokens it ends; when one
+// fails, what was gathered is dropped. A write anywhere but right after
+// the bytes taken would be a token out of order, and fails with EINVAL
+func (h *ControlHandle) Write(data []byte, off int64) (written uint32, errno syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if off != h.taken {
+		return 0, syscall.EINVAL
+	}
+
+	h.pending = append(h.pending, data...)
+	for !h.batch {
+		end := bytes.IndexByte(h.pending, '\n')
+		if end < 0 {
+			break
+		}
+
+		token := h.pending[:end + 1]
+		h.pending = h.pending[end + 1:]
+
+		errno = h.apply(token)
+		if errno != syscall.F_OK {
+			h.pending = nil
+			return 0, errno
+		}
+	}
+
+	h.taken += int64(len(data))
+	return uint32(len(data)), syscall.F_OK
+}
+
+// Flush applies what's left unterminated, if anything but whitespace
+func (h *ControlHandle) Flush(ctx context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	token := h.pending
+	h.pending = nil
+	if len(bytes.TrimSpace(token)) == 0 {
+		return syscall.F_OK
+	}
+
+	return h.apply(token)
+}
+
+func (h *ControlHandle) Release(ctx context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pending = nil
+
+	return syscall.F_OK
+}
+
+// writeControl passes a write to the handle it's made through; without
+// one, e.g. when the node is written directly, data is a whole token,
+// written at offset 0
+func writeControl(fh fs.FileHandle, data []byte, off int64, apply func(token []byte) syscall.Errno) (uint32, syscall.Errno) {
+	handle, ok := fh.(*ControlHandle)
+	if ok {
+		return handle.Write(data, off)
+	}
+
+	if off != 0 {
+		return 0, syscall.EINVAL
+	}
+
+	errno := apply(data)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return uint32(len(data)), syscall.F_OK
+}
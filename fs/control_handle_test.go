@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+type controlWrite struct {
+	data string
+	off int64
+	errno syscall.Errno
+}
+
+func TestControlHandle(t *testing.T) {
+	var tests = []struct {
+		name string
+		writes []controlWrite
+		flushErrno syscall.Errno
+		tokens []string
+	}{
+		{
+			name: "whole token",
+			writes: []controlWrite {
+				{ data: "run\n", off: 0 },
+			},
+			tokens: []string { "run\n" },
+		},
+		{
+			name: "split token",
+			writes: []controlWrite {
+				{ data: "ru", off: 0 },
+				{ data: "n\nca", off: 2 },
+				{ data: "ncel\n", off: 6 },
+			},
+			tokens: []string { "run\n", "cancel\n" },
+		},
+		{
+			name: "token ended by the flush",
+			writes: []controlWrite {
+				{ data: "run", off: 0 },
+			},
+			tokens: []string { "run" },
+		},
+		{
+			name: "first write past 0",
+			writes: []controlWrite {
+				{ data: "run\n", off: 4, errno: syscall.EINVAL },
+			},
+		},
+		{
+			name: "rewrite of taken bytes",
+			writes: []controlWrite {
+				{ data: "ru", off: 0 },
+				{ data: "ru", off: 0, errno: syscall.EINVAL },
+				{ data: "n\n", off: 2 },
+			},
+			tokens: []string { "run\n" },
+		},
+		{
+			name: "gap between writes",
+			writes: []controlWrite {
+				{ data: "run\n", off: 0 },
+				{ data: "cancel\n", off: 8, errno: syscall.EINVAL },
+			},
+			tokens: []string { "run\n" },
+		},
+		{
+			name: "failed token",
+			writes: []controlWrite {
+				{ data: "fail\n", off: 0, errno: syscall.EAFNOSUPPORT },
+				{ data: "run\n", off: 0 },
+			},
+			tokens: []string { "run\n" },
+		},
+		{
+			name: "failed token on flush",
+			writes: []controlWrite {
+				{ data: "run\nfail", off: 0 },
+			},
+			flushErrno: syscall.EAFNOSUPPORT,
+			tokens: []string { "run\n" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tokens []string
+			handle := NewControlHandle(func(token []byte) syscall.Errno {
+				if strings.HasPrefix(string(token), "fail") {
+					return syscall.EAFNOSUPPORT
+				}
+				tokens = append(tokens, string(token))
+				return syscall.F_OK
+			})
+
+			for i, write := range test.writes {
+				written, errno := handle.Write([]byte(write.data), write.off)
+				if errno != write.errno {
+					t.Fatalf("write %d: got %v, expected %v", i, errno, write.errno)
+				}
+				if errno == syscall.F_OK && written != uint32(len(write.data)) {
+					t.Fatalf("write %d: %d bytes written of %d", i, written, len(write.data))
+				}
+			}
+
+			errno := handle.Flush(context.Background())
+			if errno != test.flushErrno {
+				t.Fatalf("flush: got %v, expected %v", errno, test.flushErrno)
+			}
+			if !reflect.DeepEqual(tokens, test.tokens) {
+				t.Fatalf("got tokens %q, expected %q", tokens, test.tokens)
+			}
+		})
+	}
+}
+
+func TestWriteControlWithoutHandle(t *testing.T) {
+	var tests = []struct {
+		off int64
+		errno syscall.Errno
+	}{
+		{ off: 0, errno: syscall.F_OK },
+		{ off: 1, errno: syscall.EINVAL },
+		{ off: 4096, errno: syscall.EINVAL },
+	}
+
+	for _, test := range tests {
+		applied := false
+		_, errno := writeControl(nil, []byte("run\n"), test.off, func(token []byte) syscall.Errno {
+			applied = true
+			return syscall.F_OK
+		})
+		if errno != test.errno {
+			t.Errorf("offset %d: got %v, expected %v", test.off, errno, test.errno)
+		}
+		if applied != (test.errno == syscall.F_OK) {
+			t.Errorf("offset %d: token applied: %v", test.off, applied)
+		}
+	}
+}
+
+// TestThreadControlSplitToken writes tokens split over two writes through
+// the handles of the thread and threads control files
+func TestThreadControlSplitToken(t *testing.T) {
+	var tests = []struct {
+		name string
+		master bool
+		suspended bool
+		writes []string
+		set uint8
+		cmd uint8
+	}{
+		{ name: "suspend", suspended: false, writes: []string { "sus", "pend\n" }, set: 11, cmd: 2 },
+		{ name: "running", suspended: true, writes: []string { "runn", "ing\n" }, set: 11, cmd: 3 },
+		{ name: "suspend all", master: true, writes: []string { "sus", "pend\n" }, set: 1, cmd: 8 },
+		{ name: "resume all", master: true, writes: []string { "runn", "ing\n" }, set: 1, cmd: 9 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			var suspendStatus uint32
+			if test.suspended {
+				suspendStatus = 1
+			}
+			vm.Answer(11, 4, 0, fakeConcat(fakeInt(uint32(jdwp.ThreadRunning)), fakeInt(suspendStatus)))
+
+			var file interface {
+				Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno)
+				Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (uint32, syscall.Errno)
+			}
+			if test.master {
+				masterFile := NewThreadMasterControlFile(context.Background(), conn, JdwpFsOptions{})
+				file = &masterFile
+			} else {
+				controlFile := NewThreadControlFile(context.Background(), conn, jdwp.ThreadID(7), JdwpFsOptions{})
+				file = &controlFile
+			}
+
+			fh, _, errno := file.Open(context.Background(), syscall.O_WRONLY)
+			if errno != syscall.F_OK {
+				t.Fatalf("open: %v", errno)
+			}
+
+			var off int64
+			for _, data := range test.writes {
+				written, errno := file.Write(context.Background(), fh, []byte(data), off)
+				if errno != syscall.F_OK {
+					t.Fatalf("write %q: %v", data, errno)
+				}
+				off += int64(written)
+			}
+
+			for _, command := range [][2]uint8 { { 11, 2 }, { 11, 3 }, { 1, 8 }, { 1, 9 } } {
+				expected := 0
+				if command[0] == test.set && command[1] == test.cmd {
+					expected = 1
+				}
+				if received := vm.Received(command[0], command[1]); received != expected {
+					t.Fatalf("command %d/%d sent %d times, expected %d", command[0], command[1], received, expected)
+				}
+			}
+		})
+	}
+}
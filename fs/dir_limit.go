@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// truncatedEntryName ends a truncated listing; it can't be a class id
+// nor an escaped signature
+const truncatedEntryName = "...truncated"
+
+// truncateEntries caps a listing at maxEntries entries, 0 meaning no
+// cap, followed by the sentinel entry if any were left out; the missing
+// ones can still be looked up
+func truncateEntries(entries []fuse.DirEntry, maxEntries int) []fuse.DirEntry {
+	if maxEntries <= 0 || len(entries) <= maxEntries {
+		return entries
+	}
+
+	truncated := append([]fuse.DirEntry{}, entries[:maxEntries]...)
+	return append(truncated, fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: truncatedEntryName,
+	})
+}
+
+// newTruncatedInode is the sentinel file, telling why the listing ends
+func newTruncatedInode(ctx context.Context, parent *fs.Inode, maxEntries int, options JdwpFsOptions) *fs.Inode {
+	message := fmt.Sprintf("listing truncated to %d entries; the others can still be looked up\n", maxEntries)
+	return parent.NewInode(
+		ctx,
+		&fs.MemRegularFile {
+			Data: []byte(message),
+			Attr: staticAttr(options.fileMode()),
+		},
+		fs.StableAttr {
+			Mode: fuse.S_IFREG,
+		})
+}
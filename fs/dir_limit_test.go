@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+const stubClassCount = 200
+
+// manyClassesHandler lists stubClassCount classes, with ids from 1
+func manyClassesHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	if set != 1 || cmd != 3 {
+		return 0, nil
+	}
+
+	reply := fakeInt(stubClassCount)
+	for id := uint64(1); id <= stubClassCount; id++ {
+		reply = fakeConcat(reply, []byte{1}, fakeLong(id), fakeString(fmt.Sprintf("Lcom/myapp/Class%d;", id)), fakeInt(7))
+	}
+	return 0, reply
+}
+
+func TestClassListingTruncated(t *testing.T) {
+	var tests = []struct {
+		name string
+		maxEntries int
+		listed int
+		truncated bool
+	}{
+		{ name: "no limit", maxEntries: 0, listed: stubClassCount },
+		{ name: "above the count", maxEntries: stubClassCount + 1, listed: stubClassCount },
+		{ name: "at the count", maxEntries: stubClassCount, listed: stubClassCount },
+		{ name: "below the count", maxEntries: 50, listed: 50, truncated: true },
+		{ name: "one", maxEntries: 1, listed: 1, truncated: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, manyClassesHandler)
+			classesDir, _ := NewJdwpClassMasterDir(context.Background(), conn, nil, nil, test.maxEntries, JdwpFsOptions{})
+			namedDir, _ := NewJdwpClassNamedMasterDir(context.Background(), conn, nil, test.maxEntries, JdwpFsOptions{})
+
+			for _, dir := range []fs.NodeReaddirer { classesDir, namedDir } {
+				names := listNames(t, dir)
+
+				expected := test.listed
+				if test.truncated {
+					expected++
+				}
+				if len(names) != expected {
+					t.Fatalf("%T: listed %d entries, expected %d", dir, len(names), expected)
+				}
+				if truncated := names[len(names) - 1] == truncatedEntryName; truncated != test.truncated {
+					t.Fatalf("%T: truncated: %v", dir, truncated)
+				}
+			}
+
+			// the first classes are kept, in order
+			names := listNames(t, classesDir)
+			for i := 0; i < test.listed; i++ {
+				if names[i] != strconv.Itoa(i + 1) {
+					t.Fatalf("entry %d is %s", i, names[i])
+				}
+			}
+		})
+	}
+}
@@ -5,10 +5,14 @@ package fs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"disroot.org/kitzman/jdwpfs/debug"
@@ -46,9 +50,53 @@ var (
 		"SuspendAll": jdwp.SuspendAll,
 	}
 
-	
+	// `run <override>` tokens of the control file
+	suspendPolicyOverrideMap = map[string]jdwp.SuspendPolicy {
+		"suspendNone": jdwp.SuspendNone,
+		"suspendEventThread": jdwp.SuspendEventThread,
+		"suspendAll": jdwp.SuspendAll,
+	}
+
+	// the usage texts of the .help files, next to the files they describe
+	eventControlHelp = "control - registers the event with the VM, or cancels it\n" +
+		"run\tregister the event\n" +
+		"1\tsame as run\n" +
+		"cancel\tcancel the event\n" +
+		"0\tsame as cancel\n" +
+		"run suspendNone\trun with SuspendNone, for this run only\n" +
+		"run suspendEventThread\trun with SuspendEventThread, for this run only\n" +
+		"run suspendAll\trun with SuspendAll, for this run only\n"
 )
 
+func helpFromTokens(description string, tokens []string) string {
+	sort.Strings(tokens)
+
+	var help = description + "\n"
+	for _, token := range tokens {
+		help = help + token + "\n"
+	}
+
+	return help
+}
+
+func eventKindHelp() string {
+	var tokens = []string{}
+	for token := range eventKindReprMap {
+		tokens = append(tokens, token)
+	}
+
+	return helpFromTokens("kind - the JDWP event kind to request", tokens)
+}
+
+func eventSuspendPolicyHelp() string {
+	var tokens = []string{}
+	for token := range suspendPolicyReprMap {
+		tokens = append(tokens, token)
+	}
+
+	return helpFromTokens("suspendPolicy - which threads the VM suspends when the event fires", tokens)
+}
+
 
 //
 // EventControlFile
@@ -58,22 +106,31 @@ type EventControlFile struct {
 	fs.Inode
 
 	event *debug.DebuggingEvent
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeOpener)((*EventControlFile)(nil))
+var _ = (fs.NodeOpendirer)((*EventControlFile)(nil))
 var _ = (fs.NodeGetattrer)((*EventControlFile)(nil))
 var _ = (fs.NodeSetattrer)((*EventControlFile)(nil))
 var _ = (fs.NodeReader)((*EventControlFile)(nil))
 var _ = (fs.NodeWriter)((*EventControlFile)(nil))
 
-func NewEventControlFile(event *debug.DebuggingEvent) EventControlFile {
+func NewEventControlFile(event *debug.DebuggingEvent, options JdwpFsOptions) EventControlFile {
 	return EventControlFile {
 		event: event,
+		Options: options,
 	}
 }
 
 
 func (c *EventControlFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
 	if flags & (
 		syscall.O_APPEND |
 		syscall.O_CLOEXEC |
@@ -82,11 +139,16 @@ func (c *EventControlFile) Open(ctx context.Context, flags uint32) (fh fs.FileHa
 		return nil, 0, syscall.EBADR
 	}
 
-	return nil, fuse.FOPEN_DIRECT_IO, 0
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *EventControlFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
 }
 
 func (c *EventControlFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0660
+	out.Mode = c.Options.controlMode()
+	setTimes(out, c.event.GetChangedAt())
 	return 0
 }
 
@@ -104,14 +166,18 @@ func (c *EventControlFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fus
 }
 
 func (c *EventControlFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
-	isRunning := c.event.IsRunning()
+	// the status is taken in one go, so the fields are consistent
+	// with each other; the first token stays running/idle
+	status := c.event.GetStatus()
 	var readString string
-	switch isRunning {
+	switch status.Running {
 	case true:
 		readString = "running"
 	case false:
 		readString = "idle"
 	}
+	readString = fmt.Sprintf("%s registered=%t modifiers=%d hooks=%d\n",
+		readString, status.Registered, status.Modifiers, status.Hooks)
 	
 	if offset > int64(len(readString)) {
 		return nil, syscall.EBADR
@@ -120,36 +186,169 @@ func (c *EventControlFile) Read(ctx context.Context, _ fs.FileHandle, dest []byt
 	return fuse.ReadResultData([]byte(readString[offset:])), syscall.F_OK
 }
 
-func (c *EventControlFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
-	writtenData := strings.TrimSpace(string(data))
-	switch writtenData {
-	case "run":
-	case "1":
+func (c *EventControlFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyEventRun)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *EventControlFile) writeToken(data []byte) syscall.Errno {
+	tokens := strings.Fields(string(data))
+	if len(tokens) == 0 || len(tokens) > 2 {
+		return syscall.EBADMSG
+	}
+
+	var policyOverride *jdwp.SuspendPolicy = nil
+	if len(tokens) == 2 {
+		if tokens[0] != "run" {
+			return syscall.EBADMSG
+		}
+
+		policy, ok := suspendPolicyOverrideMap[tokens[1]]
+		if !ok {
+			return syscall.EINVAL
+		}
+		policyOverride = &policy
+	}
+
+	switch tokens[0] {
+	case "run", "1":
 		if c.event.IsRunning() {
-			return 0, syscall.ENAVAIL
+			return syscall.ENAVAIL
+		}
+
+		if !c.event.IsEnabled() {
+			return syscall.EPERM
 		}
 
-		_, err := c.event.Run()
+		var err error
+		if policyOverride != nil {
+			_, err = c.event.RunWithSuspendPolicy(*policyOverride)
+		} else {
+			_, err = c.event.Run()
+		}
 		if err != nil {
 			log.Printf("error running event %s: %s", c.event.Name, err)
-			return 0, syscall.EBADE
+			return syscall.EBADE
 		}
-	case "cancel":
-	case "0":
+	case "cancel", "0":
 		if !c.event.IsRunning() {
-			return 0, syscall.ENAVAIL
+			return syscall.ENAVAIL
 		}
 
 		err := c.event.Cancel()
 		if err != nil {
 			log.Printf("error cancelling event %s: %s", c.event.Name, err)
-			return 0, syscall.EBADE
+			return syscall.EBADE
 		}
 	default:
-		return 0, syscall.EBADMSG
+		return syscall.EBADMSG
 	}
 	
-	return uint32(len(data)), syscall.F_OK
+	return syscall.F_OK
+}
+
+//
+// Event enabled file
+// Arms or disarms the event, independently of it being run
+//
+
+type EventEnabledFile struct {
+	fs.Inode
+
+	event *debug.DebuggingEvent
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeOpener)((*EventEnabledFile)(nil))
+var _ = (fs.NodeOpendirer)((*EventEnabledFile)(nil))
+var _ = (fs.NodeGetattrer)((*EventEnabledFile)(nil))
+var _ = (fs.NodeSetattrer)((*EventEnabledFile)(nil))
+var _ = (fs.NodeReader)((*EventEnabledFile)(nil))
+var _ = (fs.NodeWriter)((*EventEnabledFile)(nil))
+
+func NewEventEnabledFile(event *debug.DebuggingEvent, options JdwpFsOptions) EventEnabledFile {
+	return EventEnabledFile {
+		event: event,
+		Options: options,
+	}
+}
+
+func (c *EventEnabledFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *EventEnabledFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *EventEnabledFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (c *EventEnabledFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (c *EventEnabledFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	var readString string
+	switch c.event.IsEnabled() {
+	case true:
+		readString = "1\n"
+	case false:
+		readString = "0\n"
+	}
+
+	if offset > int64(len(readString)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(readString[offset:])), syscall.F_OK
+}
+
+func (c *EventEnabledFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *EventEnabledFile) writeToken(data []byte) syscall.Errno {
+	writtenData := strings.TrimSpace(string(data))
+	switch writtenData {
+	case "1":
+		c.event.SetEnabled(true)
+	case "0":
+		c.event.SetEnabled(false)
+	default:
+		return syscall.EBADMSG
+	}
+
+	return syscall.F_OK
 }
 
 //
@@ -159,22 +358,31 @@ func (c *EventControlFile) Write(ctx context.Context, _ fs.FileHandle, data []by
 type EventKindFile struct {
 	fs.Inode
 	event *debug.DebuggingEvent
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeOpener)((*EventKindFile)(nil))
+var _ = (fs.NodeOpendirer)((*EventKindFile)(nil))
 var _ = (fs.NodeGetattrer)((*EventKindFile)(nil))
 var _ = (fs.NodeSetattrer)((*EventKindFile)(nil))
 var _ = (fs.NodeReader)((*EventKindFile)(nil))
 var _ = (fs.NodeWriter)((*EventKindFile)(nil))
 
-func NewEventKindFile(event *debug.DebuggingEvent) EventKindFile {
+func NewEventKindFile(event *debug.DebuggingEvent, options JdwpFsOptions) EventKindFile {
 	return EventKindFile {
 		event: event,
+		Options: options,
 	}
 }
 
 
 func (c *EventKindFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
 	if flags & (
 		syscall.O_APPEND |
 		syscall.O_CLOEXEC |
@@ -183,11 +391,16 @@ func (c *EventKindFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandl
 		return nil, 0, syscall.EBADR
 	}
 
-	return nil, fuse.FOPEN_DIRECT_IO, 0
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *EventKindFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
 }
 
 func (c *EventKindFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0660
+	out.Mode = c.Options.controlMode()
+	setMountTimes(out)
 	return 0
 }
 
@@ -206,7 +419,7 @@ func (c *EventKindFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.S
 
 func (c *EventKindFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
 	kind := c.event.GetKind()
-	readString := kind.String()
+	readString := newlineTerminated(kind.String())
 
 	if offset > int64(len(readString)) {
 		return nil, syscall.EBADR
@@ -215,16 +428,20 @@ func (c *EventKindFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte,
 	return fuse.ReadResultData([]byte(readString[offset:])), syscall.F_OK
 }
 
-func (c *EventKindFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+func (c *EventKindFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *EventKindFile) writeToken(data []byte) syscall.Errno {
 	writtenData := strings.TrimSpace(string(data))
 	eventKind, ok := eventKindReprMap[writtenData]
 	if !ok {
-		return 0, syscall.EAFNOSUPPORT
+		return syscall.EAFNOSUPPORT
 	}
 
 	c.event.SetKind(eventKind)
 
-	return uint32(len(data)), syscall.F_OK
+	return syscall.F_OK
 }
 
 //
@@ -233,22 +450,31 @@ func (c *EventKindFile) Write(ctx context.Context, _ fs.FileHandle, data []byte,
 type EventSuspendPolicyFile struct {
 	fs.Inode
 	event *debug.DebuggingEvent
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeOpener)((*EventSuspendPolicyFile)(nil))
+var _ = (fs.NodeOpendirer)((*EventSuspendPolicyFile)(nil))
 var _ = (fs.NodeGetattrer)((*EventSuspendPolicyFile)(nil))
 var _ = (fs.NodeSetattrer)((*EventSuspendPolicyFile)(nil))
 var _ = (fs.NodeReader)((*EventSuspendPolicyFile)(nil))
 var _ = (fs.NodeWriter)((*EventSuspendPolicyFile)(nil))
 
-func NewEventSuspendPolicyFile(event *debug.DebuggingEvent) EventSuspendPolicyFile {
+func NewEventSuspendPolicyFile(event *debug.DebuggingEvent, options JdwpFsOptions) EventSuspendPolicyFile {
 	return EventSuspendPolicyFile {
 		event: event,
+		Options: options,
 	}
 }
 
 
 func (c *EventSuspendPolicyFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
 	if flags & (
 		syscall.O_APPEND |
 		syscall.O_CLOEXEC |
@@ -257,11 +483,16 @@ func (c *EventSuspendPolicyFile) Open(ctx context.Context, flags uint32) (fh fs.
 		return nil, 0, syscall.EBADR
 	}
 
-	return nil, fuse.FOPEN_DIRECT_IO, 0
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *EventSuspendPolicyFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
 }
 
 func (c *EventSuspendPolicyFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0660
+	out.Mode = c.Options.controlMode()
+	setMountTimes(out)
 	return 0
 }
 
@@ -278,9 +509,16 @@ func (c *EventSuspendPolicyFile) Setattr(ctx context.Context, _ fs.FileHandle, i
 	return syscall.F_OK	
 }
 
+// Read gives the configured policy, and the one in effect as well while
+// the event runs with an override
 func (c *EventSuspendPolicyFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
 	suspendPolicy := c.event.GetSuspendPolicy()
-	readString := suspendPolicy.String()
+	readString := newlineTerminated(suspendPolicy.String())
+
+	effectivePolicy, overridden := c.event.GetEffectiveSuspendPolicy()
+	if overridden {
+		readString = fmt.Sprintf("configured=%s effective=%s\n", suspendPolicy, effectivePolicy)
+	}
 
 	if offset > int64(len(readString)) {
 		return nil, syscall.EBADR
@@ -289,17 +527,21 @@ func (c *EventSuspendPolicyFile) Read(ctx context.Context, _ fs.FileHandle, dest
 	return fuse.ReadResultData([]byte(readString[offset:])), syscall.F_OK
 }
 
-func (c *EventSuspendPolicyFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+func (c *EventSuspendPolicyFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *EventSuspendPolicyFile) writeToken(data []byte) syscall.Errno {
 	writtenData := strings.TrimSpace(string(data))
 	suspendPolicy, ok := suspendPolicyReprMap[writtenData]
 	if !ok {
 		log.Printf("unsupported suspend policy: %s\n", writtenData)
-		return 0, syscall.EAFNOSUPPORT
+		return syscall.EAFNOSUPPORT
 	}
 
 	c.event.SetSuspendPolicy(suspendPolicy)
 
-	return uint32(len(data)), syscall.F_OK
+	return syscall.F_OK
 }
 
 
@@ -310,9 +552,11 @@ func (c *EventSuspendPolicyFile) Write(ctx context.Context, _ fs.FileHandle, dat
 type EventLocationDirectory struct {
 	fs.Inode
 
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
 	event *debug.DebuggingEvent
 	absoluteMountpoint string
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*EventLocationDirectory)(nil))
@@ -320,35 +564,163 @@ var _ = (fs.NodeSymlinker)((*EventLocationDirectory)(nil))
 var _ = (fs.NodeUnlinker)((*EventLocationDirectory)(nil))
 var _ = (fs.NodeReaddirer)((*EventLocationDirectory)(nil))
 var _ = (fs.NodeLookuper)((*EventLocationDirectory)(nil))
+var _ = (fs.NodeMkdirer)((*EventLocationDirectory)(nil))
 
-func NewEventLocationDirectory(event *debug.DebuggingEvent, conn *jdwp.Connection, absMountpoint string) EventLocationDirectory {
+func NewEventLocationDirectory(event *debug.DebuggingEvent, conn *debug.Connection, absMountpoint string, options JdwpFsOptions) EventLocationDirectory {
 	return EventLocationDirectory {
 		event: event,
 		JdwpConnection: conn,
-		absoluteMountpoint: absMountpoint,	
+		absoluteMountpoint: absMountpoint,
+		Options: options,
 	}
 }
 
 func (d *EventLocationDirectory) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *EventLocationDirectory) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *EventLocationDirectory) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	var entries = []fuse.DirEntry{}
 	for _, modifier := range d.event.GetModifiers() {
+		if modifier.Pending {
+			continue
+		}
 		newEntry := fuse.DirEntry {
 			Mode: fuse.S_IFLNK,
 			Name: modifier.Name,
 		}
 		entries = append(entries, newEntry)
 	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
 	
 	return fs.NewListDirStream(entries), syscall.F_OK
 }
 
+// Symlink adds a modifier; a name already taken, by a link or by a
+// pending modifier, is EEXIST
 func (d *EventLocationDirectory) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (node *fs.Inode, errno syscall.Errno) {
-	absPathUneval, err := filepath.Abs(target)
+	// failing early; it's checked again when the modifier is added
+	_, exists := d.event.GetModifiers()[name]
+	if exists {
+		return nil, syscall.EEXIST
+	}
+
+	newModifier, errno := resolveLocationTarget(d.JdwpConnection, d.absoluteMountpoint, &d.Inode, target, d.Options)
+	if errno != syscall.F_OK {
+		return nil, errno
+	}
+	newModifier.Name = name
+
+	errno = d.pickCodeIndex(name, &newModifier)
+	if errno != syscall.F_OK {
+		return nil, errno
+	}
+
+	if !d.event.AddModifier(name, newModifier) {
+		return nil, syscall.EEXIST
+	}
+		
+	newLink := d.NewInode(
+		ctx,
+		&fs.MemSymlink {
+			Data: []byte(target),
+			Attr: staticAttr(d.Options.fileMode()),
+		},
+		fs.StableAttr {
+			Mode: fuse.S_IFLNK,
+	})
+	
+	return newLink, syscall.F_OK
+}
+
+// pickCodeIndex sets the code index of a method modifier from the
+// suffix of the link name, if it has one; the code index has to be in
+// the method's bytecode, and can't be combined with a line
+func (d *EventLocationDirectory) pickCodeIndex(name string, modifier *debug.ModifierDescriptor) syscall.Errno {
+	codeIndex, entry, found, ok := parseCodeIndexSuffix(name)
+	if !found {
+		return syscall.F_OK
+	}
+	if !ok {
+		log.Printf("location %s has an unparsable code index\n", name)
+		return syscall.EINVAL
+	}
+
+	if modifier.IsField || modifier.ThreadId != 0 || modifier.Line > 0 {
+		log.Printf("location %s picks a code index, but its target isn't a method\n", name)
+		return syscall.EINVAL
+	}
+
+	start, end, err := methodCodeRange(d.JdwpConnection,
+		jdwp.ReferenceTypeID(modifier.ClassId), jdwp.MethodID(modifier.ObjectId))
+	if err != nil {
+		log.Printf("no code range for method %d of class %d: %s\n",
+			modifier.ObjectId, modifier.ClassId, err)
+		return syscall.ENOENT
+	}
+
+	if entry {
+		codeIndex = start
+	}
+	if codeIndex < start || codeIndex > end {
+		log.Printf("code index %d is outside method %d of class %d, %d to %d\n",
+			codeIndex, modifier.ObjectId, modifier.ClassId, start, end)
+		return syscall.ERANGE
+	}
+	modifier.CodeIndex = codeIndex
+
+	return syscall.F_OK
+}
+
+func (d *EventLocationDirectory) Unlink(ctx context.Context, name string) syscall.Errno {
+	// the modifier goes away with the link, so it no longer applies
+	// the next time the event is run
+	err := d.event.DeleteModifier(name)
+	if errors.As(err, &debug.JdwpDebuggingEventError{}) {
+		return syscall.ENOENT
+	}
+	if err != nil {
+		log.Printf("unable to delete modifier %s: %s\n", name, err)
+		return syscall.EACCES
+	}
+	
+	return syscall.F_OK
+}
+
+func (d *EventLocationDirectory) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	modifier, ok := d.event.GetModifiers()[name]
+	if !ok || modifier.Pending {
+		return nil, syscall.ENOENT
+	}
+
+	target := relativeLink(&d.Inode, locationTargetPath(modifier, d.Options))
+	locationLink := d.NewInode(
+		ctx,
+		&fs.MemSymlink {
+			Data: []byte(target),
+			Attr: staticAttr(d.Options.fileMode()),
+		},
+		fs.StableAttr{
+			Mode: fuse.S_IFLNK,
+		},
+	)
+
+	return locationLink, syscall.F_OK
+}
+
+
+
+// targetComponents resolves a link target to the components of its path
+// inside the mount; a relative target is taken from dir, the directory
+// the link is made in
+func targetComponents(absoluteMountpoint string, dir *fs.Inode, target string) ([]string, syscall.Errno) {
+	absPathUneval, err := filepath.Abs(absoluteTarget(absoluteMountpoint, dir, target))
 	if err != nil {
 		log.Printf("target %s cannot be made absolute: %s\n", target, err)
 		return nil, syscall.ENOENT
@@ -360,157 +732,177 @@ func (d *EventLocationDirectory) Symlink(ctx context.Context, target, name strin
 		return nil, syscall.ENOENT
 	}
 	
-	if !strings.HasPrefix(absPath, d.absoluteMountpoint) {
+	if !strings.HasPrefix(absPath, absoluteMountpoint) {
 		log.Printf("target %s is not part of the current mount\n", target)
 		return nil, syscall.EBADE
 	}
 	
-	pathComponents := strings.Split(strings.TrimPrefix(absPath, d.absoluteMountpoint), "/")
-	for pathComponents[0] == "/" || pathComponents[0] == "" {
+	pathComponents := strings.Split(strings.TrimPrefix(absPath, absoluteMountpoint), "/")
+	for len(pathComponents) > 0 && (pathComponents[0] == "/" || pathComponents[0] == "") {
 		pathComponents = pathComponents[1:]
 	}
 
-	// classes/classid/methods/fields/method/field
+	return pathComponents, syscall.F_OK
+}
+
+// resolveLocationTarget turns a path to a field or a method of a class,
+// or to a line of a method, inside the mount, into an unnamed modifier
+// descriptor
+func resolveLocationTarget(conn *debug.Connection, absoluteMountpoint string, dir *fs.Inode, target string, options JdwpFsOptions) (debug.ModifierDescriptor, syscall.Errno) {
+	pathComponents, errno := targetComponents(absoluteMountpoint, dir, target)
+	if errno != syscall.F_OK {
+		return debug.ModifierDescriptor{}, errno
+	}
+
+	// classes/classid/methods/fields/method/field, optionally followed
+	// by locations/line=n for methods
+	var line = 0
+	if len(pathComponents) == 6 && pathComponents[2] == "methods" && pathComponents[4] == "locations" {
+		var ok bool
+		line, ok = parseLineLocation(pathComponents[5])
+		if !ok {
+			log.Printf("target %s has unparsable line\n", target)
+			return debug.ModifierDescriptor{}, syscall.EBADE
+		}
+		pathComponents = pathComponents[:4]
+	}
+
 	if !(len(pathComponents) == 4 &&
 		 pathComponents[0] == "classes" &&
 		 (pathComponents[2] == "fields" || pathComponents[2] == "methods")) {
 		log.Printf("target %s does not seem to be correct\n", target)
-		return nil, syscall.EBADE
+		return debug.ModifierDescriptor{}, syscall.EBADE
 	}
 
-	classId, err := strconv.ParseUint(pathComponents[1], 10, 64)
+	classId, err := options.parseId(pathComponents[1])
 	if err != nil {
 		log.Printf("target %s has unparsable class id\n", target)
-		return nil, syscall.EBADE
+		return debug.ModifierDescriptor{}, syscall.EBADE
 	}
-	
-	var isField = true
+
+	objectId, err := strconv.ParseUint(pathComponents[3], 10, 64)
+	if err != nil {
+		log.Printf("target %s has unparsable %s id\n", target, pathComponents[2])
+		return debug.ModifierDescriptor{}, syscall.EBADE
+	}
+
+	modifier, errno := resolveModifier(conn, classId, pathComponents[2] == "fields", objectId)
+	if errno != syscall.F_OK || line == 0 {
+		return modifier, errno
+	}
+
+	lines, err := methodLines(conn, jdwp.ReferenceTypeID(classId), jdwp.MethodID(objectId))
+	if err != nil {
+		log.Printf("no line table for method %d of class %d: %s\n", objectId, classId, err)
+		return debug.ModifierDescriptor{}, syscall.ENOENT
+	}
+
+	codeIndex, ok := lines[line]
+	if !ok {
+		log.Printf("method %d of class %d has no line %d\n", objectId, classId, line)
+		return debug.ModifierDescriptor{}, syscall.ENOENT
+	}
+	modifier.CodeIndex = codeIndex
+	modifier.Line = line
+
+	return modifier, syscall.F_OK
+}
+
+// resolveModifier checks the class and its field or method exist, and
+// fills in their signatures and names
+func resolveModifier(conn *debug.Connection, classId uint64, isField bool, objectId uint64) (debug.ModifierDescriptor, syscall.Errno) {
 	var foundClass *jdwp.ClassInfo = nil
-	var objectId uint64
-	classes, err := d.JdwpConnection.GetAllClasses()
+	var objectName string
+	var objectSignature string
+	classes, err := conn.GetAllClasses()
 	if err != nil {
-		log.Printf("unable to retrieve classes for target %s\n", target)
-		return nil, syscall.EADDRNOTAVAIL
+		log.Printf("unable to retrieve classes for class %d\n", classId)
+		return debug.ModifierDescriptor{}, syscall.EADDRNOTAVAIL
 	}
 	
 	for _, class := range classes {
 		if class.ClassID() == jdwp.ClassID(classId) {
 			foundClass = &class
+			break
 		}
 	}
 	if foundClass == nil {
-		log.Printf("unable to find a valid class for target %s\n", target)
-		return nil, syscall.ENOENT
+		log.Printf("unable to find a valid class for class %d\n", classId)
+		return debug.ModifierDescriptor{}, syscall.ENOENT
 	}
 
-	switch (pathComponents[2]) {
-	case "fields":
-		fieldId, err := strconv.ParseUint(pathComponents[3], 10, 64)
-		if err != nil {
-			log.Printf("target %s has unparsable field id\n", target)
-			return nil, syscall.EBADE
-		}
-
+	switch isField {
+	case true:
 		var foundField *jdwp.Field = nil
-		fields, err := d.JdwpConnection.GetFields(jdwp.ReferenceTypeID(classId))
+		fields, err := conn.GetFields(jdwp.ReferenceTypeID(classId))
 		if err != nil {
-			log.Printf("unable to retrieve fields for target %s\n", target)
-			return nil, syscall.EADDRNOTAVAIL
+			log.Printf("unable to retrieve fields for class %d\n", classId)
+			return debug.ModifierDescriptor{}, syscall.EADDRNOTAVAIL
 		}
 		
 		for _, field := range fields {
-			if field.ID == jdwp.FieldID(fieldId) {
+			if field.ID == jdwp.FieldID(objectId) {
 				foundField = &field
+				break
 			}
 		}
 		if foundField == nil {
-			log.Printf("unable to find valid field for target %s\n", target)
-			return nil, syscall.ENOENT
-		}
-
-		isField = true
-		objectId = fieldId
-	case "methods":
-		methodId, err := strconv.ParseUint(pathComponents[3], 10, 64)
-		if err != nil {
-			log.Printf("target %s has unparsable method id\n", target)
-			return nil, syscall.EBADE
+			log.Printf("unable to find field %d in class %d\n", objectId, classId)
+			return debug.ModifierDescriptor{}, syscall.ENOENT
 		}
 
+		objectName = foundField.Name
+		objectSignature = foundField.Signature
+	case false:
 		var foundMethod *jdwp.Method = nil
-
-		methods, err := d.JdwpConnection.GetMethods(jdwp.ReferenceTypeID(classId))
+		methods, err := conn.GetMethods(jdwp.ReferenceTypeID(classId))
 		if err != nil {
-			log.Printf("unable to retrieve methods for target %s\n", target)
-			return nil, syscall.EADDRNOTAVAIL
+			log.Printf("unable to retrieve methods for class %d\n", classId)
+			return debug.ModifierDescriptor{}, syscall.EADDRNOTAVAIL
 		}
 
 		for _, method := range methods {
-			if method.ID == jdwp.MethodID(methodId) {
+			if method.ID == jdwp.MethodID(objectId) {
 				foundMethod = &method
+				break
 			}
 		}
 		if foundMethod == nil {
-			log.Printf("unable to find matching method for target %s\n", target)
-			return nil, syscall.ENOENT
+			log.Printf("unable to find method %d in class %d\n", objectId, classId)
+			return debug.ModifierDescriptor{}, syscall.ENOENT
 		}
 		
-		isField = false
-		objectId = methodId
-	default:
-		log.Printf("target %s is not available", target)
-		return nil, syscall.EADDRNOTAVAIL
+		objectName = foundMethod.Name
+		objectSignature = foundMethod.Signature
 	}
 
 	newModifier := debug.ModifierDescriptor {
-		Name: name,
 		IsField: isField,
 		Kind: foundClass.Kind,
 		ClassId: classId,
 		ObjectId: objectId,
+		ClassSignature: foundClass.Signature,
+		ObjectName: objectName,
+		ObjectSignature: objectSignature,
 	}
-	
-	d.event.SetModifier(name, newModifier)
-		
-	newLink := d.NewInode(
-		ctx,
-		&fs.MemSymlink {
-			Data: []byte(target),
-			Attr: fuse.Attr { Mode: 0444 },
-		},
-		fs.StableAttr {
-			Mode: fuse.S_IFLNK,
-	})
-	
-	return newLink, syscall.F_OK
-}
 
-func (d *EventLocationDirectory) Unlink(ctx context.Context, name string) syscall.Errno {
-	_, ok := d.event.GetHookDescriptors()[name]
-	if !ok {
-		return syscall.ENOENT
-	}
-	
-	err := d.event.DeleteModifier(name)
-	if err != nil {
-		return syscall.EACCES
-	}
-	
-	return syscall.F_OK
+	return newModifier, syscall.F_OK
 }
 
-func (d *EventLocationDirectory) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	modifier, ok := d.event.GetModifiers()[name]
-	if !ok {
-		return nil, syscall.ENOENT
+// locationTargetPath is the path, inside the mount, of the field, the
+// method or the line of the method a modifier points to, or of the
+// thread it limits the events to
+func locationTargetPath(modifier debug.ModifierDescriptor, options JdwpFsOptions) string {
+	if modifier.ThreadId != 0 {
+		return strings.Join([]string {
+			"threads",
+			options.formatId(modifier.ThreadId),
+		}, "/")
 	}
 
-	classDirName := strconv.FormatUint(uint64(modifier.ClassId), 10)
+	classDirName := options.formatId(uint64(modifier.ClassId))
 	objectSubdir := strconv.FormatUint(modifier.ObjectId, 10)
-	var mountpoint = d.absoluteMountpoint
-	mountpoint = strings.TrimRight(mountpoint, "/")
 	var classSubDir = ""
-	var target = ""
 
 	switch modifier.IsField {
 	case true:
@@ -519,28 +911,19 @@ func (d *EventLocationDirectory) Lookup(ctx context.Context, name string, out *f
 		classSubDir = "methods"
 	}
 	
-	target = strings.Join([]string {
-		mountpoint,
+	path := strings.Join([]string {
 		"classes",
 		classDirName,
 		classSubDir,
 		objectSubdir,
 	}, "/")
-	locationLink := d.NewInode(
-		ctx,
-		&fs.MemSymlink {
-			Data: []byte(target),
-			Attr: fuse.Attr { Mode: 0444 },
-		},
-		fs.StableAttr{
-			Mode: fuse.S_IFLNK,
-		},
-	)
+	if !modifier.IsField && modifier.Line > 0 {
+		path = fmt.Sprintf("%s/locations/%s%d", path, lineLocationPrefix, modifier.Line)
+	}
 
-	return locationLink, syscall.F_OK
+	return path
 }
 
-
 //
 // Event hooks directory
 //
@@ -548,30 +931,58 @@ func (d *EventLocationDirectory) Lookup(ctx context.Context, name string, out *f
 type EventHooksDirectory struct {
 	fs.Inode
 	event *debug.DebuggingEvent
+
+	strict bool
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*EventHooksDirectory)(nil))
 var _ = (fs.NodeReaddirer)((*EventHooksDirectory)(nil))
 var _ = (fs.NodeSymlinker)((*EventHooksDirectory)(nil))
 var _ = (fs.NodeLookuper)((*EventHooksDirectory)(nil))
+var _ = (fs.NodeMkdirer)((*EventHooksDirectory)(nil))
 
-func NewEventHooksDirectory(event *debug.DebuggingEvent) EventHooksDirectory {
+func NewEventHooksDirectory(event *debug.DebuggingEvent, strict bool, options JdwpFsOptions) EventHooksDirectory {
 	return EventHooksDirectory {
 		event: event,
+		strict: strict,
+		Options: options,
 	}
 }
 
 func (d *EventHooksDirectory) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *EventHooksDirectory) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+// the file reloading the hooks, which no hook can be named after
+const hooksReloadName = "reload"
+
 func (d *EventHooksDirectory) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (node *fs.Inode, errno syscall.Errno) {
+	if name == hooksReloadName {
+		return nil, syscall.EEXIST
+	}
+
+	// an existing hook keeps its target; strictly, that's an error
+	_, exists := d.event.GetHookDescriptors()[name]
+	if exists {
+		log.Printf("hook %s already exists, keeping its target\n", name)
+		if d.strict {
+			return nil, syscall.EEXIST
+		}
+	}
+
 	newLink := d.NewInode(
 		ctx,
 		&fs.MemSymlink {
 			Data: []byte(target),
-			Attr: fuse.Attr { Mode: 0444 },
+			Attr: staticAttr(d.Options.fileMode()),
 		},
 		fs.StableAttr {
 			Mode: fuse.S_IFLNK,
@@ -583,7 +994,12 @@ func (d *EventHooksDirectory) Symlink(ctx context.Context, target, name string,
 }
 
 func (d *EventHooksDirectory) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	var entries = []fuse.DirEntry{}
+	var entries = []fuse.DirEntry{
+		{
+			Mode: fuse.S_IFREG,
+			Name: hooksReloadName,
+		},
+	}
 	for name, _ := range d.event.GetHookDescriptors() {
 		newEntry := fuse.DirEntry {
 			Mode: fuse.S_IFLNK,
@@ -596,6 +1012,17 @@ func (d *EventHooksDirectory) Readdir(ctx context.Context) (fs.DirStream, syscal
 }
 
 func (d *EventHooksDirectory) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == hooksReloadName {
+		reloadInode := d.NewInode(
+			ctx,
+			NewEventHooksReloadFile(d.event, d.Options),
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return reloadInode, syscall.F_OK
+	}
+
 	var foundLink *struct {
 		name string
 		target string
@@ -620,7 +1047,7 @@ func (d *EventHooksDirectory) Lookup(ctx context.Context, name string, out *fuse
 		ctx,
 		&fs.MemSymlink {
 			Data: []byte(foundLink.target),
-			Attr: fuse.Attr { Mode: 0444 },
+			Attr: staticAttr(d.Options.fileMode()),
 		},
 		fs.StableAttr{
 			Mode: fuse.S_IFLNK,
@@ -629,3 +1056,125 @@ func (d *EventHooksDirectory) Lookup(ctx context.Context, name string, out *fuse
 
 	return hookLink, syscall.F_OK
 }
+
+//
+// Event hooks reload file
+// Writing the name of a hook opens its plugin again; reading shows how
+// the last reload went
+//
+
+type EventHooksReloadFile struct {
+	fs.Inode
+
+	event *debug.DebuggingEvent
+
+	mu sync.Mutex
+	report string
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeOpener)((*EventHooksReloadFile)(nil))
+var _ = (fs.NodeOpendirer)((*EventHooksReloadFile)(nil))
+var _ = (fs.NodeGetattrer)((*EventHooksReloadFile)(nil))
+var _ = (fs.NodeSetattrer)((*EventHooksReloadFile)(nil))
+var _ = (fs.NodeReader)((*EventHooksReloadFile)(nil))
+var _ = (fs.NodeWriter)((*EventHooksReloadFile)(nil))
+
+func NewEventHooksReloadFile(event *debug.DebuggingEvent, options JdwpFsOptions) *EventHooksReloadFile {
+	return &EventHooksReloadFile {
+		event: event,
+		Options: options,
+	}
+}
+
+func (c *EventHooksReloadFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *EventHooksReloadFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *EventHooksReloadFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (c *EventHooksReloadFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (c *EventHooksReloadFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	c.mu.Lock()
+	report := c.report
+	c.mu.Unlock()
+
+	if offset > int64(len(report)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(report[offset:])), syscall.F_OK
+}
+
+func (c *EventHooksReloadFile) setReport(report string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.report = report
+}
+
+// Write reloads the hook named by the data; one reload runs at a time,
+// the others fail with EBUSY until it's done
+func (c *EventHooksReloadFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *EventHooksReloadFile) writeToken(data []byte) syscall.Errno {
+	tokens := strings.Fields(string(data))
+	if len(tokens) != 1 {
+		return syscall.EBADMSG
+	}
+	name := tokens[0]
+
+	_, exists := c.event.GetHookDescriptors()[name]
+	if !exists {
+		return syscall.ENOENT
+	}
+
+	err := c.event.ReloadHook(name)
+	if errors.As(err, &debug.PluginBusyError{}) {
+		return syscall.EBUSY
+	}
+	if err != nil {
+		log.Printf("error reloading hook %s of event %s: %s\n", name, c.event.Name, err)
+		c.setReport(fmt.Sprintf("%s failed: %s\n", name, err))
+		return syscall.EIO
+	}
+
+	c.setReport(fmt.Sprintf("%s reloaded\n", name))
+
+	return syscall.F_OK
+}
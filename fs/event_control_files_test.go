@@ -0,0 +1,806 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// readNode reads a file node whole, from offset
+func readNode(t *testing.T, node fs.NodeReader, offset int64) string {
+	t.Helper()
+
+	dest := make([]byte, 4096)
+	result, errno := node.Read(context.Background(), nil, dest, offset)
+	if errno != syscall.F_OK {
+		t.Fatalf("read at %d: %v", offset, errno)
+	}
+	data, _ := result.Bytes(dest)
+
+	return string(data)
+}
+
+func TestEventControlRead(t *testing.T) {
+	var tests = []struct {
+		name string
+		modifiers []string
+		hooks []string
+		registered bool
+		running bool
+		status string
+	}{
+		{ name: "new", status: "idle registered=false modifiers=0 hooks=0\n" },
+		{
+			name: "configured idle",
+			modifiers: []string { "first", "second" },
+			hooks: []string { "hook" },
+			status: "idle registered=false modifiers=2 hooks=1\n",
+		},
+		{
+			name: "registered",
+			modifiers: []string { "first" },
+			registered: true,
+			status: "idle registered=true modifiers=1 hooks=0\n",
+		},
+		{
+			name: "running",
+			hooks: []string { "hook", "other" },
+			registered: true,
+			running: true,
+			status: "running registered=true modifiers=0 hooks=2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := debug.NewStubDebuggingEvent("control")
+			for _, name := range test.modifiers {
+				event.SetModifier(name, debug.ModifierDescriptor { Name: name })
+			}
+			for _, name := range test.hooks {
+				event.SetHookDescriptor(name, "/plugins/" + name + ".so")
+			}
+			event.SetRegistered(test.registered)
+			if test.running {
+				event.SetCtx(context.Background())
+			}
+
+			controlFile := NewEventControlFile(event, JdwpFsOptions{})
+			if status := readNode(t, &controlFile, 0); status != test.status {
+				t.Fatalf("read %q, expected %q", status, test.status)
+			}
+
+			// the first token stays running/idle
+			if rest := readNode(t, &controlFile, 4); rest != test.status[4:] {
+				t.Fatalf("read %q from 4, expected %q", rest, test.status[4:])
+			}
+		})
+	}
+}
+
+// helpTokens gives the tokens a help text lists, one per line after the
+// description
+func helpTokens(help string) []string {
+	lines := strings.Split(strings.TrimSuffix(help, "\n"), "\n")
+
+	var tokens = []string{}
+	for _, line := range lines[1:] {
+		tokens = append(tokens, strings.SplitN(line, "\t", 2)[0])
+	}
+	return tokens
+}
+
+func TestEventHelp(t *testing.T) {
+	var tests = []struct {
+		name string
+		help string
+		description string
+		tokens int
+		write func(*debug.DebuggingEvent, string) syscall.Errno
+	}{
+		{
+			name: "kind",
+			help: eventKindHelp(),
+			description: "kind - ",
+			tokens: len(eventKindReprMap),
+			write: func(event *debug.DebuggingEvent, token string) syscall.Errno {
+				kindFile := NewEventKindFile(event, JdwpFsOptions{})
+				return kindFile.writeToken([]byte(token + "\n"))
+			},
+		},
+		{
+			name: "suspendPolicy",
+			help: eventSuspendPolicyHelp(),
+			description: "suspendPolicy - ",
+			tokens: len(suspendPolicyReprMap),
+			write: func(event *debug.DebuggingEvent, token string) syscall.Errno {
+				policyFile := NewEventSuspendPolicyFile(event, JdwpFsOptions{})
+				return policyFile.writeToken([]byte(token + "\n"))
+			},
+		},
+		{
+			name: "control",
+			help: eventControlHelp,
+			description: "control - ",
+			tokens: 4 + len(suspendPolicyOverrideMap),
+			write: func(event *debug.DebuggingEvent, token string) syscall.Errno {
+				// a disabled event refuses running, an idle one
+				// cancelling, but only once the token is understood
+				event.SetEnabled(false)
+				controlFile := NewEventControlFile(event, JdwpFsOptions{})
+				errno := controlFile.writeToken([]byte(token + "\n"))
+				if errno == syscall.EPERM || errno == syscall.ENAVAIL {
+					return syscall.F_OK
+				}
+				return errno
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !strings.HasPrefix(test.help, test.description) {
+				t.Fatalf("the help doesn't start with %q: %q", test.description, test.help)
+			}
+
+			tokens := helpTokens(test.help)
+			if len(tokens) != test.tokens {
+				t.Fatalf("listed %d tokens, expected %d: %v", len(tokens), test.tokens, tokens)
+			}
+
+			for _, token := range tokens {
+				event := debug.NewStubDebuggingEvent("help")
+				if errno := test.write(event, token); errno != syscall.F_OK {
+					t.Errorf("listed token %q is refused: %v", token, errno)
+				}
+			}
+
+			// and nothing else is accepted
+			event := debug.NewStubDebuggingEvent("help")
+			if errno := test.write(event, "unlisted"); errno == syscall.F_OK {
+				t.Errorf("unlisted token accepted")
+			}
+		})
+	}
+}
+
+func TestLocationsWhileRunning(t *testing.T) {
+	const runs = 20
+
+	mountpoint, method := methodMountpoint(t)
+	conn, vm := startFakeVM(t, func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		if set == 15 && cmd == 1 {
+			return 0, fakeInt(1)
+		}
+		return callsHandler(set, cmd, data)
+	})
+	// the fake VM answers over an unbuffered pipe, one call at a time
+	conn.SetMaxConcurrent(1)
+
+	event := debug.NewStubDebuggingEvent("locations")
+	event.SetKind(jdwp.MethodEntry)
+	event.SetConn(conn)
+	locationDir := NewEventLocationDirectory(event, conn, mountpoint, JdwpFsOptions{})
+	fs.NewNodeFS(&locationDir, &fs.Options{})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			// the link is set and removed through the directory, as
+			// the event runs and is cancelled
+			_, errno := locationDir.Symlink(context.Background(), method, "run", &fuse.EntryOut{})
+			if errno != syscall.F_OK {
+				t.Errorf("symlink: %v", errno)
+				return
+			}
+			if _, errno := locationDir.Readdir(context.Background()); errno != syscall.F_OK {
+				t.Errorf("readdir: %v", errno)
+			}
+			if errno := locationDir.Unlink(context.Background(), "run"); errno != syscall.F_OK {
+				t.Errorf("unlink: %v", errno)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < runs; i++ {
+		if _, err := event.Run(); err != nil {
+			t.Fatalf("run %d: %s", i, err)
+		}
+		if err := event.Cancel(); err != nil {
+			t.Fatalf("cancel %d: %s", i, err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if set := vm.Received(15, 1); set != runs {
+		t.Fatalf("%d requests set, expected %d", set, runs)
+	}
+}
+
+// methodMountpoint makes a mountpoint holding method 7 of class 41 of
+// callsHandler, and returns it with the path to the method
+func methodMountpoint(t *testing.T) (string, string) {
+	t.Helper()
+
+	mountpoint, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	method := filepath.Join(mountpoint, "classes", "41", "methods", "7")
+	if err := os.MkdirAll(method, 0755); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	return mountpoint, method
+}
+
+func TestLocationConcurrentSymlink(t *testing.T) {
+	const linkers = 16
+
+	mountpoint, method := methodMountpoint(t)
+	conn, _ := startFakeVM(t, callsHandler)
+	// the fake VM answers over an unbuffered pipe, one call at a time
+	conn.SetMaxConcurrent(1)
+	event := debug.NewStubDebuggingEvent("locations")
+	locationDir := NewEventLocationDirectory(event, conn, mountpoint, JdwpFsOptions{})
+	fs.NewNodeFS(&locationDir, &fs.Options{})
+
+	var wg sync.WaitGroup
+	errnos := make(chan syscall.Errno, linkers)
+	for i := 0; i < linkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errno := locationDir.Symlink(context.Background(), method, "run", &fuse.EntryOut{})
+			errnos <- errno
+		}()
+	}
+	wg.Wait()
+	close(errnos)
+
+	var linked int
+	for errno := range errnos {
+		switch errno {
+		case syscall.F_OK:
+			linked++
+		case syscall.EEXIST:
+		default:
+			t.Fatalf("symlink: %v", errno)
+		}
+	}
+	if linked != 1 {
+		t.Fatalf("linked %d times", linked)
+	}
+}
+
+func TestEventEnabled(t *testing.T) {
+	var tests = []struct {
+		name string
+		tokens []string
+		enabled string
+		runErrno syscall.Errno
+	}{
+		{ name: "enabled by default", enabled: "1\n", runErrno: syscall.F_OK },
+		{ name: "disabled", tokens: []string { "0\n" }, enabled: "0\n", runErrno: syscall.EPERM },
+		{ name: "enabled again", tokens: []string { "0\n", "1\n" }, enabled: "1\n", runErrno: syscall.F_OK },
+		{ name: "disabled again", tokens: []string { "1\n", "0\n", "1\n", "0\n" }, enabled: "0\n", runErrno: syscall.EPERM },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, 0, fakeInt(1))
+
+			event := debug.NewStubDebuggingEvent("enabled")
+			event.SetKind(jdwp.ThreadStart)
+			event.SetConn(conn)
+			enabledFile := NewEventEnabledFile(event, JdwpFsOptions{})
+			controlFile := NewEventControlFile(event, JdwpFsOptions{})
+
+			for _, token := range test.tokens {
+				if errno := enabledFile.writeToken([]byte(token)); errno != syscall.F_OK {
+					t.Fatalf("writing %q: %v", token, errno)
+				}
+			}
+			if enabled := readNode(t, &enabledFile, 0); enabled != test.enabled {
+				t.Fatalf("read %q, expected %q", enabled, test.enabled)
+			}
+
+			errno := controlFile.writeToken([]byte("run\n"))
+			if errno != test.runErrno {
+				t.Fatalf("run: got %v, expected %v", errno, test.runErrno)
+			}
+			if running := event.IsRunning(); running != (errno == syscall.F_OK) {
+				t.Fatalf("running: %v", running)
+			}
+
+			// disabling doesn't cancel, nor keeps from cancelling
+			enabledFile.writeToken([]byte("0\n"))
+			if errno == syscall.F_OK {
+				if errno := controlFile.writeToken([]byte("cancel\n")); errno != syscall.F_OK {
+					t.Fatalf("cancel: %v", errno)
+				}
+			}
+			if set := vm.Received(15, 1); (set == 1) != (errno == syscall.F_OK) {
+				t.Fatalf("the request was set %d times", set)
+			}
+		})
+	}
+}
+
+// suspendPolicyRecorder accepts the event requests, noting the suspend
+// policy each was set with
+type suspendPolicyRecorder struct {
+	mu sync.Mutex
+	policies []jdwp.SuspendPolicy
+}
+
+func (r *suspendPolicyRecorder) handle(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	if set != 15 || cmd != 1 {
+		return 0, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies = append(r.policies, jdwp.SuspendPolicy(data[1]))
+	return 0, fakeInt(1)
+}
+
+func (r *suspendPolicyRecorder) Policies() []jdwp.SuspendPolicy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]jdwp.SuspendPolicy(nil), r.policies...)
+}
+
+func TestEventControlSuspendOverride(t *testing.T) {
+	var tests = []struct {
+		name string
+		token string
+		errno syscall.Errno
+		requested []jdwp.SuspendPolicy
+	}{
+		{ name: "stored policy", token: "run\n", requested: []jdwp.SuspendPolicy { jdwp.SuspendEventThread } },
+		{ name: "suspendAll", token: "run suspendAll\n", requested: []jdwp.SuspendPolicy { jdwp.SuspendAll } },
+		{ name: "suspendNone", token: "run suspendNone\n", requested: []jdwp.SuspendPolicy { jdwp.SuspendNone } },
+		{ name: "unknown override", token: "run suspendSome\n", errno: syscall.EINVAL },
+		{ name: "override when cancelling", token: "cancel suspendAll\n", errno: syscall.EBADMSG },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &suspendPolicyRecorder{}
+			conn, _ := startFakeVM(t, recorder.handle)
+
+			event := debug.NewStubDebuggingEvent("override")
+			event.SetKind(jdwp.ThreadStart)
+			event.SetSuspendPolicy(jdwp.SuspendEventThread)
+			event.SetConn(conn)
+			controlFile := NewEventControlFile(event, JdwpFsOptions{})
+
+			errno := controlFile.writeToken([]byte(test.token))
+			if errno != test.errno {
+				t.Fatalf("writing %q: got %v, expected %v", test.token, errno, test.errno)
+			}
+			if errno == syscall.F_OK {
+				if errno := controlFile.writeToken([]byte("cancel\n")); errno != syscall.F_OK {
+					t.Fatalf("cancel: %v", errno)
+				}
+			}
+
+			if policies := recorder.Policies(); !reflect.DeepEqual(policies, test.requested) {
+				t.Fatalf("requested with %v, expected %v", policies, test.requested)
+			}
+			if policy := event.GetSuspendPolicy(); policy != jdwp.SuspendEventThread {
+				t.Fatalf("the stored policy became %v", policy)
+			}
+		})
+	}
+}
+
+func TestEventEnabledTokens(t *testing.T) {
+	event := debug.NewStubDebuggingEvent("enabled")
+	enabledFile := NewEventEnabledFile(event, JdwpFsOptions{})
+
+	for _, token := range []string { "true\n", "yes\n", "2\n", "\n" } {
+		if errno := enabledFile.writeToken([]byte(token)); errno != syscall.EBADMSG {
+			t.Errorf("writing %q: got %v, expected EBADMSG", token, errno)
+		}
+	}
+	if !event.IsEnabled() {
+		t.Fatalf("disabled by an unknown token")
+	}
+}
+
+// singleValueFile is a kind or a suspendPolicy file
+type singleValueFile interface {
+	fs.NodeOpener
+	fs.NodeSetattrer
+	fs.NodeWriter
+	fs.NodeReader
+}
+
+type singleValueWrite struct {
+	data string
+	off int64
+	errno syscall.Errno
+}
+
+func TestSingleValueFiles(t *testing.T) {
+	var tests = []struct {
+		name string
+		file func(*debug.DebuggingEvent) singleValueFile
+		truncate bool
+		writes []singleValueWrite
+		value string
+	}{
+		{
+			name: "echo to kind",
+			file: func(event *debug.DebuggingEvent) singleValueFile {
+				kindFile := NewEventKindFile(event, JdwpFsOptions{})
+				return &kindFile
+			},
+			truncate: true,
+			writes: []singleValueWrite {
+				{ data: "SingleStep\n", off: 0 },
+			},
+			value: "SingleStep\n",
+		},
+		{
+			name: "kind written in pieces",
+			file: func(event *debug.DebuggingEvent) singleValueFile {
+				kindFile := NewEventKindFile(event, JdwpFsOptions{})
+				return &kindFile
+			},
+			truncate: true,
+			writes: []singleValueWrite {
+				{ data: "Method", off: 0 },
+				{ data: "Entry\n", off: 6 },
+			},
+			value: "MethodEntry\n",
+		},
+		{
+			name: "kind written after a seek",
+			file: func(event *debug.DebuggingEvent) singleValueFile {
+				kindFile := NewEventKindFile(event, JdwpFsOptions{})
+				return &kindFile
+			},
+			writes: []singleValueWrite {
+				{ data: "SingleStep\n", off: 11, errno: syscall.EINVAL },
+			},
+			value: "Breakpoint\n",
+		},
+		{
+			name: "echo to suspendPolicy",
+			file: func(event *debug.DebuggingEvent) singleValueFile {
+				policyFile := NewEventSuspendPolicyFile(event, JdwpFsOptions{})
+				return &policyFile
+			},
+			truncate: true,
+			writes: []singleValueWrite {
+				{ data: "SuspendNone\n", off: 0 },
+			},
+			value: "SuspendNone\n",
+		},
+		{
+			name: "suspendPolicy written after a seek",
+			file: func(event *debug.DebuggingEvent) singleValueFile {
+				policyFile := NewEventSuspendPolicyFile(event, JdwpFsOptions{})
+				return &policyFile
+			},
+			writes: []singleValueWrite {
+				{ data: "SuspendNone\n", off: 3, errno: syscall.EINVAL },
+			},
+			value: "SuspendAll\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := debug.NewStubDebuggingEvent("value")
+			event.SetKind(jdwp.Breakpoint)
+			event.SetSuspendPolicy(jdwp.SuspendAll)
+			file := test.file(event)
+
+			ctx := context.Background()
+			fh, _, errno := file.Open(ctx, syscall.O_WRONLY | syscall.O_TRUNC)
+			if errno != syscall.F_OK {
+				t.Fatalf("open: %v", errno)
+			}
+			if test.truncate {
+				in := &fuse.SetAttrIn{}
+				in.Valid = fuse.FATTR_SIZE
+				if errno := file.Setattr(ctx, fh, in, &fuse.AttrOut{}); errno != syscall.F_OK {
+					t.Fatalf("truncate: %v", errno)
+				}
+			}
+
+			for i, write := range test.writes {
+				_, errno := file.Write(ctx, fh, []byte(write.data), write.off)
+				if errno != write.errno {
+					t.Fatalf("write %d: got %v, expected %v", i, errno, write.errno)
+				}
+			}
+			if errno := fh.(fs.FileFlusher).Flush(ctx); errno != syscall.F_OK {
+				t.Fatalf("flush: %v", errno)
+			}
+
+			if value := readNode(t, file, 0); value != test.value {
+				t.Fatalf("read %q, expected %q", value, test.value)
+			}
+		})
+	}
+}
+
+func TestSingleValueTruncate(t *testing.T) {
+	var tests = []struct {
+		size uint64
+		errno syscall.Errno
+	}{
+		{ size: 0, errno: syscall.F_OK },
+		{ size: 1, errno: syscall.EBADR },
+		{ size: 4096, errno: syscall.EBADR },
+	}
+
+	event := debug.NewStubDebuggingEvent("value")
+	kindFile := NewEventKindFile(event, JdwpFsOptions{})
+	policyFile := NewEventSuspendPolicyFile(event, JdwpFsOptions{})
+
+	for _, test := range tests {
+		for _, file := range []fs.NodeSetattrer { &kindFile, &policyFile } {
+			in := &fuse.SetAttrIn{}
+			in.Valid = fuse.FATTR_SIZE
+			in.Size = test.size
+			if errno := file.Setattr(context.Background(), nil, in, &fuse.AttrOut{}); errno != test.errno {
+				t.Errorf("%T truncated to %d: got %v, expected %v", file, test.size, errno, test.errno)
+			}
+		}
+	}
+}
+
+func TestLocationUnlink(t *testing.T) {
+	var tests = []struct {
+		name string
+		modifiers []string
+		unlinked string
+		errno syscall.Errno
+		left []string
+	}{
+		{ name: "linked", modifiers: []string { "run", "main" }, unlinked: "run", left: []string { "main" } },
+		{ name: "last", modifiers: []string { "run" }, unlinked: "run", left: []string{} },
+		{ name: "missing", modifiers: []string { "run" }, unlinked: "stop", errno: syscall.ENOENT, left: []string { "run" } },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := debug.NewStubDebuggingEvent("locations")
+			locationDir := NewEventLocationDirectory(event, nil, "/mnt", JdwpFsOptions{})
+			// as Symlink leaves them
+			for _, name := range test.modifiers {
+				event.SetModifier(name, debug.ModifierDescriptor {
+					Name: name,
+					ClassId: 42,
+					ObjectId: 7,
+				})
+			}
+
+			// a name taken is taken before resolving the target
+			if _, errno := locationDir.Symlink(context.Background(), "../../classes/42", test.modifiers[0], &fuse.EntryOut{}); errno != syscall.EEXIST {
+				t.Fatalf("symlink over %s: got %v", test.modifiers[0], errno)
+			}
+
+			if errno := locationDir.Unlink(context.Background(), test.unlinked); errno != test.errno {
+				t.Fatalf("unlink: got %v, expected %v", errno, test.errno)
+			}
+
+			var left = []string{}
+			for name := range event.GetModifiers() {
+				left = append(left, name)
+			}
+			sort.Strings(left)
+			if !reflect.DeepEqual(left, test.left) {
+				t.Fatalf("modifiers left %v, expected %v", left, test.left)
+			}
+			if listed := listNames(t, &locationDir); !reflect.DeepEqual(listed, test.left) {
+				t.Fatalf("listed %v, expected %v", listed, test.left)
+			}
+			if _, errno := locationDir.Lookup(context.Background(), test.unlinked, &fuse.EntryOut{}); errno != syscall.ENOENT {
+				t.Fatalf("lookup of %s: got %v", test.unlinked, errno)
+			}
+		})
+	}
+}
+
+func TestSuspendPolicyFileOverride(t *testing.T) {
+	var tests = []struct {
+		name string
+		token string
+		running string
+		cancelled string
+	}{
+		{ name: "stored policy", token: "run\n", running: "SuspendEventThread\n", cancelled: "SuspendEventThread\n" },
+		{
+			name: "overridden",
+			token: "run suspendAll\n",
+			running: "configured=SuspendEventThread effective=SuspendAll\n",
+			cancelled: "SuspendEventThread\n",
+		},
+		{
+			name: "overridden with the stored policy",
+			token: "run suspendEventThread\n",
+			running: "configured=SuspendEventThread effective=SuspendEventThread\n",
+			cancelled: "SuspendEventThread\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &suspendPolicyRecorder{}
+			conn, _ := startFakeVM(t, recorder.handle)
+
+			event := debug.NewStubDebuggingEvent("override")
+			event.SetKind(jdwp.ThreadStart)
+			event.SetSuspendPolicy(jdwp.SuspendEventThread)
+			event.SetConn(conn)
+			controlFile := NewEventControlFile(event, JdwpFsOptions{})
+			policyFile := NewEventSuspendPolicyFile(event, JdwpFsOptions{})
+
+			if errno := controlFile.writeToken([]byte(test.token)); errno != syscall.F_OK {
+				t.Fatalf("writing %q: %v", test.token, errno)
+			}
+			if policy := readNode(t, &policyFile, 0); policy != test.running {
+				t.Fatalf("read %q while running, expected %q", policy, test.running)
+			}
+
+			if errno := controlFile.writeToken([]byte("cancel\n")); errno != syscall.F_OK {
+				t.Fatalf("cancel: %v", errno)
+			}
+			if policy := readNode(t, &policyFile, 0); policy != test.cancelled {
+				t.Fatalf("read %q once cancelled, expected %q", policy, test.cancelled)
+			}
+		})
+	}
+}
+
+func TestEventHooksReload(t *testing.T) {
+	var tests = []struct {
+		name string
+		running bool
+		data string
+		errno syscall.Errno
+		report string
+	}{
+		{ name: "stopped event", data: "hook\n", errno: syscall.F_OK, report: "hook reloaded\n" },
+		{ name: "unknown hook", data: "missing\n", errno: syscall.ENOENT },
+		{ name: "two hooks", data: "hook other\n", errno: syscall.EBADMSG },
+		{ name: "nothing", data: "\n", errno: syscall.EBADMSG },
+		// hooked once running, so the runner hasn't loaded it
+		{ name: "not loaded", running: true, data: "hook\n", errno: syscall.EIO, report: "hook failed: " },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, 0, fakeInt(1))
+			event := debug.NewStubDebuggingEvent("reload")
+			event.SetKind(jdwp.ThreadStart)
+			event.SetConn(conn)
+			if test.running {
+				if _, err := event.Run(); err != nil {
+					t.Fatalf("run: %s", err)
+				}
+				defer event.Cancel()
+			}
+			event.SetHookDescriptor("hook", "/nonexistent/hook.so")
+
+			reloadFile := NewEventHooksReloadFile(event, JdwpFsOptions{})
+			written, errno := reloadFile.Write(context.Background(), nil, []byte(test.data), 0)
+			if errno != test.errno {
+				t.Fatalf("write: got %v, expected %v", errno, test.errno)
+			}
+			if errno == syscall.F_OK && written != uint32(len(test.data)) {
+				t.Fatalf("write: %d bytes of %d", written, len(test.data))
+			}
+			if report := readNode(t, reloadFile, 0); !strings.HasPrefix(report, test.report) || (report == "") != (test.report == "") {
+				t.Fatalf("reported %q, expected %q", report, test.report)
+			}
+		})
+	}
+}
+
+func TestLocationLinks(t *testing.T) {
+	var tests = []struct {
+		name string
+		modifiers []string
+		pending []string
+		linked string
+		errno syscall.Errno
+		listed []string
+	}{
+		{
+			name: "sorted",
+			modifiers: []string { "zeta", "alpha", "main", "beta" },
+			listed: []string { "alpha", "beta", "main", "zeta" },
+		},
+		{
+			name: "duplicate",
+			modifiers: []string { "run", "main" },
+			linked: "run",
+			errno: syscall.EEXIST,
+			listed: []string { "main", "run" },
+		},
+		{
+			name: "taken by a pending modifier",
+			modifiers: []string { "run" },
+			pending: []string { "later" },
+			linked: "later",
+			errno: syscall.EEXIST,
+			listed: []string { "run" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := debug.NewStubDebuggingEvent("locations")
+			locationDir := NewEventLocationDirectory(event, nil, "/mnt", JdwpFsOptions{})
+			for _, name := range test.modifiers {
+				event.SetModifier(name, debug.ModifierDescriptor {
+					Name: name,
+					ClassId: 42,
+					ObjectId: 7,
+				})
+			}
+			for _, name := range test.pending {
+				event.SetModifier(name, debug.ModifierDescriptor {
+					Name: name,
+					Pending: true,
+				})
+			}
+
+			// the same name is rejected before resolving the target
+			if test.linked != "" {
+				before := event.GetModifiers()[test.linked]
+				_, errno := locationDir.Symlink(context.Background(), "../../classes/43", test.linked, &fuse.EntryOut{})
+				if errno != test.errno {
+					t.Fatalf("symlink %s: got %v, expected %v", test.linked, errno, test.errno)
+				}
+				if after := event.GetModifiers()[test.linked]; after != before {
+					t.Fatalf("modifier %s changed to %+v", test.linked, after)
+				}
+			}
+
+			// the same order on every listing
+			for i := 0; i < 3; i++ {
+				if listed := listNames(t, &locationDir); !reflect.DeepEqual(listed, test.listed) {
+					t.Fatalf("listed %v, expected %v", listed, test.listed)
+				}
+			}
+		})
+	}
+}
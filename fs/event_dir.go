@@ -5,15 +5,16 @@ package fs
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log"
+	"strconv"
 	"syscall"
 
 	"disroot.org/kitzman/jdwpfs/debug"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
-	jdwp "github.com/omerye/gojdb/jdwp"
 )
 
 //
@@ -24,16 +25,18 @@ type JdwpEventDir struct {
 	
 	manager *debug.EventManager
 
-	registered bool
 	name string
 	absoluteMountpoint string
 	event *debug.DebuggingEvent
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpEventDir)(nil))
 var _ = (fs.NodeUnlinker)((*JdwpEventDir)(nil))
 var _ = (fs.NodeReaddirer)((*JdwpEventDir)(nil))
 var _ = (fs.NodeLookuper)((*JdwpEventDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpEventDir)(nil))
 
 // func NewJdwpEventDir(manager *debug.EventManager, name string) (*JdwpEventDir, error) {
 // 	event := debug.NewStubDebuggingEvent(name)
@@ -52,7 +55,7 @@ var _ = (fs.NodeLookuper)((*JdwpEventDir)(nil))
 // 	return eventDir, nil
 // }
 
-func JdwpEventDirFromDebuggingEvent(name string, absMountpoint string, manager *debug.EventManager) (*JdwpEventDir, error) {
+func JdwpEventDirFromDebuggingEvent(name string, absMountpoint string, manager *debug.EventManager, options JdwpFsOptions) (*JdwpEventDir, error) {
 	event, err := manager.GetEvent(name)
 	if errors.As(err, &debug.JdwpDebuggingEventError{}) {
 		log.Printf("inaccessible dir")
@@ -66,45 +69,88 @@ func JdwpEventDirFromDebuggingEvent(name string, absMountpoint string, manager *
 		manager: manager,
 
 		name: name,
-		registered: true,
 		absoluteMountpoint: absMountpoint,
 		event: event,
+		Options: options,
 	}
 
 	return eventDir, nil
 }
 
 func (d *JdwpEventDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+// Mkdir fails, events can't be nested
+func (d *JdwpEventDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+// eventDirEntries are the entries every event dir has
+var eventDirEntries = []string {
+	"control", "enabled", "kind", "suspendPolicy",
+	"location", "modifiers", "hooks", "threads",
+	"export.json", "event.summary", "events.log", "stream.bin", "events.dropped",
+	"fire_test",
+	"control.help", "kind.help", "suspendPolicy.help",
+}
+
+// isRegistered tells whether the manager still has the event; the dir
+// may outlive it, e.g. while it's open
+func (d *JdwpEventDir) isRegistered() bool {
+	event, err := d.manager.GetEvent(d.name)
+	return err == nil && event == d.event
+}
+
+// Unlink of `registered` deregisters the event, unless it's running;
+// the entries making up the event can't be removed, and other names
+// don't exist
 func (d *JdwpEventDir) Unlink(_ context.Context, name string) syscall.Errno {
-	if (name == "registered") {
-		if (d.registered) {
-			return syscall.EROFS
+	if name == "registered" {
+		if !d.isRegistered() {
+			return syscall.ENOENT
+		}
+
+		if d.event.IsRunning() {
+			return syscall.EBUSY
+		}
+
+		errno := d.Options.checkPolicy(PolicyEventCreate)
+		if errno != syscall.F_OK {
+			return errno
 		}
-		
-		err := d.manager.DeregisterEvent(name)
+
+		err := d.manager.DeregisterEvent(d.name)
 		if err != nil {
-			log.Printf("error deregistering event %s: %s", name, err)
+			log.Printf("error deregistering event %s: %s", d.name, err)
 			return syscall.ECANCELED
 		}
 
-		d.registered = true
-
 		return syscall.F_OK
 	}
-	
-	return syscall.EROFS;
+
+	for _, entry := range eventDirEntries {
+		if name == entry {
+			return syscall.EPERM
+		}
+	}
+
+	return syscall.ENOENT
 }
 
 func (d *JdwpEventDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	registeredEntry := fuse.DirEntry {
+	controlEntry := fuse.DirEntry {
 		Mode: fuse.S_IFREG,
 		Name: "control",
 	}
 
+	enabledEntry := fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "enabled",
+	}
+
 	kindEntry := fuse.DirEntry {
 		Mode: fuse.S_IFREG,
 		Name: "kind",
@@ -120,17 +166,74 @@ func (d *JdwpEventDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno
 		Name: "location",
 	}
 
+	modifiersEntry := fuse.DirEntry {
+		Mode: fuse.S_IFDIR,
+		Name: "modifiers",
+	}
+
 	hooksEntry := fuse.DirEntry {
 		Mode: fuse.S_IFDIR,
 		Name: "hooks",
 	}
 
+	threadsEntry := fuse.DirEntry {
+		Mode: fuse.S_IFDIR,
+		Name: "threads",
+	}
+
 	dirListing := []fuse.DirEntry {
-		registeredEntry,
+		controlEntry,
+		enabledEntry,
 		kindEntry,
 		suspendPolicyEntry,
 		locationEntry,
+		modifiersEntry,
 		hooksEntry,
+		threadsEntry,
+	}
+
+	if d.isRegistered() {
+		dirListing = append(dirListing, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "registered",
+		})
+	}
+
+	dirListing = append(dirListing, fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "export.json",
+	})
+
+	dirListing = append(dirListing, fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "event.summary",
+	})
+
+	dirListing = append(dirListing, fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "events.log",
+	})
+
+	dirListing = append(dirListing, fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "stream.bin",
+	})
+
+	dirListing = append(dirListing, fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "events.dropped",
+	})
+
+	dirListing = append(dirListing, fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "fire_test",
+	})
+
+	for _, helpName := range []string { "control.help", "kind.help", "suspendPolicy.help" } {
+		dirListing = append(dirListing, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: helpName,
+		})
 	}
 	
 	return fs.NewListDirStream(dirListing), syscall.F_OK
@@ -139,7 +242,17 @@ func (d *JdwpEventDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno
 func (d *JdwpEventDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	switch (name) {
 	case "control":
-		foundFile := NewEventControlFile(d.event)
+		foundFile := NewEventControlFile(d.event, d.Options)
+		foundInode := d.NewInode(
+			ctx,
+			&foundFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return foundInode, syscall.F_OK
+	case "enabled":
+		foundFile := NewEventEnabledFile(d.event, d.Options)
 		foundInode := d.NewInode(
 			ctx,
 			&foundFile,
@@ -149,7 +262,7 @@ func (d *JdwpEventDir) Lookup(ctx context.Context, name string, out *fuse.EntryO
 		)
 		return foundInode, syscall.F_OK
 	case "kind":
-		foundFile := NewEventKindFile(d.event)
+		foundFile := NewEventKindFile(d.event, d.Options)
 		foundInode := d.NewInode(
 			ctx,
 			&foundFile,
@@ -159,7 +272,7 @@ func (d *JdwpEventDir) Lookup(ctx context.Context, name string, out *fuse.EntryO
 		)
 		return foundInode, syscall.F_OK
 	case "suspendPolicy":
-		foundFile := NewEventSuspendPolicyFile(d.event)
+		foundFile := NewEventSuspendPolicyFile(d.event, d.Options)
 		foundInode := d.NewInode(
 			ctx,
 			&foundFile,
@@ -168,8 +281,103 @@ func (d *JdwpEventDir) Lookup(ctx context.Context, name string, out *fuse.EntryO
 			},
 		)
 		return foundInode, syscall.F_OK
+	case "registered":
+		if !d.isRegistered() {
+			return nil, syscall.ENOENT
+		}
+
+		registeredFile := NewInfoFile(d.readRegistered, d.Options)
+		registeredInode := d.NewInode(
+			ctx,
+			&registeredFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return registeredInode, syscall.F_OK
+	case "events.log":
+		logFile := NewEventLogFile(d.event.GetLog(), d.Options)
+		logInode := d.NewInode(
+			ctx,
+			&logFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return logInode, syscall.F_OK
+	case "stream.bin":
+		streamFile := NewEventLogFile(d.event.GetStream(), d.Options)
+		streamInode := d.NewInode(
+			ctx,
+			&streamFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return streamInode, syscall.F_OK
+	case "export.json":
+		exportFile := NewInfoFile(d.readExport, d.Options)
+		exportInode := d.NewInode(
+			ctx,
+			&exportFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return exportInode, syscall.F_OK
+	case "events.dropped":
+		droppedFile := NewInfoFile(d.readDropped, d.Options)
+		droppedInode := d.NewInode(
+			ctx,
+			&droppedFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return droppedInode, syscall.F_OK
+	case "fire_test":
+		fireTestFile := NewTriggerFile(d.fireTest, d.Options)
+		fireTestInode := d.NewInode(
+			ctx,
+			&fireTestFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return fireTestInode, syscall.F_OK
+	case "event.summary":
+		summaryFile := NewInfoFile(d.readSummary, d.Options)
+		summaryInode := d.NewInode(
+			ctx,
+			&summaryFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return summaryInode, syscall.F_OK
+	case "control.help", "kind.help", "suspendPolicy.help":
+		var help string
+		switch name {
+		case "control.help":
+			help = eventControlHelp
+		case "kind.help":
+			help = eventKindHelp()
+		case "suspendPolicy.help":
+			help = eventSuspendPolicyHelp()
+		}
+
+		helpInode := d.NewInode(
+			ctx,
+			&fs.MemRegularFile {
+				Data: []byte(help),
+				Attr: staticAttr(d.Options.fileMode()),
+			},
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return helpInode, syscall.F_OK
 	case "hooks":
-		foundFile := NewEventHooksDirectory(d.event)
+		foundFile := NewEventHooksDirectory(d.event, d.Options.Strict, d.Options)
 		foundInode := d.NewInode(
 			ctx,
 			&foundFile,
@@ -179,7 +387,27 @@ func (d *JdwpEventDir) Lookup(ctx context.Context, name string, out *fuse.EntryO
 		)
 		return foundInode, syscall.F_OK
 	case "location":
-		foundFile := NewEventLocationDirectory(d.event, d.manager.JdwpConnection, d.absoluteMountpoint)
+		foundFile := NewEventLocationDirectory(d.event, d.manager.JdwpConnection, d.absoluteMountpoint, d.Options)
+		foundInode := d.NewInode(
+			ctx,
+			&foundFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFDIR,
+			},
+		)
+		return foundInode, syscall.F_OK
+	case "modifiers":
+		foundFile := NewEventModifiersDirectory(d.event, d.manager.JdwpConnection, d.absoluteMountpoint, d.Options)
+		foundInode := d.NewInode(
+			ctx,
+			&foundFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFDIR,
+			},
+		)
+		return foundInode, syscall.F_OK
+	case "threads":
+		foundFile := NewEventThreadsDirectory(d.event, d.Options)
 		foundInode := d.NewInode(
 			ctx,
 			&foundFile,
@@ -192,3 +420,49 @@ func (d *JdwpEventDir) Lookup(ctx context.Context, name string, out *fuse.EntryO
 		return nil, syscall.ENOENT
 	}
 }
+
+// readExport serializes the event configuration, as it is at the time
+// of the read
+func (d *JdwpEventDir) readExport() ([]byte, syscall.Errno) {
+	export, err := json.MarshalIndent(d.event.GetDefinition(), "", "  ")
+	if err != nil {
+		log.Printf("unable to export event %s: %s\n", d.name, err)
+		return nil, syscall.EFAULT
+	}
+
+	return append(export, '\n'), 0
+}
+
+// readRegistered reads true while the event is registered; once it's
+// deregistered, the file is gone
+func (d *JdwpEventDir) readRegistered() ([]byte, syscall.Errno) {
+	if !d.isRegistered() {
+		return nil, syscall.ENOENT
+	}
+
+	return []byte(newlineTerminated(strconv.FormatBool(true))), 0
+}
+
+// readDropped is how many fired events the log and the stream dropped,
+// for being over --event-buffer-size
+func (d *JdwpEventDir) readDropped() ([]byte, syscall.Errno) {
+	return []byte(newlineTerminated(strconv.FormatUint(d.event.GetDropped(), 10))), 0
+}
+
+// fireTest runs the hooks on a synthetic event of the kind of the event,
+// without the VM; EIO if a hook fails, or its plugin can't be loaded
+func (d *JdwpEventDir) fireTest() syscall.Errno {
+	err := d.event.FireTest()
+	if err != nil {
+		log.Printf("synthetic event for event %s failed: %s\n", d.name, err)
+		return syscall.EIO
+	}
+
+	return syscall.F_OK
+}
+
+// readSummary describes the event in a line, as it is at the time of
+// the read
+func (d *JdwpEventDir) readSummary() ([]byte, syscall.Errno) {
+	return []byte(newlineTerminated(d.event.GetDefinition().Summary())), 0
+}
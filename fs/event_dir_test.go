@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+func newTestEventDir(t *testing.T, name string) (*JdwpEventDir, *debug.EventManager) {
+	t.Helper()
+
+	manager, err := debug.NewEventManager(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unable to create the event manager: %s", err)
+	}
+	if _, err := manager.CreateEvent(name); err != nil {
+		t.Fatalf("unable to create event %s: %s", name, err)
+	}
+
+	eventDir, err := JdwpEventDirFromDebuggingEvent(name, "/mnt", manager, JdwpFsOptions{})
+	if err != nil {
+		t.Fatalf("unable to make the dir of event %s: %s", name, err)
+	}
+
+	return eventDir, manager
+}
+
+func listsEntry(t *testing.T, d *JdwpEventDir, name string) bool {
+	t.Helper()
+
+	stream, errno := d.Readdir(context.Background())
+	if errno != syscall.F_OK {
+		t.Fatalf("readdir: %v", errno)
+	}
+	for stream.HasNext() {
+		entry, errno := stream.Next()
+		if errno != syscall.F_OK {
+			t.Fatalf("readdir: %v", errno)
+		}
+		if entry.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestEventDirUnlink(t *testing.T) {
+	var tests = []struct {
+		name string
+		running bool
+		errno syscall.Errno
+		deregistered bool
+	}{
+		{ name: "kind", errno: syscall.EPERM },
+		{ name: "control", errno: syscall.EPERM },
+		{ name: "fire_test", errno: syscall.EPERM },
+		{ name: "kind.help", errno: syscall.EPERM },
+		{ name: "missing", errno: syscall.ENOENT },
+		{ name: "registered", running: true, errno: syscall.EBUSY },
+		{ name: "registered", errno: syscall.F_OK, deregistered: true },
+	}
+
+	for _, test := range tests {
+		eventDir, manager := newTestEventDir(t, "unlink")
+		event, _ := manager.GetEvent("unlink")
+		if test.running {
+			event.SetCtx(context.Background())
+		}
+
+		errno := eventDir.Unlink(context.Background(), test.name)
+		if errno != test.errno {
+			t.Errorf("unlink %s: got %v, expected %v", test.name, errno, test.errno)
+		}
+
+		_, err := manager.GetEvent("unlink")
+		if deregistered := err != nil; deregistered != test.deregistered {
+			t.Errorf("unlink %s: deregistered: %v", test.name, deregistered)
+		}
+		if listed := listsEntry(t, eventDir, "registered"); listed == test.deregistered {
+			t.Errorf("unlink %s: registered listed: %v", test.name, listed)
+		}
+	}
+}
+
+func TestEventDirRegistered(t *testing.T) {
+	eventDir, manager := newTestEventDir(t, "registered")
+
+	data, errno := eventDir.readRegistered()
+	if errno != syscall.F_OK || string(data) != "true\n" {
+		t.Fatalf("registered event: read %q, %v", data, errno)
+	}
+	if !listsEntry(t, eventDir, "control") {
+		t.Fatalf("control isn't listed")
+	}
+
+	// deregistered from elsewhere, e.g. through clear_events
+	if err := manager.DeregisterEvent("registered"); err != nil {
+		t.Fatalf("unable to deregister: %s", err)
+	}
+
+	if _, errno := eventDir.readRegistered(); errno != syscall.ENOENT {
+		t.Fatalf("deregistered event: read gives %v", errno)
+	}
+	if listsEntry(t, eventDir, "registered") {
+		t.Fatalf("registered is listed after deregistering")
+	}
+	if errno := eventDir.Unlink(context.Background(), "registered"); errno != syscall.ENOENT {
+		t.Fatalf("unlinking registered again: %v", errno)
+	}
+}
+
+func TestMkdirOutsideEvents(t *testing.T) {
+	eventDir, _ := newTestEventDir(t, "nested")
+	locationDir := NewEventLocationDirectory(nil, nil, "/mnt", JdwpFsOptions{})
+	hooksDir := NewEventHooksDirectory(nil, false, JdwpFsOptions{})
+
+	var tests = []struct {
+		name string
+		dir fs.NodeMkdirer
+	}{
+		{ name: "root", dir: &JdwpRootFs{} },
+		{ name: "classes", dir: &JdwpClassMasterDir{} },
+		{ name: "classes_by_signature", dir: &JdwpClassNamedMasterDir{} },
+		{ name: "class methods", dir: &ClassMethodMasterDir{} },
+		{ name: "threads", dir: &JdwpThreadMasterDir{} },
+		{ name: "threads_by_name", dir: &JdwpThreadNamedDir{} },
+		{ name: "thread_groups", dir: &JdwpThreadGroupMasterDir{} },
+		{ name: "objects", dir: &JdwpObjectMasterDir{} },
+		{ name: "event", dir: eventDir },
+		{ name: "event location", dir: &locationDir },
+		{ name: "event hooks", dir: &hooksDir },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, errno := test.dir.Mkdir(context.Background(), "new", 0755, nil)
+			if errno != syscall.EPERM || node != nil {
+				t.Fatalf("mkdir: got %v, expected EPERM", errno)
+			}
+		})
+	}
+}
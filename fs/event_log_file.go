@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"syscall"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// the logs of an event, as text or binary records
+type appendOnlyLog interface {
+	Size() int64
+	ReadAt(dest []byte, offset int64) (int, error)
+}
+
+var _ = (appendOnlyLog)((*debug.EventLog)(nil))
+var _ = (appendOnlyLog)((*debug.EventStream)(nil))
+
+//
+// Event log file
+// Grows as the event fires; its size is the log size, so it can be
+// followed with `tail -f`. Reading what was dropped fails with ESPIPE
+//
+type EventLogFile struct {
+	fs.Inode
+
+	eventLog appendOnlyLog
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeOpener)((*EventLogFile)(nil))
+var _ = (fs.NodeOpendirer)((*EventLogFile)(nil))
+var _ = (fs.NodeGetattrer)((*EventLogFile)(nil))
+var _ = (fs.NodeReader)((*EventLogFile)(nil))
+
+func NewEventLogFile(eventLog appendOnlyLog, options JdwpFsOptions) EventLogFile {
+	return EventLogFile {
+		eventLog: eventLog,
+		Options: options,
+	}
+}
+
+func (f *EventLogFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & syscall.O_ACCMODE != syscall.O_RDONLY {
+		return nil, 0, syscall.EROFS
+	}
+
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (f *EventLogFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (f *EventLogFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = f.Options.fileMode()
+	out.Size = uint64(f.eventLog.Size())
+	setMountTimes(out)
+	return 0
+}
+
+func (f *EventLogFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := f.eventLog.ReadAt(dest, offset)
+	if errors.As(err, &debug.EventsDroppedError{}) {
+		return nil, syscall.ESPIPE
+	}
+	if err != nil {
+		log.Printf("unable to read the events log: %s\n", err)
+		return nil, syscall.EIO
+	}
+
+	return fuse.ReadResultData(dest[:n]), 0
+}
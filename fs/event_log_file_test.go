@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestEventLogFileFollow(t *testing.T) {
+	var tests = []struct {
+		name string
+		lines []string
+		size uint64
+		read string
+	}{
+		{ name: "empty", size: 0, read: "" },
+		{ name: "one line", lines: []string { "one" }, size: 4, read: "one\n" },
+		{ name: "more lines", lines: []string { "two", "three" }, size: 14, read: "two\nthree\n" },
+	}
+
+	eventLog := debug.NewEventLog(8)
+	logFile := NewEventLogFile(eventLog, JdwpFsOptions{})
+
+	var offset int64
+	for _, test := range tests {
+		for _, line := range test.lines {
+			eventLog.Append(line)
+		}
+
+		var out fuse.AttrOut
+		if errno := logFile.Getattr(context.Background(), nil, &out); errno != syscall.F_OK {
+			t.Fatalf("%s: getattr: %v", test.name, errno)
+		}
+		if out.Size != test.size {
+			t.Fatalf("%s: size %d, expected %d", test.name, out.Size, test.size)
+		}
+
+		// read on from the previous offset
+		dest := make([]byte, 64)
+		result, errno := logFile.Read(context.Background(), nil, dest, offset)
+		if errno != syscall.F_OK {
+			t.Fatalf("%s: read: %v", test.name, errno)
+		}
+		data, _ := result.Bytes(dest)
+		if string(data) != test.read {
+			t.Fatalf("%s: read %q, expected %q", test.name, data, test.read)
+		}
+		offset += int64(len(data))
+	}
+}
+
+func TestEventLogFileDropped(t *testing.T) {
+	var tests = []struct {
+		name string
+		offset int64
+		errno syscall.Errno
+		read string
+	}{
+		{ name: "dropped", offset: 0, errno: syscall.ESPIPE },
+		{ name: "within a dropped line", offset: 2, errno: syscall.ESPIPE },
+		{ name: "oldest kept", offset: 4, read: "two\n" },
+	}
+
+	eventLog := debug.NewEventLog(1)
+	eventLog.Append("one")
+	eventLog.Append("two")
+	logFile := NewEventLogFile(eventLog, JdwpFsOptions{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dest := make([]byte, 64)
+			result, errno := logFile.Read(context.Background(), nil, dest, test.offset)
+			if errno != test.errno {
+				t.Fatalf("read: got %v, expected %v", errno, test.errno)
+			}
+			if errno != syscall.F_OK {
+				return
+			}
+			data, _ := result.Bytes(dest)
+			if string(data) != test.read {
+				t.Fatalf("read %q, expected %q", data, test.read)
+			}
+		})
+	}
+}
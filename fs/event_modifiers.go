@@ -0,0 +1,337 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Event modifiers directory
+// One directory per modifier: mkdir creates an empty (pending) one,
+// linking its target makes it apply, rmdir removes it
+//
+type EventModifiersDirectory struct {
+	fs.Inode
+
+	JdwpConnection *debug.Connection
+	event *debug.DebuggingEvent
+	absoluteMountpoint string
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*EventModifiersDirectory)(nil))
+var _ = (fs.NodeMkdirer)((*EventModifiersDirectory)(nil))
+var _ = (fs.NodeRmdirer)((*EventModifiersDirectory)(nil))
+var _ = (fs.NodeReaddirer)((*EventModifiersDirectory)(nil))
+var _ = (fs.NodeLookuper)((*EventModifiersDirectory)(nil))
+
+func NewEventModifiersDirectory(event *debug.DebuggingEvent, conn *debug.Connection, absMountpoint string, options JdwpFsOptions) EventModifiersDirectory {
+	return EventModifiersDirectory {
+		event: event,
+		JdwpConnection: conn,
+		absoluteMountpoint: absMountpoint,
+		Options: options,
+	}
+}
+
+func (d *EventModifiersDirectory) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *EventModifiersDirectory) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries = []fuse.DirEntry{}
+	for _, modifier := range d.event.GetModifiers() {
+		newEntry := fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: modifier.Name,
+		}
+		entries = append(entries, newEntry)
+	}
+
+	return fs.NewListDirStream(entries), syscall.F_OK
+}
+
+func (d *EventModifiersDirectory) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	added := d.event.AddModifier(name, debug.ModifierDescriptor {
+		Name: name,
+		Pending: true,
+	})
+	if !added {
+		return nil, syscall.EEXIST
+	}
+
+	return d.newModifierInode(ctx, name), syscall.F_OK
+}
+
+func (d *EventModifiersDirectory) Rmdir(ctx context.Context, name string) syscall.Errno {
+	err := d.event.DeleteModifier(name)
+	if errors.As(err, &debug.JdwpDebuggingEventError{}) {
+		return syscall.ENOENT
+	}
+	if err != nil {
+		log.Printf("unable to delete modifier %s: %s\n", name, err)
+		return syscall.EACCES
+	}
+
+	return syscall.F_OK
+}
+
+func (d *EventModifiersDirectory) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if _, ok := d.event.GetModifiers()[name]; !ok {
+		return nil, syscall.ENOENT
+	}
+
+	return d.newModifierInode(ctx, name), syscall.F_OK
+}
+
+func (d *EventModifiersDirectory) newModifierInode(ctx context.Context, name string) *fs.Inode {
+	modifierDir := NewEventModifierDirectory(d.event, d.JdwpConnection, d.absoluteMountpoint, name, d.Options)
+	return d.NewInode(
+		ctx,
+		&modifierDir,
+		fs.StableAttr {
+			Mode: fuse.S_IFDIR,
+		})
+}
+
+// resolveModifierTarget turns a path to a thread, threads/<id>, into a
+// modifier limiting the events to it; other paths are locations
+func resolveModifierTarget(conn *debug.Connection, absoluteMountpoint string, dir *fs.Inode, target string, options JdwpFsOptions) (debug.ModifierDescriptor, syscall.Errno) {
+	pathComponents, errno := targetComponents(absoluteMountpoint, dir, target)
+	if errno != syscall.F_OK {
+		return debug.ModifierDescriptor{}, errno
+	}
+
+	if len(pathComponents) != 2 || pathComponents[0] != "threads" {
+		return resolveLocationTarget(conn, absoluteMountpoint, dir, target, options)
+	}
+
+	threadId, err := options.parseId(pathComponents[1])
+	if err != nil || threadId == 0 {
+		log.Printf("target %s has unparsable thread id\n", target)
+		return debug.ModifierDescriptor{}, syscall.EBADE
+	}
+
+	threadName, err := conn.GetThreadName(jdwp.ThreadID(threadId))
+	if err != nil {
+		log.Printf("unable to find thread %d: %s\n", threadId, err)
+		return debug.ModifierDescriptor{}, syscall.ENOENT
+	}
+
+	newModifier := debug.ModifierDescriptor {
+		ThreadId: threadId,
+		ObjectName: threadName,
+	}
+
+	return newModifier, syscall.F_OK
+}
+
+//
+// Event modifier directory
+// kind is field, method, thread or pending; class and target link to
+// the class and to the field or method the modifier applies to, while
+// thread modifiers only have target, linking to the thread
+//
+type EventModifierDirectory struct {
+	fs.Inode
+
+	JdwpConnection *debug.Connection
+	event *debug.DebuggingEvent
+	absoluteMountpoint string
+	name string
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*EventModifierDirectory)(nil))
+var _ = (fs.NodeSymlinker)((*EventModifierDirectory)(nil))
+var _ = (fs.NodeUnlinker)((*EventModifierDirectory)(nil))
+var _ = (fs.NodeReaddirer)((*EventModifierDirectory)(nil))
+var _ = (fs.NodeLookuper)((*EventModifierDirectory)(nil))
+var _ = (fs.NodeMkdirer)((*EventModifierDirectory)(nil))
+
+func NewEventModifierDirectory(event *debug.DebuggingEvent, conn *debug.Connection, absMountpoint string, name string, options JdwpFsOptions) EventModifierDirectory {
+	return EventModifierDirectory {
+		event: event,
+		JdwpConnection: conn,
+		absoluteMountpoint: absMountpoint,
+		name: name,
+		Options: options,
+	}
+}
+
+func (d *EventModifierDirectory) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *EventModifierDirectory) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *EventModifierDirectory) modifier() (debug.ModifierDescriptor, bool) {
+	modifier, ok := d.event.GetModifiers()[d.name]
+	return modifier, ok
+}
+
+func (d *EventModifierDirectory) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	modifier, ok := d.modifier()
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+
+	var entries = []fuse.DirEntry {
+		fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "kind",
+		},
+	}
+	if !modifier.Pending && modifier.ThreadId == 0 {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFLNK,
+			Name: "class",
+		})
+	}
+	if !modifier.Pending {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFLNK,
+			Name: "target",
+		})
+	}
+
+	return fs.NewListDirStream(entries), syscall.F_OK
+}
+
+func (d *EventModifierDirectory) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	modifier, ok := d.modifier()
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+
+	var link string
+	switch name {
+	case "kind":
+		var kind string
+		switch {
+		case modifier.Pending:
+			kind = "pending"
+		case modifier.ThreadId != 0:
+			kind = "thread"
+		case modifier.IsField:
+			kind = "field"
+		default:
+			kind = "method"
+		}
+
+		kindFile := d.NewInode(
+			ctx,
+			&fs.MemRegularFile {
+				Data: []byte(newlineTerminated(kind)),
+				Attr: staticAttr(d.Options.fileMode()),
+			},
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return kindFile, syscall.F_OK
+	case "class":
+		if modifier.Pending || modifier.ThreadId != 0 {
+			return nil, syscall.ENOENT
+		}
+		link = relativeLink(&d.Inode, "classes/" + d.Options.formatId(modifier.ClassId))
+	case "target":
+		if modifier.Pending {
+			return nil, syscall.ENOENT
+		}
+		link = relativeLink(&d.Inode, locationTargetPath(modifier, d.Options))
+	default:
+		return nil, syscall.ENOENT
+	}
+
+	linkInode := d.NewInode(
+		ctx,
+		&fs.MemSymlink {
+			Data: []byte(link),
+			Attr: staticAttr(d.Options.fileMode()),
+		},
+		fs.StableAttr {
+			Mode: fuse.S_IFLNK,
+		})
+	return linkInode, syscall.F_OK
+}
+
+// Symlink sets the field, method or thread the modifier applies to;
+// only target can be linked, class follows from it
+func (d *EventModifierDirectory) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (node *fs.Inode, errno syscall.Errno) {
+	if name != "target" {
+		return nil, syscall.EPERM
+	}
+
+	modifier, ok := d.modifier()
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	if !modifier.Pending {
+		return nil, syscall.EEXIST
+	}
+
+	newModifier, errno := resolveModifierTarget(d.JdwpConnection, d.absoluteMountpoint, &d.Inode, target, d.Options)
+	if errno != syscall.F_OK {
+		return nil, errno
+	}
+	newModifier.Name = d.name
+
+	// it may have been removed, or linked, while the target was resolved
+	if !d.event.CompletePendingModifier(d.name, newModifier) {
+		if _, ok := d.modifier(); !ok {
+			return nil, syscall.ENOENT
+		}
+		return nil, syscall.EEXIST
+	}
+
+	newLink := d.NewInode(
+		ctx,
+		&fs.MemSymlink {
+			Data: []byte(target),
+			Attr: staticAttr(d.Options.fileMode()),
+		},
+		fs.StableAttr {
+			Mode: fuse.S_IFLNK,
+		})
+	return newLink, syscall.F_OK
+}
+
+// Unlink of target makes the modifier pending again, so it no longer
+// applies the next time the event is run
+func (d *EventModifierDirectory) Unlink(ctx context.Context, name string) syscall.Errno {
+	if name != "target" {
+		return syscall.EPERM
+	}
+
+	modifier, ok := d.modifier()
+	if !ok || modifier.Pending {
+		return syscall.ENOENT
+	}
+
+	d.event.SetModifier(d.name, debug.ModifierDescriptor {
+		Name: d.name,
+		Pending: true,
+	})
+
+	return syscall.F_OK
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// threadNameHandler names every thread worker
+func threadNameHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	if set == 11 && cmd == 1 {
+		return 0, fakeString("worker")
+	}
+	return 0, nil
+}
+
+func TestEventModifierDirs(t *testing.T) {
+	// a mountpoint holding the thread linked to
+	mountpoint, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.MkdirAll(filepath.Join(mountpoint, "threads", "7"), 0755); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	conn, _ := startFakeVM(t, threadNameHandler)
+	event := debug.NewStubDebuggingEvent("modified")
+	modifiersDir := NewEventModifiersDirectory(event, conn, mountpoint, JdwpFsOptions{})
+	fs.NewNodeFS(&modifiersDir, &fs.Options{})
+
+	var steps = []struct {
+		name string
+		apply func() syscall.Errno
+		errno syscall.Errno
+		modifiers map[string]debug.ModifierDescriptor
+		listed []string
+	}{
+		{
+			name: "mkdir",
+			apply: func() syscall.Errno {
+				_, errno := modifiersDir.Mkdir(context.Background(), "only7", 0755, nil)
+				return errno
+			},
+			modifiers: map[string]debug.ModifierDescriptor {
+				"only7": { Name: "only7", Pending: true },
+			},
+			listed: []string { "only7" },
+		},
+		{
+			name: "mkdir again",
+			apply: func() syscall.Errno {
+				_, errno := modifiersDir.Mkdir(context.Background(), "only7", 0755, nil)
+				return errno
+			},
+			errno: syscall.EEXIST,
+			modifiers: map[string]debug.ModifierDescriptor {
+				"only7": { Name: "only7", Pending: true },
+			},
+			listed: []string { "only7" },
+		},
+		{
+			name: "link the target",
+			apply: func() syscall.Errno {
+				modifierDir := lookupModifierDir(t, &modifiersDir, "only7")
+				_, errno := modifierDir.Symlink(context.Background(), filepath.Join(mountpoint, "threads", "7"), "target", nil)
+				return errno
+			},
+			modifiers: map[string]debug.ModifierDescriptor {
+				"only7": { Name: "only7", ThreadId: 7, ObjectName: "worker" },
+			},
+			listed: []string { "only7" },
+		},
+		{
+			name: "unlink the target",
+			apply: func() syscall.Errno {
+				modifierDir := lookupModifierDir(t, &modifiersDir, "only7")
+				return modifierDir.Unlink(context.Background(), "target")
+			},
+			modifiers: map[string]debug.ModifierDescriptor {
+				"only7": { Name: "only7", Pending: true },
+			},
+			listed: []string { "only7" },
+		},
+		{
+			name: "rmdir",
+			apply: func() syscall.Errno {
+				return modifiersDir.Rmdir(context.Background(), "only7")
+			},
+			modifiers: map[string]debug.ModifierDescriptor{},
+			listed: []string{},
+		},
+		{
+			name: "rmdir again",
+			apply: func() syscall.Errno {
+				return modifiersDir.Rmdir(context.Background(), "only7")
+			},
+			errno: syscall.ENOENT,
+			modifiers: map[string]debug.ModifierDescriptor{},
+			listed: []string{},
+		},
+	}
+
+	for _, step := range steps {
+		if errno := step.apply(); errno != step.errno {
+			t.Fatalf("%s: got %v, expected %v", step.name, errno, step.errno)
+		}
+		if modifiers := event.GetModifiers(); !reflect.DeepEqual(modifiers, step.modifiers) {
+			t.Fatalf("%s: modifiers %+v, expected %+v", step.name, modifiers, step.modifiers)
+		}
+		if listed := listNames(t, &modifiersDir); !reflect.DeepEqual(listed, step.listed) {
+			t.Fatalf("%s: listed %v, expected %v", step.name, listed, step.listed)
+		}
+	}
+}
+
+func lookupModifierDir(t *testing.T, d *EventModifiersDirectory, name string) *EventModifierDirectory {
+	t.Helper()
+
+	node, errno := d.Lookup(context.Background(), name, nil)
+	if errno != syscall.F_OK {
+		t.Fatalf("lookup %s: %v", name, errno)
+	}
+	return node.Operations().(*EventModifierDirectory)
+}
+
+func TestEventThreadLinks(t *testing.T) {
+	event := debug.NewStubDebuggingEvent("limited")
+	event.SetModifier("only7", debug.ModifierDescriptor { Name: "only7", ThreadId: 7, ObjectName: "worker" })
+	event.SetModifier("only9", debug.ModifierDescriptor { Name: "only9", ThreadId: 9, ObjectName: "reaper" })
+	event.SetModifier("later", debug.ModifierDescriptor { Name: "later", Pending: true })
+	event.SetModifier("run", debug.ModifierDescriptor { Name: "run", ClassId: 42, ObjectId: 7 })
+
+	// events/limited/{threads,modifiers}, under the root of the mount
+	root := &fs.Inode{}
+	fs.NewNodeFS(root, &fs.Options{})
+	dirAttr := fs.StableAttr{ Mode: fuse.S_IFDIR }
+	eventsInode := root.NewPersistentInode(context.Background(), &fs.Inode{}, dirAttr)
+	root.AddChild("events", eventsInode, false)
+	eventInode := root.NewPersistentInode(context.Background(), &fs.Inode{}, dirAttr)
+	eventsInode.AddChild("limited", eventInode, false)
+
+	threadsDir := NewEventThreadsDirectory(event, JdwpFsOptions{})
+	eventInode.AddChild("threads", root.NewPersistentInode(context.Background(), &threadsDir, dirAttr), false)
+	modifiersDir := NewEventModifiersDirectory(event, nil, "/mnt", JdwpFsOptions{})
+	eventInode.AddChild("modifiers", root.NewPersistentInode(context.Background(), &modifiersDir, dirAttr), false)
+
+	listed := listNames(t, &threadsDir)
+	sort.Strings(listed)
+	if !reflect.DeepEqual(listed, []string { "7", "9" }) {
+		t.Fatalf("listed threads %v", listed)
+	}
+	for _, thread := range []string { "7", "9" } {
+		if target := linkTarget(t, &threadsDir, thread); target != "../../../threads/" + thread {
+			t.Fatalf("thread %s links to %s", thread, target)
+		}
+	}
+	if _, errno := threadsDir.Lookup(context.Background(), "8", &fuse.EntryOut{}); errno != syscall.ENOENT {
+		t.Fatalf("lookup of a thread without modifier: %v", errno)
+	}
+
+	// the modifier itself links to the same thread
+	modifierInode, errno := modifiersDir.Lookup(context.Background(), "only7", &fuse.EntryOut{})
+	if errno != syscall.F_OK {
+		t.Fatalf("lookup only7: %v", errno)
+	}
+	modifiersDir.AddChild("only7", modifierInode, false)
+	modifierDir := modifierInode.Operations().(*EventModifierDirectory)
+	if target := linkTarget(t, modifierDir, "target"); target != "../../../../threads/7" {
+		t.Fatalf("the modifier target links to %s", target)
+	}
+	if _, errno := modifierDir.Lookup(context.Background(), "class", &fuse.EntryOut{}); errno != syscall.ENOENT {
+		t.Fatalf("lookup of the class of a thread modifier: %v", errno)
+	}
+}
+
+func TestEventModifiersConcurrentMkdir(t *testing.T) {
+	const creators = 16
+
+	conn, _ := startFakeVM(t, nil)
+	event := debug.NewStubDebuggingEvent("modified")
+	modifiersDir := NewEventModifiersDirectory(event, conn, "/mnt", JdwpFsOptions{})
+	fs.NewNodeFS(&modifiersDir, &fs.Options{})
+
+	var wg sync.WaitGroup
+	errnos := make(chan syscall.Errno, creators)
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errno := modifiersDir.Mkdir(context.Background(), "only7", 0755, nil)
+			errnos <- errno
+		}()
+	}
+	wg.Wait()
+	close(errnos)
+
+	var created int
+	for errno := range errnos {
+		switch errno {
+		case syscall.F_OK:
+			created++
+		case syscall.EEXIST:
+		default:
+			t.Fatalf("mkdir: %v", errno)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("created %d times", created)
+	}
+}
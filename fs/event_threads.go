@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Event threads directory
+// Links to the threads the event's thread modifiers limit it to, by
+// thread id; empty unless the event is limited to some thread
+//
+type EventThreadsDirectory struct {
+	fs.Inode
+
+	event *debug.DebuggingEvent
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*EventThreadsDirectory)(nil))
+var _ = (fs.NodeReaddirer)((*EventThreadsDirectory)(nil))
+var _ = (fs.NodeLookuper)((*EventThreadsDirectory)(nil))
+var _ = (fs.NodeMkdirer)((*EventThreadsDirectory)(nil))
+
+func NewEventThreadsDirectory(event *debug.DebuggingEvent, options JdwpFsOptions) EventThreadsDirectory {
+	return EventThreadsDirectory {
+		event: event,
+		Options: options,
+	}
+}
+
+// threads returns the threads of the modifiers which apply
+func (d *EventThreadsDirectory) threads() map[jdwp.ThreadID]bool {
+	var threads = map[jdwp.ThreadID]bool{}
+	for _, modifier := range d.event.GetModifiers() {
+		if !modifier.Pending && modifier.ThreadId != 0 {
+			threads[jdwp.ThreadID(modifier.ThreadId)] = true
+		}
+	}
+
+	return threads
+}
+
+func (d *EventThreadsDirectory) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *EventThreadsDirectory) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *EventThreadsDirectory) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries = []fuse.DirEntry{}
+	for thread := range d.threads() {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFLNK,
+			Name: d.Options.formatId(uint64(thread)),
+		})
+	}
+
+	return fs.NewListDirStream(entries), syscall.F_OK
+}
+
+func (d *EventThreadsDirectory) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	threadId, err := d.Options.parseId(name)
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+
+	if !d.threads()[jdwp.ThreadID(threadId)] {
+		return nil, syscall.ENOENT
+	}
+
+	return newThreadLink(ctx, &d.Inode, jdwp.ThreadID(threadId), d.Options), syscall.F_OK
+}
@@ -7,13 +7,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 
-	jdwp "github.com/omerye/gojdb/jdwp"
-
 	"disroot.org/kitzman/jdwpfs/debug"
 )
 
@@ -41,11 +41,18 @@ type JdwpEventsMasterDir struct {
 	fs.Inode
 	
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
 
 	registered bool
 	absoluteMountpoint string
 	manager *debug.EventManager
+
+	Options JdwpFsOptions
+
+	// the outcome of the last bulk run or cancel, and import
+	mu sync.Mutex
+	controlReport string
+	importReport string
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpEventsMasterDir)(nil))
@@ -53,13 +60,11 @@ var _ = (fs.NodeMkdirer)((*JdwpEventsMasterDir)(nil))
 var _ = (fs.NodeReaddirer)((*JdwpEventsMasterDir)(nil))
 var _ = (fs.NodeLookuper)((*JdwpEventsMasterDir)(nil))
 
-func NewJdwpEventsMasterDir(ctx context.Context, conn *jdwp.Connection, absMountpoint string) (*JdwpEventsMasterDir, error) {
-	manager, err := debug.NewEventManager(ctx, conn)
-	if err != nil {
-		log.Printf("unable to create event master dir: %s\n", err)
-		return nil, JdwpEventDirError { err: err }
+func NewJdwpEventsMasterDir(ctx context.Context, conn *debug.Connection, manager *debug.EventManager, absMountpoint string, options JdwpFsOptions) (*JdwpEventsMasterDir, error) {
+	if manager == nil {
+		return nil, JdwpEventDirError { message: "no event manager" }
 	}
-	
+
 	eventsDir := &JdwpEventsMasterDir {
 		JdwpContext: ctx,
 		JdwpConnection: conn,
@@ -67,13 +72,15 @@ func NewJdwpEventsMasterDir(ctx context.Context, conn *jdwp.Connection, absMount
 		registered: false,
 		manager: manager,
 		absoluteMountpoint: absMountpoint,
+		Options: options,
 	}
 
 	return eventsDir, nil
 }
 
 func (d *JdwpEventsMasterDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
@@ -84,7 +91,20 @@ func (d *JdwpEventsMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscal
 		return nil, syscall.EBADFD
 	}
 
-	var dirListing = []fuse.DirEntry{}
+	var dirListing = []fuse.DirEntry {
+		fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "control",
+		},
+		fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "import",
+		},
+		fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "status",
+		},
+	}
 	for _, event := range events {
 		if err != nil {
 			log.Printf("unable to get event %s: %s\n", event.Name, err)
@@ -92,7 +112,7 @@ func (d *JdwpEventsMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscal
 		}
 
 		eventDirEntry := fuse.DirEntry {
-			Mode: fuse.S_IFREG,
+			Mode: fuse.S_IFDIR,
 			Name: event.Name,
 		}
 
@@ -103,13 +123,22 @@ func (d *JdwpEventsMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscal
 }
 
 func (d *JdwpEventsMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == "control" || name == "import" || name == "status" {
+		return nil, syscall.EEXIST
+	}
+
+	errno := d.Options.checkPolicy(PolicyEventCreate)
+	if errno != syscall.F_OK {
+		return nil, errno
+	}
+
 	_, err := d.manager.CreateEvent(name)
 	if err != nil {
 		log.Printf("unable to create event dir %s: %s", name, err)
 		return nil, syscall.EADDRNOTAVAIL
 	}
 
-	eventDir, err := JdwpEventDirFromDebuggingEvent(name, d.absoluteMountpoint, d.manager)
+	eventDir, err := JdwpEventDirFromDebuggingEvent(name, d.absoluteMountpoint, d.manager, d.Options)
 	if err != nil {
 		log.Printf("unable to validate the creation of event dir %s: %s", name, err)
 		return nil, syscall.EADDRNOTAVAIL
@@ -127,12 +156,51 @@ func (d *JdwpEventsMasterDir) Mkdir(ctx context.Context, name string, mode uint3
 }
 
 func (d *JdwpEventsMasterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == "control" {
+		controlFile := NewEventsMasterControlFile(d, d.Options)
+		controlFileInode := d.NewInode(
+			ctx,
+			&controlFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+
+		return controlFileInode, syscall.F_OK
+	}
+
+	if name == "import" {
+		importFile := NewEventsImportFile(d, d.Options)
+		importFileInode := d.NewInode(
+			ctx,
+			&importFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+
+		return importFileInode, syscall.F_OK
+	}
+
+	if name == "status" {
+		statusFile := NewInfoFile(d.readStatus, d.Options)
+		statusFileInode := d.NewInode(
+			ctx,
+			&statusFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+
+		return statusFileInode, syscall.F_OK
+	}
+
 	event, err := d.manager.GetEvent(name)
 	if err != nil {
 		return nil, syscall.ENOENT
 	}
 
-	eventDir, err := JdwpEventDirFromDebuggingEvent(event.Name, d.absoluteMountpoint, d.manager)
+	eventDir, err := JdwpEventDirFromDebuggingEvent(event.Name, d.absoluteMountpoint, d.manager, d.Options)
 	if err != nil {
 		log.Printf("error creating dir for %s", name)
 		return nil, syscall.EADDRNOTAVAIL
@@ -148,3 +216,182 @@ func (d *JdwpEventsMasterDir) Lookup(ctx context.Context, name string, out *fuse
 
 	return eventDirInode, syscall.F_OK
 }
+
+// readStatus gives a line per event: its name, and whether it's running
+// or idle
+func (d *JdwpEventsMasterDir) readStatus() ([]byte, syscall.Errno) {
+	events, err := d.manager.GetAllEvents()
+	if err != nil {
+		log.Printf("unable to get events: %s\n", err)
+		return nil, syscall.EBADFD
+	}
+
+	var status = ""
+	for _, event := range events {
+		var state = "idle"
+		if event.IsRunning() {
+			state = "running"
+		}
+		status = fmt.Sprintf("%s%s\t%s\n", status, event.Name, state)
+	}
+
+	return []byte(status), 0
+}
+
+// runAll runs the enabled events which aren't running yet, or cancels
+// the running ones; a failing event doesn't stop the others
+func (d *JdwpEventsMasterDir) runAll(run bool) (string, bool) {
+	events, err := d.manager.GetAllEvents()
+	if err != nil {
+		log.Printf("unable to get events: %s\n", err)
+		return fmt.Sprintf("unable to get events: %s\n", err), false
+	}
+
+	var done = 0
+	var failures []string
+	for _, event := range events {
+		switch {
+		case run && (event.IsRunning() || !event.IsEnabled()):
+			continue
+		case !run && !event.IsRunning():
+			continue
+		}
+
+		if run {
+			_, err = event.Run()
+		} else {
+			err = event.Cancel()
+		}
+
+		if err != nil {
+			log.Printf("error changing state of event %s: %s\n", event.Name, err)
+			failures = append(failures, fmt.Sprintf("%s\t%s", event.Name, err))
+			continue
+		}
+		done++
+	}
+
+	var verb = "cancelled"
+	if run {
+		verb = "ran"
+	}
+
+	report := fmt.Sprintf("%s %d of %d\n", verb, done, done + len(failures))
+	for _, failure := range failures {
+		report = fmt.Sprintf("%sfailed %s\n", report, failure)
+	}
+
+	return report, len(failures) == 0
+}
+
+//
+// Events master control file
+// Writing run-all runs every enabled event, cancel-all cancels every
+// running one; reading reports the outcome of the last write
+//
+type EventsMasterControlFile struct {
+	fs.Inode
+
+	eventsDir *JdwpEventsMasterDir
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*EventsMasterControlFile)(nil))
+var _ = (fs.NodeSetattrer)((*EventsMasterControlFile)(nil))
+var _ = (fs.NodeOpener)((*EventsMasterControlFile)(nil))
+var _ = (fs.NodeOpendirer)((*EventsMasterControlFile)(nil))
+var _ = (fs.NodeReader)((*EventsMasterControlFile)(nil))
+var _ = (fs.NodeWriter)((*EventsMasterControlFile)(nil))
+
+func NewEventsMasterControlFile(eventsDir *JdwpEventsMasterDir, options JdwpFsOptions) EventsMasterControlFile {
+	return EventsMasterControlFile {
+		eventsDir: eventsDir,
+		Options: options,
+	}
+}
+
+func (c *EventsMasterControlFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *EventsMasterControlFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *EventsMasterControlFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (c *EventsMasterControlFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (c *EventsMasterControlFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	c.eventsDir.mu.Lock()
+	report := c.eventsDir.controlReport
+	c.eventsDir.mu.Unlock()
+
+	if offset > int64(len(report)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(report[offset:])), 0
+}
+
+// Write fails with EIO if some of the events failed; the report says
+// which ones, and why
+func (c *EventsMasterControlFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyEventRun)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *EventsMasterControlFile) writeToken(data []byte) syscall.Errno {
+	var run bool
+	switch strings.TrimSpace(string(data)) {
+	case "run-all":
+		run = true
+	case "cancel-all":
+		run = false
+	default:
+		return syscall.EBADMSG
+	}
+
+	c.eventsDir.mu.Lock()
+	defer c.eventsDir.mu.Unlock()
+
+	report, ok := c.eventsDir.runAll(run)
+	c.eventsDir.controlReport = report
+	if !ok {
+		return syscall.EIO
+	}
+
+	return syscall.F_OK
+}
@@ -0,0 +1,293 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+// ImportEventsFile creates the events defined in a file holding a JSON
+// array of event definitions, as found in export.json
+func ImportEventsFile(manager *debug.EventManager, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var definitions []debug.EventDefinition
+	err = json.Unmarshal(data, &definitions)
+	if err != nil {
+		return "", JdwpEventDirError {
+			message: fmt.Sprintf("unable to parse %s: %s", path, err),
+		}
+	}
+
+	report, _ := importEvents(manager, definitions)
+	return report, nil
+}
+
+// importEvents creates an event per definition; events whose name is
+// taken are skipped, with a warning, while events which can't be created
+// are failures. The events aren't run
+func importEvents(manager *debug.EventManager, definitions []debug.EventDefinition) (string, bool) {
+	var created = 0
+	var failed = 0
+	var lines []string
+	for _, definition := range definitions {
+		if _, err := manager.GetEvent(definition.Name); err == nil {
+			log.Printf("event %s already exists, not importing it\n", definition.Name)
+			lines = append(lines, fmt.Sprintf("skipped %s\texists", definition.Name))
+			continue
+		}
+
+		err := importEvent(manager, definition)
+		if err != nil {
+			log.Printf("unable to import event %s: %s\n", definition.Name, err)
+			lines = append(lines, fmt.Sprintf("failed %s\t%s", definition.Name, err))
+			failed++
+			continue
+		}
+		created++
+	}
+
+	report := fmt.Sprintf("imported %d of %d\n", created, len(definitions))
+	for _, line := range lines {
+		report = fmt.Sprintf("%s%s\n", report, line)
+	}
+
+	return report, failed == 0
+}
+
+func importEvent(manager *debug.EventManager, definition debug.EventDefinition) error {
+	if definition.Name == "" || definition.Name == "control" || definition.Name == "import" ||
+		definition.Name == "status" {
+		return JdwpEventDirError {
+			message: fmt.Sprintf("invalid event name %q", definition.Name),
+		}
+	}
+
+	kind, ok := eventKindReprMap[definition.Kind]
+	if !ok {
+		return JdwpEventDirError {
+			message: fmt.Sprintf("unknown kind %s", definition.Kind),
+		}
+	}
+
+	suspendPolicy, ok := suspendPolicyReprMap[definition.SuspendPolicy]
+	if !ok {
+		return JdwpEventDirError {
+			message: fmt.Sprintf("unknown suspend policy %s", definition.SuspendPolicy),
+		}
+	}
+
+	var modifiers []debug.ModifierDescriptor
+	for _, modifier := range definition.Modifiers {
+		matched, err := rematchModifier(manager.JdwpConnection, modifier)
+		if err != nil {
+			return err
+		}
+		modifiers = append(modifiers, matched)
+	}
+
+	event, err := manager.CreateEvent(definition.Name)
+	if err != nil {
+		return err
+	}
+
+	event.SetKind(kind)
+	event.SetSuspendPolicy(suspendPolicy)
+	event.SetEnabled(definition.Enabled)
+	for _, modifier := range modifiers {
+		event.SetModifier(modifier.Name, modifier)
+	}
+	for name, target := range definition.Hooks {
+		event.SetHookDescriptor(name, target)
+	}
+
+	return nil
+}
+
+// rematchModifier finds the ids of the class and of the field or method
+// of a modifier from their signatures, as they may come from another
+// VM; modifiers without signatures keep their ids
+func rematchModifier(conn *debug.Connection, modifier debug.ModifierDescriptor) (debug.ModifierDescriptor, error) {
+	if modifier.Pending || modifier.ClassSignature == "" {
+		return modifier, nil
+	}
+
+	classes, err := conn.GetClassesBySignature(modifier.ClassSignature)
+	if err != nil {
+		return modifier, err
+	}
+	if len(classes) == 0 {
+		return modifier, JdwpEventDirError {
+			message: fmt.Sprintf("class %s isn't loaded", modifier.ClassSignature),
+		}
+	}
+	typeId := classes[0].TypeID
+	modifier.ClassId = uint64(typeId)
+	modifier.Kind = classes[0].Kind
+
+	switch modifier.IsField {
+	case true:
+		fields, err := conn.GetFields(typeId)
+		if err != nil {
+			return modifier, err
+		}
+
+		field := fields.FindBySignature(modifier.ObjectName, modifier.ObjectSignature)
+		if field == nil {
+			return modifier, JdwpEventDirError {
+				message: fmt.Sprintf("no field %s %s in %s",
+					modifier.ObjectName, modifier.ObjectSignature, modifier.ClassSignature),
+			}
+		}
+		modifier.ObjectId = uint64(field.ID)
+	case false:
+		methods, err := conn.GetMethods(typeId)
+		if err != nil {
+			return modifier, err
+		}
+
+		var method *jdwp.Method = nil
+		for _, foundMethod := range methods {
+			if foundMethod.Name == modifier.ObjectName && foundMethod.Signature == modifier.ObjectSignature {
+				method = &foundMethod
+				break
+			}
+		}
+		if method == nil {
+			return modifier, JdwpEventDirError {
+				message: fmt.Sprintf("no method %s%s in %s",
+					modifier.ObjectName, modifier.ObjectSignature, modifier.ClassSignature),
+			}
+		}
+		modifier.ObjectId = uint64(method.ID)
+	}
+
+	return modifier, nil
+}
+
+//
+// Events import file
+// Writing a JSON array of event definitions, as found in export.json,
+// creates the events; reading reports the outcome of the last import
+//
+type EventsImportFile struct {
+	fs.Inode
+
+	eventsDir *JdwpEventsMasterDir
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*EventsImportFile)(nil))
+var _ = (fs.NodeSetattrer)((*EventsImportFile)(nil))
+var _ = (fs.NodeOpener)((*EventsImportFile)(nil))
+var _ = (fs.NodeOpendirer)((*EventsImportFile)(nil))
+var _ = (fs.NodeReader)((*EventsImportFile)(nil))
+var _ = (fs.NodeWriter)((*EventsImportFile)(nil))
+
+func NewEventsImportFile(eventsDir *JdwpEventsMasterDir, options JdwpFsOptions) EventsImportFile {
+	return EventsImportFile {
+		eventsDir: eventsDir,
+		Options: options,
+	}
+}
+
+func (c *EventsImportFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return NewBatchHandle(c.importBatch), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *EventsImportFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *EventsImportFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (c *EventsImportFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (c *EventsImportFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	c.eventsDir.mu.Lock()
+	report := c.eventsDir.importReport
+	c.eventsDir.mu.Unlock()
+
+	if offset > int64(len(report)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(report[offset:])), 0
+}
+
+// Write gathers the batch, which may take several writes; it's imported
+// when the file is closed
+func (c *EventsImportFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyEventCreate)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.importBatch)
+}
+
+// importBatch fails with EIO if some events couldn't be created, while
+// the skipped ones are only reported
+func (c *EventsImportFile) importBatch(data []byte) syscall.Errno {
+	var definitions []debug.EventDefinition
+	err := json.Unmarshal(data, &definitions)
+	if err != nil {
+		log.Printf("unable to parse the imported events: %s\n", err)
+		return syscall.EBADMSG
+	}
+
+	c.eventsDir.mu.Lock()
+	defer c.eventsDir.mu.Unlock()
+
+	report, ok := importEvents(c.eventsDir.manager, definitions)
+	c.eventsDir.importReport = report
+	if !ok {
+		return syscall.EIO
+	}
+
+	return syscall.F_OK
+}
@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// movedClassHandler has the class of the exported modifier loaded with
+// other ids, class 142 with method 207
+func movedClassHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 1 && cmd == 2:
+		return 0, fakeConcat(fakeInt(1), []byte{1}, fakeLong(142), fakeInt(7))
+	case set == 2 && cmd == 5:
+		return 0, fakeConcat(fakeInt(2),
+			fakeLong(206), fakeString("<init>"), fakeString("()V"), fakeInt(1),
+			fakeLong(207), fakeString("run"), fakeString("()V"), fakeInt(1))
+	default:
+		return 0, nil
+	}
+}
+
+func TestEventExportImport(t *testing.T) {
+	var method = debug.ModifierDescriptor {
+		Name: "run",
+		Kind: jdwp.Class,
+		ClassId: 42,
+		ObjectId: 7,
+		ClassSignature: "Lcom/example/Main;",
+		ObjectName: "run",
+		ObjectSignature: "()V",
+		CodeIndex: 3,
+		Line: 12,
+	}
+	var thread = debug.ModifierDescriptor {
+		Name: "main",
+		ThreadId: 31,
+	}
+	var pending = debug.ModifierDescriptor {
+		Name: "later",
+		Pending: true,
+	}
+
+	var tests = []struct {
+		name string
+		kind jdwp.EventKind
+		policy jdwp.SuspendPolicy
+		enabled bool
+		modifiers []debug.ModifierDescriptor
+		hooks map[string]string
+	}{
+		{ name: "bare", kind: jdwp.ThreadStart, policy: jdwp.SuspendNone, enabled: true, hooks: map[string]string{} },
+		{
+			name: "breakpoint",
+			kind: jdwp.Breakpoint,
+			policy: jdwp.SuspendEventThread,
+			enabled: false,
+			modifiers: []debug.ModifierDescriptor { method, thread, pending },
+			hooks: map[string]string { "log": "/plugins/log.so", "count": "/plugins/count.so" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			eventDir, manager := newTestEventDir(t, "exported")
+			event, _ := manager.GetEvent("exported")
+			event.SetKind(test.kind)
+			event.SetSuspendPolicy(test.policy)
+			event.SetEnabled(test.enabled)
+			for _, modifier := range test.modifiers {
+				event.SetModifier(modifier.Name, modifier)
+			}
+			for name, target := range test.hooks {
+				event.SetHookDescriptor(name, target)
+			}
+
+			export, errno := eventDir.readExport()
+			if errno != 0 {
+				t.Fatalf("export: %v", errno)
+			}
+			var definition debug.EventDefinition
+			if err := json.Unmarshal(export, &definition); err != nil {
+				t.Fatalf("unable to parse the export: %s", err)
+			}
+
+			// imported into another VM, where the class moved
+			conn, _ := startFakeVM(t, movedClassHandler)
+			imported, err := debug.NewEventManager(context.Background(), conn)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			report, ok := importEvents(imported, []debug.EventDefinition { definition })
+			if !ok || !strings.HasPrefix(report, "imported 1 of 1\n") {
+				t.Fatalf("import failed: %q", report)
+			}
+
+			importedEvent, err := imported.GetEvent("exported")
+			if err != nil {
+				t.Fatalf("the event wasn't imported: %s", err)
+			}
+
+			expected := event.GetDefinition()
+			for i, modifier := range expected.Modifiers {
+				if modifier.ClassSignature != "" {
+					expected.Modifiers[i].ClassId = 142
+					expected.Modifiers[i].ObjectId = 207
+				}
+			}
+			if got := importedEvent.GetDefinition(); !reflect.DeepEqual(got, expected) {
+				t.Fatalf("imported %+v, expected %+v", got, expected)
+			}
+		})
+	}
+}
+
+func TestEventImportSkipsTaken(t *testing.T) {
+	_, manager := newTestEventDir(t, "taken")
+
+	var definitions = []debug.EventDefinition {
+		{ Name: "taken", Kind: "Breakpoint", SuspendPolicy: "SuspendAll" },
+		{ Name: "fresh", Kind: "ThreadStart", SuspendPolicy: "SuspendNone" },
+		{ Name: "broken", Kind: "Nothing", SuspendPolicy: "SuspendNone" },
+		{ Name: "control", Kind: "ThreadStart", SuspendPolicy: "SuspendNone" },
+	}
+
+	report, ok := importEvents(manager, definitions)
+	if ok {
+		t.Fatalf("imported with failures: %q", report)
+	}
+	if !strings.HasPrefix(report, "imported 1 of 4\nskipped taken\texists\nfailed broken\t") {
+		t.Fatalf("report %q", report)
+	}
+	if _, err := manager.GetEvent("fresh"); err != nil {
+		t.Fatalf("fresh wasn't imported: %s", err)
+	}
+}
+
+func TestEventsImportFile(t *testing.T) {
+	var tests = []struct {
+		name string
+		existing []string
+		imported string
+		report string
+		events []string
+	}{
+		{
+			name: "duplicate in the batch",
+			imported: `[
+				{ "name": "starts", "kind": "ThreadStart", "suspendPolicy": "SuspendNone" },
+				{ "name": "deaths", "kind": "ThreadDeath", "suspendPolicy": "SuspendNone" },
+				{ "name": "starts", "kind": "ThreadStart", "suspendPolicy": "SuspendAll" }
+			]`,
+			report: "imported 2 of 3\nskipped starts\texists\n",
+			events: []string { "deaths", "starts" },
+		},
+		{
+			name: "duplicate of an existing event",
+			existing: []string { "deaths" },
+			imported: `[
+				{ "name": "starts", "kind": "ThreadStart", "suspendPolicy": "SuspendNone" },
+				{ "name": "deaths", "kind": "ThreadDeath", "suspendPolicy": "SuspendNone" },
+				{ "name": "breaks", "kind": "Breakpoint", "suspendPolicy": "SuspendAll" }
+			]`,
+			report: "imported 2 of 3\nskipped deaths\texists\n",
+			events: []string { "breaks", "deaths", "starts" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, nil)
+			manager, err := debug.NewEventManager(context.Background(), conn)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			for _, name := range test.existing {
+				if _, err := manager.CreateEvent(name); err != nil {
+					t.Fatalf("unable to create event %s: %s", name, err)
+				}
+			}
+			eventsDir, err := NewJdwpEventsMasterDir(context.Background(), conn, manager, "/mnt", JdwpFsOptions{})
+			if err != nil {
+				t.Fatalf("unable to create the events dir: %s", err)
+			}
+			importFile := NewEventsImportFile(eventsDir, JdwpFsOptions{})
+
+			written, errno := importFile.Write(context.Background(), nil, []byte(test.imported), 0)
+			if errno != 0 || written != uint32(len(test.imported)) {
+				t.Fatalf("write: %d bytes, %v", written, errno)
+			}
+			if report := readNode(t, &importFile, 0); report != test.report {
+				t.Fatalf("reported %q, expected %q", report, test.report)
+			}
+
+			events, err := manager.GetAllEvents()
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			var names []string
+			for _, event := range events {
+				names = append(names, event.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, test.events) {
+				t.Fatalf("got events %v, expected %v", names, test.events)
+			}
+		})
+	}
+}
+
+func TestEventsImportSplitWrite(t *testing.T) {
+	var tests = []struct {
+		name string
+		writes []string
+		errno syscall.Errno
+		events []string
+	}{
+		{
+			name: "split batch",
+			writes: []string {
+				`[ { "name": "starts", "kind": "Thread`,
+				`Start", "suspendPolicy": "SuspendNone" },` + "\n",
+				`{ "name": "deaths", "kind": "ThreadDeath", "suspendPolicy": "SuspendNone" } ]`,
+			},
+			events: []string { "deaths", "starts" },
+		},
+		{
+			name: "failing batch",
+			writes: []string {
+				`[ { "name": "starts", "kind": "ThreadStart", "suspendPolicy": "SuspendNone" },` + "\n",
+				`{ "name": "broken", "kind": "Nothing", "suspendPolicy": "SuspendNone" } ]`,
+			},
+			errno: syscall.EIO,
+			events: []string { "starts" },
+		},
+		{ name: "unterminated batch", writes: []string { `[ { "name": "starts",` }, errno: syscall.EBADMSG },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, nil)
+			manager, err := debug.NewEventManager(context.Background(), conn)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			eventsDir, err := NewJdwpEventsMasterDir(context.Background(), conn, manager, "/mnt", JdwpFsOptions{})
+			if err != nil {
+				t.Fatalf("unable to create the events dir: %s", err)
+			}
+			importFile := NewEventsImportFile(eventsDir, JdwpFsOptions{})
+
+			fh, _, errno := importFile.Open(context.Background(), syscall.O_WRONLY)
+			if errno != syscall.F_OK {
+				t.Fatalf("open: %v", errno)
+			}
+			handle := fh.(*ControlHandle)
+			var off int64
+			for _, data := range test.writes {
+				written, errno := importFile.Write(context.Background(), fh, []byte(data), off)
+				if errno != syscall.F_OK || written != uint32(len(data)) {
+					t.Fatalf("write %q: %d bytes, %v", data, written, errno)
+				}
+				off += int64(written)
+			}
+
+			// nothing is imported before the file is closed
+			if events, _ := manager.GetAllEvents(); len(events) != 0 {
+				t.Fatalf("imported %d events before close", len(events))
+			}
+			if errno := handle.Flush(context.Background()); errno != test.errno {
+				t.Fatalf("close: got %v, expected %v", errno, test.errno)
+			}
+			handle.Release(context.Background())
+
+			events, err := manager.GetAllEvents()
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			var names []string
+			for _, event := range events {
+				names = append(names, event.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, test.events) {
+				t.Fatalf("got events %v, expected %v", names, test.events)
+			}
+		})
+	}
+}
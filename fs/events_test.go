@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// stubEvent configures an event of the manager
+type stubEvent struct {
+	name string
+	kind jdwp.EventKind
+	disabled bool
+}
+
+func TestEventsMasterControl(t *testing.T) {
+	var tests = []struct {
+		name string
+		events []stubEvent
+		token string
+		errno syscall.Errno
+		report string
+		running []string
+	}{
+		{
+			name: "run-all",
+			events: []stubEvent {
+				{ name: "starts", kind: jdwp.ThreadStart },
+				{ name: "disabled", kind: jdwp.ThreadStart, disabled: true },
+				{ name: "deaths", kind: jdwp.ThreadDeath },
+			},
+			token: "run-all\n",
+			report: "ran 2 of 2\n",
+			running: []string { "starts", "deaths" },
+		},
+		{
+			name: "run-all, one failing",
+			events: []stubEvent {
+				{ name: "starts", kind: jdwp.ThreadStart },
+				{ name: "disabled", kind: jdwp.ThreadStart, disabled: true },
+				{ name: "unwatched", kind: jdwp.FieldModification },
+			},
+			token: "run-all\n",
+			errno: syscall.EIO,
+			report: "ran 1 of 2\nfailed unwatched\t",
+			running: []string { "starts" },
+		},
+		{
+			name: "unknown token",
+			events: []stubEvent {
+				{ name: "starts", kind: jdwp.ThreadStart },
+			},
+			token: "run\n",
+			errno: syscall.EBADMSG,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, 0, fakeInt(1))
+			manager, err := debug.NewEventManager(context.Background(), conn)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			for _, stub := range test.events {
+				event, err := manager.CreateEvent(stub.name)
+				if err != nil {
+					t.Fatalf("unable to create event %s: %s", stub.name, err)
+				}
+				event.SetKind(stub.kind)
+				event.SetEnabled(!stub.disabled)
+			}
+
+			eventsDir, err := NewJdwpEventsMasterDir(context.Background(), conn, manager, "/mnt", JdwpFsOptions{})
+			if err != nil {
+				t.Fatalf("unable to create the events dir: %s", err)
+			}
+			controlFile := NewEventsMasterControlFile(eventsDir, JdwpFsOptions{})
+
+			if errno := controlFile.writeToken([]byte(test.token)); errno != test.errno {
+				t.Fatalf("writing %q: got %v, expected %v", test.token, errno, test.errno)
+			}
+			if report := readNode(t, &controlFile, 0); !strings.HasPrefix(report, test.report) {
+				t.Fatalf("reported %q, expected %q", report, test.report)
+			}
+
+			var running = map[string]bool{}
+			for _, name := range test.running {
+				running[name] = true
+			}
+			for _, stub := range test.events {
+				event, _ := manager.GetEvent(stub.name)
+				if event.IsRunning() != running[stub.name] {
+					t.Fatalf("event %s running: %v", stub.name, event.IsRunning())
+				}
+			}
+			if len(test.running) == 0 {
+				return
+			}
+
+			if errno := controlFile.writeToken([]byte("cancel-all\n")); errno != syscall.F_OK {
+				t.Fatalf("cancel-all: %v", errno)
+			}
+			cancelled := fmt.Sprintf("cancelled %d of %d\n", len(test.running), len(test.running))
+			if report := readNode(t, &controlFile, 0); report != cancelled {
+				t.Fatalf("reported %q after cancelling, expected %q", report, cancelled)
+			}
+			for _, stub := range test.events {
+				event, _ := manager.GetEvent(stub.name)
+				if event.IsRunning() {
+					t.Fatalf("event %s still runs", stub.name)
+				}
+			}
+		})
+	}
+}
+
+func TestEventsDirStatus(t *testing.T) {
+	var tests = []struct {
+		name string
+		events []stubEvent
+		running []string
+		status string
+	}{
+		{ name: "no events", status: "" },
+		{
+			name: "idle and running",
+			events: []stubEvent {
+				{ name: "starts", kind: jdwp.ThreadStart },
+				{ name: "deaths", kind: jdwp.ThreadDeath },
+				{ name: "breaks", kind: jdwp.ThreadStart },
+			},
+			running: []string { "starts", "breaks" },
+			status: "starts\trunning\ndeaths\tidle\nbreaks\trunning\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, 0, fakeInt(1))
+			manager, err := debug.NewEventManager(context.Background(), conn)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			for _, stub := range test.events {
+				event, err := manager.CreateEvent(stub.name)
+				if err != nil {
+					t.Fatalf("unable to create event %s: %s", stub.name, err)
+				}
+				event.SetKind(stub.kind)
+			}
+			for _, name := range test.running {
+				event, _ := manager.GetEvent(name)
+				if _, err := event.Run(); err != nil {
+					t.Fatalf("unable to run event %s: %s", name, err)
+				}
+				defer event.Cancel()
+			}
+
+			eventsDir, err := NewJdwpEventsMasterDir(context.Background(), conn, manager, "/mnt", JdwpFsOptions{})
+			if err != nil {
+				t.Fatalf("unable to create the events dir: %s", err)
+			}
+
+			var files = map[string]bool { "control": true, "import": true, "status": true }
+			stream, errno := eventsDir.Readdir(context.Background())
+			if errno != syscall.F_OK {
+				t.Fatalf("readdir: %v", errno)
+			}
+			var listed = 0
+			for stream.HasNext() {
+				entry, _ := stream.Next()
+				listed++
+				if files[entry.Name] {
+					if entry.Mode != fuse.S_IFREG {
+						t.Fatalf("%s is listed with mode %#o", entry.Name, entry.Mode)
+					}
+					continue
+				}
+				if entry.Mode != fuse.S_IFDIR {
+					t.Fatalf("event %s is listed with mode %#o", entry.Name, entry.Mode)
+				}
+			}
+			if listed != len(files) + len(test.events) {
+				t.Fatalf("listed %d entries", listed)
+			}
+
+			status, errno := eventsDir.readStatus()
+			if errno != syscall.F_OK {
+				t.Fatalf("reading the status: %v", errno)
+			}
+			if string(status) != test.status {
+				t.Fatalf("read %q, expected %q", status, test.status)
+			}
+		})
+	}
+}
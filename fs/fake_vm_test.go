@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Fake VM
+// Answers the commands of a connection over a pipe, as a VM would; the
+// version, id sizes and capabilities are answered here, everything else
+// by the test's handler, unless the test set a fixed answer
+//
+type fakeHandler func(set uint8, cmd uint8, data []byte) (uint16, []byte)
+
+type fakeAnswer struct {
+	errorCode uint16
+	data []byte
+}
+
+type fakeVM struct {
+	mu sync.Mutex
+	handler fakeHandler
+	received map[[2]uint8]int
+	answers map[[2]uint8]fakeAnswer
+}
+
+// Answer makes the VM answer a command the same way from then on
+func (vm *fakeVM) Answer(set uint8, cmd uint8, errorCode uint16, data []byte) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	vm.answers[[2]uint8{set, cmd}] = fakeAnswer {
+		errorCode: errorCode,
+		data: data,
+	}
+}
+
+// Received counts the commands of a command set the VM was sent
+func (vm *fakeVM) Received(set uint8, cmd uint8) int {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	return vm.received[[2]uint8{set, cmd}]
+}
+
+func (vm *fakeVM) serve(server net.Conn) {
+	handshake := make([]byte, 14)
+	if _, err := io.ReadFull(server, handshake); err != nil {
+		return
+	}
+	server.Write(handshake)
+
+	for {
+		header := make([]byte, 11)
+		if _, err := io.ReadFull(server, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header) - 11)
+		if _, err := io.ReadFull(server, body); err != nil {
+			return
+		}
+		set, cmd := header[9], header[10]
+
+		vm.mu.Lock()
+		vm.received[[2]uint8{set, cmd}]++
+		answer, answered := vm.answers[[2]uint8{set, cmd}]
+		vm.mu.Unlock()
+
+		var errorCode uint16
+		var data []byte
+		switch {
+		case answered:
+			errorCode, data = answer.errorCode, answer.data
+		case set == 1 && cmd == 1:
+			data = fakeConcat(fakeString("fake"), fakeInt(11), fakeInt(0), fakeString("11"), fakeString("fake"))
+		case set == 1 && cmd == 7:
+			data = fakeConcat(fakeInt(8), fakeInt(8), fakeInt(8), fakeInt(8), fakeInt(8))
+		case set == 1 && cmd == 12:
+			data = make([]byte, 7)
+		case set == 1 && cmd == 17:
+			data = make([]byte, 32)
+		case vm.handler != nil:
+			errorCode, data = vm.handler(set, cmd, body)
+		}
+
+		reply := make([]byte, 11)
+		binary.BigEndian.PutUint32(reply, uint32(11 + len(data)))
+		copy(reply[4:8], header[4:8])
+		reply[8] = 0x80
+		binary.BigEndian.PutUint16(reply[9:], errorCode)
+		server.Write(append(reply, data...))
+	}
+}
+
+// startFakeVM opens a connection to a fake VM, which is closed with the test
+func startFakeVM(t *testing.T, handler fakeHandler) (*debug.Connection, *fakeVM) {
+	t.Helper()
+
+	client, server := net.Pipe()
+	vm := &fakeVM {
+		handler: handler,
+		received: map[[2]uint8]int{},
+		answers: map[[2]uint8]fakeAnswer{},
+	}
+	go vm.serve(server)
+
+	conn, err := debug.OpenConnection(context.Background(), client)
+	if err != nil {
+		t.Fatalf("unable to open a connection to the fake VM: %s", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	return conn, vm
+}
+
+func fakeInt(value uint32) []byte {
+	var data = make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+	return data
+}
+
+func fakeLong(value uint64) []byte {
+	var data = make([]byte, 8)
+	binary.BigEndian.PutUint64(data, value)
+	return data
+}
+
+func fakeString(value string) []byte {
+	return append(fakeInt(uint32(len(value))), []byte(value)...)
+}
+
+func fakeConcat(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Field value file
+// The value of a static field, as it is at the time of the read;
+// writing a value of the type of the field sets it
+//
+type FieldValueFile struct {
+	fs.Inode
+
+	fieldDir *ClassFieldDir
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*FieldValueFile)(nil))
+var _ = (fs.NodeSetattrer)((*FieldValueFile)(nil))
+var _ = (fs.NodeOpener)((*FieldValueFile)(nil))
+var _ = (fs.NodeOpendirer)((*FieldValueFile)(nil))
+var _ = (fs.NodeReader)((*FieldValueFile)(nil))
+var _ = (fs.NodeWriter)((*FieldValueFile)(nil))
+
+func NewFieldValueFile(fieldDir *ClassFieldDir, options JdwpFsOptions) FieldValueFile {
+	return FieldValueFile {
+		fieldDir: fieldDir,
+		Options: options,
+	}
+}
+
+func (f *FieldValueFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	return NewControlHandle(f.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (f *FieldValueFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (f *FieldValueFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = f.Options.writableFileMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (f *FieldValueFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (f *FieldValueFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	contents, errno := f.fieldDir.readValue()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	if offset > int64(len(contents)) {
+		return nil, syscall.EBADR
+	}
+
+	contents = contents[offset:]
+	if len(contents) > len(dest) {
+		contents = contents[:len(dest)]
+	}
+
+	return fuse.ReadResultData(contents), 0
+}
+
+// Write takes a value ended by a newline, or by closing the file, e.g.
+// `echo 42 > value`
+func (f *FieldValueFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	return writeControl(fh, data, off, f.writeToken)
+}
+
+func (f *FieldValueFile) writeToken(token []byte) syscall.Errno {
+	return f.fieldDir.writeValue(strings.TrimSpace(string(token)))
+}
+
+// writeValue sets a static field to a value rendered as it's read;
+// EINVAL if it isn't of the type of the field, and EPERM for instance
+// fields, which have a value per object
+func (d *ClassFieldDir) writeValue(text string) syscall.Errno {
+	errno := d.Options.checkPolicy(PolicyFieldSet)
+	if errno != syscall.F_OK {
+		return errno
+	}
+
+	field, errno := d.field()
+	if errno != 0 {
+		return errno
+	}
+	if field.ModBits & jdwp.ModStatic == 0 {
+		return syscall.EPERM
+	}
+
+	value, err := debug.ParseValue(field.Signature, text)
+	if err != nil {
+		log.Printf("error writing field %d of class %d: %s\n", d.FieldId, d.TypeId, err)
+		return syscall.EINVAL
+	}
+
+	err = d.JdwpConnection.SetStaticFieldValue(d.TypeId, d.FieldId, value)
+	if errors.Is(err, jdwp.ErrTypeMismatch) || errors.Is(err, jdwp.ErrInvalidObject) {
+		log.Printf("error writing field %d of class %d: %s\n", d.FieldId, d.TypeId, err)
+		return syscall.EINVAL
+	}
+	if err != nil {
+		log.Printf("error writing field %d of class %d: %s\n", d.FieldId, d.TypeId, err)
+		return syscall.EFAULT
+	}
+
+	return 0
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// staticFieldHandler has class 42 with the static int count, field 1,
+// and the int size of its instances, field 2; count keeps the value
+// last set
+func staticFieldHandler(initial int) fakeHandler {
+	var mu sync.Mutex
+	var count = fakeInt(uint32(initial))
+
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case set == 2 && cmd == 14:
+			return 0, fakeConcat(fakeInt(2),
+				fakeLong(1), fakeString("count"), fakeString("I"), fakeString(""), fakeInt(uint32(jdwp.ModStatic)),
+				fakeLong(2), fakeString("size"), fakeString("I"), fakeString(""), fakeInt(0))
+		case set == 2 && cmd == 6:
+			return 0, fakeConcat(fakeInt(1), []byte{'I'}, count)
+		case set == 3 && cmd == 2:
+			// class, count and field, then the untagged value
+			count = append([]byte{}, data[20:24]...)
+			return 0, nil
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestFieldValueWrite(t *testing.T) {
+	var tests = []struct {
+		name string
+		policy string
+		field jdwp.FieldID
+		written string
+		errno syscall.Errno
+		value string
+	}{
+		{ name: "round trip", field: 1, written: "42\n", value: "42\n" },
+		{ name: "negative", field: 1, written: "-42", value: "-42\n" },
+		{ name: "malformed", field: 1, written: "4 2\n", errno: syscall.EINVAL, value: "7\n" },
+		{ name: "not an int", field: 1, written: "true\n", errno: syscall.EINVAL, value: "7\n" },
+		{ name: "instance field", field: 2, written: "42\n", errno: syscall.EPERM },
+		{ name: "denied", policy: "deny field.set\n", field: 1, written: "42\n", errno: syscall.EPERM, value: "7\n" },
+		{ name: "allowed", policy: "default deny\nallow field.set\n", field: 1, written: "42\n", value: "42\n" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := usePolicy(t, test.policy)
+			conn, vm := startFakeVM(t, staticFieldHandler(7))
+			fieldDir, err := NewClassFieldDir(context.Background(), conn, 42, test.field, valueRenderer{}, options)
+			if err != nil {
+				t.Fatalf("unable to make the field dir: %s", err)
+			}
+			valueFile := NewFieldValueFile(fieldDir, options)
+
+			written, errno := valueFile.Write(context.Background(), nil, []byte(test.written), 0)
+			if errno != test.errno {
+				t.Fatalf("write: got %v, expected %v", errno, test.errno)
+			}
+			if errno != syscall.F_OK {
+				if vm.Received(3, 2) != 0 {
+					t.Fatalf("the value was set")
+				}
+			} else if written != uint32(len(test.written)) {
+				t.Fatalf("wrote %d bytes of %d", written, len(test.written))
+			}
+
+			if test.value == "" {
+				return
+			}
+			if value := readNode(t, &valueFile, 0); value != test.value {
+				t.Fatalf("read %q, expected %q", value, test.value)
+			}
+		})
+	}
+}
+
+func TestFieldValueSplitWrite(t *testing.T) {
+	var tests = []struct {
+		name string
+		writes []string
+		flush bool
+		value string
+	}{
+		{ name: "one write", writes: []string{ "42\n" }, value: "42\n" },
+		{ name: "split", writes: []string{ "4", "2\n" }, value: "42\n" },
+		{ name: "split sign", writes: []string{ "-", "4", "2\n" }, value: "-42\n" },
+		{ name: "ended by close", writes: []string{ "4", "2" }, flush: true, value: "42\n" },
+		{ name: "unterminated", writes: []string{ "4", "2" }, value: "7\n" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, staticFieldHandler(7))
+			fieldDir, err := NewClassFieldDir(context.Background(), conn, 42, 1, valueRenderer{}, JdwpFsOptions{})
+			if err != nil {
+				t.Fatalf("unable to make the field dir: %s", err)
+			}
+			valueFile := NewFieldValueFile(fieldDir, JdwpFsOptions{})
+
+			fh, _, errno := valueFile.Open(context.Background(), syscall.O_WRONLY)
+			if errno != syscall.F_OK {
+				t.Fatalf("open: %v", errno)
+			}
+			handle := fh.(*ControlHandle)
+
+			var off int64
+			for _, data := range test.writes {
+				written, errno := valueFile.Write(context.Background(), fh, []byte(data), off)
+				if errno != syscall.F_OK {
+					t.Fatalf("write %q: %v", data, errno)
+				}
+				off += int64(written)
+			}
+			if test.flush {
+				if errno := handle.Flush(context.Background()); errno != syscall.F_OK {
+					t.Fatalf("flush: %v", errno)
+				}
+			}
+
+			if value := readNode(t, &valueFile, 0); value != test.value {
+				t.Fatalf("read %q, expected %q", value, test.value)
+			}
+			handle.Release(context.Background())
+		})
+	}
+}
+
+// TestFieldValueToString reads a static reference through the renderer
+// of the mount, as the locals of frames are
+func TestFieldValueToString(t *testing.T) {
+	var tests = []struct {
+		name string
+		options JdwpFsOptions
+		value string
+	}{
+		{ name: "toString", options: JdwpFsOptions { ValueToString: true, AllowInvoke: true }, value: "Point(1, 2)\n" },
+		{ name: "ids", options: JdwpFsOptions { AllowInvoke: true }, value: "42\n" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			toString := toStringHandler(true, false)
+			conn, _ := startFakeVM(t, func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+				switch {
+				case set == 2 && cmd == 14:
+					return 0, fakeConcat(fakeInt(1),
+						fakeLong(1), fakeString("origin"), fakeString("Lcom/example/Point;"), fakeString(""), fakeInt(uint32(jdwp.ModStatic)))
+				case set == 2 && cmd == 6:
+					return 0, fakeConcat(fakeInt(1), []byte{'L'}, fakeLong(42))
+				default:
+					return toString(set, cmd, data)
+				}
+			})
+			fieldDir, err := NewClassFieldDir(context.Background(), conn, 42, 1, newValueRenderer(conn, test.options), JdwpFsOptions{})
+			if err != nil {
+				t.Fatalf("unable to make the field dir: %s", err)
+			}
+			valueFile := NewFieldValueFile(fieldDir, JdwpFsOptions{})
+
+			if value := readNode(t, &valueFile, 0); value != test.value {
+				t.Fatalf("read %q, expected %q", value, test.value)
+			}
+		})
+	}
+}
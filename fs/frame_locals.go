@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Frame locals directory
+// A file per variable live at the location of a frame, arguments
+// included, holding its value; a variable without a name, or with the
+// name of another, is named by its slot. Methods compiled without a
+// variable table have none
+//
+type FrameLocalsDir struct {
+	fs.Inode
+
+	ThreadId jdwp.ThreadID
+	Index int
+	JdwpConnection *debug.Connection
+
+	values valueRenderer
+	watchdog *Watchdog
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*FrameLocalsDir)(nil))
+var _ = (fs.NodeReaddirer)((*FrameLocalsDir)(nil))
+var _ = (fs.NodeLookuper)((*FrameLocalsDir)(nil))
+var _ = (fs.NodeMkdirer)((*FrameLocalsDir)(nil))
+
+func NewFrameLocalsDir(conn *debug.Connection, thread jdwp.ThreadID, index int, values valueRenderer, watchdog *Watchdog, options JdwpFsOptions) FrameLocalsDir {
+	return FrameLocalsDir {
+		ThreadId: thread,
+		Index: index,
+		JdwpConnection: conn,
+		values: values,
+		watchdog: watchdog,
+		Options: options,
+	}
+}
+
+func (d *FrameLocalsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *FrameLocalsDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+// locals returns the frame and its live variables, by their names
+func (d *FrameLocalsDir) locals() (jdwp.FrameInfo, []string, map[string]debug.LocalVariable, syscall.Errno) {
+	frame, errno := threadFrame(d.JdwpConnection, d.ThreadId, d.Index)
+	if errno != 0 {
+		return jdwp.FrameInfo{}, nil, nil, errno
+	}
+
+	variables, err := d.JdwpConnection.GetLocalVariables(frame.Location)
+	if err != nil {
+		log.Printf("no variable table for method %d: %s\n", frame.Location.Method, err)
+		return frame, nil, nil, 0
+	}
+
+	var names []string
+	byName := map[string]debug.LocalVariable{}
+	for _, variable := range variables {
+		name := variable.Name
+		if _, taken := byName[name]; name == "" || taken {
+			name = strconv.Itoa(variable.Slot)
+		}
+		if _, taken := byName[name]; taken {
+			continue
+		}
+
+		names = append(names, name)
+		byName[name] = variable
+	}
+
+	return frame, names, byName, 0
+}
+
+func (d *FrameLocalsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	_, names, _, _ := d.locals()
+
+	var entries = []fuse.DirEntry{}
+	for _, name := range names {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: name,
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *FrameLocalsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	_, _, byName, errno := d.locals()
+	if errno != 0 {
+		return nil, errno
+	}
+	if _, ok := byName[name]; !ok {
+		return nil, syscall.ENOENT
+	}
+
+	localFile := NewFrameLocalFile(d, name, d.Options)
+	localFileInode := d.NewInode(
+		ctx,
+		&localFile,
+		fs.StableAttr {
+			Mode: fuse.S_IFREG,
+		})
+
+	return localFileInode, 0
+}
+
+// readLocal reads the variable from the frame at the depth of the
+// directory, as it is at the time of the read
+func (d *FrameLocalsDir) readLocal(name string) ([]byte, syscall.Errno) {
+	frame, _, byName, errno := d.locals()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	variable, ok := byName[name]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+
+	value, err := d.JdwpConnection.GetLocalValue(d.ThreadId, frame.Frame, variable)
+	if err != nil {
+		log.Printf("error reading %s in frame %d of thread %d: %s\n", name, d.Index, d.ThreadId, err)
+		return nil, syscall.EFAULT
+	}
+
+	return []byte(newlineTerminated(d.values.render(value))), 0
+}
+
+// writeLocal sets the variable in the frame at the depth of the
+// directory to a value rendered as it's read; EINVAL if it isn't of the
+// type of the variable
+func (d *FrameLocalsDir) writeLocal(name string, text string) syscall.Errno {
+	errno := d.Options.checkPolicy(PolicyFrameSet)
+	if errno != syscall.F_OK {
+		return errno
+	}
+
+	_, suspendStatus, err := d.JdwpConnection.GetThreadStatus(d.ThreadId)
+	if err != nil {
+		log.Printf("error getting status of thread %d: %s\n", d.ThreadId, err)
+		return syscall.EBADF
+	}
+	if suspendStatus == 0 {
+		return syscall.EPERM
+	}
+
+	frame, _, byName, errno := d.locals()
+	if errno != 0 {
+		return errno
+	}
+
+	variable, ok := byName[name]
+	if !ok {
+		return syscall.ENOENT
+	}
+
+	value, err := debug.ParseValue(variable.Signature, text)
+	if err != nil {
+		log.Printf("error writing %s in frame %d of thread %d: %s\n", name, d.Index, d.ThreadId, err)
+		return syscall.EINVAL
+	}
+
+	err = d.JdwpConnection.SetLocalValue(d.ThreadId, frame.Frame, variable, value)
+	if errors.Is(err, jdwp.ErrTypeMismatch) || errors.Is(err, jdwp.ErrInvalidObject) {
+		log.Printf("error writing %s in frame %d of thread %d: %s\n", name, d.Index, d.ThreadId, err)
+		return syscall.EINVAL
+	}
+	if err != nil {
+		log.Printf("error writing %s in frame %d of thread %d: %s\n", name, d.Index, d.ThreadId, err)
+		return syscall.EFAULT
+	}
+
+	return 0
+}
+
+//
+// Frame local file
+// The value of a variable of a frame, as it is at the time of the read;
+// writing a value of the type of the variable sets it, while the thread
+// is suspended
+//
+type FrameLocalFile struct {
+	fs.Inode
+
+	Name string
+
+	localsDir *FrameLocalsDir
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*FrameLocalFile)(nil))
+var _ = (fs.NodeSetattrer)((*FrameLocalFile)(nil))
+var _ = (fs.NodeOpener)((*FrameLocalFile)(nil))
+var _ = (fs.NodeOpendirer)((*FrameLocalFile)(nil))
+var _ = (fs.NodeReader)((*FrameLocalFile)(nil))
+var _ = (fs.NodeWriter)((*FrameLocalFile)(nil))
+var _ = (fs.NodeReleaser)((*FrameLocalFile)(nil))
+
+func NewFrameLocalFile(localsDir *FrameLocalsDir, name string, options JdwpFsOptions) FrameLocalFile {
+	return FrameLocalFile {
+		Name: name,
+		localsDir: localsDir,
+		Options: options,
+	}
+}
+
+func (f *FrameLocalFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	f.localsDir.watchdog.Acquire()
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (f *FrameLocalFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (f *FrameLocalFile) Release(ctx context.Context, _ fs.FileHandle) syscall.Errno {
+	f.localsDir.watchdog.Release()
+	return 0
+}
+
+func (f *FrameLocalFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = f.Options.writableFileMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (f *FrameLocalFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (f *FrameLocalFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	contents, errno := f.localsDir.readLocal(f.Name)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	if offset > int64(len(contents)) {
+		return nil, syscall.EBADR
+	}
+
+	contents = contents[offset:]
+	if len(contents) > len(dest) {
+		contents = contents[:len(dest)]
+	}
+
+	return fuse.ReadResultData(contents), 0
+}
+
+// Write takes the whole value in one write, e.g. `echo 5 > counter`
+func (f *FrameLocalFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	if off != 0 {
+		return 0, syscall.EINVAL
+	}
+
+	errno = f.localsDir.writeLocal(f.Name, strings.TrimSpace(string(data)))
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return uint32(len(data)), 0
+}
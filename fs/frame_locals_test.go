@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// frameLocalsHandler has thread 7 stopped in frame 99 of method 7 of
+// class 42, where the int count lives in slot 1; the variable keeps the
+// value last set
+func frameLocalsHandler(initial int) fakeHandler {
+	var mu sync.Mutex
+	var count = fakeConcat([]byte{'I'}, fakeInt(uint32(initial)))
+
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case set == 11 && cmd == 6:
+			return 0, fakeConcat(fakeInt(1), fakeLong(99), []byte{1}, fakeLong(42), fakeLong(7), fakeLong(5))
+		case set == 6 && cmd == 2:
+			return 0, fakeConcat(fakeInt(0), fakeInt(1),
+				fakeLong(0), fakeString("count"), fakeString("I"), fakeInt(20), fakeInt(1))
+		case set == 16 && cmd == 1:
+			return 0, fakeConcat(fakeInt(1), count)
+		case set == 16 && cmd == 2:
+			// thread, frame, count and slot, then the tagged value
+			count = append([]byte{}, data[24:29]...)
+			return 0, nil
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestFrameLocalWrite(t *testing.T) {
+	var tests = []struct {
+		name string
+		policy string
+		suspended bool
+		written string
+		errno syscall.Errno
+		value string
+	}{
+		{ name: "round trip", suspended: true, written: "42\n", value: "42\n" },
+		{ name: "negative", suspended: true, written: "-3", value: "-3\n" },
+		{ name: "not an int", suspended: true, written: "forty\n", errno: syscall.EINVAL, value: "7\n" },
+		{ name: "out of range", suspended: true, written: "4294967296\n", errno: syscall.EINVAL, value: "7\n" },
+		{ name: "running", suspended: false, written: "42\n", errno: syscall.EPERM },
+		{ name: "denied", policy: "deny frame.set\n", suspended: true, written: "42\n", errno: syscall.EPERM, value: "7\n" },
+		{ name: "allowed", policy: "default deny\nallow frame.set\n", suspended: true, written: "42\n", value: "42\n" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := usePolicy(t, test.policy)
+			conn, vm := startFakeVM(t, frameLocalsHandler(7))
+			var suspendStatus uint32 = 0
+			if test.suspended {
+				suspendStatus = 1
+			}
+			vm.Answer(11, 4, 0, fakeConcat(fakeInt(1), fakeInt(suspendStatus)))
+
+			localsDir := NewFrameLocalsDir(conn, 7, 0, valueRenderer{}, nil, options)
+			localFile := NewFrameLocalFile(&localsDir, "count", options)
+
+			written, errno := localFile.Write(context.Background(), nil, []byte(test.written), 0)
+			if errno != test.errno {
+				t.Fatalf("write: got %v, expected %v", errno, test.errno)
+			}
+			if errno != syscall.F_OK {
+				if vm.Received(16, 2) != 0 {
+					t.Fatalf("the value was set")
+				}
+			} else if written != uint32(len(test.written)) {
+				t.Fatalf("wrote %d bytes of %d", written, len(test.written))
+			}
+
+			if test.value == "" {
+				return
+			}
+			if value := readNode(t, &localFile, 0); value != test.value {
+				t.Fatalf("read %q, expected %q", value, test.value)
+			}
+		})
+	}
+}
+
+// variablesHandler has thread 7 stopped at code index 5 of method 7 of
+// class 42, with variables count, in slot 1, an unnamed one in slot 2,
+// another count in slot 3, and a variable in slot 4 which isn't live
+// yet; a slot holds the int ten times its number. The method may have
+// been compiled without a variable table
+func variablesHandler(table bool) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 11 && cmd == 6:
+			return 0, fakeConcat(fakeInt(1), fakeLong(99), []byte{1}, fakeLong(42), fakeLong(7), fakeLong(5))
+		case set == 6 && cmd == 2 && !table:
+			// ABSENT_INFORMATION
+			return 101, nil
+		case set == 6 && cmd == 2:
+			return 0, fakeConcat(fakeInt(0), fakeInt(4),
+				fakeLong(0), fakeString("count"), fakeString("I"), fakeInt(20), fakeInt(1),
+				fakeLong(0), fakeString(""), fakeString("I"), fakeInt(20), fakeInt(2),
+				fakeLong(0), fakeString("count"), fakeString("I"), fakeInt(20), fakeInt(3),
+				fakeLong(10), fakeString("later"), fakeString("I"), fakeInt(5), fakeInt(4))
+		case set == 16 && cmd == 1:
+			// thread, frame and count, then the slot and tag asked for
+			slot := binary.BigEndian.Uint32(data[20:24])
+			return 0, fakeConcat(fakeInt(1), []byte{'I'}, fakeInt(slot * 10))
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestFrameLocals(t *testing.T) {
+	var tests = []struct {
+		name string
+		table bool
+		suspended bool
+		locals []string
+		values map[string]string
+	}{
+		{
+			name: "variable table",
+			table: true,
+			suspended: true,
+			locals: []string { "count", "2", "3" },
+			values: map[string]string { "count": "10\n", "2": "20\n", "3": "30\n" },
+		},
+		{ name: "no variable table", table: false, suspended: true, locals: []string {} },
+		{ name: "running", table: true, suspended: false, locals: []string {} },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, variablesHandler(test.table))
+			threadSuspended(vm, test.suspended)
+			localsDir := NewFrameLocalsDir(conn, 7, 0, valueRenderer{}, nil, JdwpFsOptions{})
+
+			stream, errno := localsDir.Readdir(context.Background())
+			if errno != syscall.F_OK {
+				t.Fatalf("readdir: %v", errno)
+			}
+			var locals = []string {}
+			for stream.HasNext() {
+				entry, _ := stream.Next()
+				locals = append(locals, entry.Name)
+			}
+			if !reflect.DeepEqual(locals, test.locals) {
+				t.Fatalf("listed %v, expected %v", locals, test.locals)
+			}
+
+			for name, expected := range test.values {
+				value, errno := localsDir.readLocal(name)
+				if errno != syscall.F_OK {
+					t.Fatalf("%s: read: %v", name, errno)
+				}
+				if string(value) != expected {
+					t.Fatalf("%s: read %q, expected %q", name, value, expected)
+				}
+			}
+			if _, errno := localsDir.readLocal("later"); errno == syscall.F_OK {
+				t.Fatalf("read a variable which isn't live")
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Thread frames directory
+// A directory per frame of a suspended thread, numbered from 0, the top
+// of the stack; JDWP only gives out the frames of suspended threads, so
+// it's empty while the thread runs
+//
+type ThreadFramesDir struct {
+	fs.Inode
+
+	ThreadId jdwp.ThreadID
+	JdwpConnection *debug.Connection
+
+	values valueRenderer
+	watchdog *Watchdog
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ThreadFramesDir)(nil))
+var _ = (fs.NodeReaddirer)((*ThreadFramesDir)(nil))
+var _ = (fs.NodeLookuper)((*ThreadFramesDir)(nil))
+var _ = (fs.NodeMkdirer)((*ThreadFramesDir)(nil))
+
+func NewThreadFramesDir(conn *debug.Connection, id jdwp.ThreadID, values valueRenderer, watchdog *Watchdog, options JdwpFsOptions) ThreadFramesDir {
+	return ThreadFramesDir {
+		ThreadId: id,
+		JdwpConnection: conn,
+		values: values,
+		watchdog: watchdog,
+		Options: options,
+	}
+}
+
+func (d *ThreadFramesDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *ThreadFramesDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+// frames returns the frames of the thread, none if it runs
+func (d *ThreadFramesDir) frames() []jdwp.FrameInfo {
+	_, suspendStatus, err := d.JdwpConnection.GetThreadStatus(d.ThreadId)
+	if err != nil {
+		log.Printf("error getting status of thread %d: %s\n", d.ThreadId, err)
+		return nil
+	}
+	if suspendStatus == 0 {
+		return nil
+	}
+
+	frames, err := d.JdwpConnection.GetFrames(d.ThreadId, 0, -1)
+	if err != nil {
+		log.Printf("error getting frames of thread %d: %s\n", d.ThreadId, err)
+		return nil
+	}
+
+	return frames
+}
+
+func (d *ThreadFramesDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries = []fuse.DirEntry{}
+	for index := range d.frames() {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: strconv.Itoa(index),
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *ThreadFramesDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	index, err := strconv.Atoi(name)
+	if err != nil || index < 0 || strconv.Itoa(index) != name {
+		return nil, syscall.ENOENT
+	}
+
+	if index >= len(d.frames()) {
+		return nil, syscall.ENOENT
+	}
+
+	frameDir := NewThreadFrameDir(d.JdwpConnection, d.ThreadId, index, d.values, d.watchdog, d.Options)
+	frameDirInode := d.NewInode(
+		ctx,
+		&frameDir,
+		fs.StableAttr {
+			Mode: fuse.S_IFDIR,
+		})
+
+	return frameDirInode, 0
+}
+
+//
+// Thread frame directory
+// A frame of a suspended thread, by its depth; frame ids don't outlive
+// a resumption, so the frame at that depth is asked for on every read
+//
+type ThreadFrameDir struct {
+	fs.Inode
+
+	ThreadId jdwp.ThreadID
+	Index int
+	JdwpConnection *debug.Connection
+
+	values valueRenderer
+	watchdog *Watchdog
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ThreadFrameDir)(nil))
+var _ = (fs.NodeReaddirer)((*ThreadFrameDir)(nil))
+var _ = (fs.NodeLookuper)((*ThreadFrameDir)(nil))
+var _ = (fs.NodeMkdirer)((*ThreadFrameDir)(nil))
+
+func NewThreadFrameDir(conn *debug.Connection, thread jdwp.ThreadID, index int, values valueRenderer, watchdog *Watchdog, options JdwpFsOptions) ThreadFrameDir {
+	return ThreadFrameDir {
+		ThreadId: thread,
+		Index: index,
+		JdwpConnection: conn,
+		values: values,
+		watchdog: watchdog,
+		Options: options,
+	}
+}
+
+func (d *ThreadFrameDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *ThreadFrameDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *ThreadFrameDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries []fuse.DirEntry
+	for _, name := range []string{ "location", "method", "class" } {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: name,
+		})
+	}
+
+	// static methods run on no object
+	if static, errno := d.static(); errno != 0 || !static {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "this",
+		})
+	}
+
+	entries = append(entries, fuse.DirEntry {
+		Mode: fuse.S_IFDIR,
+		Name: "locals",
+	})
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *ThreadFrameDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	var contents func() ([]byte, syscall.Errno)
+	switch name {
+	case "locals":
+		localsDir := NewFrameLocalsDir(d.JdwpConnection, d.ThreadId, d.Index, d.values, d.watchdog, d.Options)
+		localsDirInode := d.NewInode(
+			ctx,
+			&localsDir,
+			fs.StableAttr {
+				Mode: fuse.S_IFDIR,
+			})
+		return localsDirInode, 0
+	case "location":
+		contents = d.readLocation
+	case "method":
+		contents = d.readMethod
+	case "class":
+		contents = d.readClass
+	case "this":
+		contents = d.readThis
+	default:
+		return nil, syscall.ENOENT
+	}
+
+	frameFile := NewInspectionFile(contents, d.watchdog, d.Options)
+	frameFileInode := d.NewInode(
+		ctx,
+		&frameFile,
+		fs.StableAttr {
+			Mode: fuse.S_IFREG,
+		})
+
+	return frameFileInode, 0
+}
+
+func (d *ThreadFrameDir) frame() (jdwp.FrameInfo, syscall.Errno) {
+	return threadFrame(d.JdwpConnection, d.ThreadId, d.Index)
+}
+
+// threadFrame returns the frame of a thread at a depth; EBUSY if the
+// thread runs, and ENOENT if the stack got shallower
+func threadFrame(conn *debug.Connection, thread jdwp.ThreadID, index int) (jdwp.FrameInfo, syscall.Errno) {
+	_, suspendStatus, err := conn.GetThreadStatus(thread)
+	if err != nil {
+		log.Printf("error getting status of thread %d: %s\n", thread, err)
+		return jdwp.FrameInfo{}, syscall.EBADF
+	}
+	if suspendStatus == 0 {
+		return jdwp.FrameInfo{}, syscall.EBUSY
+	}
+
+	frames, err := conn.GetFrames(thread, index, 1)
+	if err != nil {
+		log.Printf("error getting frame %d of thread %d: %s\n", index, thread, err)
+		return jdwp.FrameInfo{}, syscall.ENOENT
+	}
+	if len(frames) != 1 {
+		return jdwp.FrameInfo{}, syscall.ENOENT
+	}
+
+	return frames[0], 0
+}
+
+// method returns the method the frame is in, nil if the class doesn't
+// list it anymore
+func (d *ThreadFrameDir) method(location jdwp.Location) (*jdwp.Method, syscall.Errno) {
+	methods, err := d.JdwpConnection.GetMethods(jdwp.ReferenceTypeID(location.Class))
+	if err != nil {
+		log.Printf("error getting methods of class %d: %s\n", location.Class, err)
+		return nil, syscall.EFAULT
+	}
+
+	for _, method := range methods {
+		if method.ID == location.Method {
+			return &method, 0
+		}
+	}
+
+	return nil, 0
+}
+
+// names returns the class signature, method name and code index of
+// the frame
+func (d *ThreadFrameDir) names() (string, string, uint64, syscall.Errno) {
+	frame, errno := d.frame()
+	if errno != 0 {
+		return "", "", 0, errno
+	}
+	location := frame.Location
+
+	signature, err := d.JdwpConnection.GetTypeSignature(jdwp.ReferenceTypeID(location.Class))
+	if err != nil {
+		log.Printf("error getting signature of class %d: %s\n", location.Class, err)
+		return "", "", 0, syscall.EFAULT
+	}
+
+	method, errno := d.method(location)
+	if errno != 0 {
+		return "", "", 0, errno
+	}
+
+	methodName := fmt.Sprintf("<method %d>", location.Method)
+	if method != nil {
+		methodName = method.Name
+	}
+
+	return signature, methodName, location.Location, 0
+}
+
+// static tells if the frame is in a static method, after its modifiers
+func (d *ThreadFrameDir) static() (bool, syscall.Errno) {
+	frame, errno := d.frame()
+	if errno != 0 {
+		return false, errno
+	}
+
+	method, errno := d.method(frame.Location)
+	if errno != 0 {
+		return false, errno
+	}
+	if method == nil {
+		return false, syscall.ENOENT
+	}
+
+	return method.ModBits & jdwp.ModStatic != 0, 0
+}
+
+// readLocation gives the class signature, method name and code index,
+// separated by tabs
+func (d *ThreadFrameDir) readLocation() ([]byte, syscall.Errno) {
+	signature, methodName, codeIndex, errno := d.names()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	return []byte(fmt.Sprintf("%s\t%s\t%d\n", signature, methodName, codeIndex)), 0
+}
+
+func (d *ThreadFrameDir) readMethod() ([]byte, syscall.Errno) {
+	_, methodName, _, errno := d.names()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	return []byte(newlineTerminated(methodName)), 0
+}
+
+func (d *ThreadFrameDir) readClass() ([]byte, syscall.Errno) {
+	signature, _, _, errno := d.names()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	return []byte(newlineTerminated(signature)), 0
+}
+
+// readThis gives the id of the object the frame runs on; static and
+// native methods run on none, and have no data
+func (d *ThreadFrameDir) readThis() ([]byte, syscall.Errno) {
+	static, errno := d.static()
+	if errno != 0 {
+		return nil, errno
+	}
+	if static {
+		return nil, syscall.ENODATA
+	}
+
+	frame, errno := d.frame()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	this, err := d.JdwpConnection.GetThisObject(d.ThreadId, frame.Frame)
+	if err != nil {
+		log.Printf("error getting this of frame %d of thread %d: %s\n", d.Index, d.ThreadId, err)
+		return nil, syscall.EFAULT
+	}
+	if this == 0 {
+		return nil, syscall.ENODATA
+	}
+
+	return []byte(newlineTerminated(strconv.FormatUint(uint64(this), 10))), 0
+}
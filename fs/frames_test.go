@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// framesHandler has thread 7 in frame 99, at code index 5 of method 7,
+// run, of class 42, com.example.Main; the method is static or not, and
+// runs on object 123
+func framesHandler(modBits jdwp.ModBits) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 11 && cmd == 6:
+			return 0, fakeConcat(fakeInt(1), fakeLong(99), []byte{1}, fakeLong(42), fakeLong(7), fakeLong(5))
+		case set == 2 && cmd == 1:
+			return 0, fakeString("Lcom/example/Main;")
+		case set == 2 && cmd == 5:
+			return 0, fakeConcat(fakeInt(1), fakeLong(7), fakeString("run"), fakeString("()V"), fakeInt(uint32(modBits)))
+		case set == 16 && cmd == 3:
+			return 0, fakeConcat([]byte{'L'}, fakeLong(123))
+		default:
+			return 0, nil
+		}
+	}
+}
+
+// threadSuspended answers the status of every thread, suspended or not
+func threadSuspended(vm *fakeVM, suspended bool) {
+	var suspendStatus uint32 = 0
+	if suspended {
+		suspendStatus = 1
+	}
+	vm.Answer(11, 4, 0, fakeConcat(fakeInt(uint32(jdwp.ThreadRunning)), fakeInt(suspendStatus)))
+}
+
+func TestThreadFrames(t *testing.T) {
+	var tests = []struct {
+		name string
+		suspended bool
+		frames []string
+		errno syscall.Errno
+		location string
+		method string
+		class string
+	}{
+		{
+			name: "suspended",
+			suspended: true,
+			frames: []string { "0" },
+			location: "Lcom/example/Main;\trun\t5\n",
+			method: "run\n",
+			class: "Lcom/example/Main;\n",
+		},
+		{ name: "running", suspended: false, frames: []string {}, errno: syscall.EBUSY },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, framesHandler(jdwp.ModPublic))
+			threadSuspended(vm, test.suspended)
+
+			framesDir := NewThreadFramesDir(conn, 7, valueRenderer{}, nil, JdwpFsOptions{})
+			stream, errno := framesDir.Readdir(context.Background())
+			if errno != syscall.F_OK {
+				t.Fatalf("readdir: %v", errno)
+			}
+			var frames = []string {}
+			for stream.HasNext() {
+				entry, _ := stream.Next()
+				frames = append(frames, entry.Name)
+			}
+			if !reflect.DeepEqual(frames, test.frames) {
+				t.Fatalf("listed frames %v, expected %v", frames, test.frames)
+			}
+
+			frameDir := NewThreadFrameDir(conn, 7, 0, valueRenderer{}, nil, JdwpFsOptions{})
+			var files = []struct {
+				name string
+				read func() ([]byte, syscall.Errno)
+				contents string
+			}{
+				{ name: "location", read: frameDir.readLocation, contents: test.location },
+				{ name: "method", read: frameDir.readMethod, contents: test.method },
+				{ name: "class", read: frameDir.readClass, contents: test.class },
+			}
+			for _, file := range files {
+				contents, errno := file.read()
+				if errno != test.errno {
+					t.Fatalf("%s: got %v, expected %v", file.name, errno, test.errno)
+				}
+				if string(contents) != file.contents {
+					t.Fatalf("%s: read %q, expected %q", file.name, contents, file.contents)
+				}
+			}
+		})
+	}
+}
+
+func TestFrameThis(t *testing.T) {
+	var tests = []struct {
+		name string
+		modBits jdwp.ModBits
+		listed bool
+		errno syscall.Errno
+		this string
+	}{
+		{ name: "instance method", modBits: jdwp.ModPublic, listed: true, this: "123\n" },
+		{ name: "static method", modBits: jdwp.ModPublic | jdwp.ModStatic, listed: false, errno: syscall.ENODATA },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, framesHandler(test.modBits))
+			threadSuspended(vm, true)
+			frameDir := NewThreadFrameDir(conn, 7, 0, valueRenderer{}, nil, JdwpFsOptions{})
+
+			stream, errno := frameDir.Readdir(context.Background())
+			if errno != syscall.F_OK {
+				t.Fatalf("readdir: %v", errno)
+			}
+			var listed bool
+			for stream.HasNext() {
+				entry, _ := stream.Next()
+				listed = listed || entry.Name == "this"
+			}
+			if listed != test.listed {
+				t.Fatalf("this listed: %v, expected %v", listed, test.listed)
+			}
+
+			this, errno := frameDir.readThis()
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if string(this) != test.this {
+				t.Fatalf("read %q, expected %q", this, test.this)
+			}
+			if asked := vm.Received(16, 3); (asked != 0) != test.listed {
+				t.Fatalf("asked the VM for this %d times", asked)
+			}
+		})
+	}
+}
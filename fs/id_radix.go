@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const hexIdPrefix = "0x"
+
+//
+// Id radix
+// How the thread and class ids are written in the names of their
+// entries and in the links to them, as set in the options of the mount:
+// decimal, or hexadecimal with a 0x prefix, as in the JVM logs
+//
+func validateIdRadix(options JdwpFsOptions) error {
+	switch options.IdRadix {
+	case "", "dec", "hex":
+		return nil
+	default:
+		return fmt.Errorf("unknown id radix %q, expected dec or hex", options.IdRadix)
+	}
+}
+
+func (o JdwpFsOptions) hexIds() bool {
+	return o.IdRadix == "hex"
+}
+
+// formatId writes a thread or class id in the radix of the mount
+func (o JdwpFsOptions) formatId(id uint64) string {
+	return formatIdIn(id, o.hexIds())
+}
+
+// parseId reads a thread or class id written by formatId; an id in the
+// other radix isn't one, so every id has a single name
+func (o JdwpFsOptions) parseId(name string) (uint64, error) {
+	return parseIdIn(name, o.hexIds())
+}
+
+func formatIdIn(id uint64, hex bool) string {
+	if hex {
+		return hexIdPrefix + strconv.FormatUint(id, 16)
+	}
+
+	return strconv.FormatUint(id, 10)
+}
+
+func parseIdIn(name string, hex bool) (uint64, error) {
+	if !hex {
+		return strconv.ParseUint(name, 10, 64)
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(name, hexIdPrefix), 16, 64)
+	if err != nil || formatIdIn(id, true) != name {
+		return 0, fmt.Errorf("%q is not a hexadecimal id", name)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"testing"
+)
+
+func TestIdRadix(t *testing.T) {
+	var tests = []struct {
+		id uint64
+		hex bool
+		name string
+	}{
+		{ id: 0, hex: false, name: "0" },
+		{ id: 31, hex: false, name: "31" },
+		{ id: 31, hex: true, name: "0x1f" },
+		{ id: 1 << 40, hex: true, name: "0x10000000000" },
+		{ id: ^uint64(0), hex: false, name: "18446744073709551615" },
+		{ id: ^uint64(0), hex: true, name: "0xffffffffffffffff" },
+	}
+
+	for _, test := range tests {
+		if name := formatIdIn(test.id, test.hex); name != test.name {
+			t.Errorf("id %d, hex %v: formatted as %q, expected %q", test.id, test.hex, name, test.name)
+		}
+
+		id, err := parseIdIn(test.name, test.hex)
+		if err != nil || id != test.id {
+			t.Errorf("%q, hex %v: parsed as %d (%v), expected %d", test.name, test.hex, id, err, test.id)
+		}
+	}
+}
+
+func TestParseIdInOtherRadix(t *testing.T) {
+	var tests = []struct {
+		name string
+		hex bool
+	}{
+		{ name: "0x1f", hex: false },
+		{ name: "1f", hex: true },
+		{ name: "31", hex: true },
+		{ name: "0X1F", hex: true },
+		{ name: "0x01f", hex: true },
+		{ name: "", hex: false },
+		{ name: "0x", hex: true },
+	}
+
+	for _, test := range tests {
+		if id, err := parseIdIn(test.name, test.hex); err == nil {
+			t.Errorf("%q, hex %v: parsed as %d, expected an error", test.name, test.hex, id)
+		}
+	}
+}
+
+func TestValidateIdRadix(t *testing.T) {
+	var tests = []struct {
+		radix string
+		hex bool
+		fails bool
+	}{
+		{ radix: "", hex: false },
+		{ radix: "dec", hex: false },
+		{ radix: "hex", hex: true },
+		{ radix: "oct", fails: true },
+	}
+
+	for _, test := range tests {
+		options := JdwpFsOptions { IdRadix: test.radix }
+		err := validateIdRadix(options)
+		if (err != nil) != test.fails {
+			t.Errorf("radix %q: error %v", test.radix, err)
+			continue
+		}
+		if !test.fails && options.hexIds() != test.hex {
+			t.Errorf("radix %q: hex ids %v", test.radix, options.hexIds())
+		}
+	}
+}
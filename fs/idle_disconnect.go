@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Idle disconnector
+// Closes the connection to the VM once nothing has asked it anything for
+// a while; the next call opens it again. Running events and threads
+// suspended by jdwpfs keep it open, as both would go away with it. A nil
+// disconnector is disabled
+//
+type IdleDisconnector struct {
+	JdwpConnection *debug.Connection
+	EventManager *debug.EventManager
+	timeout time.Duration
+}
+
+// NewIdleDisconnector starts watching until ctx is done; with a timeout
+// of 0 there is no disconnector
+func NewIdleDisconnector(ctx context.Context, conn *debug.Connection, manager *debug.EventManager, timeout time.Duration) *IdleDisconnector {
+	if timeout <= 0 {
+		return nil
+	}
+
+	d := &IdleDisconnector {
+		JdwpConnection: conn,
+		EventManager: manager,
+		timeout: timeout,
+	}
+	go d.watch(ctx)
+
+	return d
+}
+
+// idle tells if the connection is open, and can be closed
+func (d *IdleDisconnector) idle(now time.Time) bool {
+	if d.JdwpConnection.IsDisconnected() {
+		return false
+	}
+
+	if now.Sub(d.JdwpConnection.LastActivity()) < d.timeout {
+		return false
+	}
+
+	if d.JdwpConnection.HasOwnedSuspensions() {
+		return false
+	}
+
+	events, err := d.EventManager.GetAllEvents()
+	if err != nil {
+		return false
+	}
+	for _, event := range events {
+		if event.IsRunning() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *IdleDisconnector) watch(ctx context.Context) {
+	var interval = d.timeout / 4
+	if interval <= 0 {
+		interval = d.timeout
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.check(now)
+		}
+	}
+}
+
+func (d *IdleDisconnector) check(now time.Time) {
+	if !d.idle(now) {
+		return
+	}
+
+	// calls may have come in since, which keep it connected
+	disconnected, err := d.JdwpConnection.DisconnectIfIdle(now, d.timeout)
+	if err != nil {
+		log.Printf("error disconnecting: %s\n", err)
+	}
+	if disconnected {
+		log.Printf("the VM wasn't used for %s, disconnected until it's needed\n", d.timeout)
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	testIdleTimeout = time.Minute
+)
+
+func newTestIdleDisconnector(t *testing.T, conn *debug.Connection) *IdleDisconnector {
+	t.Helper()
+
+	manager, err := debug.NewEventManager(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("unable to create the event manager: %s", err)
+	}
+
+	return &IdleDisconnector {
+		JdwpConnection: conn,
+		EventManager: manager,
+		timeout: testIdleTimeout,
+	}
+}
+
+func TestIdleDisconnectorIdle(t *testing.T) {
+	var tests = []struct {
+		name string
+		after time.Duration
+		suspend bool
+		running bool
+		idle bool
+	}{
+		{ name: "used recently", after: testIdleTimeout / 2, idle: false },
+		{ name: "unused for the timeout", after: 2 * testIdleTimeout, idle: true },
+		{ name: "thread suspended", after: 2 * testIdleTimeout, suspend: true, idle: false },
+		{ name: "event running", after: 2 * testIdleTimeout, running: true, idle: false },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, 0, fakeInt(1))
+			d := newTestIdleDisconnector(t, conn)
+
+			if test.suspend {
+				if err := conn.Suspend(jdwp.ThreadID(1)); err != nil {
+					t.Fatalf("unable to suspend: %s", err)
+				}
+			}
+			if test.running {
+				event, err := d.EventManager.CreateEvent("running")
+				if err != nil {
+					t.Fatalf("unable to create the event: %s", err)
+				}
+				event.SetKind(jdwp.ThreadStart)
+				if _, err := event.Run(); err != nil {
+					t.Fatalf("unable to run the event: %s", err)
+				}
+				defer event.Cancel()
+			}
+
+			if idle := d.idle(time.Now().Add(test.after)); idle != test.idle {
+				t.Fatalf("got idle %v, expected %v", idle, test.idle)
+			}
+		})
+	}
+}
+
+func TestIdleDisconnect(t *testing.T) {
+	conn, vm := startFakeVM(t, nil)
+	d := newTestIdleDisconnector(t, conn)
+
+	var reopened *fakeVM
+	conn.SetRedial(func() (*debug.Connection, error) {
+		other, otherVM := startFakeVM(t, nil)
+		otherVM.Answer(1, 4, 0, fakeInt(0))
+		reopened = otherVM
+		return other, nil
+	})
+
+	d.check(time.Now())
+	if conn.IsDisconnected() {
+		t.Fatalf("disconnected while in use")
+	}
+
+	// past the timeout
+	d.check(time.Now().Add(2 * testIdleTimeout))
+	if !conn.IsDisconnected() {
+		t.Fatalf("still connected once idle")
+	}
+	if idle := d.idle(time.Now().Add(4 * testIdleTimeout)); idle {
+		t.Fatalf("idle again once disconnected")
+	}
+
+	// opened again on access
+	if _, err := conn.GetAllThreads(); err != nil {
+		t.Fatalf("unable to list the threads once disconnected: %s", err)
+	}
+	if conn.IsDisconnected() {
+		t.Fatalf("still disconnected after an access")
+	}
+	if reopened == nil {
+		t.Fatalf("the connection wasn't opened again")
+	}
+	if listed := reopened.Received(1, 4); listed != 1 {
+		t.Fatalf("the threads were listed %d times on the new connection", listed)
+	}
+	if listed := vm.Received(1, 4); listed != 0 {
+		t.Fatalf("the threads were listed %d times on the closed connection", listed)
+	}
+}
+
+func TestIdleDisconnectInFlight(t *testing.T) {
+	// the threads are only listed once released
+	release := make(chan struct{})
+	conn, _ := startFakeVM(t, func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		if set == 1 && cmd == 4 {
+			<-release
+			return 0, fakeInt(0)
+		}
+		return 0, nil
+	})
+	d := newTestIdleDisconnector(t, conn)
+
+	listed := make(chan error)
+	go func() {
+		_, err := conn.GetAllThreads()
+		listed <- err
+	}()
+	for _, inFlight := conn.GetPendingCalls(); inFlight == 0; _, inFlight = conn.GetPendingCalls() {
+		time.Sleep(time.Millisecond)
+	}
+
+	d.check(time.Now().Add(2 * testIdleTimeout))
+	disconnected := conn.IsDisconnected()
+	close(release)
+	if err := <-listed; err != nil {
+		t.Fatalf("the call in flight failed: %s", err)
+	}
+	if disconnected {
+		t.Fatalf("disconnected under a call in flight")
+	}
+}
+
+func TestNilIdleDisconnector(t *testing.T) {
+	if d := NewIdleDisconnector(context.Background(), nil, nil, 0); d != nil {
+		t.Fatalf("got a disconnector without a timeout")
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+//
+// Info file
+// A read only file whose contents are computed on every read, for
+// information which changes while the filesystem is mounted
+//
+type InfoFile struct {
+	fs.Inode
+
+	contents func() ([]byte, syscall.Errno)
+
+	// nil unless the file inspects suspended threads
+	watchdog *Watchdog
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeOpener)((*InfoFile)(nil))
+var _ = (fs.NodeOpendirer)((*InfoFile)(nil))
+var _ = (fs.NodeGetattrer)((*InfoFile)(nil))
+var _ = (fs.NodeReader)((*InfoFile)(nil))
+var _ = (fs.NodeReleaser)((*InfoFile)(nil))
+
+func NewInfoFile(contents func() ([]byte, syscall.Errno), options JdwpFsOptions) InfoFile {
+	return InfoFile {
+		contents: contents,
+		Options: options,
+	}
+}
+
+// NewInspectionFile is an info file about suspended threads; while it's
+// open, the watchdog leaves the threads suspended
+func NewInspectionFile(contents func() ([]byte, syscall.Errno), watchdog *Watchdog, options JdwpFsOptions) InfoFile {
+	return InfoFile {
+		contents: contents,
+		watchdog: watchdog,
+		Options: options,
+	}
+}
+
+func (f *InfoFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & syscall.O_ACCMODE != syscall.O_RDONLY {
+		return nil, 0, syscall.EROFS
+	}
+
+	f.watchdog.Acquire()
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (f *InfoFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (f *InfoFile) Release(ctx context.Context, _ fs.FileHandle) syscall.Errno {
+	f.watchdog.Release()
+	return 0
+}
+
+func (f *InfoFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = f.Options.fileMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (f *InfoFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	contents, errno := f.contents()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	if offset > int64(len(contents)) {
+		return nil, syscall.EBADR
+	}
+
+	contents = contents[offset:]
+	if len(contents) > len(dest) {
+		contents = contents[:len(dest)]
+	}
+
+	return fuse.ReadResultData(contents), 0
+}
+
+// newlineTerminated ends the contents of a single value file with a
+// newline, as line oriented tools expect; no value stays empty
+func newlineTerminated(value string) string {
+	if value == "" || strings.HasSuffix(value, "\n") {
+		return value
+	}
+
+	return value + "\n"
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestNewlineTerminated(t *testing.T) {
+	var tests = []struct {
+		value string
+		terminated string
+	}{
+		{ value: "", terminated: "" },
+		{ value: "worker", terminated: "worker\n" },
+		{ value: "worker\n", terminated: "worker\n" },
+		{ value: "two\nlines", terminated: "two\nlines\n" },
+	}
+
+	for _, test := range tests {
+		if terminated := newlineTerminated(test.value); terminated != test.terminated {
+			t.Errorf("%q: got %q, expected %q", test.value, terminated, test.terminated)
+		}
+	}
+}
+
+// namedThreadHandler has thread 7, named worker, sleeping
+func namedThreadHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 11 && cmd == 1:
+		return 0, fakeString("worker")
+	case set == 11 && cmd == 4:
+		return 0, fakeConcat(fakeInt(uint32(jdwp.ThreadSleeping)), fakeInt(0))
+	default:
+		return 0, nil
+	}
+}
+
+func TestSingleValueNewline(t *testing.T) {
+	conn, _ := startFakeVM(t, namedThreadHandler)
+
+	threadDir, _ := NewJdwpThreadDir(context.Background(), conn, jdwp.ThreadID(7), JdwpFsOptions{}, nil)
+	fs.NewNodeFS(threadDir, &fs.Options{})
+	statusInode, errno := threadDir.Lookup(context.Background(), "threadStatus", &fuse.EntryOut{})
+	if errno != syscall.F_OK {
+		t.Fatalf("lookup threadStatus: %v", errno)
+	}
+	threadStatus := string(statusInode.Operations().(*fs.MemRegularFile).Data)
+
+	nameFile := NewThreadNameFile(conn, jdwp.ThreadID(7), false, JdwpFsOptions{})
+
+	event := debug.NewStubDebuggingEvent("kinded")
+	event.SetKind(jdwp.Breakpoint)
+	kindFile := NewEventKindFile(event, JdwpFsOptions{})
+
+	var tests = []struct {
+		name string
+		read string
+		expected string
+	}{
+		{ name: "name", read: readNode(t, &nameFile, 0), expected: "worker\n" },
+		{ name: "kind", read: readNode(t, &kindFile, 0), expected: "Breakpoint\n" },
+		{ name: "threadStatus", read: threadStatus, expected: "Sleeping\n" },
+	}
+
+	for _, test := range tests {
+		if test.read != test.expected {
+			t.Errorf("%s: read %q, expected %q", test.name, test.read, test.expected)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Method breakpoint file
+// A shortcut for a Breakpoint event at the entry of the method: writing
+// 1 creates and runs it, writing 0 cancels and removes it; the event
+// shows up under events, like any other. Every node of the method arms
+// and disarms the same event, so they take turns on its name
+//
+type MethodBreakpointFile struct {
+	fs.Inode
+
+	TypeId jdwp.ReferenceTypeID
+	MethodId jdwp.MethodID
+
+	JdwpConnection *debug.Connection
+	manager *debug.EventManager
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*MethodBreakpointFile)(nil))
+var _ = (fs.NodeSetattrer)((*MethodBreakpointFile)(nil))
+var _ = (fs.NodeOpener)((*MethodBreakpointFile)(nil))
+var _ = (fs.NodeOpendirer)((*MethodBreakpointFile)(nil))
+var _ = (fs.NodeReader)((*MethodBreakpointFile)(nil))
+var _ = (fs.NodeWriter)((*MethodBreakpointFile)(nil))
+
+func NewMethodBreakpointFile(manager *debug.EventManager, conn *debug.Connection, typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID, options JdwpFsOptions) MethodBreakpointFile {
+	return MethodBreakpointFile {
+		TypeId: typeId,
+		MethodId: methodId,
+		JdwpConnection: conn,
+		manager: manager,
+		Options: options,
+	}
+}
+
+// MethodBreakpointEventName is the name of the event backing the
+// breakpoint file of a method
+func MethodBreakpointEventName(typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID) string {
+	return fmt.Sprintf("breakpoint-%d-%d", typeId, methodId)
+}
+
+func (c *MethodBreakpointFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *MethodBreakpointFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *MethodBreakpointFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (c *MethodBreakpointFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (c *MethodBreakpointFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	var readString = "disarmed\n"
+	event, err := c.manager.GetEvent(MethodBreakpointEventName(c.TypeId, c.MethodId))
+	if err == nil && event.IsRunning() {
+		readString = "armed\n"
+	}
+
+	if offset > int64(len(readString)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(readString[offset:])), syscall.F_OK
+}
+
+func (c *MethodBreakpointFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyEventCreate)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *MethodBreakpointFile) writeToken(data []byte) (errno syscall.Errno) {
+	defer c.manager.LockName(MethodBreakpointEventName(c.TypeId, c.MethodId))()
+
+	switch strings.TrimSpace(string(data)) {
+	case "1":
+		errno = c.arm()
+	case "0":
+		errno = c.disarm()
+	default:
+		return syscall.EBADMSG
+	}
+	if errno != syscall.F_OK {
+		return errno
+	}
+
+	return syscall.F_OK
+}
+
+func (c *MethodBreakpointFile) arm() syscall.Errno {
+	name := MethodBreakpointEventName(c.TypeId, c.MethodId)
+	if event, err := c.manager.GetEvent(name); err == nil {
+		if event.IsRunning() {
+			return syscall.F_OK
+		}
+		return syscall.EEXIST
+	}
+
+	modifier, errno := resolveModifier(c.JdwpConnection, uint64(c.TypeId), false, uint64(c.MethodId))
+	if errno != syscall.F_OK {
+		return errno
+	}
+	modifier.Name = "entry"
+
+	event, err := c.manager.CreateEvent(name)
+	if err != nil {
+		log.Printf("unable to create event %s: %s\n", name, err)
+		return syscall.EADDRNOTAVAIL
+	}
+
+	event.SetKind(jdwp.Breakpoint)
+	event.SetModifier(modifier.Name, modifier)
+
+	_, err = event.Run()
+	if err != nil {
+		log.Printf("error running event %s: %s\n", name, err)
+		if err := c.manager.DeregisterEvent(name); err != nil {
+			log.Printf("unable to remove event %s: %s\n", name, err)
+		}
+		return syscall.EBADE
+	}
+
+	return syscall.F_OK
+}
+
+func (c *MethodBreakpointFile) disarm() syscall.Errno {
+	name := MethodBreakpointEventName(c.TypeId, c.MethodId)
+	event, err := c.manager.GetEvent(name)
+	if err != nil {
+		return syscall.F_OK
+	}
+
+	if event.IsRunning() {
+		err = event.Cancel()
+		if err != nil {
+			log.Printf("error cancelling event %s: %s\n", name, err)
+			return syscall.EBADE
+		}
+	}
+
+	err = c.manager.DeregisterEvent(name)
+	if err != nil {
+		log.Printf("unable to remove event %s: %s\n", name, err)
+		return syscall.EBUSY
+	}
+
+	return syscall.F_OK
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// breakpointHandler lists the stub classes, each with method 206, and
+// accepts every event request
+func breakpointHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 2 && cmd == 5:
+		return 0, fakeConcat(fakeInt(1), fakeLong(206), fakeString("run"), fakeString("()V"), fakeInt(1))
+	case set == 15 && cmd == 1:
+		return 0, fakeInt(1)
+	default:
+		return classListHandler(set, cmd, data)
+	}
+}
+
+func TestMethodBreakpoint(t *testing.T) {
+	var tests = []struct {
+		name string
+		method jdwp.MethodID
+		tokens []string
+		errno syscall.Errno
+		armed string
+		exists bool
+		requested int
+	}{
+		{ name: "disarmed", method: 206, armed: "disarmed\n" },
+		{ name: "armed", method: 206, tokens: []string { "1\n" }, armed: "armed\n", exists: true, requested: 1 },
+		{ name: "armed twice", method: 206, tokens: []string { "1\n", "1\n" }, armed: "armed\n", exists: true, requested: 1 },
+		{ name: "disarmed again", method: 206, tokens: []string { "1\n", "0\n" }, armed: "disarmed\n", requested: 1 },
+		{ name: "disarmed twice", method: 206, tokens: []string { "1\n", "0\n", "0\n" }, armed: "disarmed\n", requested: 1 },
+		{ name: "rearmed", method: 206, tokens: []string { "1\n", "0\n", "1\n" }, armed: "armed\n", exists: true, requested: 2 },
+		{ name: "unknown token", method: 206, tokens: []string { "2\n" }, errno: syscall.EBADMSG, armed: "disarmed\n" },
+		{ name: "unknown method", method: 99, tokens: []string { "1\n" }, errno: syscall.ENOENT, armed: "disarmed\n" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, breakpointHandler)
+			manager, err := debug.NewEventManager(context.Background(), conn)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			breakpointFile := NewMethodBreakpointFile(manager, conn, jdwp.ReferenceTypeID(42), test.method, JdwpFsOptions{})
+
+			var errno syscall.Errno
+			for _, token := range test.tokens {
+				errno = breakpointFile.writeToken([]byte(token))
+			}
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if armed := readNode(t, &breakpointFile, 0); armed != test.armed {
+				t.Fatalf("read %q, expected %q", armed, test.armed)
+			}
+
+			name := MethodBreakpointEventName(jdwp.ReferenceTypeID(42), test.method)
+			event, err := manager.GetEvent(name)
+			if exists := err == nil; exists != test.exists {
+				t.Fatalf("event %s exists: %v", name, exists)
+			}
+
+			// the requests are set by the runs, which are over once cancelled
+			if test.exists {
+				if err := event.Cancel(); err != nil {
+					t.Fatalf("cancel: %s", err)
+				}
+			}
+			if requested := vm.Received(15, 1); requested != test.requested {
+				t.Fatalf("the breakpoint was requested %d times, expected %d", requested, test.requested)
+			}
+		})
+	}
+}
+
+func TestMethodBreakpointConcurrentArm(t *testing.T) {
+	const armers = 16
+
+	conn, vm := startFakeVM(t, breakpointHandler)
+	// the fake VM answers over an unbuffered pipe, one call at a time
+	conn.SetMaxConcurrent(1)
+	manager, err := debug.NewEventManager(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("unable to create the event manager: %s", err)
+	}
+
+	// a node per lookup of the method, armed at once
+	var wg sync.WaitGroup
+	errnos := make(chan syscall.Errno, armers)
+	for i := 0; i < armers; i++ {
+		breakpointFile := NewMethodBreakpointFile(manager, conn, jdwp.ReferenceTypeID(42), 206, JdwpFsOptions{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errnos <- breakpointFile.writeToken([]byte("1\n"))
+		}()
+	}
+	wg.Wait()
+	close(errnos)
+
+	for errno := range errnos {
+		if errno != syscall.F_OK {
+			t.Fatalf("arm: %v", errno)
+		}
+	}
+
+	event, err := manager.GetEvent(MethodBreakpointEventName(jdwp.ReferenceTypeID(42), 206))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := event.Cancel(); err != nil {
+		t.Fatalf("cancel: %s", err)
+	}
+	if requested := vm.Received(15, 1); requested != 1 {
+		t.Fatalf("the breakpoint was requested %d times", requested)
+	}
+}
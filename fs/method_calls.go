@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+// a link to a method is named <class id>.<method id>
+const methodLinkSeparator = "."
+
+//
+// Method calls directory
+// Links to the methods a method invokes, or to the ones invoking it, as
+// found in their bytecode; empty when the VM can't give out bytecode or
+// constant pools. Finding the callers goes through every loaded class
+// the class filter lets through, so the listing is kept for the lookups
+// following it, e.g. by ls -l; it's found again on the next readdir
+//
+type MethodCallsDir struct {
+	fs.Inode
+
+	TypeId jdwp.ReferenceTypeID
+	MethodId jdwp.MethodID
+
+	JdwpConnection *debug.Connection
+
+	callers bool
+	classFilter *ClassFilter
+
+	mu sync.Mutex
+	listing []string
+	listed bool
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*MethodCallsDir)(nil))
+var _ = (fs.NodeReaddirer)((*MethodCallsDir)(nil))
+var _ = (fs.NodeLookuper)((*MethodCallsDir)(nil))
+var _ = (fs.NodeMkdirer)((*MethodCallsDir)(nil))
+
+func NewMethodCallsDir(conn *debug.Connection, typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID, callers bool, classFilter *ClassFilter, options JdwpFsOptions) MethodCallsDir {
+	return MethodCallsDir {
+		TypeId: typeId,
+		MethodId: methodId,
+		JdwpConnection: conn,
+		callers: callers,
+		classFilter: classFilter,
+		Options: options,
+	}
+}
+
+func (d *MethodCallsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *MethodCallsDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+// methods returns the link names of the methods, in the order found
+func (d *MethodCallsDir) methods() []string {
+	capabilities, err := d.JdwpConnection.GetCapabilities()
+	if err != nil {
+		log.Printf("unable to get the VM capabilities: %s\n", err)
+		return nil
+	}
+	if !capabilities.Has("canGetBytecodes") || !capabilities.Has("canGetConstantPool") {
+		return nil
+	}
+
+	var locations []jdwp.Location
+	if d.callers {
+		allClasses, err := d.JdwpConnection.GetAllClasses()
+		if err != nil {
+			log.Printf("unable to get the classes: %s\n", err)
+			return nil
+		}
+
+		var classes []jdwp.ClassInfo
+		for _, class := range allClasses {
+			if d.classFilter.Matches(class.Signature) {
+				classes = append(classes, class)
+			}
+		}
+
+		locations, err = d.JdwpConnection.GetCallers(d.TypeId, d.MethodId, classes)
+		if err != nil {
+			log.Printf("unable to find the callers of method %d: %s\n", d.MethodId, err)
+		}
+	} else {
+		callees, err := d.JdwpConnection.GetCallees(d.TypeId, d.MethodId)
+		if err != nil {
+			log.Printf("could only scan part of method %d: %s\n", d.MethodId, err)
+		}
+
+		for _, callee := range callees {
+			class, method, err := d.JdwpConnection.ResolveMethodRef(callee)
+			if err != nil {
+				continue
+			}
+			locations = append(locations, jdwp.Location {
+				Class: jdwp.ClassID(class),
+				Method: method,
+			})
+		}
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, location := range locations {
+		name := d.Options.formatId(uint64(location.Class)) + methodLinkSeparator +
+			strconv.FormatUint(uint64(location.Method), 10)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// refresh finds the methods again, and keeps them for the lookups
+func (d *MethodCallsDir) refresh() []string {
+	names := d.methods()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.listing = names
+	d.listed = true
+
+	return names
+}
+
+// cached returns the methods last listed, finding them if they never were
+func (d *MethodCallsDir) cached() []string {
+	d.mu.Lock()
+	listing, listed := d.listing, d.listed
+	d.mu.Unlock()
+
+	if listed {
+		return listing
+	}
+
+	return d.refresh()
+}
+
+func (d *MethodCallsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries = []fuse.DirEntry{}
+	for _, name := range d.refresh() {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFLNK,
+			Name: name,
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *MethodCallsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	var found bool
+	for _, method := range d.cached() {
+		if method == name {
+			found = true
+		}
+	}
+	if !found {
+		return nil, syscall.ENOENT
+	}
+
+	ids := strings.SplitN(name, methodLinkSeparator, 2)
+	methodLink := d.NewInode(
+		ctx,
+		&fs.MemSymlink {
+			Data: []byte(relativeLink(&d.Inode, "classes/" + ids[0] + "/methods/" + ids[1])),
+			Attr: staticAttr(d.Options.fileMode()),
+		},
+		fs.StableAttr {
+			Mode: fuse.S_IFLNK,
+		})
+
+	return methodLink, 0
+}
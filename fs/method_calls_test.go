@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// canGetBytecodes, in the reply of CapabilitiesNew
+const capabilityBytecodes = 2
+
+// the constant pool entries of a class file
+func poolUtf8(text string) []byte {
+	return fakeConcat([]byte { 1, 0, byte(len(text)) }, []byte(text))
+}
+
+func poolRef(tag byte, indexes ...byte) []byte {
+	entry := []byte { tag }
+	for _, index := range indexes {
+		entry = append(entry, 0, index)
+	}
+	return entry
+}
+
+// callsHandler has com.example.Main.run, method 7 of class 41, invoking
+// com.example.Foo.bar, method 8 of class 42, through one invokevirtual
+func callsHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	var signatures = map[uint64]string {
+		41: "Lcom/example/Main;",
+		42: "Lcom/example/Foo;",
+	}
+
+	switch {
+	case set == 1 && cmd == 3:
+		return 0, fakeConcat(fakeInt(2),
+			[]byte{1}, fakeLong(41), fakeString(signatures[41]), fakeInt(7),
+			[]byte{1}, fakeLong(42), fakeString(signatures[42]), fakeInt(7))
+	case set == 1 && cmd == 2:
+		signature := string(data[4:4 + binary.BigEndian.Uint32(data)])
+		for id, classSignature := range signatures {
+			if classSignature == signature {
+				return 0, fakeConcat(fakeInt(1), []byte{1}, fakeLong(id), fakeInt(7))
+			}
+		}
+		return 0, fakeInt(0)
+	case set == 2 && cmd == 5 && data[7] == 41:
+		return 0, fakeConcat(fakeInt(1), fakeLong(7), fakeString("run"), fakeString("()V"), fakeInt(1))
+	case set == 2 && cmd == 5 && data[7] == 42:
+		return 0, fakeConcat(fakeInt(1), fakeLong(8), fakeString("bar"), fakeString("()V"), fakeInt(1))
+	case set == 2 && cmd == 18 && data[7] == 41:
+		pool := fakeConcat(
+			poolUtf8("com/example/Foo"),
+			poolRef(7, 1),
+			poolUtf8("bar"),
+			poolUtf8("()V"),
+			poolRef(12, 3, 4),
+			poolRef(10, 2, 5))
+		return 0, fakeConcat(fakeInt(7), fakeInt(uint32(len(pool))), pool)
+	case set == 2 && cmd == 18 && data[7] == 42:
+		pool := fakeConcat(poolUtf8("com/example/Foo"), poolRef(7, 1))
+		return 0, fakeConcat(fakeInt(3), fakeInt(uint32(len(pool))), pool)
+	case set == 3 && cmd == 1:
+		return 0, fakeLong(0)
+	case set == 6 && cmd == 3 && data[15] == 7:
+		// aload_0, invokevirtual #6, return
+		return 0, fakeConcat(fakeInt(5), []byte { 0x2a, 0xb6, 0x00, 0x06, 0xb1 })
+	case set == 6 && cmd == 3 && data[15] == 8:
+		return 0, fakeConcat(fakeInt(1), []byte { 0xb1 })
+	default:
+		return 0, nil
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	var tests = []struct {
+		name string
+		class uint64
+		method uint64
+		callers bool
+		capabilities []int
+		listed []string
+		resolved []string
+	}{
+		{
+			name: "callees",
+			class: 41,
+			method: 7,
+			capabilities: []int { capabilityBytecodes, capabilityConstantPool },
+			listed: []string { "42.8" },
+			resolved: []string { "classes/42/methods/8" },
+		},
+		{
+			name: "callers",
+			class: 42,
+			method: 8,
+			callers: true,
+			capabilities: []int { capabilityBytecodes, capabilityConstantPool },
+			listed: []string { "41.7" },
+			resolved: []string { "classes/41/methods/7" },
+		},
+		{
+			name: "no callees",
+			class: 42,
+			method: 8,
+			capabilities: []int { capabilityBytecodes, capabilityConstantPool },
+			listed: []string{},
+		},
+		{
+			name: "no bytecode",
+			class: 41,
+			method: 7,
+			capabilities: []int { capabilityConstantPool },
+			listed: []string{},
+		},
+		{
+			name: "no constant pool",
+			class: 42,
+			method: 8,
+			callers: true,
+			capabilities: []int { capabilityBytecodes },
+			listed: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, callsHandler)
+			vm.Answer(1, 17, 0, fakeCapabilities(test.capabilities...))
+			callsDir := NewMethodCallsDir(conn, jdwp.ReferenceTypeID(test.class), jdwp.MethodID(test.method), test.callers, nil, JdwpFsOptions{})
+			dir := mountAt(t, fmt.Sprintf("classes/%d/methods/%d/calls", test.class, test.method), &callsDir)
+
+			listed := listNames(t, &callsDir)
+			if !reflect.DeepEqual(listed, test.listed) {
+				t.Fatalf("listed %v, expected %v", listed, test.listed)
+			}
+			for i, name := range listed {
+				if at := resolved(dir, linkTarget(t, &callsDir, name)); at != test.resolved[i] {
+					t.Fatalf("%s leads to %s, expected %s", name, at, test.resolved[i])
+				}
+			}
+
+			if _, errno := callsDir.Lookup(context.Background(), "43.9", nil); errno == 0 {
+				t.Fatalf("found a method which isn't invoked")
+			}
+		})
+	}
+}
+
+func TestMethodCallersScans(t *testing.T) {
+	var tests = []struct {
+		name string
+		include []string
+		listed []string
+	}{
+		{ name: "all classes", listed: []string { "41.7" } },
+		{ name: "caller filtered out", include: []string { "Lcom/example/Foo;" }, listed: []string{} },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := NewClassFilter(test.include, nil)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			conn, vm := startFakeVM(t, callsHandler)
+			vm.Answer(1, 17, 0, fakeCapabilities(capabilityBytecodes, capabilityConstantPool))
+			callsDir := NewMethodCallsDir(conn, 42, 8, true, filter, JdwpFsOptions{})
+			mountAt(t, "classes/42/methods/8/callers", &callsDir)
+
+			listed := listNames(t, &callsDir)
+			if !reflect.DeepEqual(listed, test.listed) {
+				t.Fatalf("listed %v, expected %v", listed, test.listed)
+			}
+			if scans := vm.Received(1, 3); scans != 1 {
+				t.Fatalf("listing scanned the classes %d times", scans)
+			}
+
+			// as ls -l does, after listing
+			for _, name := range append(listed, "43.9") {
+				callsDir.Lookup(context.Background(), name, nil)
+			}
+			if scans := vm.Received(1, 3); scans != 1 {
+				t.Fatalf("lookups scanned the classes %d more times", scans - 1)
+			}
+		})
+	}
+}
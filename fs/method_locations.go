@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+const lineLocationPrefix = "line="
+
+// methodLines maps each line of a method to its first code index; a line
+// can span several ranges of the bytecode, but a breakpoint on it is set
+// where it starts
+func methodLines(conn *debug.Connection, typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID) (map[int]uint64, error) {
+	table, err := conn.GetLineTable(typeId, methodId)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(map[int]uint64)
+	for _, line := range table.Lines {
+		codeIndex, ok := lines[line.Number]
+		if !ok || line.CodeIndex < codeIndex {
+			lines[line.Number] = line.CodeIndex
+		}
+	}
+
+	return lines, nil
+}
+
+// the suffix of a location link name picking a code index, name@<n>, or
+// name@entry for where the method starts
+const codeIndexSeparator = "@"
+const codeIndexEntry = "entry"
+
+// methodCodeRange gives the first and the last code index of a method
+func methodCodeRange(conn *debug.Connection, typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID) (uint64, uint64, error) {
+	table, err := conn.GetLineTable(typeId, methodId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return table.Start, table.End, nil
+}
+
+// parseCodeIndexSuffix parses the code index suffix of a location link
+// name; entry is reported separately, as its code index depends on the
+// method. found is false when the name has no suffix
+func parseCodeIndexSuffix(name string) (codeIndex uint64, entry bool, found bool, ok bool) {
+	separatorIndex := strings.LastIndex(name, codeIndexSeparator)
+	if separatorIndex < 0 {
+		return 0, false, false, true
+	}
+
+	suffix := name[separatorIndex + len(codeIndexSeparator):]
+	if suffix == codeIndexEntry {
+		return 0, true, true, true
+	}
+
+	codeIndex, err := strconv.ParseUint(suffix, 10, 64)
+	if err != nil {
+		return 0, false, true, false
+	}
+
+	return codeIndex, false, true, true
+}
+
+// parseLineLocation parses the name of a line location, line=<n>
+func parseLineLocation(name string) (int, bool) {
+	if !strings.HasPrefix(name, lineLocationPrefix) {
+		return 0, false
+	}
+
+	line, err := strconv.Atoi(strings.TrimPrefix(name, lineLocationPrefix))
+	if err != nil || line <= 0 {
+		return 0, false
+	}
+
+	return line, true
+}
+
+//
+// Method locations directory
+// A directory per line of the method, named line=<n>; linking one into
+// an event's location directory sets the location at that line
+//
+type ClassMethodLocationsDir struct {
+	fs.Inode
+
+	TypeId jdwp.ReferenceTypeID
+	MethodId jdwp.MethodID
+
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ClassMethodLocationsDir)(nil))
+var _ = (fs.NodeReaddirer)((*ClassMethodLocationsDir)(nil))
+var _ = (fs.NodeLookuper)((*ClassMethodLocationsDir)(nil))
+var _ = (fs.NodeMkdirer)((*ClassMethodLocationsDir)(nil))
+
+func NewClassMethodLocationsDir(conn *debug.Connection, typeId jdwp.ReferenceTypeID, methodId jdwp.MethodID, options JdwpFsOptions) ClassMethodLocationsDir {
+	return ClassMethodLocationsDir {
+		TypeId: typeId,
+		MethodId: methodId,
+		JdwpConnection: conn,
+		Options: options,
+	}
+}
+
+func (d *ClassMethodLocationsDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *ClassMethodLocationsDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+// Readdir lists the lines in order; native and abstract methods, or
+// classes compiled without debug information, have none
+func (d *ClassMethodLocationsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries = []fuse.DirEntry{}
+	lines, err := methodLines(d.JdwpConnection, d.TypeId, d.MethodId)
+	if err != nil {
+		log.Printf("no line table for method %d of class %d: %s\n", d.MethodId, d.TypeId, err)
+		return fs.NewListDirStream(entries), syscall.F_OK
+	}
+
+	var numbers []int
+	for line := range lines {
+		numbers = append(numbers, line)
+	}
+	sort.Ints(numbers)
+
+	for _, line := range numbers {
+		newEntry := fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: fmt.Sprintf("%s%d", lineLocationPrefix, line),
+		}
+		entries = append(entries, newEntry)
+	}
+
+	return fs.NewListDirStream(entries), syscall.F_OK
+}
+
+func (d *ClassMethodLocationsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	line, ok := parseLineLocation(name)
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+
+	lines, err := methodLines(d.JdwpConnection, d.TypeId, d.MethodId)
+	if err != nil {
+		log.Printf("no line table for method %d of class %d: %s\n", d.MethodId, d.TypeId, err)
+		return nil, syscall.ENOENT
+	}
+
+	codeIndex, ok := lines[line]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+
+	locationDir := NewClassMethodLocationDir(codeIndex, d.Options)
+	locationInode := d.NewInode(
+		ctx,
+		&locationDir,
+		fs.StableAttr {
+			Mode: fuse.S_IFDIR,
+		})
+	return locationInode, syscall.F_OK
+}
+
+//
+// Method location directory
+// Holds the code index the line starts at
+//
+type ClassMethodLocationDir struct {
+	fs.Inode
+
+	CodeIndex uint64
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ClassMethodLocationDir)(nil))
+var _ = (fs.NodeReaddirer)((*ClassMethodLocationDir)(nil))
+var _ = (fs.NodeLookuper)((*ClassMethodLocationDir)(nil))
+var _ = (fs.NodeMkdirer)((*ClassMethodLocationDir)(nil))
+
+func NewClassMethodLocationDir(codeIndex uint64, options JdwpFsOptions) ClassMethodLocationDir {
+	return ClassMethodLocationDir {
+		CodeIndex: codeIndex,
+		Options: options,
+	}
+}
+
+func (d *ClassMethodLocationDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *ClassMethodLocationDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *ClassMethodLocationDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry {
+		fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "codeIndex",
+		},
+	}
+
+	return fs.NewListDirStream(entries), syscall.F_OK
+}
+
+func (d *ClassMethodLocationDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name != "codeIndex" {
+		return nil, syscall.ENOENT
+	}
+
+	codeIndexFile := d.NewInode(
+		ctx,
+		&fs.MemRegularFile {
+			Data: []byte(newlineTerminated(strconv.FormatUint(d.CodeIndex, 10))),
+			Attr: staticAttr(d.Options.fileMode()),
+		},
+		fs.StableAttr {
+			Mode: fuse.S_IFREG,
+		})
+	return codeIndexFile, syscall.F_OK
+}
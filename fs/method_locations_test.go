@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// lineTableHandler gives method 7 lines 12 and 13, line 12 spanning two
+// ranges; other methods have no line table
+func lineTableHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	if set != 6 || cmd != 1 {
+		return 0, nil
+	}
+	if data[15] != 7 {
+		return uint16(jdwp.ErrAbsentInformation), nil
+	}
+
+	return 0, fakeConcat(fakeLong(0), fakeLong(20), fakeInt(3),
+		fakeLong(4), fakeInt(12),
+		fakeLong(9), fakeInt(13),
+		fakeLong(14), fakeInt(12))
+}
+
+func TestMethodLocations(t *testing.T) {
+	var tests = []struct {
+		name string
+		method jdwp.MethodID
+		listed []string
+		lookups map[string]string
+	}{
+		{
+			name: "with lines",
+			method: 7,
+			listed: []string { "line=12", "line=13" },
+			lookups: map[string]string {
+				"line=12": "4\n",
+				"line=13": "9\n",
+				"line=14": "",
+				"line=0": "",
+				"codeIndex": "",
+			},
+		},
+		{
+			name: "without a line table",
+			method: 8,
+			listed: []string{},
+			lookups: map[string]string {
+				"line=12": "",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, lineTableHandler)
+			locationsDir := NewClassMethodLocationsDir(conn, jdwp.ReferenceTypeID(42), test.method, JdwpFsOptions{})
+			fs.NewNodeFS(&locationsDir, &fs.Options{})
+
+			if listed := listNames(t, &locationsDir); !reflect.DeepEqual(listed, test.listed) {
+				t.Fatalf("listed %v, expected %v", listed, test.listed)
+			}
+
+			for name, codeIndex := range test.lookups {
+				node, errno := locationsDir.Lookup(context.Background(), name, nil)
+				if codeIndex == "" {
+					if errno != syscall.ENOENT {
+						t.Fatalf("lookup %s: got %v, expected ENOENT", name, errno)
+					}
+					continue
+				}
+				if errno != syscall.F_OK {
+					t.Fatalf("lookup %s: %v", name, errno)
+				}
+
+				locationDir := node.Operations().(*ClassMethodLocationDir)
+				codeIndexNode, errno := locationDir.Lookup(context.Background(), "codeIndex", nil)
+				if errno != syscall.F_OK {
+					t.Fatalf("lookup %s/codeIndex: %v", name, errno)
+				}
+				if read := readNode(t, codeIndexNode.Operations().(fs.NodeReader), 0); read != codeIndex {
+					t.Fatalf("%s: read code index %q, expected %q", name, read, codeIndex)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCodeIndexSuffix(t *testing.T) {
+	var tests = []struct {
+		name string
+		codeIndex uint64
+		entry bool
+		found bool
+		ok bool
+	}{
+		{ name: "run", found: false, ok: true },
+		{ name: "run@42", codeIndex: 42, found: true, ok: true },
+		{ name: "@42", codeIndex: 42, found: true, ok: true },
+		{ name: "run@entry", entry: true, found: true, ok: true },
+		{ name: "run@", found: true, ok: false },
+		{ name: "run@-1", found: true, ok: false },
+		{ name: "run@0x2a", found: true, ok: false },
+	}
+
+	for _, test := range tests {
+		codeIndex, entry, found, ok := parseCodeIndexSuffix(test.name)
+		if codeIndex != test.codeIndex || entry != test.entry || found != test.found || ok != test.ok {
+			t.Errorf("%s: got %d, entry %v, found %v, ok %v", test.name, codeIndex, entry, found, ok)
+		}
+	}
+}
+
+// codeRangeHandler gives method 7 the code indices 10 to 50; other
+// methods have no line table
+func codeRangeHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	if set != 6 || cmd != 1 {
+		return 0, nil
+	}
+	if data[15] != 7 {
+		return uint16(jdwp.ErrAbsentInformation), nil
+	}
+
+	return 0, fakeConcat(fakeLong(10), fakeLong(50), fakeInt(1),
+		fakeLong(10), fakeInt(3))
+}
+
+func TestPickCodeIndex(t *testing.T) {
+	var method = debug.ModifierDescriptor { ClassId: 42, ObjectId: 7 }
+	var noLines = debug.ModifierDescriptor { ClassId: 42, ObjectId: 8 }
+	var atLine = debug.ModifierDescriptor { ClassId: 42, ObjectId: 7, CodeIndex: 10, Line: 3 }
+	var field = debug.ModifierDescriptor { ClassId: 42, ObjectId: 9, IsField: true }
+
+	var tests = []struct {
+		name string
+		modifier debug.ModifierDescriptor
+		errno syscall.Errno
+		codeIndex uint64
+	}{
+		{ name: "run", modifier: method, codeIndex: 0 },
+		{ name: "run@42", modifier: method, codeIndex: 42 },
+		{ name: "run@10", modifier: method, codeIndex: 10 },
+		{ name: "run@50", modifier: method, codeIndex: 50 },
+		{ name: "run@entry", modifier: method, codeIndex: 10 },
+		{ name: "run@51", modifier: method, errno: syscall.ERANGE },
+		{ name: "run@4", modifier: method, errno: syscall.ERANGE },
+		{ name: "run@x", modifier: method, errno: syscall.EINVAL },
+		{ name: "run@42", modifier: atLine, errno: syscall.EINVAL, codeIndex: 10 },
+		{ name: "count@42", modifier: field, errno: syscall.EINVAL },
+		{ name: "run@42", modifier: noLines, errno: syscall.ENOENT },
+	}
+
+	for _, test := range tests {
+		conn, _ := startFakeVM(t, codeRangeHandler)
+		locationDir := NewEventLocationDirectory(debug.NewStubDebuggingEvent("locations"), conn, "/mnt", JdwpFsOptions{})
+
+		modifier := test.modifier
+		if errno := locationDir.pickCodeIndex(test.name, &modifier); errno != test.errno {
+			t.Errorf("%s: got %v, expected %v", test.name, errno, test.errno)
+			continue
+		}
+		if modifier.CodeIndex != test.codeIndex {
+			t.Errorf("%s: picked code index %d, expected %d", test.name, modifier.CodeIndex, test.codeIndex)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"fmt"
+)
+
+const (
+	defaultFileMode uint32 = 0444
+	defaultDirMode uint32 = 0755
+	defaultControlMode uint32 = 0660
+)
+
+// ValidateModes checks the modes leave the owner able to use the files:
+// read the files, list the directories, and read and write the control
+// files; read only files can't be made writable
+func ValidateModes(file uint32, dir uint32, control uint32) error {
+	for _, mode := range []uint32 { file, dir, control } {
+		if mode &^ 0777 != 0 {
+			return fmt.Errorf("mode %#o has bits besides the permissions", mode)
+		}
+	}
+
+	if file & 0400 == 0 || file & 0222 != 0 {
+		return fmt.Errorf("file mode %#o must be readable by the owner, and not writable", file)
+	}
+
+	if dir & 0500 != 0500 {
+		return fmt.Errorf("directory mode %#o must be readable and searchable by the owner", dir)
+	}
+
+	if control & 0600 != 0600 {
+		return fmt.Errorf("control mode %#o must be readable and writable by the owner", control)
+	}
+
+	return nil
+}
+
+//
+// Node modes
+// Read only files and links, directories, and the files taking
+// writes, as set in the options of the mount the nodes are made for;
+// options leaving a mode unset, as the ones of nodes made on their own
+// do, give the default one
+//
+func (o JdwpFsOptions) fileMode() uint32 {
+	if o.FileMode == 0 {
+		return defaultFileMode
+	}
+
+	return o.FileMode
+}
+
+func (o JdwpFsOptions) dirMode() uint32 {
+	if o.DirMode == 0 {
+		return defaultDirMode
+	}
+
+	return o.DirMode
+}
+
+func (o JdwpFsOptions) controlMode() uint32 {
+	if o.ControlMode == 0 {
+		return defaultControlMode
+	}
+
+	return o.ControlMode
+}
+
+// writableFileMode is the mode of a read only file which can be written
+// to, but isn't a control file
+func (o JdwpFsOptions) writableFileMode() uint32 {
+	return o.fileMode() | 0200
+}
+
+// triggerMode is the mode of a control file which can't be read
+func (o JdwpFsOptions) triggerMode() uint32 {
+	return o.controlMode() &^ 0444
+}
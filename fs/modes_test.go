@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestModesGetattr(t *testing.T) {
+	var tests = []struct {
+		name string
+		file uint32
+		dir uint32
+		control uint32
+		fails bool
+	}{
+		{ name: "defaults", file: 0444, dir: 0755, control: 0660 },
+		{ name: "owner only", file: 0400, dir: 0700, control: 0600 },
+		{ name: "group writable", file: 0440, dir: 0750, control: 0660 },
+		{ name: "writable files", file: 0644, dir: 0755, control: 0660, fails: true },
+		{ name: "unsearchable directories", file: 0444, dir: 0644, control: 0660, fails: true },
+		{ name: "unwritable control files", file: 0444, dir: 0755, control: 0440, fails: true },
+		{ name: "setuid control files", file: 0444, dir: 0755, control: 04660, fails: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := DefaultJdwpFsOptions()
+			options.FileMode = test.file
+			options.DirMode = test.dir
+			options.ControlMode = test.control
+			err := ValidateModes(options.FileMode, options.DirMode, options.ControlMode)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			infoFile := NewInfoFile(nil, options)
+			triggerFile := NewTriggerFile(nil, options)
+			clearFile := NewClearEventsFile(nil, nil, options)
+			var nodes = []struct {
+				name string
+				node fs.NodeGetattrer
+				mode uint32
+			}{
+				{ name: "info file", node: &infoFile, mode: test.file },
+				{ name: "directory", node: &JdwpClassMasterDir{ Options: options }, mode: test.dir },
+				{ name: "control file", node: &clearFile, mode: test.control },
+				{ name: "trigger file", node: &triggerFile, mode: test.control &^ 0444 },
+			}
+			for _, node := range nodes {
+				var out fuse.AttrOut
+				if errno := node.node.Getattr(context.Background(), nil, &out); errno != syscall.F_OK {
+					t.Fatalf("%s: getattr: %v", node.name, errno)
+				}
+				if out.Mode != node.mode {
+					t.Fatalf("%s: got mode %#o, expected %#o", node.name, out.Mode, node.mode)
+				}
+			}
+		})
+	}
+}
+
+// TestModesUnset checks the nodes made without the options of a mount
+// get the default modes
+func TestModesUnset(t *testing.T) {
+	infoFile := NewInfoFile(nil, JdwpFsOptions{})
+	clearFile := NewClearEventsFile(nil, nil, JdwpFsOptions{})
+	var nodes = []struct {
+		name string
+		node fs.NodeGetattrer
+		mode uint32
+	}{
+		{ name: "info file", node: &infoFile, mode: 0444 },
+		{ name: "directory", node: &JdwpClassMasterDir{}, mode: 0755 },
+		{ name: "control file", node: &clearFile, mode: 0660 },
+	}
+
+	for _, node := range nodes {
+		t.Run(node.name, func(t *testing.T) {
+			var out fuse.AttrOut
+			if errno := node.node.Getattr(context.Background(), nil, &out); errno != syscall.F_OK {
+				t.Fatalf("getattr: %v", errno)
+			}
+			if out.Mode != node.mode {
+				t.Fatalf("got mode %#o, expected %#o", out.Mode, node.mode)
+			}
+		})
+	}
+}
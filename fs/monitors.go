@@ -0,0 +1,336 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+// monitorUsages reads the monitors used by the suspended threads, the
+// only ones whose monitors can be read; ENOTSUP if the VM can't tell
+func monitorUsages(conn *debug.Connection) (map[jdwp.ObjectID]*debug.MonitorUsage, syscall.Errno) {
+	capabilities, err := conn.GetCapabilities()
+	if err != nil {
+		log.Printf("unable to get the VM capabilities: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	if !capabilities.Has("canGetOwnedMonitorInfo") ||
+		!capabilities.Has("canGetCurrentContendedMonitor") {
+		return nil, syscall.ENOTSUP
+	}
+
+	threads, err := conn.GetAllThreads()
+	if err != nil {
+		log.Printf("unable to read threads from the JVM: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	var suspended []jdwp.ThreadID
+	for _, thread := range threads {
+		_, suspendStatus, err := conn.GetThreadStatus(thread)
+		if err != nil {
+			// the thread may have died since it was listed
+			log.Printf("error getting status of thread %d: %s\n", thread, err)
+			continue
+		}
+
+		if suspendStatus != 0 {
+			suspended = append(suspended, thread)
+		}
+	}
+
+	usages, err := conn.GetMonitorUsages(suspended)
+	if err != nil {
+		log.Printf("unable to read the monitors: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	return usages, 0
+}
+
+// newThreadLink is a symlink to threads/<id>
+func newThreadLink(ctx context.Context, parent *fs.Inode, thread jdwp.ThreadID, options JdwpFsOptions) *fs.Inode {
+	symlinkPath := relativeLink(parent, "threads/" + options.formatId(uint64(thread)))
+
+	return parent.NewInode(
+		ctx,
+		&fs.MemSymlink {
+			Data: []byte(symlinkPath),
+			Attr: staticAttr(options.fileMode()),
+		},
+		fs.StableAttr{
+			Mode: fuse.S_IFLNK,
+		},
+	)
+}
+
+//
+// Jdwp monitor master directory
+// The monitors owned or waited for by the suspended threads, by object id
+//
+type JdwpMonitorMasterDir struct {
+	fs.Inode
+
+	JdwpContext context.Context
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*JdwpMonitorMasterDir)(nil))
+var _ = (fs.NodeReaddirer)((*JdwpMonitorMasterDir)(nil))
+var _ = (fs.NodeLookuper)((*JdwpMonitorMasterDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpMonitorMasterDir)(nil))
+
+func NewJdwpMonitorMasterDir(ctx context.Context, conn *debug.Connection, options JdwpFsOptions) (*JdwpMonitorMasterDir, error) {
+	newMonitorDir := &JdwpMonitorMasterDir {
+		JdwpContext: ctx,
+		JdwpConnection: conn,
+		Options: options,
+	}
+
+	return newMonitorDir, nil
+}
+
+func (d *JdwpMonitorMasterDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *JdwpMonitorMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *JdwpMonitorMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	usages, errno := monitorUsages(d.JdwpConnection)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	var entries = []fuse.DirEntry{}
+	for monitor := range usages {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: strconv.FormatUint(uint64(monitor), 10),
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *JdwpMonitorMasterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	monitorId, err := strconv.ParseUint(name, 10, 64)
+	if err != nil || monitorId == 0 {
+		return nil, syscall.ENOENT
+	}
+
+	usages, errno := monitorUsages(d.JdwpConnection)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	_, ok := usages[jdwp.ObjectID(monitorId)]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+
+	monitorDir := NewJdwpMonitorDir(d.JdwpConnection, jdwp.ObjectID(monitorId), d.Options)
+	monitorDirInode := d.NewInode(
+		ctx,
+		monitorDir,
+		fs.StableAttr{
+			Mode: fuse.S_IFDIR,
+		},
+	)
+
+	return monitorDirInode, syscall.F_OK
+}
+
+//
+// Jdwp monitor dir
+// Links to the thread owning the monitor, if a suspended one does, and
+// to the suspended threads waiting for it
+//
+type JdwpMonitorDir struct {
+	fs.Inode
+
+	MonitorId jdwp.ObjectID
+
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*JdwpMonitorDir)(nil))
+var _ = (fs.NodeReaddirer)((*JdwpMonitorDir)(nil))
+var _ = (fs.NodeLookuper)((*JdwpMonitorDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpMonitorDir)(nil))
+
+func NewJdwpMonitorDir(conn *debug.Connection, id jdwp.ObjectID, options JdwpFsOptions) *JdwpMonitorDir {
+	return &JdwpMonitorDir {
+		MonitorId: id,
+		JdwpConnection: conn,
+		Options: options,
+	}
+}
+
+// usage reads the monitor's usage again; a monitor no suspended thread
+// uses anymore has none
+func (d *JdwpMonitorDir) usage() (*debug.MonitorUsage, syscall.Errno) {
+	usages, errno := monitorUsages(d.JdwpConnection)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	usage, ok := usages[d.MonitorId]
+	if !ok {
+		return &debug.MonitorUsage{}, 0
+	}
+
+	return usage, 0
+}
+
+func (d *JdwpMonitorDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *JdwpMonitorDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *JdwpMonitorDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	usage, errno := d.usage()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	var entries = []fuse.DirEntry {
+		fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: "waiters",
+		},
+	}
+
+	if usage.Owner != 0 {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFLNK,
+			Name: "owner",
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *JdwpMonitorDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	switch name {
+	case "owner":
+		usage, errno := d.usage()
+		if errno != 0 {
+			return nil, errno
+		}
+
+		if usage.Owner == 0 {
+			return nil, syscall.ENOENT
+		}
+
+		return newThreadLink(ctx, &d.Inode, usage.Owner, d.Options), syscall.F_OK
+	case "waiters":
+		waitersDir := NewMonitorWaitersDir(d, d.Options)
+		waitersDirInode := d.NewInode(
+			ctx,
+			waitersDir,
+			fs.StableAttr{
+				Mode: fuse.S_IFDIR,
+			},
+		)
+		return waitersDirInode, syscall.F_OK
+	default:
+		return nil, syscall.ENOENT
+	}
+}
+
+//
+// Monitor waiters dir
+// Links to the suspended threads waiting for the monitor, by thread id
+//
+type MonitorWaitersDir struct {
+	fs.Inode
+
+	monitorDir *JdwpMonitorDir
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*MonitorWaitersDir)(nil))
+var _ = (fs.NodeReaddirer)((*MonitorWaitersDir)(nil))
+var _ = (fs.NodeLookuper)((*MonitorWaitersDir)(nil))
+var _ = (fs.NodeMkdirer)((*MonitorWaitersDir)(nil))
+
+func NewMonitorWaitersDir(monitorDir *JdwpMonitorDir, options JdwpFsOptions) *MonitorWaitersDir {
+	return &MonitorWaitersDir {
+		monitorDir: monitorDir,
+		Options: options,
+	}
+}
+
+func (d *MonitorWaitersDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *MonitorWaitersDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *MonitorWaitersDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	usage, errno := d.monitorDir.usage()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	var entries = []fuse.DirEntry{}
+	for _, waiter := range usage.Waiters {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFLNK,
+			Name: d.Options.formatId(uint64(waiter)),
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *MonitorWaitersDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	threadId, err := d.Options.parseId(name)
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+
+	usage, errno := d.monitorDir.usage()
+	if errno != 0 {
+		return nil, errno
+	}
+
+	for _, waiter := range usage.Waiters {
+		if waiter == jdwp.ThreadID(threadId) {
+			return newThreadLink(ctx, &d.Inode, waiter, d.Options), syscall.F_OK
+		}
+	}
+
+	return nil, syscall.ENOENT
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// monitorHandler has monitor 50 owned by thread 31 and waited for by
+// threads 32 and 33, all suspended; thread 34 runs
+func monitorHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 1 && cmd == 4:
+		return 0, fakeConcat(fakeInt(4), fakeLong(31), fakeLong(32), fakeLong(33), fakeLong(34))
+	case set == 11 && cmd == 4 && data[7] == 34:
+		return 0, fakeConcat(fakeInt(1), fakeInt(0))
+	case set == 11 && cmd == 4:
+		return 0, fakeConcat(fakeInt(3), fakeInt(1))
+	case set == 11 && cmd == 8 && data[7] == 31:
+		return 0, fakeConcat(fakeInt(1), []byte{'L'}, fakeLong(50))
+	case set == 11 && cmd == 8:
+		return 0, fakeInt(0)
+	case set == 11 && cmd == 9 && (data[7] == 32 || data[7] == 33):
+		return 0, fakeConcat([]byte{'L'}, fakeLong(50))
+	case set == 11 && cmd == 9:
+		return 0, fakeConcat([]byte{'L'}, fakeLong(0))
+	default:
+		return 0, nil
+	}
+}
+
+// linkTarget looks a symlink up in dir, and returns where it points to
+func linkTarget(t *testing.T, dir fs.NodeLookuper, name string) string {
+	t.Helper()
+
+	inode, errno := dir.Lookup(context.Background(), name, &fuse.EntryOut{})
+	if errno != syscall.F_OK {
+		t.Fatalf("lookup %s: %v", name, errno)
+	}
+	return string(inode.Operations().(*fs.MemSymlink).Data)
+}
+
+func TestMonitorDirs(t *testing.T) {
+	conn, vm := startFakeVM(t, monitorHandler)
+	vm.Answer(1, 17, 0, fakeCapabilities(4, 5))
+
+	// monitors, under the root of the mount
+	root := &fs.Inode{}
+	fs.NewNodeFS(root, &fs.Options{})
+	masterDir, _ := NewJdwpMonitorMasterDir(context.Background(), conn, JdwpFsOptions{})
+	root.AddChild("monitors", root.NewPersistentInode(context.Background(), masterDir, fs.StableAttr{ Mode: fuse.S_IFDIR }), false)
+
+	if listed := listNames(t, masterDir); !reflect.DeepEqual(listed, []string { "50" }) {
+		t.Fatalf("listed monitors %v", listed)
+	}
+	if _, errno := masterDir.Lookup(context.Background(), "51", &fuse.EntryOut{}); errno != syscall.ENOENT {
+		t.Fatalf("lookup of an unused monitor: %v", errno)
+	}
+
+	monitorInode, errno := masterDir.Lookup(context.Background(), "50", &fuse.EntryOut{})
+	if errno != syscall.F_OK {
+		t.Fatalf("lookup 50: %v", errno)
+	}
+	masterDir.AddChild("50", monitorInode, false)
+	monitorDir := monitorInode.Operations().(*JdwpMonitorDir)
+
+	if listed := listNames(t, monitorDir); !reflect.DeepEqual(listed, []string { "waiters", "owner" }) {
+		t.Fatalf("listed %v in the monitor", listed)
+	}
+	if owner := linkTarget(t, monitorDir, "owner"); owner != "../../threads/31" {
+		t.Fatalf("the owner links to %s", owner)
+	}
+
+	waitersInode, errno := monitorDir.Lookup(context.Background(), "waiters", &fuse.EntryOut{})
+	if errno != syscall.F_OK {
+		t.Fatalf("lookup waiters: %v", errno)
+	}
+	monitorInode.AddChild("waiters", waitersInode, false)
+	waitersDir := waitersInode.Operations().(*MonitorWaitersDir)
+
+	if listed := listNames(t, waitersDir); !reflect.DeepEqual(listed, []string { "32", "33" }) {
+		t.Fatalf("listed waiters %v", listed)
+	}
+	for _, waiter := range []string { "32", "33" } {
+		if target := linkTarget(t, waitersDir, waiter); target != "../../../threads/" + waiter {
+			t.Fatalf("waiter %s links to %s", waiter, target)
+		}
+	}
+	if _, errno := waitersDir.Lookup(context.Background(), "31", &fuse.EntryOut{}); errno != syscall.ENOENT {
+		t.Fatalf("lookup of the owner as a waiter: %v", errno)
+	}
+}
+
+func TestMonitorsUnsupported(t *testing.T) {
+	conn, _ := startFakeVM(t, monitorHandler)
+	masterDir, _ := NewJdwpMonitorMasterDir(context.Background(), conn, JdwpFsOptions{})
+
+	if _, errno := masterDir.Readdir(context.Background()); errno != syscall.ENOTSUP {
+		t.Fatalf("got %v, expected ENOTSUP", errno)
+	}
+}
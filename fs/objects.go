@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Jdwp object master directory
+// Objects can't be listed, only looked up by their id; the ones kept
+// for the suspending events are listed, and described in inspected
+//
+type JdwpObjectMasterDir struct {
+	fs.Inode
+
+	JdwpContext context.Context
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*JdwpObjectMasterDir)(nil))
+var _ = (fs.NodeReaddirer)((*JdwpObjectMasterDir)(nil))
+var _ = (fs.NodeLookuper)((*JdwpObjectMasterDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpObjectMasterDir)(nil))
+
+func NewJdwpObjectMasterDir(ctx context.Context, conn *debug.Connection, options JdwpFsOptions) (*JdwpObjectMasterDir, error) {
+	newObjectDir := &JdwpObjectMasterDir {
+		JdwpContext: ctx,
+		JdwpConnection: conn,
+		Options: options,
+	}
+
+	return newObjectDir, nil
+}
+
+func (d *JdwpObjectMasterDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *JdwpObjectMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *JdwpObjectMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry {
+		fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "inspected",
+		},
+	}
+
+	for _, object := range d.JdwpConnection.GetInspectedObjects() {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: strconv.FormatUint(uint64(object.Id), 10),
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *JdwpObjectMasterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == "inspected" {
+		inspectedFile := NewInfoFile(d.readInspected, d.Options)
+		inspectedInode := d.NewInode(
+			ctx,
+			&inspectedFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return inspectedInode, syscall.F_OK
+	}
+
+	objectId, err := strconv.ParseUint(name, 10, 64)
+	if err != nil || objectId == 0 {
+		return nil, syscall.ENOENT
+	}
+
+	_, err = d.JdwpConnection.GetObjectType(jdwp.ObjectID(objectId))
+	if err != nil {
+		log.Printf("could not access object with id %d: %s\n", objectId, err)
+		return nil, syscall.ENOENT
+	}
+
+	objectDir := NewJdwpObjectDir(d.JdwpContext, d.JdwpConnection, jdwp.ObjectID(objectId), d.Options)
+	objectDirInode := d.NewInode(
+		ctx,
+		objectDir,
+		fs.StableAttr{
+			Mode: fuse.S_IFDIR,
+		},
+	)
+
+	return objectDirInode, syscall.F_OK
+}
+
+// readInspected lists the objects kept for the suspending events, one
+// per line: the object, the thread, the event, and this or the argument
+func (d *JdwpObjectMasterDir) readInspected() ([]byte, syscall.Errno) {
+	var builder strings.Builder
+	for _, object := range d.JdwpConnection.GetInspectedObjects() {
+		fmt.Fprintf(&builder, "%d\t%s\t%s\t%s\n",
+			object.Id, d.Options.formatId(uint64(object.Thread)), object.Event, object.Role)
+	}
+
+	return []byte(builder.String()), 0
+}
+
+//
+// Jdwp object dir
+// The fields of an object and its type; its identity hash code as well
+// if invoking is allowed
+//
+type JdwpObjectDir struct {
+	fs.Inode
+
+	ObjectId jdwp.ObjectID
+
+	JdwpContext context.Context
+	JdwpConnection *debug.Connection
+
+	allowInvoke bool
+	values valueRenderer
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*JdwpObjectDir)(nil))
+var _ = (fs.NodeReaddirer)((*JdwpObjectDir)(nil))
+var _ = (fs.NodeLookuper)((*JdwpObjectDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpObjectDir)(nil))
+
+func NewJdwpObjectDir(ctx context.Context, conn *debug.Connection, id jdwp.ObjectID, options JdwpFsOptions) *JdwpObjectDir {
+	return &JdwpObjectDir {
+		ObjectId: id,
+		JdwpContext: ctx,
+		JdwpConnection: conn,
+		allowInvoke: options.AllowInvoke,
+		values: newValueRenderer(conn, options),
+		Options: options,
+	}
+}
+
+func (d *JdwpObjectDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *JdwpObjectDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *JdwpObjectDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry {
+		fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: "fields",
+		},
+		fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "type",
+		},
+	}
+
+	if d.allowInvoke {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: "identityHashCode",
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *JdwpObjectDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	switch name {
+	case "fields":
+		fieldsDir := NewObjectFieldsDir(d.JdwpConnection, d.ObjectId, d.values, d.Options)
+		fieldsDirInode := d.NewInode(
+			ctx,
+			fieldsDir,
+			fs.StableAttr{
+				Mode: fuse.S_IFDIR,
+			},
+		)
+		return fieldsDirInode, syscall.F_OK
+	case "type":
+		typeFile := NewInfoFile(d.readType, d.Options)
+		typeFileInode := d.NewInode(
+			ctx,
+			&typeFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return typeFileInode, syscall.F_OK
+	case "identityHashCode":
+		if !d.allowInvoke {
+			return nil, syscall.ENOENT
+		}
+
+		hashCodeFile := NewInfoFile(d.readIdentityHashCode, d.Options)
+		hashCodeFileInode := d.NewInode(
+			ctx,
+			&hashCodeFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+		return hashCodeFileInode, syscall.F_OK
+	default:
+		return nil, syscall.ENOENT
+	}
+}
+
+// readType gives the signature of the object's reference type
+func (d *JdwpObjectDir) readType() ([]byte, syscall.Errno) {
+	objectType, err := d.JdwpConnection.GetObjectType(d.ObjectId)
+	if err != nil {
+		log.Printf("error getting type of object %d: %s\n", d.ObjectId, err)
+		return nil, syscall.EFAULT
+	}
+
+	signature, err := d.JdwpConnection.GetTypeSignature(objectType.Type)
+	if err != nil {
+		log.Printf("error getting signature of type %d: %s\n", objectType.Type, err)
+		return nil, syscall.EFAULT
+	}
+
+	return []byte(newlineTerminated(signature)), 0
+}
+
+// readIdentityHashCode invokes System.identityHashCode on the first
+// suspended thread it can; with none suspended, it fails with EBUSY
+func (d *JdwpObjectDir) readIdentityHashCode() ([]byte, syscall.Errno) {
+	errno := d.Options.checkPolicy(PolicyMethodInvoke)
+	if errno != syscall.F_OK {
+		return nil, errno
+	}
+
+	threads, err := suspendedThreads(d.JdwpConnection)
+	if err != nil {
+		log.Printf("unable to read threads from the JVM: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	for _, thread := range threads {
+		// only threads suspended by an event can invoke
+		hashCode, err := d.JdwpConnection.IdentityHashCode(d.ObjectId, thread)
+		if err != nil {
+			log.Printf("error invoking identityHashCode on thread %d: %s\n", thread, err)
+			continue
+		}
+
+		return []byte(newlineTerminated(strconv.FormatInt(int64(hashCode), 10))), 0
+	}
+
+	if len(threads) == 0 {
+		return nil, syscall.EBUSY
+	}
+
+	return nil, syscall.EFAULT
+}
+
+//
+// Object fields dir
+// The field values of an object, by field name
+//
+type ObjectFieldsDir struct {
+	fs.Inode
+
+	ObjectId jdwp.ObjectID
+	JdwpConnection *debug.Connection
+
+	values valueRenderer
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ObjectFieldsDir)(nil))
+var _ = (fs.NodeReaddirer)((*ObjectFieldsDir)(nil))
+var _ = (fs.NodeLookuper)((*ObjectFieldsDir)(nil))
+var _ = (fs.NodeMkdirer)((*ObjectFieldsDir)(nil))
+
+func NewObjectFieldsDir(conn *debug.Connection, id jdwp.ObjectID, values valueRenderer, options JdwpFsOptions) *ObjectFieldsDir {
+	return &ObjectFieldsDir {
+		ObjectId: id,
+		JdwpConnection: conn,
+		values: values,
+		Options: options,
+	}
+}
+
+func (d *ObjectFieldsDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *ObjectFieldsDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *ObjectFieldsDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	fields, err := d.JdwpConnection.GetObjectFields(d.ObjectId)
+	if err != nil {
+		log.Printf("error getting fields of object %d: %s\n", d.ObjectId, err)
+		return nil, syscall.EFAULT
+	}
+
+	var entries = []fuse.DirEntry{}
+	for name := range fields {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: name,
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *ObjectFieldsDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	fields, err := d.JdwpConnection.GetObjectFields(d.ObjectId)
+	if err != nil {
+		log.Printf("error getting fields of object %d: %s\n", d.ObjectId, err)
+		return nil, syscall.EFAULT
+	}
+
+	field, ok := fields[name]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+
+	valueFile := NewInfoFile(func() ([]byte, syscall.Errno) {
+		values, err := d.JdwpConnection.GetFieldValues(d.ObjectId, field.Field.ID)
+		if err != nil || len(values) != 1 {
+			log.Printf("error getting field %s of object %d: %s\n", name, d.ObjectId, err)
+			return nil, syscall.EFAULT
+		}
+
+		return []byte(newlineTerminated(d.values.render(values[0]))), 0
+	}, d.Options)
+	valueFileInode := d.NewInode(
+		ctx,
+		&valueFile,
+		fs.StableAttr{
+			Mode: fuse.S_IFREG,
+		},
+	)
+
+	return valueFileInode, syscall.F_OK
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// objectFieldsHandler has object 42 of class 100, declaring count and
+// size, extending class 200, declaring count again and base; a field
+// value is ten times its id
+func objectFieldsHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	var fields = map[byte][]byte {
+		100: fakeConcat(fakeInt(2),
+			fakeLong(1), fakeString("count"), fakeString("I"), fakeInt(0),
+			fakeLong(2), fakeString("size"), fakeString("I"), fakeInt(0)),
+		200: fakeConcat(fakeInt(2),
+			fakeLong(3), fakeString("count"), fakeString("I"), fakeInt(0),
+			fakeLong(4), fakeString("base"), fakeString("I"), fakeInt(0)),
+	}
+	var superclasses = map[byte]uint64 { 100: 200, 200: 0 }
+
+	switch {
+	case set == 9 && cmd == 1:
+		return 0, fakeConcat([]byte{ byte(jdwp.Class) }, fakeLong(100))
+	case set == 2 && cmd == 4:
+		return 0, fields[data[7]]
+	case set == 3 && cmd == 1:
+		return 0, fakeLong(superclasses[data[7]])
+	case set == 9 && cmd == 2:
+		return 0, fakeConcat(fakeInt(1), []byte{'I'}, fakeInt(uint32(data[19]) * 10))
+	default:
+		return 0, nil
+	}
+}
+
+func TestObjectFieldsDir(t *testing.T) {
+	var tests = []struct {
+		name string
+		errno syscall.Errno
+		value string
+	}{
+		{ name: "count", value: "10\n" },
+		{ name: "size", value: "20\n" },
+		{ name: "base", value: "40\n" },
+		{ name: "missing", errno: syscall.ENOENT },
+	}
+
+	conn, _ := startFakeVM(t, objectFieldsHandler)
+	dir := NewObjectFieldsDir(conn, jdwp.ObjectID(42), valueRenderer{}, JdwpFsOptions{})
+	fs.NewNodeFS(dir, &fs.Options{})
+
+	names := listNames(t, dir)
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string { "base", "count", "size" }) {
+		t.Fatalf("listed %v", names)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, errno := dir.Lookup(context.Background(), test.name, nil)
+			if errno != test.errno {
+				t.Fatalf("lookup: got %v, expected %v", errno, test.errno)
+			}
+			if errno != syscall.F_OK {
+				return
+			}
+
+			if value := readNode(t, node.Operations().(fs.NodeReader), 0); value != test.value {
+				t.Fatalf("read %q, expected %q", value, test.value)
+			}
+		})
+	}
+}
+
+// objectTypeHandler has object 42 of class com.example.Main, object 43
+// an int array, and object 44 collected
+func objectTypeHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 9 && cmd == 1 && data[7] == 42:
+		return 0, fakeConcat([]byte{ byte(jdwp.Class) }, fakeLong(100))
+	case set == 9 && cmd == 1 && data[7] == 43:
+		return 0, fakeConcat([]byte{ byte(jdwp.Array) }, fakeLong(101))
+	case set == 9 && cmd == 1:
+		return uint16(jdwp.ErrInvalidObject), nil
+	case set == 2 && cmd == 1 && data[7] == 100:
+		return 0, fakeString("Lcom/example/Main;")
+	case set == 2 && cmd == 1:
+		return 0, fakeString("[I")
+	default:
+		return 0, nil
+	}
+}
+
+func TestObjectType(t *testing.T) {
+	var tests = []struct {
+		name string
+		objectId jdwp.ObjectID
+		errno syscall.Errno
+		signature string
+	}{
+		{ name: "class instance", objectId: 42, signature: "Lcom/example/Main;\n" },
+		{ name: "array", objectId: 43, signature: "[I\n" },
+		{ name: "collected", objectId: 44, errno: syscall.EFAULT },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, objectTypeHandler)
+			objectDir := NewJdwpObjectDir(context.Background(), conn, test.objectId, JdwpFsOptions{})
+
+			signature, errno := objectDir.readType()
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if string(signature) != test.signature {
+				t.Fatalf("read %q, expected %q", signature, test.signature)
+			}
+		})
+	}
+}
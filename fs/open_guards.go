@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"syscall"
+)
+
+//
+// Open guards
+// A file node opened as a directory, e.g. through a stale handle, fails
+// with ENOTDIR; directory nodes have no Open, so the reverse fails too
+//
+func checkFileOpen(flags uint32) syscall.Errno {
+	if flags & syscall.O_DIRECTORY != 0 {
+		return syscall.ENOTDIR
+	}
+
+	return syscall.F_OK
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+// fileNode is a file node which can be asked to open as a directory
+type fileNode interface {
+	fs.NodeOpener
+	fs.NodeOpendirer
+}
+
+func TestOpenFileAsDirectory(t *testing.T) {
+	event := debug.NewStubDebuggingEvent("opened")
+	eventControlFile := NewEventControlFile(event, JdwpFsOptions{})
+	// the threads control reads the statuses of the threads when opened
+	conn, _ := startFakeVM(t, suspendStatusHandler(nil))
+	threadsControlFile := NewThreadMasterControlFile(context.Background(), conn, JdwpFsOptions{})
+	threadControlFile := NewThreadControlFile(context.Background(), nil, 7, JdwpFsOptions{})
+	threadSelectFile := NewThreadSelectFile(nil, JdwpFsOptions{})
+	clearEventsFile := NewClearEventsFile(nil, nil, JdwpFsOptions{})
+	importFile := NewEventsImportFile(nil, JdwpFsOptions{})
+	infoFile := NewInfoFile(nil, JdwpFsOptions{})
+	triggerFile := NewTriggerFile(nil, JdwpFsOptions{})
+
+	var tests = []struct {
+		name string
+		node fileNode
+	}{
+		{ name: "event control", node: &eventControlFile },
+		{ name: "threads control", node: &threadsControlFile },
+		{ name: "thread control", node: &threadControlFile },
+		{ name: "threads select", node: &threadSelectFile },
+		{ name: "clear_events", node: &clearEventsFile },
+		{ name: "events import", node: &importFile },
+		{ name: "info file", node: &infoFile },
+		{ name: "trigger file", node: &triggerFile },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, errno := test.node.Open(context.Background(), syscall.O_RDONLY | syscall.O_DIRECTORY)
+			if errno != syscall.ENOTDIR {
+				t.Fatalf("open with O_DIRECTORY: got %v, expected ENOTDIR", errno)
+			}
+			if errno := test.node.Opendir(context.Background()); errno != syscall.ENOTDIR {
+				t.Fatalf("opendir: got %v, expected ENOTDIR", errno)
+			}
+
+			// opened as a file, it isn't refused as one
+			if _, _, errno := test.node.Open(context.Background(), syscall.O_RDONLY); errno == syscall.ENOTDIR {
+				t.Fatalf("open without O_DIRECTORY: got ENOTDIR")
+			}
+		})
+	}
+}
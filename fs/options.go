@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"time"
+)
+
+//
+// Filesystem options
+// The tunables of the filesystem, as set from the command line
+//
+type JdwpFsOptions struct {
+	// how many times the initial dial and handshake are retried
+	ConnectRetry int
+	ConnectRetryInterval time.Duration
+
+	// globs over class signatures, trimming the classes trees
+	ClassInclude []string
+	ClassExclude []string
+
+	// signature prefixes of the classes whose methods and fields are
+	// fetched in the background after mounting, at most
+	// ClassPrefetchRate classes a second, 0 for no limit
+	ClassPrefetch []string
+	ClassPrefetchRate int
+
+	// entries listed in classes and classes_by_signature, 0 for no
+	// limit; the others can still be looked up
+	MaxDirEntries int
+
+	// allows running code inside the VM, e.g. renaming threads
+	AllowInvoke bool
+
+	// renders the objects read from the VM through their toString,
+	// rather than as their id; it needs AllowInvoke
+	ValueToString bool
+
+	// errors which would otherwise be logged and skipped are fatal,
+	// or returned to the caller
+	Strict bool
+
+	// JDWP calls in flight at once, 0 for no limit
+	MaxConcurrentJdwp int
+
+	// dials the VM on the first access needing it, rather than when
+	// mounting
+	ConnectOnDemand bool
+
+	// closes the connection once the VM wasn't used for this long, and
+	// opens it again when it's needed; 0 keeps it open
+	IdleDisconnect time.Duration
+
+	// only uses the legacy JDWP commands, for older VMs whose JDWP
+	// version can't be relied on
+	Compat bool
+
+	// retries of the listings and the names read from the VM
+	ReadRetries int
+
+	// subtrees left out of the mount
+	NoThreads bool
+	NoClasses bool
+	NoEvents bool
+
+	// resumes the threads suspended through jdwpfs once nothing
+	// inspected them for AutoResumeTimeout
+	AutoResume bool
+	AutoResumeTimeout time.Duration
+
+	// keeps the this object and the object arguments of the frames
+	// the suspending events fire in, under objects, until their thread
+	// is resumed
+	SuspendOnEventInspect bool
+
+	// the fired events kept in the log and the stream of each event
+	EventBufferSize int
+
+	// a file of event definitions, created at mount
+	EventsImport string
+
+	// runs the running events again after a reconnection, with their
+	// classes, methods and fields found by name in the new session
+	ReconnectPreserveEvents bool
+
+	// a file of the operations the mount allows and denies
+	Policy string
+	// the policy read from it, when the mount is created
+	policy *Policy
+
+	// resumes the VM after connecting, if it was started suspended
+	ResumeOnMount bool
+
+	// radix of the thread and class ids in paths: dec, or hex
+	IdRadix string
+
+	// permissions of the read only files and links, of the directories,
+	// and of the files taking writes
+	FileMode uint32
+	DirMode uint32
+	ControlMode uint32
+}
+
+func DefaultJdwpFsOptions() JdwpFsOptions {
+	return JdwpFsOptions {
+		ConnectRetry: 0,
+		ConnectRetryInterval: time.Second,
+		ReadRetries: 1,
+		ClassPrefetchRate: 10,
+		IdRadix: "dec",
+		FileMode: defaultFileMode,
+		DirMode: defaultDirMode,
+		ControlMode: defaultControlMode,
+		AutoResumeTimeout: 5 * time.Minute,
+		EventBufferSize: 1024,
+	}
+}
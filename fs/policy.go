@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// the operations a policy can allow or deny
+const (
+	PolicyThreadSuspend = "thread.suspend"
+	PolicyMethodInvoke = "method.invoke"
+	PolicyEventCreate = "event.create"
+	PolicyEventRun = "event.run"
+	PolicyClassRedefine = "class.redefine"
+	PolicyFrameSet = "frame.set"
+	PolicyFieldSet = "field.set"
+)
+
+var policyCapabilities = []string {
+	PolicyThreadSuspend,
+	PolicyMethodInvoke,
+	PolicyEventCreate,
+	PolicyEventRun,
+	PolicyClassRedefine,
+	PolicyFrameSet,
+	PolicyFieldSet,
+}
+
+//
+// Policy
+// The operations a mount permits, as read from the --policy file; a
+// line allows or denies one, `default allow` or `default deny` decides
+// the ones left out, and # starts a comment. Without a file, or without
+// a default, everything not denied is allowed
+//
+type Policy struct {
+	defaultAllow bool
+	rules map[string]bool
+}
+
+func NewPolicy() *Policy {
+	return &Policy {
+		defaultAllow: true,
+		rules: map[string]bool{},
+	}
+}
+
+// ParsePolicy reads a policy, failing on unknown operations, so a typo
+// doesn't leave an operation allowed
+func ParsePolicy(text string) (*Policy, error) {
+	policy := NewPolicy()
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+
+		tokens := strings.Fields(line)
+		if len(tokens) == 0 {
+			continue
+		}
+		if len(tokens) != 2 {
+			return nil, fmt.Errorf("line %d: expected `allow|deny <operation>` or `default allow|deny`", lineNumber)
+		}
+
+		var allow bool
+		switch tokens[0] {
+		case "allow":
+			allow = true
+		case "deny":
+			allow = false
+		case "default":
+			switch tokens[1] {
+			case "allow":
+				policy.defaultAllow = true
+			case "deny":
+				policy.defaultAllow = false
+			default:
+				return nil, fmt.Errorf("line %d: unknown default %s", lineNumber, tokens[1])
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("line %d: unknown rule %s", lineNumber, tokens[0])
+		}
+
+		if !isPolicyCapability(tokens[1]) {
+			return nil, fmt.Errorf("line %d: unknown operation %s, expected one of %s",
+				lineNumber, tokens[1], strings.Join(policyCapabilities, ", "))
+		}
+		policy.rules[tokens[1]] = allow
+	}
+
+	return policy, nil
+}
+
+func LoadPolicy(path string) (*Policy, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePolicy(string(text))
+}
+
+func isPolicyCapability(name string) bool {
+	for _, capability := range policyCapabilities {
+		if capability == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows tells if the operation is permitted; a nil policy permits all
+func (p *Policy) Allows(capability string) bool {
+	if p == nil {
+		return true
+	}
+
+	allow, ok := p.rules[capability]
+	if !ok {
+		return p.defaultAllow
+	}
+
+	return allow
+}
+
+//
+// Mount policy
+// The policy of the mount, loaded from the options when the filesystem
+// is created, and carried with them to the nodes
+//
+func loadPolicy(options *JdwpFsOptions) error {
+	if options.Policy == "" {
+		options.policy = nil
+		return nil
+	}
+
+	loaded, err := LoadPolicy(options.Policy)
+	if err != nil {
+		return fmt.Errorf("unable to load the policy %s: %s", options.Policy, err)
+	}
+	options.policy = loaded
+
+	return nil
+}
+
+// checkPolicy is EPERM when the mount's policy denies the operation
+func (o JdwpFsOptions) checkPolicy(capability string) syscall.Errno {
+	if !o.policy.Allows(capability) {
+		return syscall.EPERM
+	}
+
+	return syscall.F_OK
+}
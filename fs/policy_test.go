@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+func TestParsePolicy(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		fails bool
+		allowed map[string]bool
+	}{
+		{
+			name: "empty",
+			text: "",
+			allowed: map[string]bool { PolicyThreadSuspend: true, PolicyMethodInvoke: true },
+		},
+		{
+			name: "deny",
+			text: "# read only threads\ndeny thread.suspend\n",
+			allowed: map[string]bool { PolicyThreadSuspend: false, PolicyEventCreate: true },
+		},
+		{
+			name: "default deny",
+			text: "default deny\nallow event.create # but nothing else\n",
+			allowed: map[string]bool {
+				PolicyThreadSuspend: false,
+				PolicyMethodInvoke: false,
+				PolicyEventCreate: true,
+			},
+		},
+		{ name: "unknown operation", text: "deny thread.kill\n", fails: true },
+		{ name: "unknown rule", text: "forbid thread.suspend\n", fails: true },
+		{ name: "unknown default", text: "default maybe\n", fails: true },
+		{ name: "missing operation", text: "deny\n", fails: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := ParsePolicy(test.text)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			for capability, allowed := range test.allowed {
+				if policy.Allows(capability) != allowed {
+					t.Fatalf("%s allowed: %v, expected %v", capability, !allowed, allowed)
+				}
+			}
+		})
+	}
+}
+
+// usePolicy gives the options of a mount loading a policy file
+func usePolicy(t *testing.T, text string) JdwpFsOptions {
+	t.Helper()
+
+	var options JdwpFsOptions
+	if text != "" {
+		options.Policy = filepath.Join(t.TempDir(), "policy")
+		if err := os.WriteFile(options.Policy, []byte(text), 0644); err != nil {
+			t.Fatalf("unable to write the policy: %s", err)
+		}
+	}
+	if err := loadPolicy(&options); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	return options
+}
+
+func TestPolicyDeniesSuspend(t *testing.T) {
+	var tests = []struct {
+		name string
+		policy string
+		errno syscall.Errno
+	}{
+		{ name: "no policy", policy: "", errno: syscall.F_OK },
+		{ name: "thread control denied", policy: "deny thread.suspend\n", errno: syscall.EPERM },
+		{ name: "only events allowed", policy: "default deny\nallow event.create\n", errno: syscall.EPERM },
+		{ name: "thread control allowed", policy: "default deny\nallow thread.suspend\n", errno: syscall.F_OK },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := usePolicy(t, test.policy)
+			conn, vm := startFakeVM(t, suspendCountHandler(0))
+			vm.Answer(11, 4, 0, fakeConcat(fakeInt(uint32(jdwp.ThreadRunning)), fakeInt(0)))
+			suspendedFile := NewThreadSuspendedFile(conn, jdwp.ThreadID(1), options)
+			controlFile := NewThreadControlFile(context.Background(), conn, jdwp.ThreadID(1), options)
+
+			if _, errno := suspendedFile.Write(context.Background(), nil, []byte("1\n"), 0); errno != test.errno {
+				t.Fatalf("write to suspended: got %v, expected %v", errno, test.errno)
+			}
+			if _, errno := controlFile.Write(context.Background(), nil, []byte("0\n"), 0); errno != test.errno {
+				t.Fatalf("write to control: got %v, expected %v", errno, test.errno)
+			}
+
+			// denied before asking the VM anything
+			if suspended := vm.Received(11, 2); (suspended == 0) != (test.errno == syscall.EPERM) {
+				t.Fatalf("suspended %d times", suspended)
+			}
+		})
+	}
+}
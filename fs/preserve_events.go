@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"fmt"
+	"log"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+// preserveEvents runs the running events again once the connection was
+// swapped, as their requests went away with the old one; the ids of
+// their modifiers are found again from the signatures and names. Events
+// whose modifiers can't be found again are left idle
+func preserveEvents(manager *debug.EventManager, conn *debug.Connection) {
+	if manager == nil {
+		return
+	}
+
+	events, err := manager.GetAllEvents()
+	if err != nil {
+		log.Printf("unable to list the events to preserve: %s\n", err)
+		return
+	}
+
+	for _, event := range events {
+		if !event.IsRunning() {
+			continue
+		}
+
+		policy, overridden := event.GetEffectiveSuspendPolicy()
+
+		// the watch is on the old connection, and is of no use anymore
+		err := event.Cancel()
+		if err != nil {
+			log.Printf("event %s: cancelling on the old connection: %s\n", event.Name, err)
+		}
+
+		modifiers, err := rematchModifiers(conn, event.GetModifiers())
+		if err != nil {
+			log.Printf("event %s stays idle after reconnecting: %s\n", event.Name, err)
+			continue
+		}
+		for name, modifier := range modifiers {
+			event.SetModifier(name, modifier)
+		}
+
+		if overridden {
+			_, err = event.RunWithSuspendPolicy(policy)
+		} else {
+			_, err = event.Run()
+		}
+		if err != nil {
+			log.Printf("event %s stays idle after reconnecting: %s\n", event.Name, err)
+			continue
+		}
+
+		log.Printf("event %s runs again after reconnecting\n", event.Name)
+	}
+}
+
+// rematchModifiers finds all the modifiers of an event in the new
+// session, or none; thread ids, and modifiers without signatures, have
+// nothing to be found again by
+func rematchModifiers(conn *debug.Connection, modifiers map[string]debug.ModifierDescriptor) (map[string]debug.ModifierDescriptor, error) {
+	var matched = map[string]debug.ModifierDescriptor{}
+	for name, modifier := range modifiers {
+		if modifier.Pending {
+			matched[name] = modifier
+			continue
+		}
+
+		if modifier.ThreadId != 0 {
+			return nil, JdwpEventDirError {
+				message: fmt.Sprintf("modifier %s: thread %d can't be found again", name, modifier.ThreadId),
+			}
+		}
+
+		if modifier.ClassSignature == "" {
+			return nil, JdwpEventDirError {
+				message: fmt.Sprintf("modifier %s: no class signature to find it again by", name),
+			}
+		}
+
+		rematched, err := rematchModifier(conn, modifier)
+		if err != nil {
+			return nil, JdwpEventDirError {
+				message: fmt.Sprintf("modifier %s: %s", name, err),
+			}
+		}
+		matched[name] = rematched
+	}
+
+	return matched, nil
+}
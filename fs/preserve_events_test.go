@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// waitRegistered waits for the VM to answer the event request, which is
+// set after Run returns
+func waitRegistered(t *testing.T, event *debug.DebuggingEvent) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !event.GetRegistered() {
+		if time.Now().After(deadline) {
+			t.Fatalf("event %s wasn't registered", event.Name)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPreserveEvents(t *testing.T) {
+	var tests = []struct {
+		name string
+		signature string
+		running bool
+		classId uint64
+		methodId uint64
+	}{
+		{ name: "method found again", signature: "()V", running: true, classId: 142, methodId: 207 },
+		{ name: "method gone", signature: "(I)V", running: false, classId: 42, methodId: 7 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, 0, fakeInt(1))
+
+			manager, err := debug.NewEventManager(context.Background(), conn)
+			if err != nil {
+				t.Fatalf("unable to create the event manager: %s", err)
+			}
+			event, err := manager.CreateEvent("breaks")
+			if err != nil {
+				t.Fatalf("unable to create the event: %s", err)
+			}
+			event.SetKind(jdwp.Breakpoint)
+			event.SetSuspendPolicy(jdwp.SuspendEventThread)
+			event.SetModifier("run", debug.ModifierDescriptor {
+				Name: "run",
+				Kind: jdwp.Class,
+				ClassId: 42,
+				ObjectId: 7,
+				ClassSignature: "Lcom/example/Main;",
+				ObjectName: "run",
+				ObjectSignature: test.signature,
+			})
+			if _, err := event.Run(); err != nil {
+				t.Fatalf("run: %s", err)
+			}
+			t.Cleanup(func() { event.Cancel() })
+			waitRegistered(t, event)
+
+			// the VM restarted, with the class loaded under other ids
+			var requests = make(chan []byte, 4)
+			otherConn, _ := startFakeVM(t, func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+				if set == 15 && cmd == 1 {
+					requests <- data
+					return 0, fakeInt(2)
+				}
+				return movedClassHandler(set, cmd, data)
+			})
+			conn.Swap(otherConn)
+
+			preserveEvents(manager, conn)
+
+			if running := event.IsRunning(); running != test.running {
+				t.Fatalf("running: %v, expected %v", running, test.running)
+			}
+			modifier := event.GetModifiers()["run"]
+			if modifier.ClassId != test.classId || modifier.ObjectId != test.methodId {
+				t.Fatalf("modifier on class %d, method %d; expected class %d, method %d",
+					modifier.ClassId, modifier.ObjectId, test.classId, test.methodId)
+			}
+
+			if !test.running {
+				if len(requests) != 0 {
+					t.Fatalf("the event was registered with the new VM")
+				}
+				return
+			}
+
+			// kind, policy, count, then a location modifier: its kind,
+			// type tag, class and method
+			var request []byte
+			select {
+			case request = <-requests:
+			case <-time.After(time.Second):
+				t.Fatalf("the event wasn't registered with the new VM")
+			}
+			waitRegistered(t, event)
+			if len(request) < 24 || request[6] != 7 {
+				t.Fatalf("no location modifier in request %v", request)
+			}
+			classId := binary.BigEndian.Uint64(request[8:16])
+			methodId := binary.BigEndian.Uint64(request[16:24])
+			if classId != test.classId || methodId != test.methodId {
+				t.Fatalf("registered class %d, method %d; expected class %d, method %d",
+					classId, methodId, test.classId, test.methodId)
+			}
+		})
+	}
+}
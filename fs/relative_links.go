@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+)
+
+//
+// Relative links
+// The links made by the filesystem point inside the mount relative to
+// the directory holding them, so they resolve wherever the mount is
+// reached from, e.g. through a bind mount
+//
+
+// relativeLink is the target of a link in dir to path, a path inside
+// the mount
+func relativeLink(dir *fs.Inode, path string) string {
+	var ups []string
+	for _, component := range strings.Split(dir.Path(nil), "/") {
+		if component != "" {
+			ups = append(ups, "..")
+		}
+	}
+
+	return strings.Join(append(ups, strings.Trim(path, "/")), "/")
+}
+
+// absoluteTarget is where a link target written in dir points to, on
+// the mountpoint
+func absoluteTarget(absoluteMountpoint string, dir *fs.Inode, target string) string {
+	if filepath.IsAbs(target) {
+		return target
+	}
+
+	return filepath.Join(absoluteMountpoint, dir.Path(nil), target)
+}
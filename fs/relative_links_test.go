@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// mountAt places node at dirPath in a tree of plain directories
+func mountAt(t *testing.T, dirPath string, node fs.InodeEmbedder) *fs.Inode {
+	t.Helper()
+
+	root := &fs.Inode{}
+	fs.NewNodeFS(root, &fs.Options{})
+
+	ctx := context.Background()
+	parent := root
+	components := strings.Split(dirPath, "/")
+	for i, component := range components {
+		var child fs.InodeEmbedder = &fs.Inode{}
+		if i == len(components) - 1 {
+			child = node
+		}
+		inode := parent.NewPersistentInode(ctx, child, fs.StableAttr{Mode: fuse.S_IFDIR})
+		parent.AddChild(component, inode, false)
+		parent = inode
+	}
+
+	return parent
+}
+
+// resolved is where a link target in dir leads, inside the mount
+func resolved(dir *fs.Inode, target string) string {
+	return strings.TrimPrefix(path.Join("/", dir.Path(nil), target), "/")
+}
+
+func TestRelativeLink(t *testing.T) {
+	var tests = []struct {
+		dir string
+		path string
+		target string
+	}{
+		{ dir: "classes_by_signature", path: "classes/41", target: "../classes/41" },
+		{ dir: "threads_by_name", path: "/threads/31/", target: "../threads/31" },
+		{ dir: "events/bp/location", path: "classes/41/methods/7", target: "../../../classes/41/methods/7" },
+		{ dir: "events/bp/modifiers/thread", path: "threads/31", target: "../../../../threads/31" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.dir, func(t *testing.T) {
+			dir := mountAt(t, test.dir, &fs.Inode{})
+
+			target := relativeLink(dir, test.path)
+			if target != test.target {
+				t.Fatalf("got target %s, expected %s", target, test.target)
+			}
+			if at := resolved(dir, target); at != strings.Trim(test.path, "/") {
+				t.Fatalf("the target leads to %s", at)
+			}
+
+			// the same wherever the mount is reached from
+			for _, mountpoint := range []string { "/mnt", "/srv/bind/jdwp" } {
+				absolute := absoluteTarget(mountpoint, dir, target)
+				if absolute != path.Join(mountpoint, test.path) {
+					t.Fatalf("on %s, the target leads to %s", mountpoint, absolute)
+				}
+			}
+		})
+	}
+}
+
+func TestNamedLinks(t *testing.T) {
+	t.Run("classes_by_signature", func(t *testing.T) {
+		conn, _ := startFakeVM(t, classListHandler)
+		classesDir, _ := NewJdwpClassNamedMasterDir(context.Background(), conn, nil, 0, JdwpFsOptions{})
+		dir := mountAt(t, "classes_by_signature", classesDir)
+
+		target := linkTarget(t, classesDir, url.PathEscape("Lcom/myapp/Main;"))
+		if at := resolved(dir, target); at != "classes/41" {
+			t.Fatalf("%s leads to %s", target, at)
+		}
+	})
+
+	t.Run("threads_by_name", func(t *testing.T) {
+		conn, _ := startFakeVM(t, unsortedThreadsHandler([]uint64 { 30, 31 }, map[byte]string {
+			30: "worker",
+			31: "main",
+		}))
+		threadsDir, _ := NewJdwpThreadNamedDir(context.Background(), conn, JdwpFsOptions{})
+		dir := mountAt(t, "threads_by_name", threadsDir)
+
+		target := linkTarget(t, threadsDir, "main")
+		if at := resolved(dir, target); at != "threads/31" {
+			t.Fatalf("%s leads to %s", target, at)
+		}
+	})
+}
@@ -10,11 +10,17 @@ import (
 	"syscall"
 	"net"
 	"log"
+	"sort"
+	"sync"
+	"strings"
+	"time"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 
 	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
 )
 
 //
@@ -44,16 +50,36 @@ type JdwpRootFs struct {
 	Host string
 	Port int
 
+	// replaced on reconnection, under mu; with ConnectOnDemand, nil
+	// until the first access needing the VM
+	mu sync.Mutex
 	Connection net.Conn
+	connectedAt time.Time
+	suspendedAtStart bool
 
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	// shared by the events directory and the method breakpoints
+	EventManager *debug.EventManager
+
+	// nil unless auto-resume is enabled
+	watchdog *Watchdog
+
+	// nil unless idle disconnection is enabled
+	idleDisconnector *IdleDisconnector
+
+	Options JdwpFsOptions
+	classFilter *ClassFilter
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpRootFs)(nil))
 var _ = (fs.NodeOnAdder)((*JdwpRootFs)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpRootFs)(nil))
+var _ = (fs.NodeLookuper)((*JdwpRootFs)(nil))
+var _ = (fs.NodeReaddirer)((*JdwpRootFs)(nil))
 
-func NewJdwpRootfs(ctx context.Context, absMountpoint string, host string, port int) (*JdwpRootFs, error) {
+func NewJdwpRootfs(ctx context.Context, absMountpoint string, host string, port int, options JdwpFsOptions) (*JdwpRootFs, error) {
 	if port < 1 {
 		return nil, JdwpProtocolError {
 			message: fmt.Sprintf("port %d cannot exist", port),
@@ -66,48 +92,327 @@ func NewJdwpRootfs(ctx context.Context, absMountpoint string, host string, port
 		}
 	}
 
-	tcpConnection, err := net.Dial("tcp", fmt.Sprintf("%s:%d", host, port))
+	classFilter, err := NewClassFilter(options.ClassInclude, options.ClassExclude)
 	if err != nil {
-		return nil, JdwpProtocolError { err: err }
+		return nil, err
 	}
 
-	jdwpConnection, err := jdwp.Open(ctx, tcpConnection)
+	err = ValidateModes(options.FileMode, options.DirMode, options.ControlMode)
 	if err != nil {
-		return nil, JdwpProtocolError { err: err }
+		return nil, err
+	}
+
+	err = validateIdRadix(options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadPolicy(&options)
+	if err != nil {
+		return nil, err
 	}
 
 	newJdwpFs := &JdwpRootFs {
 		AbsoluteMountpoint: absMountpoint,
 		Host: host,
 		Port: port,
-		Connection: tcpConnection,
 		JdwpContext: ctx,
-		JdwpConnection: jdwpConnection,
+		Options: options,
+		classFilter: classFilter,
 	}
-	
+
+	if options.ConnectOnDemand {
+		return newJdwpFs, nil
+	}
+
+	var tcpConnection net.Conn
+	var jdwpConnection *debug.Connection
+	for attempt := 0; ; attempt++ {
+		tcpConnection, jdwpConnection, err = connect(ctx, host, port, options)
+		if err == nil {
+			break
+		}
+
+		if attempt >= options.ConnectRetry {
+			return nil, JdwpProtocolError { err: err }
+		}
+
+		log.Printf("connecting to %s:%d failed (%s), retrying in %s\n",
+			host, port, err, options.ConnectRetryInterval)
+		select {
+		case <-time.After(options.ConnectRetryInterval):
+		case <-ctx.Done():
+			return nil, JdwpProtocolError { err: ctx.Err() }
+		}
+	}
+
+	err = newJdwpFs.attach(tcpConnection, jdwpConnection)
+	if err != nil {
+		tcpConnection.Close()
+		return nil, err
+	}
+
 	return newJdwpFs, nil
 }
 
+// attach sets up what's built on the first connection to the VM: the
+// events, and the watchdog; under mu, once connected on demand
+func (r *JdwpRootFs) attach(tcpConnection net.Conn, jdwpConnection *debug.Connection) error {
+	eventManager, err := debug.NewEventManager(r.JdwpContext, jdwpConnection)
+	if err != nil {
+		return err
+	}
+	eventManager.SetEventBufferSize(r.Options.EventBufferSize)
+
+	if r.Options.EventsImport != "" {
+		report, err := ImportEventsFile(eventManager, r.Options.EventsImport)
+		if err != nil {
+			return err
+		}
+		log.Printf("events from %s: %s", r.Options.EventsImport, report)
+	}
+
+	suspended, err := resumeIfSuspended(jdwpConnection, r.Options)
+	if err != nil {
+		return err
+	}
+
+	r.Connection = tcpConnection
+	r.connectedAt = time.Now()
+	r.suspendedAtStart = suspended
+	r.JdwpConnection = jdwpConnection
+	r.EventManager = eventManager
+	r.watchdog = newWatchdog(r.JdwpContext, jdwpConnection, r.Options)
+
+	if r.Options.IdleDisconnect > 0 {
+		jdwpConnection.SetRedial(r.redial)
+		r.idleDisconnector = NewIdleDisconnector(r.JdwpContext, jdwpConnection,
+			eventManager, r.Options.IdleDisconnect)
+	}
+
+	return nil
+}
+
+// redial opens the connection again after it was closed for being idle;
+// it's called by the connection itself, on the first call needing it
+func (r *JdwpRootFs) redial() (*debug.Connection, error) {
+	tcpConnection, jdwpConnection, err := connect(r.JdwpContext, r.Host, r.Port, r.Options)
+	if err != nil {
+		log.Printf("unable to reconnect to %s:%d: %s\n", r.Host, r.Port, err)
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.Connection = tcpConnection
+	r.connectedAt = time.Now()
+	r.mu.Unlock()
+
+	log.Printf("reconnected to %s:%d after being idle\n", r.Host, r.Port)
+
+	return jdwpConnection, nil
+}
+
+// connectOnDemand connects to the VM the first time it's needed, and adds
+// the nodes built from it; a failure is retried on the next access
+func (r *JdwpRootFs) connectOnDemand(ctx context.Context) syscall.Errno {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.JdwpConnection != nil {
+		return 0
+	}
+
+	tcpConnection, jdwpConnection, err := connect(r.JdwpContext, r.Host, r.Port, r.Options)
+	if err != nil {
+		log.Printf("unable to connect to %s:%d: %s\n", r.Host, r.Port, err)
+		return syscall.ECONNREFUSED
+	}
+
+	err = r.attach(tcpConnection, jdwpConnection)
+	if err != nil {
+		log.Printf("unable to set up the connection to %s:%d: %s\n", r.Host, r.Port, err)
+		tcpConnection.Close()
+		return syscall.ECONNREFUSED
+	}
+
+	r.addVmNodes(ctx)
+	log.Printf("connected to %s:%d\n", r.Host, r.Port)
+
+	return 0
+}
+
+func (r *JdwpRootFs) isConnected() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.JdwpConnection != nil
+}
+
+// connect dials the VM and does the JDWP handshake
+func connect(ctx context.Context, host string, port int, options JdwpFsOptions) (net.Conn, *debug.Connection, error) {
+	tcpConnection, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jdwpConnection, err := debug.OpenConnection(ctx, tcpConnection)
+	if err != nil {
+		tcpConnection.Close()
+		return nil, nil, err
+	}
+	jdwpConnection.SetMaxConcurrent(options.MaxConcurrentJdwp)
+	jdwpConnection.SetReadRetries(options.ReadRetries)
+	jdwpConnection.SetCompat(options.Compat)
+	jdwpConnection.SetInspectEvents(options.SuspendOnEventInspect)
+
+	return tcpConnection, jdwpConnection, nil
+}
+
+// isSuspended tells if the VM is suspended, as it is right after it's
+// started with suspend=y: all its threads are, at least by the VM itself
+func isSuspended(conn *debug.Connection) (bool, error) {
+	threads, err := conn.GetAllThreads()
+	if err != nil {
+		return false, err
+	}
+
+	for _, thread := range threads {
+		_, suspendStatus, err := conn.GetThreadStatus(thread)
+		if err != nil {
+			return false, err
+		}
+
+		if suspendStatus == 0 {
+			return false, nil
+		}
+	}
+
+	return len(threads) > 0, nil
+}
+
+// resumeIfSuspended tells if the VM was suspended when connecting, and
+// resumes it if the options ask for it
+func resumeIfSuspended(conn *debug.Connection, options JdwpFsOptions) (bool, error) {
+	suspended, err := isSuspended(conn)
+	if err != nil {
+		return false, JdwpProtocolError { err: err }
+	}
+
+	if suspended && options.ResumeOnMount {
+		log.Printf("the VM is suspended, resuming it\n")
+		err = conn.ResumeAll()
+		if err != nil {
+			return suspended, JdwpProtocolError { err: err }
+		}
+	}
+
+	return suspended, nil
+}
+
 func (r *JdwpRootFs) OnAdd(ctx context.Context) {
+	mountTime = time.Now()
+
 	// creation of informational files
 	hostFile := r.NewPersistentInode(
 		ctx, &fs.MemRegularFile{
-			Data: []byte(r.Host),
-			Attr: fuse.Attr{
-				Mode: 0444,
-			},
+			Data: []byte(newlineTerminated(r.Host)),
+			Attr: staticAttr(r.Options.fileMode()),
 		}, fs.StableAttr{Ino: 2})
 	
 	portFile := r.NewPersistentInode(
 		ctx, &fs.MemRegularFile{
-			Data: []byte(strconv.Itoa(r.Port)),
-			Attr: fuse.Attr{
-				Mode: 0444,
-			},
+			Data: []byte(newlineTerminated(strconv.Itoa(r.Port))),
+			Attr: staticAttr(r.Options.fileMode()),
 		}, fs.StableAttr{Ino: 3})
 
+	kindRequirementsFile := r.NewPersistentInode(
+		ctx, &fs.MemRegularFile{
+			Data: []byte(kindRequirements()),
+			Attr: staticAttr(r.Options.fileMode()),
+		}, fs.StableAttr{Ino: 19})
+
+	buildFile := r.NewPersistentInode(
+		ctx, &fs.MemRegularFile{
+			Data: []byte(BuildInfo()),
+			Attr: staticAttr(r.Options.fileMode()),
+		}, fs.StableAttr{Ino: 14})
+
+	connectionFile := NewInfoFile(r.readConnection, r.Options)
+	connectionFileInode := r.NewPersistentInode(
+		ctx, &connectionFile, fs.StableAttr{Ino: 15})
+
+	reconnectFile := NewTriggerFile(r.reconnect, r.Options)
+	reconnectFileInode := r.NewPersistentInode(
+		ctx, &reconnectFile, fs.StableAttr{Ino: 12})
+
+	r.AddChild("host", hostFile, false)
+	r.AddChild("port", portFile, false)
+	r.AddChild("build", buildFile, false)
+	r.AddChild("kind_requirements", kindRequirementsFile, false)
+	r.AddChild("connection", connectionFileInode, false)
+	r.AddChild("reconnect", reconnectFileInode, false)
+
+	if r.JdwpConnection != nil {
+		r.addVmNodes(ctx)
+	}
+}
+
+// addVmNodes adds the nodes built from the VM state, once connected
+func (r *JdwpRootFs) addVmNodes(ctx context.Context) {
+	eventKindsFile := NewInfoFile(r.readEventKinds, r.Options)
+	eventKindsFileInode := r.NewPersistentInode(
+		ctx, &eventKindsFile, fs.StableAttr{Ino: 9})
+
+	deadlocksFile := NewInfoFile(r.readDeadlocks, r.Options)
+	deadlocksFileInode := r.NewPersistentInode(
+		ctx, &deadlocksFile, fs.StableAttr{Ino: 11})
+
+	vmSuspendedFile := NewInfoFile(r.readVmSuspended, r.Options)
+	vmSuspendedFileInode := r.NewPersistentInode(
+		ctx, &vmSuspendedFile, fs.StableAttr{Ino: 16})
+
+	flushCachesFile := NewTriggerFile(r.flushCaches, r.Options)
+	flushCachesFileInode := r.NewPersistentInode(
+		ctx, &flushCachesFile, fs.StableAttr{Ino: 21})
+
+	pendingJdwpFile := NewInfoFile(r.readPendingJdwp, r.Options)
+	pendingJdwpFileInode := r.NewPersistentInode(
+		ctx, &pendingJdwpFile, fs.StableAttr{Ino: 20})
+
+	vmInfoDir := NewVmInfoDir(r.JdwpConnection, r.Options)
+	vmInfoDirInode := r.NewPersistentInode(
+		ctx,
+		&vmInfoDir,
+		fs.StableAttr{
+			Mode: fuse.S_IFDIR,
+			Ino: 22,
+		})
+
+	// hooking files
+	r.AddChild("event_kinds", eventKindsFileInode, false)
+	r.AddChild("deadlocks", deadlocksFileInode, false)
+	r.AddChild("vm_suspended", vmSuspendedFileInode, false)
+	r.AddChild("pending_jdwp", pendingJdwpFileInode, false)
+	r.AddChild("flush_caches", flushCachesFileInode, false)
+	r.AddChild("vm", vmInfoDirInode, false)
+
+	// the subtrees left out aren't built at all
+	if !r.Options.NoThreads {
+		r.addThreadNodes(ctx)
+	}
+	if !r.Options.NoClasses {
+		r.addClassNodes(ctx)
+	}
+	if !r.Options.NoEvents {
+		r.addEventNodes(ctx)
+	}
+}
+
+// addThreadNodes adds the threads, and the views linking into them: the
+// thread groups and the monitors
+func (r *JdwpRootFs) addThreadNodes(ctx context.Context) {
 	// thread listing
-	threadMasterDir, err := NewJdwpThreadMasterDir(r.JdwpContext, r.JdwpConnection)
+	threadMasterDir, err := NewJdwpThreadMasterDir(r.JdwpContext, r.JdwpConnection, r.Options, r.watchdog)
 	if err != nil {
 		log.Panicf("could not create thread dir: %s", err)
 	}
@@ -120,7 +425,7 @@ func (r *JdwpRootFs) OnAdd(ctx context.Context) {
 		})
 
 	// named thread listing
-	threadNamedDir, err := NewJdwpThreadNamedDir(r.JdwpContext, r.JdwpConnection, r.AbsoluteMountpoint)
+	threadNamedDir, err := NewJdwpThreadNamedDir(r.JdwpContext, r.JdwpConnection, r.Options)
 	if err != nil {
 		log.Panicf("could not create named thread dir: %s", err)
 	}
@@ -132,11 +437,47 @@ func (r *JdwpRootFs) OnAdd(ctx context.Context) {
 			Ino: 5,
 		})
 
+	// thread groups
+	threadGroupMasterDir, err := NewJdwpThreadGroupMasterDir(r.JdwpContext, r.JdwpConnection, r.Options)
+	if err != nil {
+		log.Panicf("could not create thread groups dir: %s", err)
+	}
+	threadGroupMasterDirInode := r.NewPersistentInode(
+		ctx,
+		threadGroupMasterDir,
+		fs.StableAttr{
+			Mode: fuse.S_IFDIR,
+			Ino: 10,
+		})
+
+	// monitors, by object id
+	monitorMasterDir, err := NewJdwpMonitorMasterDir(r.JdwpContext, r.JdwpConnection, r.Options)
+	if err != nil {
+		log.Panicf("could not create monitors dir: %s", err)
+	}
+	monitorMasterDirInode := r.NewPersistentInode(
+		ctx,
+		monitorMasterDir,
+		fs.StableAttr{
+			Mode: fuse.S_IFDIR,
+			Ino: 18,
+		})
+
+	r.AddChild("threads", threadMasterDirInode, false)
+	r.AddChild("threads_by_name", threadNamedDirInode, false)
+	r.AddChild("thread_groups", threadGroupMasterDirInode, false)
+	r.AddChild("monitors", monitorMasterDirInode, false)
+}
+
+// addClassNodes adds the classes, by id and by signature, and starts
+// prefetching them
+func (r *JdwpRootFs) addClassNodes(ctx context.Context) {
 	// classes dir
-	classesDir, err := NewJdwpClassMasterDir(r.JdwpContext, r.JdwpConnection)
+	classesDir, err := NewJdwpClassMasterDir(r.JdwpContext, r.JdwpConnection, r.classFilter, r.EventManager, r.Options.MaxDirEntries, r.Options)
 	if err != nil {
 		log.Panicf("could not create named classes dir: %s", err)
 	}
+	classesDir.values = newValueRenderer(r.JdwpConnection, r.Options)
 	
 	classesDirInode := r.NewPersistentInode(
 		ctx,
@@ -147,7 +488,7 @@ func (r *JdwpRootFs) OnAdd(ctx context.Context) {
 		})
 
 	// named classes dir
-	classesNamedDir, err := NewJdwpClassNamedMasterDir(r.JdwpContext, r.JdwpConnection, r.AbsoluteMountpoint)
+	classesNamedDir, err := NewJdwpClassNamedMasterDir(r.JdwpContext, r.JdwpConnection, r.classFilter, r.Options.MaxDirEntries, r.Options)
 	if err != nil {
 		log.Panicf("could not create named events dir: %s", err)
 	}
@@ -160,8 +501,42 @@ func (r *JdwpRootFs) OnAdd(ctx context.Context) {
 			Ino: 7,
 		})
 
+	r.AddChild("classes", classesDirInode, false)
+	r.AddChild("classes_by_signature", classesNamedDirInode, false)
+
+	// the classes trees are served from the warmed caches
+	if len(r.Options.ClassPrefetch) != 0 {
+		go prefetchClasses(r.JdwpContext, r.JdwpConnection, r.classFilter,
+			r.Options.ClassPrefetch, r.Options.ClassPrefetchRate)
+	}
+}
+
+// addEventNodes adds the events, and the objects the suspending events
+// keep
+func (r *JdwpRootFs) addEventNodes(ctx context.Context) {
+	// objects, by id
+	objectMasterDir, err := NewJdwpObjectMasterDir(r.JdwpContext, r.JdwpConnection, r.Options)
+	if err != nil {
+		log.Panicf("could not create objects dir: %s", err)
+	}
+	objectMasterDirInode := r.NewPersistentInode(
+		ctx,
+		objectMasterDir,
+		fs.StableAttr{
+			Mode: fuse.S_IFDIR,
+			Ino: 13,
+		})
+	r.AddChild("objects", objectMasterDirInode, false)
+
 	// events directory
-	eventsDir, err := NewJdwpEventsMasterDir(r.JdwpContext, r.JdwpConnection, r.AbsoluteMountpoint)
+	eventsDir, err := NewJdwpEventsMasterDir(r.JdwpContext, r.JdwpConnection, r.EventManager, r.AbsoluteMountpoint, r.Options)
+	if err != nil {
+		if r.Options.Strict {
+			log.Panicf("could not create events dir: %s", err)
+		}
+		log.Printf("could not create events dir, continuing without it: %s\n", err)
+		return
+	}
 	eventsDirInode := r.NewPersistentInode(
 		ctx,
 		eventsDir,
@@ -169,23 +544,298 @@ func (r *JdwpRootFs) OnAdd(ctx context.Context) {
 			Mode: fuse.S_IFDIR,
 			Ino: 8,
 		})
-	
 
-	
-	// hooking files
-	r.AddChild("host", hostFile, false)
-	r.AddChild("port", portFile, false)
-
-	r.AddChild("threads", threadMasterDirInode, false)
-	r.AddChild("threads_by_name", threadNamedDirInode, false)
-
-	r.AddChild("classes", classesDirInode, false)
-	r.AddChild("classes_by_signature", classesNamedDirInode, false)
+	clearEventsFile := NewClearEventsFile(r.EventManager, eventsDirInode, r.Options)
+	clearEventsFileInode := r.NewPersistentInode(
+		ctx, &clearEventsFile, fs.StableAttr{Ino: 17})
 
 	r.AddChild("events", eventsDirInode, false)
+	r.AddChild("clear_events", clearEventsFileInode, false)
 }
 
 func (r *JdwpRootFs) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = r.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (r *JdwpRootFs) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+// Lookup finds the children added when mounting; with ConnectOnDemand,
+// looking up anything else connects to the VM first
+func (r *JdwpRootFs) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	child := r.GetChild(name)
+	if child == nil && !r.isConnected() {
+		errno := r.connectOnDemand(ctx)
+		if errno != 0 {
+			return nil, errno
+		}
+		child = r.GetChild(name)
+	}
+
+	if child == nil {
+		return nil, syscall.ENOENT
+	}
+
+	if getattrer, ok := child.Operations().(fs.NodeGetattrer); ok {
+		var attr fuse.AttrOut
+		errno := getattrer.Getattr(ctx, nil, &attr)
+		if errno == 0 {
+			out.Attr = attr.Attr
+		}
+	}
+
+	return child, 0
+}
+
+// Readdir lists the children; with ConnectOnDemand, it tries to connect
+// first, and lists the files which don't need the VM if it can't
+func (r *JdwpRootFs) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	if !r.isConnected() {
+		r.connectOnDemand(ctx)
+	}
+
+	var entries = []fuse.DirEntry{}
+	for name, child := range r.Children() {
+		entries = append(entries, fuse.DirEntry {
+			Mode: child.Mode(),
+			Name: name,
+			Ino: child.StableAttr().Ino,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	return fs.NewListDirStream(entries), 0
+}
+
+// readEventKinds lists the event kinds, and whether the VM capabilities
+// allow requesting them
+func (r *JdwpRootFs) readEventKinds() ([]byte, syscall.Errno) {
+	capabilities, err := r.JdwpConnection.GetCapabilities()
+	if err != nil {
+		log.Printf("unable to get the VM capabilities: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	var kinds []jdwp.EventKind
+	for _, kind := range eventKindReprMap {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	var eventKinds = ""
+	for _, kind := range kinds {
+		capability, ok := debug.EventKindCapability(kind)
+		switch {
+		case !ok:
+			eventKinds = fmt.Sprintf("%s%s\tavailable\n", eventKinds, kind)
+		case capabilities.Has(capability):
+			eventKinds = fmt.Sprintf("%s%s\tavailable\t%s\n", eventKinds, kind, capability)
+		default:
+			eventKinds = fmt.Sprintf("%s%s\tunavailable\t%s\n", eventKinds, kind, capability)
+		}
+	}
+
+	return []byte(eventKinds), 0
+}
+
+// kindRequirements lists the modifiers each event kind needs to be run,
+// separated by tabs, or none
+func kindRequirements() string {
+	var kinds []jdwp.EventKind
+	for _, kind := range eventKindReprMap {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	var requirements = ""
+	for _, kind := range kinds {
+		var descriptions = []string{}
+		for _, requirement := range debug.KindRequirements(kind) {
+			descriptions = append(descriptions, requirement.Description)
+		}
+		if len(descriptions) == 0 {
+			descriptions = append(descriptions, "none")
+		}
+
+		requirements = fmt.Sprintf("%s%s\t%s\n", requirements, kind, strings.Join(descriptions, "\t"))
+	}
+
+	return requirements
+}
+
+// readDeadlocks reports the sets of threads waiting for each other's
+// monitors, one set per line; all the threads have to be suspended
+func (r *JdwpRootFs) readDeadlocks() ([]byte, syscall.Errno) {
+	capabilities, err := r.JdwpConnection.GetCapabilities()
+	if err != nil {
+		log.Printf("unable to get the VM capabilities: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	if !capabilities.Has("canGetOwnedMonitorInfo") ||
+		!capabilities.Has("canGetCurrentContendedMonitor") {
+		return nil, syscall.ENOTSUP
+	}
+
+	threads, err := r.JdwpConnection.GetAllThreads()
+	if err != nil {
+		log.Printf("unable to read threads from the JVM: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	for _, thread := range threads {
+		_, suspendStatus, err := r.JdwpConnection.GetThreadStatus(thread)
+		if err != nil {
+			log.Printf("error getting status of thread %d: %s\n", thread, err)
+			return nil, syscall.EFAULT
+		}
+
+		if suspendStatus == 0 {
+			return nil, syscall.EBUSY
+		}
+	}
+
+	deadlocks, err := r.JdwpConnection.GetDeadlocks(threads)
+	if err != nil {
+		log.Printf("unable to look for deadlocks: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	var report = ""
+	for _, deadlock := range deadlocks {
+		var members []string
+		for _, thread := range deadlock {
+			name, err := r.JdwpConnection.GetThreadName(thread)
+			if err != nil {
+				name = "?"
+			}
+			members = append(members, fmt.Sprintf("%s (%s)", r.Options.formatId(uint64(thread)), name))
+		}
+		// the cycle is closed by its first thread
+		members = append(members, r.Options.formatId(uint64(deadlock[0])))
+		report = report + "deadlock: " + strings.Join(members, " -> ") + "\n"
+	}
+
+	return []byte(report), 0
+}
+
+// readConnection describes the socket to the VM, and since when it's open
+func (r *JdwpRootFs) readConnection() ([]byte, syscall.Errno) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Connection == nil {
+		return []byte("connected: down\n"), 0
+	}
+
+	if r.JdwpConnection.IsDisconnected() {
+		return []byte("connected: idle\n"), 0
+	}
+
+	return []byte(formatConnection(r.Connection, r.connectedAt, time.Now())), 0
+}
+
+func formatConnection(conn net.Conn, connectedAt time.Time, now time.Time) string {
+	return fmt.Sprintf("local: %s\nremote: %s\nconnected: %s\nuptime: %s\n",
+		conn.LocalAddr(),
+		conn.RemoteAddr(),
+		connectedAt.Format(time.RFC3339),
+		now.Sub(connectedAt).Truncate(time.Second))
+}
+
+// readVmSuspended tells if the VM was suspended when jdwpfs connected to
+// it, whether or not it was resumed since
+func (r *JdwpRootFs) readVmSuspended() ([]byte, syscall.Errno) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.suspendedAtStart {
+		return []byte("1\n"), 0
+	}
+
+	return []byte("0\n"), 0
+}
+
+// readPendingJdwp tells how many JDWP calls wait for their turn under
+// --max-concurrent-jdwp, and how many the VM is answering
+func (r *JdwpRootFs) readPendingJdwp() ([]byte, syscall.Errno) {
+	queued, inFlight := r.JdwpConnection.GetPendingCalls()
+
+	return []byte(fmt.Sprintf("queued: %d\nin flight: %d\n", queued, inFlight)), 0
+}
+
+// reconnect dials the VM again, and moves everyone to the new
+// connection; if it fails, the old connection is kept
+func (r *JdwpRootFs) reconnect() syscall.Errno {
+	if !r.isConnected() {
+		return r.connectOnDemand(r.JdwpContext)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tcpConnection, jdwpConnection, err := connect(r.JdwpContext, r.Host, r.Port, r.Options)
+	if err != nil {
+		log.Printf("unable to reconnect to %s:%d: %s\n", r.Host, r.Port, err)
+		return syscall.ECONNREFUSED
+	}
+
+	err = r.JdwpConnection.Swap(jdwpConnection)
+	if err != nil {
+		log.Printf("error closing the previous connection: %s\n", err)
+	}
+	r.Connection = tcpConnection
+	r.connectedAt = time.Now()
+
+	// before resuming, so a VM started suspended misses no event
+	if r.Options.ReconnectPreserveEvents {
+		preserveEvents(r.EventManager, r.JdwpConnection)
+	}
+
+	suspended, err := resumeIfSuspended(r.JdwpConnection, r.Options)
+	if err != nil {
+		log.Printf("unable to check whether the VM is suspended: %s\n", err)
+	}
+	r.suspendedAtStart = suspended
+
+	// the nodes built from the VM state may be gone
+	r.forgetVmNodes()
+
+	log.Printf("reconnected to %s:%d\n", r.Host, r.Port)
+
+	return 0
+}
+
+// flushCaches forgets what's cached about the VM, and the nodes built
+// from it, so that the next accesses ask the VM again
+func (r *JdwpRootFs) flushCaches() syscall.Errno {
+	r.JdwpConnection.FlushCaches()
+	r.forgetVmNodes()
+
 	return 0
 }
+
+// forgetVmNodes makes the kernel look up the nodes built from the VM
+// state again
+func (r *JdwpRootFs) forgetVmNodes() {
+	for _, dirName := range []string {
+		"threads",
+		"threads_by_name",
+		"thread_groups",
+		"objects",
+		"classes",
+		"classes_by_signature",
+	} {
+		dir := r.GetChild(dirName)
+		if dir == nil {
+			continue
+		}
+
+		for name := range dir.Children() {
+			dir.NotifyEntry(name)
+		}
+	}
+}
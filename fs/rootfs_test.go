@@ -0,0 +1,808 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// newTestRootFs builds the root of a mount on a fake VM, without
+// mounting it
+func newTestRootFs(t *testing.T, options JdwpFsOptions) *JdwpRootFs {
+	t.Helper()
+
+	conn, _ := startFakeVM(t, nil)
+	manager, err := debug.NewEventManager(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("unable to create the event manager: %s", err)
+	}
+
+	root := &JdwpRootFs {
+		AbsoluteMountpoint: "/mnt",
+		Host: "localhost",
+		Port: 5005,
+		JdwpContext: context.Background(),
+		JdwpConnection: conn,
+		EventManager: manager,
+		Options: options,
+	}
+	fs.NewNodeFS(root, &fs.Options{})
+
+	return root
+}
+
+func rootListing(t *testing.T, root *JdwpRootFs) map[string]bool {
+	t.Helper()
+
+	stream, errno := root.Readdir(context.Background())
+	if errno != syscall.F_OK {
+		t.Fatalf("readdir: %v", errno)
+	}
+
+	var listing = map[string]bool{}
+	for stream.HasNext() {
+		entry, _ := stream.Next()
+		listing[entry.Name] = true
+	}
+	return listing
+}
+
+func TestRootSubtrees(t *testing.T) {
+	var threadNodes = []string { "threads", "threads_by_name", "thread_groups", "monitors" }
+	var classNodes = []string { "classes", "classes_by_signature" }
+	var eventNodes = []string { "events", "clear_events", "objects" }
+
+	var tests = []struct {
+		name string
+		options JdwpFsOptions
+		absent []string
+	}{
+		{ name: "everything", options: JdwpFsOptions{} },
+		{ name: "no threads", options: JdwpFsOptions { NoThreads: true }, absent: threadNodes },
+		{ name: "no classes", options: JdwpFsOptions { NoClasses: true }, absent: classNodes },
+		{ name: "no events", options: JdwpFsOptions { NoEvents: true }, absent: eventNodes },
+		{
+			name: "none",
+			options: JdwpFsOptions { NoThreads: true, NoClasses: true, NoEvents: true },
+			absent: append(append(append([]string{}, threadNodes...), classNodes...), eventNodes...),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := newTestRootFs(t, test.options)
+			listing := rootListing(t, root)
+
+			var absent = map[string]bool{}
+			for _, name := range test.absent {
+				absent[name] = true
+			}
+
+			var missing, extra []string
+			for _, names := range [][]string { threadNodes, classNodes, eventNodes } {
+				for _, name := range names {
+					switch {
+					case absent[name] && listing[name]:
+						extra = append(extra, name)
+					case !absent[name] && !listing[name]:
+						missing = append(missing, name)
+					}
+
+					// not built, rather than only not listed
+					if absent[name] && root.GetChild(name) != nil {
+						extra = append(extra, name)
+					}
+				}
+			}
+			sort.Strings(missing)
+			sort.Strings(extra)
+
+			if len(missing) != 0 || len(extra) != 0 {
+				t.Fatalf("missing %v, left in %v", missing, extra)
+			}
+			for _, name := range []string { "vm", "host", "event_kinds", "flush_caches" } {
+				if !listing[name] {
+					t.Fatalf("%s isn't listed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRootListingSorted(t *testing.T) {
+	root := newTestRootFs(t, JdwpFsOptions{})
+
+	stream, _ := root.Readdir(context.Background())
+	var names []string
+	for stream.HasNext() {
+		entry, _ := stream.Next()
+		names = append(names, entry.Name)
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	if !reflect.DeepEqual(names, sorted) {
+		t.Fatalf("the root listing isn't sorted: %v", names)
+	}
+}
+
+// fakeCapabilities is the CapabilitiesNew reply of a VM having only the
+// capabilities at the given positions of the reply
+func fakeCapabilities(positions ...int) []byte {
+	reply := make([]byte, 32)
+	for _, position := range positions {
+		reply[position] = 1
+	}
+	return reply
+}
+
+func TestEventKinds(t *testing.T) {
+	var tests = []struct {
+		name string
+		capabilities []byte
+		kinds map[string]string
+	}{
+		{
+			name: "no capabilities",
+			capabilities: fakeCapabilities(),
+			kinds: map[string]string {
+				"FieldAccess": "unavailable\tcanWatchFieldAccess",
+				"FieldModification": "unavailable\tcanWatchFieldModification",
+				"VMDeath": "unavailable\tcanRequestVMDeathEvent",
+				"Breakpoint": "available",
+			},
+		},
+		{
+			name: "field modification only",
+			capabilities: fakeCapabilities(0),
+			kinds: map[string]string {
+				"FieldAccess": "unavailable\tcanWatchFieldAccess",
+				"FieldModification": "available\tcanWatchFieldModification",
+			},
+		},
+		{
+			name: "every capability",
+			capabilities: fakeCapabilities(0, 1, 13),
+			kinds: map[string]string {
+				"FieldAccess": "available\tcanWatchFieldAccess",
+				"FieldModification": "available\tcanWatchFieldModification",
+				"VMDeath": "available\tcanRequestVMDeathEvent",
+				"ThreadStart": "available",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(1, 17, 0, test.capabilities)
+			root := &JdwpRootFs {
+				JdwpConnection: conn,
+			}
+
+			data, errno := root.readEventKinds()
+			if errno != syscall.F_OK {
+				t.Fatalf("read: %v", errno)
+			}
+
+			var kinds = map[string]string{}
+			for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+				fields := strings.SplitN(line, "\t", 2)
+				kinds[fields[0]] = fields[1]
+			}
+			if len(kinds) != len(eventKindReprMap) {
+				t.Fatalf("listed %d kinds, expected %d", len(kinds), len(eventKindReprMap))
+			}
+			for kind, status := range test.kinds {
+				if kinds[kind] != status {
+					t.Errorf("%s: got %q, expected %q", kind, kinds[kind], status)
+				}
+			}
+
+			// tied to the capabilities cache
+			root.readEventKinds()
+			if asked := vm.Received(1, 17); asked != 1 {
+				t.Fatalf("capabilities asked %d times", asked)
+			}
+		})
+	}
+}
+
+// freePort finds a port nothing listens on, for now
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %s", err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// listenFakeVM starts a fake VM listening on port after delay, having
+// no threads; every connection accepted is served by a new VM, sent on
+// the returned channel
+func listenFakeVM(t *testing.T, port int, delay time.Duration) <-chan *fakeVM {
+	t.Helper()
+
+	accepted := make(chan *fakeVM, 16)
+
+	listen := func() net.Listener {
+		listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err != nil {
+			t.Errorf("unable to listen on %d: %s", port, err)
+			return nil
+		}
+
+		go func() {
+			for {
+				server, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				vm := &fakeVM {
+					received: map[[2]uint8]int{},
+					answers: map[[2]uint8]fakeAnswer {
+						{1, 4}: { data: fakeInt(0) },
+					},
+				}
+				go vm.serve(server)
+
+				select {
+				case accepted <- vm:
+				default:
+				}
+			}
+		}()
+
+		return listener
+	}
+
+	ready := make(chan net.Listener, 1)
+	if delay == 0 {
+		ready <- listen()
+	} else {
+		time.AfterFunc(delay, func() { ready <- listen() })
+	}
+
+	t.Cleanup(func() {
+		if listener := <-ready; listener != nil {
+			listener.Close()
+		}
+	})
+
+	return accepted
+}
+
+func TestConnectRetry(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	var tests = []struct {
+		name string
+		retries int
+		listening bool
+		delay time.Duration
+		cancelled bool
+		connects bool
+	}{
+		{ name: "listening", retries: 0, listening: true, connects: true },
+		{ name: "listening after the first attempt", retries: 5, listening: true, delay: interval / 2, connects: true },
+		{ name: "listening too late", retries: 1, listening: true, delay: 10 * interval, connects: false },
+		{ name: "never listening", retries: 2, connects: false },
+		{ name: "cancelled while waiting", retries: 100, cancelled: true, connects: false },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			port := freePort(t)
+			if test.listening {
+				listenFakeVM(t, port, test.delay)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.cancelled {
+				time.AfterFunc(2 * interval, cancel)
+			}
+
+			options := DefaultJdwpFsOptions()
+			options.ConnectRetry = test.retries
+			options.ConnectRetryInterval = interval
+			root, err := NewJdwpRootfs(ctx, "/mnt", "127.0.0.1", port, options)
+			if connected := err == nil; connected != test.connects {
+				t.Fatalf("connected: %v, error: %v", connected, err)
+			}
+			if err != nil {
+				return
+			}
+			defer root.Connection.Close()
+
+			if _, err := root.JdwpConnection.GetVersion(); err != nil {
+				t.Fatalf("unable to talk to the VM: %s", err)
+			}
+		})
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	var tests = []struct {
+		name string
+		listening bool
+		errno syscall.Errno
+	}{
+		{ name: "redialed", listening: true, errno: syscall.F_OK },
+		{ name: "nobody listening", listening: false, errno: syscall.ECONNREFUSED },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			port := freePort(t)
+			vms := listenFakeVM(t, port, 0)
+
+			root, err := NewJdwpRootfs(context.Background(), "/mnt", "127.0.0.1", port, DefaultJdwpFsOptions())
+			if err != nil {
+				t.Fatalf("unable to connect: %s", err)
+			}
+			defer root.Connection.Close()
+			first := <-vms
+			firstConnection := root.Connection
+
+			if !test.listening {
+				root.Port = freePort(t)
+			}
+			if errno := root.reconnect(); errno != test.errno {
+				t.Fatalf("reconnect: got %v, expected %v", errno, test.errno)
+			}
+
+			// the version is asked again to whichever VM is used now
+			root.JdwpConnection.FlushCaches()
+			asked := first.Received(1, 1)
+			if _, err := root.JdwpConnection.GetVersion(); err != nil {
+				t.Fatalf("unable to talk to the VM: %s", err)
+			}
+
+			if !test.listening {
+				if root.Connection != firstConnection {
+					t.Fatalf("the connection was dropped on a failed reconnect")
+				}
+				if first.Received(1, 1) != asked + 1 {
+					t.Fatalf("the previous connection isn't used")
+				}
+				return
+			}
+
+			if root.Connection == firstConnection {
+				t.Fatalf("the connection wasn't replaced")
+			}
+			var second *fakeVM
+			select {
+			case second = <-vms:
+			case <-time.After(time.Second):
+				t.Fatalf("the VM wasn't dialed again")
+			}
+			if first.Received(1, 1) != asked {
+				t.Fatalf("the previous connection is still used")
+			}
+			if second.Received(1, 1) != 1 {
+				t.Fatalf("the new connection isn't used")
+			}
+		})
+	}
+}
+
+// deadlockHandler has threads 31 and 32 waiting for each other's
+// monitors, and names them; running threads aren't suspended
+func deadlockHandler(running bool) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		var owned, contended = map[byte]uint64 { 31: 41, 32: 42 }, map[byte]uint64 { 31: 42, 32: 41 }
+		switch {
+		case set == 1 && cmd == 4:
+			return 0, fakeConcat(fakeInt(2), fakeLong(31), fakeLong(32))
+		case set == 11 && cmd == 1:
+			return 0, fakeString(fmt.Sprintf("worker-%d", data[7]))
+		case set == 11 && cmd == 4:
+			var suspendStatus uint32 = 1
+			if running {
+				suspendStatus = 0
+			}
+			return 0, fakeConcat(fakeInt(3), fakeInt(suspendStatus))
+		case set == 11 && cmd == 8:
+			return 0, fakeConcat(fakeInt(1), []byte{'L'}, fakeLong(owned[data[7]]))
+		case set == 11 && cmd == 9:
+			return 0, fakeConcat([]byte{'L'}, fakeLong(contended[data[7]]))
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestReadDeadlocks(t *testing.T) {
+	var tests = []struct {
+		name string
+		capabilities []byte
+		running bool
+		hex bool
+		errno syscall.Errno
+		report string
+	}{
+		{
+			name: "deadlocked",
+			capabilities: fakeCapabilities(4, 5),
+			report: "deadlock: 31 (worker-31) -> 32 (worker-32) -> 31\n",
+		},
+		{
+			name: "deadlocked, in hex",
+			capabilities: fakeCapabilities(4, 5),
+			hex: true,
+			report: "deadlock: 0x1f (worker-31) -> 0x20 (worker-32) -> 0x1f\n",
+		},
+		{ name: "running", capabilities: fakeCapabilities(4, 5), running: true, errno: syscall.EBUSY },
+		{ name: "no owned monitors", capabilities: fakeCapabilities(5), errno: syscall.ENOTSUP },
+		{ name: "no contended monitor", capabilities: fakeCapabilities(4), errno: syscall.ENOTSUP },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := DefaultJdwpFsOptions()
+			if test.hex {
+				options.IdRadix = "hex"
+			}
+			conn, vm := startFakeVM(t, deadlockHandler(test.running))
+			vm.Answer(1, 17, 0, test.capabilities)
+			root := &JdwpRootFs {
+				JdwpConnection: conn,
+				Options: options,
+			}
+
+			report, errno := root.readDeadlocks()
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if string(report) != test.report {
+				t.Fatalf("read %q, expected %q", report, test.report)
+			}
+		})
+	}
+}
+
+func TestStrictEventsDir(t *testing.T) {
+	var tests = []struct {
+		name string
+		strict bool
+		panics bool
+	}{
+		{ name: "lenient", strict: false, panics: false },
+		{ name: "strict", strict: true, panics: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, nil)
+
+			// without an event manager, the events dir can't be created
+			root := &JdwpRootFs {
+				AbsoluteMountpoint: "/mnt",
+				JdwpContext: context.Background(),
+				JdwpConnection: conn,
+				Options: JdwpFsOptions { NoThreads: true, NoClasses: true, Strict: test.strict },
+			}
+
+			panicked := func() (panicked bool) {
+				defer func() {
+					panicked = recover() != nil
+				}()
+				fs.NewNodeFS(root, &fs.Options{})
+				return false
+			}()
+			if panicked != test.panics {
+				t.Fatalf("panicked: %v, expected %v", panicked, test.panics)
+			}
+			if test.panics {
+				return
+			}
+
+			listing := rootListing(t, root)
+			if listing["events"] || listing["clear_events"] {
+				t.Fatalf("the events dir is listed without an event manager")
+			}
+			if !listing["objects"] {
+				t.Fatalf("objects isn't listed")
+			}
+		})
+	}
+}
+
+// addrConn is a connection between known addresses
+type addrConn struct {
+	net.Conn
+	local net.Addr
+	remote net.Addr
+}
+
+func (c addrConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c addrConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func TestFormatConnection(t *testing.T) {
+	connectedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name string
+		local net.Addr
+		remote net.Addr
+		since time.Duration
+		formatted string
+	}{
+		{
+			name: "ipv4",
+			local: &net.TCPAddr { IP: net.ParseIP("127.0.0.1"), Port: 41234 },
+			remote: &net.TCPAddr { IP: net.ParseIP("10.0.0.7"), Port: 5005 },
+			since: 90 * time.Second + 400 * time.Millisecond,
+			formatted: "local: 127.0.0.1:41234\nremote: 10.0.0.7:5005\nconnected: 2022-03-01T12:00:00Z\nuptime: 1m30s\n",
+		},
+		{
+			name: "ipv6",
+			local: &net.TCPAddr { IP: net.ParseIP("::1"), Port: 41234 },
+			remote: &net.TCPAddr { IP: net.ParseIP("::1"), Port: 5005 },
+			formatted: "local: [::1]:41234\nremote: [::1]:5005\nconnected: 2022-03-01T12:00:00Z\nuptime: 0s\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := addrConn { local: test.local, remote: test.remote }
+			formatted := formatConnection(conn, connectedAt, connectedAt.Add(test.since))
+			if formatted != test.formatted {
+				t.Fatalf("got %q, expected %q", formatted, test.formatted)
+			}
+		})
+	}
+}
+
+func TestReadConnectionDown(t *testing.T) {
+	root := &JdwpRootFs{}
+
+	connection, errno := root.readConnection()
+	if errno != syscall.F_OK || string(connection) != "connected: down\n" {
+		t.Fatalf("read %q, %v", connection, errno)
+	}
+}
+
+func TestResumeOnMount(t *testing.T) {
+	var tests = []struct {
+		name string
+		suspendStatuses []int
+		resumeOnMount bool
+		suspended bool
+		resumed int
+	}{
+		{ name: "suspended, resumed", suspendStatuses: []int { 1, 1 }, resumeOnMount: true, suspended: true, resumed: 1 },
+		{ name: "suspended, left alone", suspendStatuses: []int { 1, 1 }, suspended: true },
+		{ name: "running", suspendStatuses: []int { 0, 0 }, resumeOnMount: true },
+		{ name: "partly suspended", suspendStatuses: []int { 1, 0 }, resumeOnMount: true },
+		{ name: "no threads", resumeOnMount: true },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, suspendStatusHandler(test.suspendStatuses))
+
+			suspended, err := resumeIfSuspended(conn, JdwpFsOptions { ResumeOnMount: test.resumeOnMount })
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if suspended != test.suspended {
+				t.Fatalf("suspended: %v, expected %v", suspended, test.suspended)
+			}
+			if resumed := vm.Received(1, 9); resumed != test.resumed {
+				t.Fatalf("resumed all %d times, expected %d", resumed, test.resumed)
+			}
+
+			// still reported once resumed
+			root := &JdwpRootFs { suspendedAtStart: suspended }
+			vmSuspended, _ := root.readVmSuspended()
+			if (string(vmSuspended) == "1\n") != test.suspended {
+				t.Fatalf("vm_suspended reads %q", vmSuspended)
+			}
+		})
+	}
+}
+
+func TestConnectOnDemand(t *testing.T) {
+	port := freePort(t)
+	options := DefaultJdwpFsOptions()
+	options.ConnectOnDemand = true
+
+	// mounted with nobody listening
+	root, err := NewJdwpRootfs(context.Background(), "/mnt", "127.0.0.1", port, options)
+	if err != nil {
+		t.Fatalf("unable to mount without the VM: %s", err)
+	}
+	fs.NewNodeFS(root, &fs.Options{})
+	if root.isConnected() {
+		t.Fatalf("connected without a VM")
+	}
+
+	if _, errno := root.Lookup(context.Background(), "threads", &fuse.EntryOut{}); errno != syscall.ECONNREFUSED {
+		t.Fatalf("lookup without the VM: got %v, expected ECONNREFUSED", errno)
+	}
+	if listing := rootListing(t, root); listing["threads"] {
+		t.Fatalf("threads is listed without the VM")
+	}
+
+	// ls threads, once the VM is up
+	vms := listenFakeVM(t, port, 0)
+	threads, errno := root.Lookup(context.Background(), "threads", &fuse.EntryOut{})
+	if errno != syscall.F_OK {
+		t.Fatalf("lookup once the VM is up: %v", errno)
+	}
+	defer root.Connection.Close()
+	if !root.isConnected() {
+		t.Fatalf("not connected after the lookup")
+	}
+
+	vm := <-vms
+	before := vm.Received(1, 4)
+	if _, errno := threads.Operations().(fs.NodeReaddirer).Readdir(context.Background()); errno != syscall.F_OK {
+		t.Fatalf("ls threads: %v", errno)
+	}
+	if listed := vm.Received(1, 4); listed != before + 1 {
+		t.Fatalf("ls threads listed the threads of the VM %d times", listed - before)
+	}
+	if listing := rootListing(t, root); !listing["threads"] || !listing["events"] {
+		t.Fatalf("the nodes of the VM aren't listed: %v", listing)
+	}
+}
+
+func TestPendingJdwp(t *testing.T) {
+	const limit = 1
+	const callers = 3
+
+	release := make(chan struct{})
+	conn, _ := startFakeVM(t, func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		if set == 11 && cmd == 1 {
+			<-release
+			return 0, fakeString("main")
+		}
+		return 0, nil
+	})
+	conn.SetMaxConcurrent(limit)
+	root := &JdwpRootFs{ JdwpConnection: conn }
+
+	if pending, _ := root.readPendingJdwp(); string(pending) != "queued: 0\nin flight: 0\n" {
+		t.Fatalf("read %q with nothing asked", pending)
+	}
+
+	// blocked in the VM, and behind the one blocked
+	done := make(chan struct{}, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			conn.GetThreadName(1)
+			done <- struct{}{}
+		}()
+	}
+
+	expected := fmt.Sprintf("queued: %d\nin flight: %d\n", callers - limit, limit)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		pending, _ := root.readPendingJdwp()
+		if string(pending) == expected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("read %q, expected %q", pending, expected)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	for i := 0; i < callers; i++ {
+		<-done
+	}
+	if pending, _ := root.readPendingJdwp(); string(pending) != "queued: 0\nin flight: 0\n" {
+		t.Fatalf("read %q once answered", pending)
+	}
+}
+
+func TestFlushCaches(t *testing.T) {
+	conn, vm := startFakeVM(t, nil)
+	vm.Answer(2, 5, 0, fakeConcat(fakeInt(1),
+		fakeLong(7), fakeString("run"), fakeString("()V"), fakeInt(1)))
+	vm.Answer(2, 15, 0, fakeConcat(fakeInt(1),
+		fakeLong(7), fakeString("get"), fakeString("()Ljava/lang/Object;"), fakeString("()TT;"), fakeInt(1)))
+	vm.Answer(11, 4, 0, fakeConcat(fakeInt(uint32(jdwp.ThreadRunning)), fakeInt(0)))
+	root := &JdwpRootFs {
+		AbsoluteMountpoint: "/mnt",
+		JdwpContext: context.Background(),
+		JdwpConnection: conn,
+		Options: JdwpFsOptions { NoEvents: true },
+	}
+	fs.NewNodeFS(root, &fs.Options{})
+
+	var accesses = []struct {
+		name string
+		set uint8
+		cmd uint8
+		access func() error
+	}{
+		{
+			name: "methods",
+			set: 2,
+			cmd: 5,
+			access: func() error {
+				_, err := conn.GetMethods(42)
+				return err
+			},
+		},
+		{
+			name: "generic methods",
+			set: 2,
+			cmd: 15,
+			access: func() error {
+				_, err := conn.GetMethodsWithGeneric(42)
+				return err
+			},
+		},
+		{
+			name: "thread status",
+			set: 11,
+			cmd: 4,
+			access: func() error {
+				_, _, err := conn.GetThreadStatus(1)
+				return err
+			},
+		},
+	}
+
+	// cached after the first access
+	for _, access := range accesses {
+		for i := 0; i < 2; i++ {
+			if err := access.access(); err != nil {
+				t.Fatalf("%s: %s", access.name, err)
+			}
+		}
+		if sent := vm.Received(access.set, access.cmd); sent != 1 {
+			t.Fatalf("%s: asked the VM %d times before flushing", access.name, sent)
+		}
+	}
+
+	flushCaches := root.GetChild("flush_caches")
+	if flushCaches == nil {
+		t.Fatalf("flush_caches is missing")
+	}
+	written, errno := flushCaches.Operations().(*TriggerFile).Write(context.Background(), nil, []byte("1\n"), 0)
+	if errno != syscall.F_OK || written != 2 {
+		t.Fatalf("write: %d bytes, %v", written, errno)
+	}
+
+	for _, access := range accesses {
+		if err := access.access(); err != nil {
+			t.Fatalf("%s: %s", access.name, err)
+		}
+		if sent := vm.Received(access.set, access.cmd); sent != 2 {
+			t.Fatalf("%s: asked the VM %d times once flushed, expected 2", access.name, sent)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Jdwp thread group master directory
+// All the thread groups, flattened, by id
+//
+type JdwpThreadGroupMasterDir struct {
+	fs.Inode
+
+	JdwpContext context.Context
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*JdwpThreadGroupMasterDir)(nil))
+var _ = (fs.NodeReaddirer)((*JdwpThreadGroupMasterDir)(nil))
+var _ = (fs.NodeLookuper)((*JdwpThreadGroupMasterDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpThreadGroupMasterDir)(nil))
+
+func NewJdwpThreadGroupMasterDir(ctx context.Context, conn *debug.Connection, options JdwpFsOptions) (*JdwpThreadGroupMasterDir, error) {
+	newThreadGroupDir := &JdwpThreadGroupMasterDir {
+		JdwpContext: ctx,
+		JdwpConnection: conn,
+		Options: options,
+	}
+
+	return newThreadGroupDir, nil
+}
+
+func (d *JdwpThreadGroupMasterDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *JdwpThreadGroupMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *JdwpThreadGroupMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	groupIds, err := d.JdwpConnection.GetAllThreadGroups()
+	if err != nil {
+		log.Printf("unable to read thread groups from the JVM: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+
+	var groupDirEntries []fuse.DirEntry
+	for _, groupId := range groupIds {
+		groupDirEntries = append(groupDirEntries, fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: strconv.FormatUint(uint64(groupId), 10),
+		})
+	}
+
+	return fs.NewListDirStream(groupDirEntries), 0
+}
+
+func (d *JdwpThreadGroupMasterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	groupId, err := strconv.ParseUint(name, 10, 64)
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+
+	// checks the group exists
+	_, err = d.JdwpConnection.GetThreadGroupName(jdwp.ThreadGroupID(groupId))
+	if err != nil {
+		log.Printf("could not access thread group with id %d: %s\n", groupId, err)
+		return nil, syscall.ENOENT
+	}
+
+	groupDir := NewJdwpThreadGroupDir(d.JdwpContext, d.JdwpConnection, jdwp.ThreadGroupID(groupId), d.Options)
+	groupDirInode := d.NewInode(
+		ctx,
+		groupDir,
+		fs.StableAttr{
+			Mode: fuse.S_IFDIR,
+		},
+	)
+
+	return groupDirInode, syscall.F_OK
+}
+
+//
+// Jdwp thread group dir
+//
+type JdwpThreadGroupDir struct {
+	fs.Inode
+
+	GroupId jdwp.ThreadGroupID
+
+	JdwpContext context.Context
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*JdwpThreadGroupDir)(nil))
+var _ = (fs.NodeReaddirer)((*JdwpThreadGroupDir)(nil))
+var _ = (fs.NodeLookuper)((*JdwpThreadGroupDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpThreadGroupDir)(nil))
+
+func NewJdwpThreadGroupDir(ctx context.Context, conn *debug.Connection, id jdwp.ThreadGroupID, options JdwpFsOptions) *JdwpThreadGroupDir {
+	return &JdwpThreadGroupDir {
+		GroupId: id,
+		JdwpContext: ctx,
+		JdwpConnection: conn,
+		Options: options,
+	}
+}
+
+func (d *JdwpThreadGroupDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *JdwpThreadGroupDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *JdwpThreadGroupDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	groupDirContents := [...]string{"name", "parent", "threads", "groups", "control"}
+	var infoFiles []fuse.DirEntry
+	for _, infoFileName := range groupDirContents {
+		infoFileEntry := fuse.DirEntry {
+			Mode: fuse.S_IFREG,
+			Name: infoFileName,
+		}
+		infoFiles = append(infoFiles, infoFileEntry)
+	}
+
+	return fs.NewListDirStream(infoFiles), 0
+}
+
+func (d *JdwpThreadGroupDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	var contents string
+	switch name {
+	case "name":
+		groupName, err := d.JdwpConnection.GetThreadGroupName(d.GroupId)
+		if err != nil {
+			log.Printf("error getting thread group name: %s\n", err)
+			return nil, syscall.EBADF
+		}
+		contents = groupName
+	case "parent":
+		parent, err := d.JdwpConnection.GetThreadGroupParent(d.GroupId)
+		if err != nil {
+			log.Printf("error getting thread group parent: %s\n", err)
+			return nil, syscall.EBADF
+		}
+		// top level groups have no parent
+		if parent != 0 {
+			contents = strconv.FormatUint(uint64(parent), 10)
+		}
+	case "threads", "groups":
+		threads, groups, err := d.JdwpConnection.GetThreadGroupChildren(d.GroupId)
+		if err != nil {
+			log.Printf("error getting thread group children: %s\n", err)
+			return nil, syscall.EBADF
+		}
+
+		var ids []string
+		if name == "threads" {
+			for _, thread := range threads {
+				ids = append(ids, d.Options.formatId(uint64(thread)))
+			}
+		} else {
+			for _, group := range groups {
+				ids = append(ids, strconv.FormatUint(uint64(group), 10))
+			}
+		}
+		contents = strings.Join(ids, "\n")
+	case "control":
+		controlFile := NewThreadGroupControlFile(d.JdwpContext, d.JdwpConnection, d.GroupId, d.Options)
+		controlFileInode := d.NewInode(
+			ctx,
+			&controlFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return controlFileInode, 0
+	default:
+		return nil, syscall.ENOENT
+	}
+
+	infoFile := d.NewInode(
+		ctx,
+		&fs.MemRegularFile {
+			Data: []byte(newlineTerminated(contents)),
+			Attr: staticAttr(d.Options.fileMode()),
+		},
+		fs.StableAttr {
+			Mode: fuse.S_IFREG,
+		})
+	return infoFile, 0
+}
+
+//
+// Thread group control file
+// Suspends or resumes every thread of the group, one by one; writing
+// "suspend recursive" or "running recursive" includes the subgroups
+//
+type ThreadGroupControlFile struct {
+	fs.Inode
+
+	mu sync.Mutex
+
+	GroupId jdwp.ThreadGroupID
+	JdwpContext context.Context
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ThreadGroupControlFile)(nil))
+var _ = (fs.NodeSetattrer)((*ThreadGroupControlFile)(nil))
+var _ = (fs.NodeOpener)((*ThreadGroupControlFile)(nil))
+var _ = (fs.NodeOpendirer)((*ThreadGroupControlFile)(nil))
+var _ = (fs.NodeReader)((*ThreadGroupControlFile)(nil))
+var _ = (fs.NodeWriter)((*ThreadGroupControlFile)(nil))
+
+func NewThreadGroupControlFile(ctx context.Context, conn *debug.Connection, id jdwp.ThreadGroupID, options JdwpFsOptions) ThreadGroupControlFile {
+	return ThreadGroupControlFile {
+		GroupId: id,
+		JdwpContext: ctx,
+		JdwpConnection: conn,
+		Options: options,
+	}
+}
+
+func (c *ThreadGroupControlFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *ThreadGroupControlFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *ThreadGroupControlFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setTimes(out, c.JdwpConnection.GetSuspendAllChangedAt())
+	return 0
+}
+
+func (c *ThreadGroupControlFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+// Read lists the suspend status of each thread of the group
+func (c *ThreadGroupControlFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	threads, err := c.JdwpConnection.GetThreadGroupThreads(c.GroupId, false)
+	if err != nil {
+		log.Printf("error getting threads of group %d: %s\n", c.GroupId, err)
+		return nil, syscall.EACCES
+	}
+
+	var controlFileContents = ""
+	for _, thread := range threads {
+		var status string
+		_, suspendStatus, err := c.JdwpConnection.GetThreadStatus(thread)
+		switch {
+		case err != nil:
+			status = "unknown"
+		case suspendStatus == 0:
+			status = "running"
+		default:
+			status = "suspended"
+		}
+		controlFileContents = fmt.Sprintf("%s%s\t%s\n", controlFileContents, c.Options.formatId(uint64(thread)), status)
+	}
+
+	if offset > int64(len(controlFileContents)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(controlFileContents[offset:])), 0
+}
+
+// Write changes the state of each thread; if some of them fail, the
+// rest are still changed, the failures are logged and EIO is returned
+func (c *ThreadGroupControlFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyThreadSuspend)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *ThreadGroupControlFile) writeToken(data []byte) syscall.Errno {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens := strings.Fields(string(data))
+	if len(tokens) < 1 || len(tokens) > 2 {
+		return syscall.EFAULT
+	}
+
+	var suspend bool
+	switch tokens[0] {
+	case "running", "1":
+		suspend = false
+	case "suspend", "0":
+		suspend = true
+	default:
+		return syscall.EFAULT
+	}
+
+	var recursive = false
+	if len(tokens) == 2 {
+		if tokens[1] != "recursive" {
+			return syscall.EFAULT
+		}
+		recursive = true
+	}
+
+	threads, err := c.JdwpConnection.GetThreadGroupThreads(c.GroupId, recursive)
+	if err != nil {
+		log.Printf("error getting threads of group %d: %s\n", c.GroupId, err)
+		return syscall.EFAULT
+	}
+
+	var failed []string
+	for _, thread := range threads {
+		if suspend {
+			err = c.JdwpConnection.Suspend(thread)
+		} else {
+			err = c.JdwpConnection.Resume(thread)
+		}
+
+		if err != nil {
+			log.Printf("error changing state of thread %d in group %d: %s\n", thread, c.GroupId, err)
+			failed = append(failed, c.Options.formatId(uint64(thread)))
+		}
+	}
+
+	if len(failed) != 0 {
+		log.Printf("group %d: %d of %d threads failed: %s\n",
+			c.GroupId, len(failed), len(threads), strings.Join(failed, ","))
+		return syscall.EIO
+	}
+
+	return syscall.F_OK
+}
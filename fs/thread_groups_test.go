@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// threadGroupHandler has a group of two threads, 31 suspended and 32
+// running
+func threadGroupHandler(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 12 && cmd == 3:
+		return 0, fakeConcat(fakeInt(2), fakeLong(31), fakeLong(32), fakeInt(0))
+	case set == 11 && cmd == 4:
+		var suspendStatus uint32
+		if data[7] == 31 {
+			suspendStatus = 1
+		}
+		return 0, fakeConcat(fakeInt(1), fakeInt(suspendStatus))
+	default:
+		return 0, nil
+	}
+}
+
+func TestThreadGroupControlRead(t *testing.T) {
+	var tests = []struct {
+		hex bool
+		contents string
+	}{
+		{ hex: false, contents: "31\tsuspended\n32\trunning\n" },
+		{ hex: true, contents: "0x1f\tsuspended\n0x20\trunning\n" },
+	}
+
+	for _, test := range tests {
+		options := JdwpFsOptions { IdRadix: "dec" }
+		if test.hex {
+			options.IdRadix = "hex"
+		}
+		conn, _ := startFakeVM(t, threadGroupHandler)
+		controlFile := NewThreadGroupControlFile(context.Background(), conn, jdwp.ThreadGroupID(1), options)
+
+		result, errno := controlFile.Read(context.Background(), nil, make([]byte, 4096), 0)
+		if errno != syscall.F_OK {
+			t.Fatalf("hex %v: read: %v", test.hex, errno)
+		}
+		contents, _ := result.Bytes(make([]byte, 4096))
+		if string(contents) != test.contents {
+			t.Errorf("hex %v: read %q, expected %q", test.hex, contents, test.contents)
+		}
+	}
+}
+
+// threadTreeHandler has group 1 with threads 31 and 32, and its subgroup
+// 2 with thread 33; it records the threads suspended and resumed, and
+// fails the ones in failing
+func threadTreeHandler(failing map[byte]bool, calls map[[2]byte]int, mu *sync.Mutex) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 12 && cmd == 3 && data[7] == 1:
+			return 0, fakeConcat(fakeInt(2), fakeLong(31), fakeLong(32), fakeInt(1), fakeLong(2))
+		case set == 12 && cmd == 3:
+			return 0, fakeConcat(fakeInt(1), fakeLong(33), fakeInt(0))
+		case set == 11 && (cmd == 2 || cmd == 3):
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls[[2]byte{cmd, data[7]}]++
+			if failing[data[7]] {
+				// INVALID_THREAD
+				return 10, nil
+			}
+			return 0, nil
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestThreadGroupControlWrite(t *testing.T) {
+	const suspend = 2
+	const resume = 3
+
+	var tests = []struct {
+		name string
+		token string
+		failing []byte
+		errno syscall.Errno
+		calls map[[2]byte]int
+	}{
+		{
+			name: "suspend",
+			token: "suspend\n",
+			calls: map[[2]byte]int { {suspend, 31}: 1, {suspend, 32}: 1 },
+		},
+		{
+			name: "resume",
+			token: "running\n",
+			calls: map[[2]byte]int { {resume, 31}: 1, {resume, 32}: 1 },
+		},
+		{
+			name: "suspend recursive",
+			token: "0 recursive\n",
+			calls: map[[2]byte]int { {suspend, 31}: 1, {suspend, 32}: 1, {suspend, 33}: 1 },
+		},
+		{
+			name: "partial failure",
+			token: "suspend\n",
+			failing: []byte { 31 },
+			errno: syscall.EIO,
+			calls: map[[2]byte]int { {suspend, 31}: 1, {suspend, 32}: 1 },
+		},
+		{
+			name: "unknown token",
+			token: "stop\n",
+			errno: syscall.EFAULT,
+			calls: map[[2]byte]int {},
+		},
+		{
+			name: "unknown option",
+			token: "suspend deeply\n",
+			errno: syscall.EFAULT,
+			calls: map[[2]byte]int {},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var failing = map[byte]bool{}
+			for _, thread := range test.failing {
+				failing[thread] = true
+			}
+			var calls = map[[2]byte]int{}
+
+			conn, _ := startFakeVM(t, threadTreeHandler(failing, calls, &mu))
+			controlFile := NewThreadGroupControlFile(context.Background(), conn, jdwp.ThreadGroupID(1), JdwpFsOptions{})
+
+			if errno := controlFile.writeToken([]byte(test.token)); errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(calls, test.calls) {
+				t.Fatalf("got calls %v, expected %v", calls, test.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+//
+// Thread select file
+// Writing "suspend <glob>" or "resume <glob>" suspends or resumes the
+// threads whose name matches the glob; reading reports how many were
+// affected by the last write
+//
+type ThreadSelectFile struct {
+	fs.Inode
+
+	threadsDir *JdwpThreadMasterDir
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ThreadSelectFile)(nil))
+var _ = (fs.NodeSetattrer)((*ThreadSelectFile)(nil))
+var _ = (fs.NodeOpener)((*ThreadSelectFile)(nil))
+var _ = (fs.NodeOpendirer)((*ThreadSelectFile)(nil))
+var _ = (fs.NodeReader)((*ThreadSelectFile)(nil))
+var _ = (fs.NodeWriter)((*ThreadSelectFile)(nil))
+
+func NewThreadSelectFile(threadsDir *JdwpThreadMasterDir, options JdwpFsOptions) ThreadSelectFile {
+	return ThreadSelectFile {
+		threadsDir: threadsDir,
+		Options: options,
+	}
+}
+
+func (c *ThreadSelectFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *ThreadSelectFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *ThreadSelectFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setTimes(out, c.threadsDir.JdwpConnection.GetSuspendAllChangedAt())
+	return 0
+}
+
+func (c *ThreadSelectFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (c *ThreadSelectFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	c.threadsDir.mu.Lock()
+	report := c.threadsDir.selectReport
+	c.threadsDir.mu.Unlock()
+
+	if offset > int64(len(report)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(report[offset:])), 0
+}
+
+// Write fails with EIO if some of the matching threads couldn't be
+// changed; the report says which ones
+func (c *ThreadSelectFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyThreadSuspend)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *ThreadSelectFile) writeToken(data []byte) syscall.Errno {
+	tokens := strings.SplitN(strings.TrimSpace(string(data)), " ", 2)
+	if len(tokens) != 2 {
+		return syscall.EBADMSG
+	}
+
+	var suspend bool
+	switch tokens[0] {
+	case "suspend":
+		suspend = true
+	case "resume":
+		suspend = false
+	default:
+		return syscall.EBADMSG
+	}
+
+	glob, err := compileGlob(strings.TrimSpace(tokens[1]))
+	if err != nil {
+		return syscall.EINVAL
+	}
+
+	conn := c.threadsDir.JdwpConnection
+	threads, err := conn.GetAllThreads()
+	if err != nil {
+		log.Printf("unable to read threads from the JVM: %s\n", err)
+		return syscall.EFAULT
+	}
+
+	c.threadsDir.mu.Lock()
+	defer c.threadsDir.mu.Unlock()
+
+	var matched = 0
+	var failures []string
+	for _, thread := range threads {
+		name, err := conn.GetThreadName(thread)
+		if err != nil {
+			// the thread may have died since it was listed
+			log.Printf("error getting name of thread %d: %s\n", thread, err)
+			continue
+		}
+
+		if !glob.MatchString(name) {
+			continue
+		}
+		matched++
+
+		if suspend {
+			err = conn.Suspend(thread)
+		} else {
+			err = conn.Resume(thread)
+		}
+
+		if err != nil {
+			log.Printf("error changing state of thread %d: %s\n", thread, err)
+			failures = append(failures, fmt.Sprintf("%d\t%s", thread, err))
+		}
+	}
+
+	var verb = "resumed"
+	if suspend {
+		verb = "suspended"
+	}
+
+	report := fmt.Sprintf("%s %d of %d\n", verb, matched - len(failures), matched)
+	for _, failure := range failures {
+		report = fmt.Sprintf("%sfailed %s\n", report, failure)
+	}
+	c.threadsDir.selectReport = report
+
+	if len(failures) != 0 {
+		return syscall.EIO
+	}
+
+	return syscall.F_OK
+}
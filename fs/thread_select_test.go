@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// selectThreadNames are the threads of selectRecorder, by id; the one
+// named pool-3-gone can't be suspended or resumed anymore
+var selectThreadNames = map[byte]string {
+	31: "pool-1-thread-1",
+	32: "pool-1-thread-2",
+	33: "main",
+	34: "pool-2-thread-1",
+	35: "pool-3-gone",
+}
+
+// selectRecorder notes the threads suspended and resumed, by name
+type selectRecorder struct {
+	mu sync.Mutex
+	changed []string
+}
+
+func (r *selectRecorder) handle(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+	switch {
+	case set == 1 && cmd == 4:
+		return 0, fakeConcat(fakeInt(5), fakeLong(31), fakeLong(32), fakeLong(33), fakeLong(34), fakeLong(35))
+	case set == 11 && cmd == 1:
+		return 0, fakeString(selectThreadNames[data[7]])
+	case set == 11 && (cmd == 2 || cmd == 3):
+		if selectThreadNames[data[7]] == "pool-3-gone" {
+			return uint16(jdwp.ErrInvalidThread), nil
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.changed = append(r.changed, selectThreadNames[data[7]])
+		return 0, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (r *selectRecorder) Changed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := append([]string(nil), r.changed...)
+	sort.Strings(changed)
+	return changed
+}
+
+func TestThreadSelect(t *testing.T) {
+	var tests = []struct {
+		name string
+		token string
+		errno syscall.Errno
+		report string
+		changed []string
+	}{
+		{
+			name: "suspend a pool",
+			token: "suspend pool-1-*\n",
+			report: "suspended 2 of 2\n",
+			changed: []string { "pool-1-thread-1", "pool-1-thread-2" },
+		},
+		{
+			name: "resume by name",
+			token: "resume main\n",
+			report: "resumed 1 of 1\n",
+			changed: []string { "main" },
+		},
+		{
+			name: "single character",
+			token: "suspend pool-?-thread-1\n",
+			report: "suspended 2 of 2\n",
+			changed: []string { "pool-1-thread-1", "pool-2-thread-1" },
+		},
+		{
+			name: "no match",
+			token: "suspend worker-*\n",
+			report: "suspended 0 of 0\n",
+		},
+		{
+			name: "a thread failing",
+			token: "suspend pool-*\n",
+			errno: syscall.EIO,
+			report: "suspended 3 of 4\nfailed 35\t",
+			changed: []string { "pool-1-thread-1", "pool-1-thread-2", "pool-2-thread-1" },
+		},
+		{ name: "unknown action", token: "stop pool-*\n", errno: syscall.EBADMSG },
+		{ name: "no glob", token: "suspend\n", errno: syscall.EBADMSG },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &selectRecorder{}
+			conn, _ := startFakeVM(t, recorder.handle)
+			threadsDir, _ := NewJdwpThreadMasterDir(context.Background(), conn, JdwpFsOptions{}, nil)
+			selectFile := NewThreadSelectFile(threadsDir, JdwpFsOptions{})
+
+			if errno := selectFile.writeToken([]byte(test.token)); errno != test.errno {
+				t.Fatalf("writing %q: got %v, expected %v", test.token, errno, test.errno)
+			}
+			if report := readNode(t, &selectFile, 0); !strings.HasPrefix(report, test.report) {
+				t.Fatalf("reported %q, expected %q", report, test.report)
+			}
+			if changed := recorder.Changed(); !reflect.DeepEqual(changed, test.changed) {
+				t.Fatalf("changed %v, expected %v", changed, test.changed)
+			}
+		})
+	}
+}
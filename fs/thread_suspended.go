@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Thread suspended file
+// Reads 1 while the thread is suspended, 0 otherwise; writing 1 makes
+// sure it is suspended, and 0 that it runs, however many times it was
+// suspended, so writing the same value twice changes nothing
+//
+type ThreadSuspendedFile struct {
+	fs.Inode
+
+	mu sync.Mutex
+
+	ThreadId jdwp.ThreadID
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ThreadSuspendedFile)(nil))
+var _ = (fs.NodeSetattrer)((*ThreadSuspendedFile)(nil))
+var _ = (fs.NodeOpener)((*ThreadSuspendedFile)(nil))
+var _ = (fs.NodeOpendirer)((*ThreadSuspendedFile)(nil))
+var _ = (fs.NodeReader)((*ThreadSuspendedFile)(nil))
+var _ = (fs.NodeWriter)((*ThreadSuspendedFile)(nil))
+
+func NewThreadSuspendedFile(conn *debug.Connection, id jdwp.ThreadID, options JdwpFsOptions) ThreadSuspendedFile {
+	return ThreadSuspendedFile {
+		ThreadId: id,
+		JdwpConnection: conn,
+		Options: options,
+	}
+}
+
+func (c *ThreadSuspendedFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & (
+		syscall.O_APPEND |
+		syscall.O_CLOEXEC |
+		syscall.O_EXCL |
+		syscall.O_NOCTTY) != 0 {
+		return nil, 0, syscall.EBADR
+	}
+
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *ThreadSuspendedFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (c *ThreadSuspendedFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = c.Options.controlMode()
+	setTimes(out, c.JdwpConnection.GetSuspendChangedAt(c.ThreadId))
+	return 0
+}
+
+func (c *ThreadSuspendedFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (c *ThreadSuspendedFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count, err := c.JdwpConnection.GetSuspendCount(c.ThreadId)
+	if err != nil {
+		log.Printf("error getting suspend count of thread %d: %s\n", c.ThreadId, err)
+		return nil, threadErrno(err, syscall.EACCES)
+	}
+
+	var contents = "0\n"
+	if count > 0 {
+		contents = "1\n"
+	}
+
+	if offset > int64(len(contents)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(contents[offset:])), 0
+}
+
+func (c *ThreadSuspendedFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyThreadSuspend)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *ThreadSuspendedFile) writeToken(data []byte) syscall.Errno {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var suspend bool
+	switch strings.TrimSpace(string(data)) {
+	case "1":
+		suspend = true
+	case "0":
+		suspend = false
+	default:
+		return syscall.EBADMSG
+	}
+
+	count, err := c.JdwpConnection.GetSuspendCount(c.ThreadId)
+	if err != nil {
+		log.Printf("error getting suspend count of thread %d: %s\n", c.ThreadId, err)
+		return threadErrno(err, syscall.EACCES)
+	}
+
+	switch {
+	case suspend && count == 0:
+		err = c.JdwpConnection.Suspend(c.ThreadId)
+	case !suspend:
+		// each resume only undoes one suspension
+		for ; count > 0 && err == nil; count-- {
+			err = c.JdwpConnection.Resume(c.ThreadId)
+		}
+	}
+
+	if err != nil {
+		log.Printf("error changing the suspension of thread %d: %s\n", c.ThreadId, err)
+		return threadErrno(err, syscall.EIO)
+	}
+
+	return syscall.F_OK
+}
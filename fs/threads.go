@@ -5,17 +5,21 @@ package fs
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
 	"syscall"
 	"log"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 
 	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
 )
 
 //
@@ -41,27 +45,42 @@ type JdwpThreadMasterDir struct {
 	fs.Inode
 
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
+	watchdog *Watchdog
+
+	// the outcome of the last write to select
+	mu sync.Mutex
+	selectReport string
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpThreadMasterDir)(nil))
 var _ = (fs.NodeReaddirer)((*JdwpThreadMasterDir)(nil))
 var _ = (fs.NodeLookuper)((*JdwpThreadMasterDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpThreadMasterDir)(nil))
 
-func NewJdwpThreadMasterDir(ctx context.Context, conn *jdwp.Connection) (*JdwpThreadMasterDir, error) {
+func NewJdwpThreadMasterDir(ctx context.Context, conn *debug.Connection, options JdwpFsOptions, watchdog *Watchdog) (*JdwpThreadMasterDir, error) {
 	newThreadDir := &JdwpThreadMasterDir {
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+		Options: options,
+		watchdog: watchdog,
 	}
 
 	return newThreadDir, nil
 }
 
 func (d *JdwpThreadMasterDir) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *JdwpThreadMasterDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *JdwpThreadMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	// thread directories
 	threadIds, err := d.JdwpConnection.GetAllThreads()
@@ -69,10 +88,11 @@ func (d *JdwpThreadMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscal
 		log.Println("unable to read threads from the JVM")
 		return nil, syscall.EFAULT
 	}
+	sortThreads(threadIds)
 
 	var threadDirEntries []fuse.DirEntry
 	for _, threadId := range threadIds {
-		newThreadDir, err := NewJdwpThreadDir(d.JdwpContext, d.JdwpConnection, threadId)
+		newThreadDir, err := NewJdwpThreadDir(d.JdwpContext, d.JdwpConnection, threadId, d.Options, d.watchdog)
 		if err != nil {
 			log.Printf("error creating thread dir: %s", err)
 			return nil, syscall.EFAULT
@@ -87,14 +107,53 @@ func (d *JdwpThreadMasterDir) Readdir(ctx context.Context) (fs.DirStream, syscal
 		Name: "control",
 	}
 	
-	threadDirEntries = append(threadDirEntries, masterControlEntry)
+	selectEntry := fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "select",
+	}
+
+	indexEntry := fuse.DirEntry {
+		Mode: fuse.S_IFREG,
+		Name: "index",
+	}
+
+	threadDirEntries = append(threadDirEntries, masterControlEntry, selectEntry, indexEntry)
 	
 	return fs.NewListDirStream(threadDirEntries), 0
 }
 
+// sortThreads orders threads by id, which is roughly the order they
+// were created in
+func sortThreads(threadIds []jdwp.ThreadID) {
+	sort.Slice(threadIds, func(i, j int) bool { return threadIds[i] < threadIds[j] })
+}
+
+// readIndex lists the threads by id, with their names, one per line
+func (d *JdwpThreadMasterDir) readIndex() ([]byte, syscall.Errno) {
+	threadIds, err := d.JdwpConnection.GetAllThreads()
+	if err != nil {
+		log.Printf("unable to read threads from the JVM: %s\n", err)
+		return nil, syscall.EFAULT
+	}
+	sortThreads(threadIds)
+
+	var index = ""
+	for _, threadId := range threadIds {
+		name, err := d.JdwpConnection.GetThreadName(threadId)
+		if err != nil {
+			// the thread may have died since it was listed
+			log.Printf("error getting name of thread %d: %s\n", threadId, err)
+			continue
+		}
+		index = fmt.Sprintf("%s%s\t%s\n", index, d.Options.formatId(uint64(threadId)), name)
+	}
+
+	return []byte(index), 0
+}
+
 func (d *JdwpThreadMasterDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	if name == "control" {
-		masterControlFile := NewThreadMasterControlFile(d.JdwpContext, d.JdwpConnection)
+		masterControlFile := NewThreadMasterControlFile(d.JdwpContext, d.JdwpConnection, d.Options)
 		masterControlInode := d.NewInode(
 			ctx,
 			&masterControlFile,
@@ -105,13 +164,39 @@ func (d *JdwpThreadMasterDir) Lookup(ctx context.Context, name string, out *fuse
 
 		return masterControlInode, syscall.F_OK
 	}
+
+	if name == "select" {
+		selectFile := NewThreadSelectFile(d, d.Options)
+		selectInode := d.NewInode(
+			ctx,
+			&selectFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+
+		return selectInode, syscall.F_OK
+	}
+
+	if name == "index" {
+		indexFile := NewInfoFile(d.readIndex, d.Options)
+		indexInode := d.NewInode(
+			ctx,
+			&indexFile,
+			fs.StableAttr{
+				Mode: fuse.S_IFREG,
+			},
+		)
+
+		return indexInode, syscall.F_OK
+	}
 	
-	threadId, err := strconv.ParseUint(name, 10, 64)
+	threadId, err := d.Options.parseId(name)
 	if err != nil {
 		return nil, syscall.ENOENT
 	}
 
-	threadEntry, err := NewJdwpThreadDir(d.JdwpContext, d.JdwpConnection, jdwp.ThreadID(threadId))
+	threadEntry, err := NewJdwpThreadDir(d.JdwpContext, d.JdwpConnection, jdwp.ThreadID(threadId), d.Options, d.watchdog)
 	if err != nil {
 		log.Printf("could not access thread with id %d\n", threadId)
 		return nil, syscall.ENOENT
@@ -137,19 +222,25 @@ type JdwpThreadDir struct {
 	ThreadId jdwp.ThreadID
 	
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection	
+	JdwpConnection *debug.Connection	
+
+	Options JdwpFsOptions
+	watchdog *Watchdog
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpThreadDir)(nil))
 // var _ = (fs.NodeOnAdder)((*JdwpThreadDir)(nil))
 var _ = (fs.NodeReaddirer)((*JdwpThreadDir)(nil))
 var _ = (fs.NodeLookuper)((*JdwpThreadDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpThreadDir)(nil))
 
-func NewJdwpThreadDir(ctx context.Context, conn *jdwp.Connection, id jdwp.ThreadID) (*JdwpThreadDir, error) {
+func NewJdwpThreadDir(ctx context.Context, conn *debug.Connection, id jdwp.ThreadID, options JdwpFsOptions, watchdog *Watchdog) (*JdwpThreadDir, error) {
 	newThreadDir := &JdwpThreadDir {
 		ThreadId: id,
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+		Options: options,
+		watchdog: watchdog,
 	}
 
 	return newThreadDir, nil
@@ -158,17 +249,22 @@ func NewJdwpThreadDir(ctx context.Context, conn *jdwp.Connection, id jdwp.Thread
 func (d *JdwpThreadDir) GetDirEntry(ctx context.Context) fuse.DirEntry {
 	return fuse.DirEntry {
 		Mode: fuse.S_IFREG,
-		Name: strconv.FormatUint(uint64(d.ThreadId), 10),
+		Name: d.Options.formatId(uint64(d.ThreadId)),
 	}
 }
 
 func (d *JdwpThreadDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *JdwpThreadDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *JdwpThreadDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	threadDirContents := [...]string{"name", "threadStatus", "suspendStatus", "control"}
+	threadDirContents := [...]string{"name", "threadStatus", "suspendStatus", "statusChangedAt", "suspendOwnership", "control", "suspended", "stackTrace"}
 	var infoFiles []fuse.DirEntry
 	for _, infoFileName := range threadDirContents {
 		infoFileEntry := fuse.DirEntry {
@@ -177,6 +273,11 @@ func (d *JdwpThreadDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errn
 		}
 		infoFiles = append(infoFiles, infoFileEntry)
 	}
+
+	infoFiles = append(infoFiles, fuse.DirEntry {
+		Mode: fuse.S_IFDIR,
+		Name: "frames",
+	})
 	
 	return fs.NewListDirStream(infoFiles), 0
 }
@@ -184,19 +285,15 @@ func (d *JdwpThreadDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errn
 func (d *JdwpThreadDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	switch name {
 	case "name":
-		threadName, err := d.JdwpConnection.GetThreadName(d.ThreadId)
+		_, err := d.JdwpConnection.GetThreadName(d.ThreadId)
 		if err != nil {
 			log.Printf("error getting thread name: %s", err)
 			return nil, syscall.EBADF
 		}
+		threadNameFile := NewThreadNameFile(d.JdwpConnection, d.ThreadId, d.Options.AllowInvoke, d.Options)
 		nameFile := d.NewInode(
 			ctx,
-			&fs.MemRegularFile {
-				Data: []byte(threadName),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
-			},
+			&threadNameFile,
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
 			})
@@ -211,10 +308,8 @@ func (d *JdwpThreadDir) Lookup(ctx context.Context, name string, out *fuse.Entry
 		threadStatusFile := d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(threadStatus.String()),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(threadStatus.String())),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
@@ -230,17 +325,51 @@ func (d *JdwpThreadDir) Lookup(ctx context.Context, name string, out *fuse.Entry
 		suspendStatusFile := d.NewInode(
 			ctx,
 			&fs.MemRegularFile {
-				Data: []byte(suspendStatus.String()),
-				Attr: fuse.Attr{
-					Mode: 0444,
-				},
+				Data: []byte(newlineTerminated(suspendStatus.String())),
+				Attr: staticAttr(d.Options.fileMode()),
 			},
 			fs.StableAttr {
 				Mode: fuse.S_IFREG,
 			})
 		return suspendStatusFile, 0
+	case "statusChangedAt":
+		changedAtFile := NewInfoFile(d.readStatusChangedAt, d.Options)
+		changedAtFileInode := d.NewInode(
+			ctx,
+			&changedAtFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return changedAtFileInode, 0
+	case "suspendOwnership":
+		ownershipFile := NewInfoFile(d.readSuspendOwnership, d.Options)
+		ownershipFileInode := d.NewInode(
+			ctx,
+			&ownershipFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return ownershipFileInode, 0
+	case "stackTrace":
+		stackTraceFile := NewInspectionFile(d.readStackTrace, d.watchdog, d.Options)
+		stackTraceInode := d.NewInode(
+			ctx,
+			&stackTraceFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return stackTraceInode, 0
+	case "frames":
+		framesDir := NewThreadFramesDir(d.JdwpConnection, d.ThreadId, newValueRenderer(d.JdwpConnection, d.Options), d.watchdog, d.Options)
+		framesDirInode := d.NewInode(
+			ctx,
+			&framesDir,
+			fs.StableAttr {
+				Mode: fuse.S_IFDIR,
+			})
+		return framesDirInode, 0
 	case "control":
-		controlFile := NewThreadControlFile(d.JdwpContext, d.JdwpConnection, d.ThreadId)
+		controlFile := NewThreadControlFile(d.JdwpContext, d.JdwpConnection, d.ThreadId, d.Options)
 		controlFileInode := d.NewInode(
 			ctx,
 			&controlFile,
@@ -248,11 +377,199 @@ func (d *JdwpThreadDir) Lookup(ctx context.Context, name string, out *fuse.Entry
 				Mode: fuse.S_IFREG,
 			})
 		return controlFileInode, 0
+	case "suspended":
+		suspendedFile := NewThreadSuspendedFile(d.JdwpConnection, d.ThreadId, d.Options)
+		suspendedFileInode := d.NewInode(
+			ctx,
+			&suspendedFile,
+			fs.StableAttr {
+				Mode: fuse.S_IFREG,
+			})
+		return suspendedFileInode, 0
 	default:
 		return nil, syscall.ENOENT
 	}
 }
 
+// readStatusChangedAt tells when jdwpfs saw the status of the thread
+// change last; the status is read first, so a change since is seen
+func (d *JdwpThreadDir) readStatusChangedAt() ([]byte, syscall.Errno) {
+	_, _, err := d.JdwpConnection.GetThreadStatus(d.ThreadId)
+	if err != nil {
+		log.Printf("error getting thread status: %s\n", err)
+		return nil, syscall.EBADF
+	}
+
+	changedAt := d.JdwpConnection.GetStatusChangedAt(d.ThreadId)
+
+	return []byte(newlineTerminated(changedAt.Format(time.RFC3339Nano))), 0
+}
+
+// readSuspendOwnership tells how many of the suspensions of the thread
+// jdwpfs made, out of all of them; the others are made by events, or by
+// other debuggers, and keep the thread suspended after jdwpfs resumes it
+func (d *JdwpThreadDir) readSuspendOwnership() ([]byte, syscall.Errno) {
+	total, err := d.JdwpConnection.GetSuspendCount(d.ThreadId)
+	if err != nil {
+		log.Printf("error getting suspend count of thread %d: %s\n", d.ThreadId, err)
+		return nil, syscall.EBADF
+	}
+
+	owned := d.JdwpConnection.GetOwnedSuspensionCount(d.ThreadId)
+
+	return []byte(fmt.Sprintf("owned: %d\ntotal: %d\n", owned, total)), 0
+}
+
+// readStackTrace renders the frames the way Java does; the thread has
+// to be suspended
+func (d *JdwpThreadDir) readStackTrace() ([]byte, syscall.Errno) {
+	_, suspendStatus, err := d.JdwpConnection.GetThreadStatus(d.ThreadId)
+	if err != nil {
+		log.Printf("error getting thread status: %s\n", err)
+		return nil, syscall.EBADF
+	}
+
+	if suspendStatus == 0 {
+		return nil, syscall.EBUSY
+	}
+
+	elements, err := d.JdwpConnection.GetStackTrace(d.ThreadId)
+	if err != nil {
+		log.Printf("error getting stack trace of thread %d: %s\n", d.ThreadId, err)
+		return nil, syscall.EFAULT
+	}
+
+	var stackTrace = ""
+	for _, element := range elements {
+		stackTrace = stackTrace + element.String() + "\n"
+	}
+
+	return []byte(stackTrace), 0
+}
+
+//
+// Thread name file
+// Read only, unless invocations are allowed; then writing renames the
+// thread through Thread.setName
+//
+type ThreadNameFile struct {
+	fs.Inode
+
+	ThreadId jdwp.ThreadID
+	JdwpConnection *debug.Connection
+
+	allowInvoke bool
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*ThreadNameFile)(nil))
+var _ = (fs.NodeSetattrer)((*ThreadNameFile)(nil))
+var _ = (fs.NodeOpener)((*ThreadNameFile)(nil))
+var _ = (fs.NodeOpendirer)((*ThreadNameFile)(nil))
+var _ = (fs.NodeReader)((*ThreadNameFile)(nil))
+var _ = (fs.NodeWriter)((*ThreadNameFile)(nil))
+
+func NewThreadNameFile(conn *debug.Connection, id jdwp.ThreadID, allowInvoke bool, options JdwpFsOptions) ThreadNameFile {
+	return ThreadNameFile {
+		ThreadId: id,
+		JdwpConnection: conn,
+		allowInvoke: allowInvoke,
+		Options: options,
+	}
+}
+
+func (f *ThreadNameFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	if flags & syscall.O_ACCMODE != syscall.O_RDONLY && !f.allowInvoke {
+		return nil, 0, syscall.EROFS
+	}
+
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (f *ThreadNameFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (f *ThreadNameFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if f.allowInvoke {
+		out.Mode = f.Options.writableFileMode()
+	} else {
+		out.Mode = f.Options.fileMode()
+	}
+	setMountTimes(out)
+	return 0
+}
+
+func (f *ThreadNameFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if !f.allowInvoke {
+		return syscall.EROFS
+	}
+
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	out.Attr.Mode = in.Mode
+	out.Atime = in.Atime
+	out.Atimensec = in.Atimensec
+
+	return syscall.F_OK
+}
+
+func (f *ThreadNameFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	threadName, err := f.JdwpConnection.GetThreadName(f.ThreadId)
+	if err != nil {
+		log.Printf("error getting thread name: %s\n", err)
+		return nil, syscall.EBADF
+	}
+
+	threadName = newlineTerminated(threadName)
+	if offset > int64(len(threadName)) {
+		return nil, syscall.EBADR
+	}
+
+	return fuse.ReadResultData([]byte(threadName[offset:])), 0
+}
+
+func (f *ThreadNameFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	if !f.allowInvoke {
+		return 0, syscall.EROFS
+	}
+
+	errno = f.Options.checkPolicy(PolicyMethodInvoke)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	if off != 0 {
+		return 0, syscall.EINVAL
+	}
+
+	_, suspendStatus, err := f.JdwpConnection.GetThreadStatus(f.ThreadId)
+	if err != nil {
+		log.Printf("error getting thread status: %s\n", err)
+		return 0, syscall.EBADF
+	}
+
+	if suspendStatus == 0 {
+		return 0, syscall.EBUSY
+	}
+
+	newName := strings.TrimRight(string(data), "\n")
+	err = f.JdwpConnection.SetThreadName(f.ThreadId, newName)
+	if err != nil {
+		log.Printf("error renaming thread %d: %s\n", f.ThreadId, err)
+		return 0, syscall.EROFS
+	}
+
+	return uint32(len(data)), 0
+}
 
 //
 // Thread master control file
@@ -264,23 +581,32 @@ type ThreadMasterControlFile struct {
 	
 	ThreadId jdwp.ThreadID
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*ThreadMasterControlFile)(nil))
 var _ = (fs.NodeSetattrer)((*ThreadMasterControlFile)(nil))
 var _ = (fs.NodeOpener)((*ThreadMasterControlFile)(nil))
+var _ = (fs.NodeOpendirer)((*ThreadMasterControlFile)(nil))
 var _ = (fs.NodeReader)((*ThreadMasterControlFile)(nil))
 var _ = (fs.NodeWriter)((*ThreadMasterControlFile)(nil))
 
-func NewThreadMasterControlFile(ctx context.Context, conn *jdwp.Connection) ThreadMasterControlFile {
+func NewThreadMasterControlFile(ctx context.Context, conn *debug.Connection, options JdwpFsOptions) ThreadMasterControlFile {
 	return ThreadMasterControlFile {
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+		Options: options,
 	}
 }
 
 func (c *ThreadMasterControlFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if flags & (
@@ -291,13 +617,26 @@ func (c *ThreadMasterControlFile) Open(ctx context.Context, flags uint32) (fh fs
 		return nil, 0, syscall.EBADR
 	}
 
-	return nil, fuse.FOPEN_DIRECT_IO, 0
+	handle := NewControlHandle(c.writeToken)
+	if flags & syscall.O_ACCMODE != syscall.O_WRONLY {
+		handle.contents, errno = c.readContents()
+		if errno != syscall.F_OK {
+			return nil, 0, errno
+		}
+	}
+
+	return handle, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *ThreadMasterControlFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
 }
 
 func (c *ThreadMasterControlFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	out.Mode = 0660
+	out.Mode = c.Options.controlMode()
+	setTimes(out, c.JdwpConnection.GetSuspendAllChangedAt())
 	return 0
 }
 
@@ -317,32 +656,93 @@ func (c *ThreadMasterControlFile) Setattr(ctx context.Context, _ fs.FileHandle,
 	return syscall.F_OK	
 }
 
-func (c *ThreadMasterControlFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
-	if offset > 0 {
+// Read reports whether all the threads are suspended, running, or a mix
+// of both, as they were when the file was opened; the statuses of every
+// thread are read once per open, not on every read
+func (c *ThreadMasterControlFile) Read(ctx context.Context, fh fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	var output []byte
+	handle, ok := fh.(*ControlHandle)
+	if ok && handle.contents != nil {
+		output = handle.contents
+	} else {
+		var errno syscall.Errno
+		output, errno = c.readContents()
+		if errno != syscall.F_OK {
+			return nil, errno
+		}
+	}
+
+	if offset > int64(len(output)) {
 		return nil, syscall.EBADR
 	}
 
-	output := []byte("")
+	return fuse.ReadResultData(output[offset:]), 0
+}
+
+func (c *ThreadMasterControlFile) readContents() ([]byte, syscall.Errno) {
+	threads, err := c.JdwpConnection.GetAllThreads()
+	if err != nil {
+		log.Printf("error getting all threads: %s\n", err)
+		return nil, syscall.EACCES
+	}
+
+	var suspendStatuses []jdwp.SuspendStatus
+	for _, thread := range threads {
+		_, suspendStatus, err := c.JdwpConnection.GetThreadStatus(thread)
+		if err != nil {
+			// the thread may have died since it was listed
+			log.Printf("error getting status of thread %d: %s\n", thread, err)
+			continue
+		}
+		suspendStatuses = append(suspendStatuses, suspendStatus)
+	}
 
-	return fuse.ReadResultData(output), 0
+	return []byte(newlineTerminated(aggregateSuspendStatus(suspendStatuses))), syscall.F_OK
+}
+
+// aggregateSuspendStatus is suspended or running when all the threads
+// agree, mixed otherwise; no threads at all count as running
+func aggregateSuspendStatus(suspendStatuses []jdwp.SuspendStatus) string {
+	var suspended = 0
+	for _, suspendStatus := range suspendStatuses {
+		if suspendStatus != 0 {
+			suspended++
+		}
+	}
+
+	switch {
+	case suspended == 0:
+		return "running"
+	case suspended == len(suspendStatuses):
+		return "suspended"
+	default:
+		return "mixed"
+	}
 }
 
 // mostly doesn't work, truncation has to be implemented
-func (c *ThreadMasterControlFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+func (c *ThreadMasterControlFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyThreadSuspend)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *ThreadMasterControlFile) writeToken(data []byte) syscall.Errno {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	var err error
 	var writtenState jdwp.SuspendStatus
         switch strings.TrimSpace(string(data)) {
-	case "running":
-	case "1":
+	case "running", "1":
 		writtenState = 1
-	case "suspend":
-	case "0":
+	case "suspend", "0":
 		writtenState = 0
 	default:
-		return 0, syscall.EFAULT
+		return syscall.EFAULT
 	}
 
 	switch writtenState {
@@ -351,15 +751,15 @@ func (c *ThreadMasterControlFile) Write(ctx context.Context, _ fs.FileHandle, da
 	case 1:
 		err = c.JdwpConnection.ResumeAll()
 	default:
-		return 0, syscall.EFAULT
+		return syscall.EFAULT
 	}
 
 	if err != nil {
 		log.Printf("error changing state for all threads: %s", err)
-		return 0, syscall.EFAULT
+		return syscall.EFAULT
 	}
 	
-	return uint32(len(data)), 0
+	return syscall.F_OK
 }
 
 //
@@ -372,24 +772,43 @@ type ThreadControlFile struct {
 	
 	ThreadId jdwp.ThreadID
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*ThreadControlFile)(nil))
 var _ = (fs.NodeSetattrer)((*ThreadControlFile)(nil))
 var _ = (fs.NodeOpener)((*ThreadControlFile)(nil))
+var _ = (fs.NodeOpendirer)((*ThreadControlFile)(nil))
 var _ = (fs.NodeReader)((*ThreadControlFile)(nil))
 var _ = (fs.NodeWriter)((*ThreadControlFile)(nil))
 
-func NewThreadControlFile(ctx context.Context, conn *jdwp.Connection, id jdwp.ThreadID) ThreadControlFile {
+// threadErrno is ENOENT for a thread which died since it was looked up,
+// and the given errno for other errors
+func threadErrno(err error, otherwise syscall.Errno) syscall.Errno {
+	if errors.Is(err, jdwp.ErrInvalidThread) || errors.Is(err, jdwp.ErrInvalidObject) {
+		return syscall.ENOENT
+	}
+
+	return otherwise
+}
+
+func NewThreadControlFile(ctx context.Context, conn *debug.Connection, id jdwp.ThreadID, options JdwpFsOptions) ThreadControlFile {
 	return ThreadControlFile {
 		ThreadId: id,
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+		Options: options,
 	}
 }
 
 func (c *ThreadControlFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if flags & (
@@ -400,13 +819,18 @@ func (c *ThreadControlFile) Open(ctx context.Context, flags uint32) (fh fs.FileH
 		return nil, 0, syscall.EBADR
 	}
 
-	return nil, fuse.FOPEN_DIRECT_IO, 0
+	return NewControlHandle(c.writeToken), fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (c *ThreadControlFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
 }
 
 func (c *ThreadControlFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	out.Mode = 0660
+	out.Mode = c.Options.controlMode()
+	setTimes(out, c.JdwpConnection.GetSuspendChangedAt(c.ThreadId))
 	return 0
 }
 
@@ -432,17 +856,18 @@ func (c *ThreadControlFile) Read(ctx context.Context, _ fs.FileHandle, dest []by
 
 	_, suspendStatus, err := c.JdwpConnection.GetThreadStatus(c.ThreadId)
 	if err != nil {
-		return nil, syscall.EACCES
+		log.Printf("error getting status of thread %d: %s\n", c.ThreadId, err)
+		return nil, threadErrno(err, syscall.EACCES)
 	}
 
 	var controlFileContents string
         switch int(suspendStatus) {
 	case 0:
-		controlFileContents = "running"
+		controlFileContents = "running\n"
 	case 1:
-		controlFileContents = "suspended"
+		controlFileContents = "suspended\n"
 	default:
-		controlFileContents = "not implemented"
+		controlFileContents = "not implemented\n"
 	}
 
 	if offset > int64(len(controlFileContents)) {
@@ -454,42 +879,51 @@ func (c *ThreadControlFile) Read(ctx context.Context, _ fs.FileHandle, dest []by
 	return fuse.ReadResultData(output), 0
 }
 
-func (c *ThreadControlFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+func (c *ThreadControlFile) Write(ctx context.Context, fh fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = c.Options.checkPolicy(PolicyThreadSuspend)
+	if errno != syscall.F_OK {
+		return 0, errno
+	}
+
+	return writeControl(fh, data, off, c.writeToken)
+}
+
+func (c *ThreadControlFile) writeToken(data []byte) syscall.Errno {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	_, suspendStatus, err := c.JdwpConnection.GetThreadStatus(c.ThreadId)
 	if err != nil {
-		return 0, syscall.EACCES
+		log.Printf("error getting status of thread %d: %s\n", c.ThreadId, err)
+		return threadErrno(err, syscall.EACCES)
 	}
 
+	// the suspend status the thread is left with
 	var writtenState jdwp.SuspendStatus
         switch strings.TrimSpace(string(data)) {
-	case "running":
-	case "1":
-		writtenState = 1
-	case "suspend":
-	case "0":
-		writtenState = 0
+	case "running", "1":
+		writtenState = jdwp.NotSuspended
+	case "suspend", "0":
+		writtenState = jdwp.Suspended
 	default:
-		return 0, syscall.EFAULT
+		return syscall.EFAULT
 	}
 
 	if suspendStatus != writtenState {
 		switch writtenState {
-		case 0:
+		case jdwp.Suspended:
 			err = c.JdwpConnection.Suspend(c.ThreadId)
-		case 1:
+		case jdwp.NotSuspended:
 			err = c.JdwpConnection.Resume(c.ThreadId)
 		default:
-			return 0, syscall.EFAULT
+			return syscall.EFAULT
 			
 		}
 	}
 
 	if err != nil {
 		log.Printf("error changing state: %s", err)
-		return 0, syscall.EFAULT
+		return syscall.EFAULT
 	}
 	
-	return uint32(len(data)), 0
+	return syscall.F_OK
 }
@@ -5,15 +5,15 @@ package fs
 
 import (
 	"context"
-	"strconv"
 	"syscall"
-	"path/filepath"
 	"log"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 
 	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
 )
 
 //
@@ -22,31 +22,37 @@ import (
 type JdwpThreadNamedDir struct {
 	fs.Inode
 
-	AbsoluteMountpoint string
-	
 	JdwpContext context.Context
-	JdwpConnection *jdwp.Connection
+	JdwpConnection *debug.Connection
+
+	Options JdwpFsOptions
 }
 
 var _ = (fs.NodeGetattrer)((*JdwpThreadNamedDir)(nil))
 var _ = (fs.NodeReaddirer)((*JdwpThreadNamedDir)(nil))
 var _ = (fs.NodeLookuper)((*JdwpThreadNamedDir)(nil))
+var _ = (fs.NodeMkdirer)((*JdwpThreadNamedDir)(nil))
 
-func NewJdwpThreadNamedDir(ctx context.Context, conn *jdwp.Connection, absMountpoint string) (*JdwpThreadNamedDir, error) {
+func NewJdwpThreadNamedDir(ctx context.Context, conn *debug.Connection, options JdwpFsOptions) (*JdwpThreadNamedDir, error) {
 	newThreadDir := &JdwpThreadNamedDir {
-		AbsoluteMountpoint: absMountpoint,
 		JdwpContext: ctx,
 		JdwpConnection: conn,
+		Options: options,
 	}
 
 	return newThreadDir, nil
 }
 
 func (d *JdwpThreadNamedDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = 0755
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
 	return 0
 }
 
+func (d *JdwpThreadNamedDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
 func (d *JdwpThreadNamedDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
 	threadIds, err := d.JdwpConnection.GetAllThreads()
 	if err != nil {
@@ -101,17 +107,13 @@ func (d *JdwpThreadNamedDir) Lookup(ctx context.Context, name string, out *fuse.
 		return nil, syscall.EBADF
 	}
 
-	symlinkPath :=  filepath.Join(
-		d.AbsoluteMountpoint,
-		"threads",
-		strconv.FormatUint(uint64(foundThreadId), 10),
-	)
+	symlinkPath := relativeLink(&d.Inode, "threads/" + d.Options.formatId(uint64(foundThreadId)))
 	
 	threadEntryInode := d.NewInode(
 		ctx,
 		&fs.MemSymlink {
 			Data: []byte(symlinkPath),
-			Attr: fuse.Attr { Mode: 0444 },
+			Attr: staticAttr(d.Options.fileMode()),
 		},
 		fs.StableAttr{
 			Mode: fuse.S_IFLNK,
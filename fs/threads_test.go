@@ -0,0 +1,615 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// fakeFrame is a frame of a thread, in a class of the stack VM
+type fakeFrame struct {
+	class uint64
+	method uint64
+	codeIndex uint64
+}
+
+// stackHandler has a thread with frames, suspended or not; class 42 is
+// com.example.Main, with run as method 7, and class 43 is
+// com.example.Worker, with <init> as method 8
+func stackHandler(suspended bool, frames []fakeFrame) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 11 && cmd == 4:
+			var suspendStatus uint32
+			if suspended {
+				suspendStatus = 1
+			}
+			return 0, fakeConcat(fakeInt(1), fakeInt(suspendStatus))
+		case set == 11 && cmd == 6:
+			reply := fakeInt(uint32(len(frames)))
+			for i, frame := range frames {
+				reply = fakeConcat(reply, fakeLong(uint64(i + 1)), []byte{1},
+					fakeLong(frame.class), fakeLong(frame.method), fakeLong(frame.codeIndex))
+			}
+			return 0, reply
+		case set == 2 && cmd == 1 && data[7] == 42:
+			return 0, fakeString("Lcom/example/Main;")
+		case set == 2 && cmd == 1:
+			return 0, fakeString("Lcom/example/Worker;")
+		case set == 2 && cmd == 5 && data[7] == 42:
+			return 0, fakeConcat(fakeInt(1), fakeLong(7), fakeString("run"), fakeString("()V"), fakeInt(1))
+		case set == 2 && cmd == 5:
+			return 0, fakeConcat(fakeInt(1), fakeLong(8), fakeString("<init>"), fakeString("()V"), fakeInt(1))
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	var tests = []struct {
+		name string
+		suspended bool
+		frames []fakeFrame
+		errno syscall.Errno
+		stackTrace string
+	}{
+		{
+			name: "two frames",
+			suspended: true,
+			frames: []fakeFrame {
+				{ class: 43, method: 8, codeIndex: 4 },
+				{ class: 42, method: 7, codeIndex: 12 },
+			},
+			stackTrace: "at com.example.Worker.<init>(4)\nat com.example.Main.run(12)\n",
+		},
+		{
+			name: "unknown method",
+			suspended: true,
+			frames: []fakeFrame {
+				{ class: 42, method: 9, codeIndex: 0 },
+			},
+			stackTrace: "at com.example.Main.<method 9>(0)\n",
+		},
+		{ name: "no frames", suspended: true, stackTrace: "" },
+		{
+			name: "running",
+			suspended: false,
+			frames: []fakeFrame {
+				{ class: 42, method: 7, codeIndex: 12 },
+			},
+			errno: syscall.EBUSY,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, stackHandler(test.suspended, test.frames))
+			threadDir, err := NewJdwpThreadDir(context.Background(), conn, jdwp.ThreadID(31), JdwpFsOptions{}, nil)
+			if err != nil {
+				t.Fatalf("unable to make the thread dir: %s", err)
+			}
+
+			stackTrace, errno := threadDir.readStackTrace()
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if string(stackTrace) != test.stackTrace {
+				t.Fatalf("read %q, expected %q", stackTrace, test.stackTrace)
+			}
+		})
+	}
+}
+
+// renameHandler lets thread 31, suspended or not, be renamed through
+// Thread.setName, which throws if throws is set; it records the names
+func renameHandler(suspended bool, throws bool, names *[]string, mu *sync.Mutex) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 11 && cmd == 1:
+			return 0, fakeString("main")
+		case set == 11 && cmd == 4:
+			var suspendStatus uint32
+			if suspended {
+				suspendStatus = 1
+			}
+			return 0, fakeConcat(fakeInt(1), fakeInt(suspendStatus))
+		case set == 1 && cmd == 2:
+			return 0, fakeConcat(fakeInt(1), []byte{1}, fakeLong(50), fakeInt(7))
+		case set == 2 && cmd == 5:
+			return 0, fakeConcat(fakeInt(1),
+				fakeLong(60), fakeString("setName"), fakeString("(Ljava/lang/String;)V"), fakeInt(1))
+		case set == 1 && cmd == 11:
+			mu.Lock()
+			*names = append(*names, string(data[4:4 + binary.BigEndian.Uint32(data)]))
+			mu.Unlock()
+			return 0, fakeLong(70)
+		case set == 9 && cmd == 6:
+			var exception uint64
+			if throws {
+				exception = 80
+			}
+			return 0, fakeConcat([]byte{'V'}, []byte{'L'}, fakeLong(exception))
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestThreadNameWrite(t *testing.T) {
+	var tests = []struct {
+		name string
+		allowInvoke bool
+		suspended bool
+		throws bool
+		off int64
+		openErrno syscall.Errno
+		errno syscall.Errno
+		names []string
+	}{
+		{ name: "renamed", allowInvoke: true, suspended: true, names: []string { "worker" } },
+		{ name: "running", allowInvoke: true, suspended: false, errno: syscall.EBUSY },
+		{ name: "past the start", allowInvoke: true, suspended: true, off: 2, errno: syscall.EINVAL },
+		{ name: "setName threw", allowInvoke: true, suspended: true, throws: true, errno: syscall.EROFS, names: []string { "worker" } },
+		{ name: "read only", allowInvoke: false, suspended: true, openErrno: syscall.EROFS, errno: syscall.EROFS },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var names []string
+			conn, vm := startFakeVM(t, renameHandler(test.suspended, test.throws, &names, &mu))
+			nameFile := NewThreadNameFile(conn, jdwp.ThreadID(31), test.allowInvoke, JdwpFsOptions{})
+			ctx := context.Background()
+
+			if _, _, errno := nameFile.Open(ctx, syscall.O_WRONLY); errno != test.openErrno {
+				t.Fatalf("open: got %v, expected %v", errno, test.openErrno)
+			}
+			if _, _, errno := nameFile.Open(ctx, syscall.O_RDONLY); errno != syscall.F_OK {
+				t.Fatalf("open for reading: %v", errno)
+			}
+
+			written, errno := nameFile.Write(ctx, nil, []byte("worker\n"), test.off)
+			if errno != test.errno {
+				t.Fatalf("write: got %v, expected %v", errno, test.errno)
+			}
+			if errno == syscall.F_OK && written != 7 {
+				t.Fatalf("%d bytes written", written)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(names, test.names) {
+				t.Fatalf("created the names %v, expected %v", names, test.names)
+			}
+			if invoked := vm.Received(9, 6); invoked != len(test.names) {
+				t.Fatalf("invoked setName %d times", invoked)
+			}
+
+			// the name read is still the VM's
+			if name := readNode(t, &nameFile, 0); name != "main\n" {
+				t.Fatalf("read %q", name)
+			}
+		})
+	}
+}
+
+func TestThreadNameReadOnly(t *testing.T) {
+	conn, _ := startFakeVM(t, nil)
+	nameFile := NewThreadNameFile(conn, jdwp.ThreadID(31), false, JdwpFsOptions{})
+
+	in := &fuse.SetAttrIn{}
+	in.Valid = fuse.FATTR_SIZE
+	if errno := nameFile.Setattr(context.Background(), nil, in, &fuse.AttrOut{}); errno != syscall.EROFS {
+		t.Fatalf("truncate: got %v, expected EROFS", errno)
+	}
+
+	var out fuse.AttrOut
+	nameFile.Getattr(context.Background(), nil, &out)
+	if out.Mode & 0222 != 0 {
+		t.Fatalf("read only name has mode %o", out.Mode)
+	}
+}
+
+// suspendStatusHandler lists a thread per suspend status, with ids
+// from 31; a thread whose status is missing died since it was listed
+func suspendStatusHandler(suspendStatuses []int) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 1 && cmd == 4:
+			reply := fakeInt(uint32(len(suspendStatuses)))
+			for i := range suspendStatuses {
+				reply = fakeConcat(reply, fakeLong(uint64(31 + i)))
+			}
+			return 0, reply
+		case set == 11 && cmd == 4:
+			suspendStatus := suspendStatuses[data[7] - 31]
+			if suspendStatus < 0 {
+				return uint16(jdwp.ErrInvalidThread), nil
+			}
+			return 0, fakeConcat(fakeInt(2), fakeInt(uint32(suspendStatus)))
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestThreadMasterControlRead(t *testing.T) {
+	var tests = []struct {
+		name string
+		suspendStatuses []int
+		offset int64
+		errno syscall.Errno
+		read string
+	}{
+		{ name: "no threads", read: "running\n" },
+		{ name: "all running", suspendStatuses: []int { 0, 0 }, read: "running\n" },
+		{ name: "all suspended", suspendStatuses: []int { 1, 1, 1 }, read: "suspended\n" },
+		{ name: "mixed", suspendStatuses: []int { 1, 0, 1 }, read: "mixed\n" },
+		{ name: "a thread died", suspendStatuses: []int { 1, -1 }, read: "suspended\n" },
+		{ name: "mixed, from an offset", suspendStatuses: []int { 0, 1 }, offset: 3, read: "ed\n" },
+		{ name: "at the end", suspendStatuses: []int { 0, 1 }, offset: 6, read: "" },
+		{ name: "past the end", suspendStatuses: []int { 0, 1 }, offset: 7, errno: syscall.EBADR },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, suspendStatusHandler(test.suspendStatuses))
+			controlFile := NewThreadMasterControlFile(context.Background(), conn, JdwpFsOptions{})
+
+			dest := make([]byte, 4096)
+			result, errno := controlFile.Read(context.Background(), nil, dest, test.offset)
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if errno != syscall.F_OK {
+				return
+			}
+
+			read, _ := result.Bytes(dest)
+			if string(read) != test.read {
+				t.Fatalf("read %q, expected %q", read, test.read)
+			}
+		})
+	}
+}
+
+func TestThreadMasterControlOpenOnce(t *testing.T) {
+	var tests = []struct {
+		name string
+		flags uint32
+		listed int
+		reads []int64
+		read []string
+	}{
+		{ name: "read", flags: syscall.O_RDONLY, listed: 1, reads: []int64 { 0, 4 }, read: []string { "suspended\n", "ended\n" } },
+		{ name: "read and write", flags: syscall.O_RDWR, listed: 1, reads: []int64 { 0 }, read: []string { "suspended\n" } },
+		{ name: "write only", flags: syscall.O_WRONLY, listed: 0 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, suspendStatusHandler([]int { 1, 1 }))
+			controlFile := NewThreadMasterControlFile(context.Background(), conn, JdwpFsOptions{})
+
+			fh, _, errno := controlFile.Open(context.Background(), test.flags)
+			if errno != syscall.F_OK {
+				t.Fatalf("open: %v", errno)
+			}
+
+			// the reads through the handle share what was read on open
+			for i, offset := range test.reads {
+				dest := make([]byte, 4096)
+				result, errno := controlFile.Read(context.Background(), fh, dest, offset)
+				if errno != syscall.F_OK {
+					t.Fatalf("read from %d: %v", offset, errno)
+				}
+				read, _ := result.Bytes(dest)
+				if string(read) != test.read[i] {
+					t.Fatalf("read %q from %d, expected %q", read, offset, test.read[i])
+				}
+			}
+			if listed := vm.Received(1, 4); listed != test.listed {
+				t.Fatalf("listed the threads %d times, expected %d", listed, test.listed)
+			}
+		})
+	}
+}
+
+// flakyThreadsHandler fails listing the threads failures times, then
+// lists threads 31 and 32
+func flakyThreadsHandler(failures int) fakeHandler {
+	var mu sync.Mutex
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		if set != 1 || cmd != 4 {
+			return 0, nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return uint16(jdwp.ErrInternal), nil
+		}
+		return 0, fakeConcat(fakeInt(2), fakeLong(31), fakeLong(32))
+	}
+}
+
+func TestThreadsReaddirRetry(t *testing.T) {
+	var tests = []struct {
+		name string
+		failures int
+		retries int
+		errno syscall.Errno
+		sent int
+	}{
+		{ name: "answered", failures: 0, retries: 1, sent: 1 },
+		{ name: "failing once", failures: 1, retries: 1, sent: 2 },
+		{ name: "failing once, not retried", failures: 1, retries: 0, errno: syscall.EFAULT, sent: 1 },
+		{ name: "failing past the retries", failures: 3, retries: 2, errno: syscall.EFAULT, sent: 3 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, flakyThreadsHandler(test.failures))
+			conn.SetReadRetries(test.retries)
+			threadsDir, _ := NewJdwpThreadMasterDir(context.Background(), conn, JdwpFsOptions{}, nil)
+
+			stream, errno := threadsDir.Readdir(context.Background())
+			if errno != test.errno {
+				t.Fatalf("got %v, expected %v", errno, test.errno)
+			}
+			if sent := vm.Received(1, 4); sent != test.sent {
+				t.Fatalf("listed the threads %d times, expected %d", sent, test.sent)
+			}
+			if errno != syscall.F_OK {
+				return
+			}
+
+			var listed = map[string]bool{}
+			for stream.HasNext() {
+				entry, _ := stream.Next()
+				listed[entry.Name] = true
+			}
+			if !listed["31"] || !listed["32"] {
+				t.Fatalf("the threads aren't listed: %v", listed)
+			}
+		})
+	}
+}
+
+// unsortedThreadsHandler lists the given threads in the given order,
+// named after their ids; threads missing from names have died
+func unsortedThreadsHandler(threadIds []uint64, names map[byte]string) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 1 && cmd == 4:
+			reply := fakeInt(uint32(len(threadIds)))
+			for _, threadId := range threadIds {
+				reply = fakeConcat(reply, fakeLong(threadId))
+			}
+			return 0, reply
+		case set == 11 && cmd == 1:
+			name, ok := names[data[7]]
+			if !ok {
+				return uint16(jdwp.ErrInvalidThread), nil
+			}
+			return 0, fakeString(name)
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestThreadsSorted(t *testing.T) {
+	var tests = []struct {
+		name string
+		threadIds []uint64
+		names map[byte]string
+		listed []string
+		index string
+	}{
+		{ name: "no threads", threadIds: []uint64{}, listed: []string { "control", "select", "index" } },
+		{
+			name: "sorted",
+			threadIds: []uint64 { 3, 12, 40 },
+			names: map[byte]string { 3: "main", 12: "worker-1", 40: "worker-2" },
+			listed: []string { "3", "12", "40", "control", "select", "index" },
+			index: "3\tmain\n12\tworker-1\n40\tworker-2\n",
+		},
+		{
+			name: "unsorted",
+			threadIds: []uint64 { 40, 3, 255, 12 },
+			names: map[byte]string { 3: "main", 12: "worker-1", 40: "worker-2", 255: "reaper" },
+			listed: []string { "3", "12", "40", "255", "control", "select", "index" },
+			index: "3\tmain\n12\tworker-1\n40\tworker-2\n255\treaper\n",
+		},
+		{
+			name: "died while indexed",
+			threadIds: []uint64 { 40, 3, 12 },
+			names: map[byte]string { 3: "main", 40: "worker-2" },
+			listed: []string { "3", "12", "40", "control", "select", "index" },
+			index: "3\tmain\n40\tworker-2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, unsortedThreadsHandler(test.threadIds, test.names))
+			threadsDir, _ := NewJdwpThreadMasterDir(context.Background(), conn, JdwpFsOptions{}, nil)
+
+			if listed := listNames(t, threadsDir); !reflect.DeepEqual(listed, test.listed) {
+				t.Fatalf("listed %v, expected %v", listed, test.listed)
+			}
+
+			index, errno := threadsDir.readIndex()
+			if errno != syscall.F_OK {
+				t.Fatalf("reading the index: %v", errno)
+			}
+			if string(index) != test.index {
+				t.Fatalf("indexed %q, expected %q", index, test.index)
+			}
+		})
+	}
+}
+
+// suspendCountHandler keeps the suspend count of the thread, starting
+// from the suspensions made by someone else
+func suspendCountHandler(preexisting int) fakeHandler {
+	var mu sync.Mutex
+	count := preexisting
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		if set != 11 {
+			return 0, nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch cmd {
+		case 2:
+			count++
+		case 3:
+			if count > 0 {
+				count--
+			}
+		case 12:
+			return 0, fakeInt(uint32(count))
+		}
+		return 0, nil
+	}
+}
+
+func TestSuspendOwnership(t *testing.T) {
+	var tests = []struct {
+		name string
+		preexisting int
+		suspends int
+		resumes int
+		ownership string
+	}{
+		{ name: "running", ownership: "owned: 0\ntotal: 0\n" },
+		{ name: "suspended by jdwpfs", suspends: 1, ownership: "owned: 1\ntotal: 1\n" },
+		{ name: "suspended by someone else", preexisting: 1, ownership: "owned: 0\ntotal: 1\n" },
+		{ name: "suspended over someone else", preexisting: 1, suspends: 1, ownership: "owned: 1\ntotal: 2\n" },
+		{ name: "resumed over someone else", preexisting: 1, suspends: 1, resumes: 1, ownership: "owned: 0\ntotal: 1\n" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, suspendCountHandler(test.preexisting))
+			threadDir, err := NewJdwpThreadDir(context.Background(), conn, jdwp.ThreadID(7), JdwpFsOptions{}, nil)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			for i := 0; i < test.suspends; i++ {
+				if err := conn.Suspend(jdwp.ThreadID(7)); err != nil {
+					t.Fatalf("unable to suspend: %s", err)
+				}
+			}
+			for i := 0; i < test.resumes; i++ {
+				if err := conn.Resume(jdwp.ThreadID(7)); err != nil {
+					t.Fatalf("unable to resume: %s", err)
+				}
+			}
+
+			ownership, errno := threadDir.readSuspendOwnership()
+			if errno != syscall.F_OK {
+				t.Fatalf("got %v", errno)
+			}
+			if string(ownership) != test.ownership {
+				t.Fatalf("read %q, expected %q", ownership, test.ownership)
+			}
+		})
+	}
+}
+
+func TestThreadControlDeadThread(t *testing.T) {
+	var tests = []struct {
+		name string
+		errorCode uint16
+		errno syscall.Errno
+	}{
+		{ name: "alive", errorCode: 0, errno: syscall.F_OK },
+		{ name: "died", errorCode: uint16(jdwp.ErrInvalidThread), errno: syscall.ENOENT },
+		{ name: "collected", errorCode: uint16(jdwp.ErrInvalidObject), errno: syscall.ENOENT },
+		{ name: "other error", errorCode: uint16(jdwp.ErrInternal), errno: syscall.EACCES },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(11, 4, test.errorCode, fakeConcat(fakeInt(2), fakeInt(0)))
+			controlFile := NewThreadControlFile(context.Background(), conn, jdwp.ThreadID(7), JdwpFsOptions{})
+
+			if _, errno := controlFile.Read(context.Background(), nil, make([]byte, 64), 0); errno != test.errno {
+				t.Fatalf("read: got %v, expected %v", errno, test.errno)
+			}
+			if errno := controlFile.writeToken([]byte("1\n")); errno != test.errno {
+				t.Fatalf("write: got %v, expected %v", errno, test.errno)
+			}
+		})
+	}
+}
+
+func TestThreadSuspendedFile(t *testing.T) {
+	var tests = []struct {
+		name string
+		preexisting int
+		data string
+		errno syscall.Errno
+		suspends int
+		resumes int
+		read string
+	}{
+		{ name: "resume suspended twice", preexisting: 2, data: "0\n", resumes: 2, read: "0\n" },
+		{ name: "resume running", preexisting: 0, data: "0\n", read: "0\n" },
+		{ name: "suspend running", preexisting: 0, data: "1\n", suspends: 1, read: "1\n" },
+		{ name: "suspend suspended", preexisting: 1, data: "1\n", read: "1\n" },
+		{ name: "not a boolean", preexisting: 1, data: "2\n", errno: syscall.EBADMSG, read: "1\n" },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, suspendCountHandler(test.preexisting))
+			suspendedFile := NewThreadSuspendedFile(conn, jdwp.ThreadID(1), JdwpFsOptions{})
+
+			written, errno := suspendedFile.Write(context.Background(), nil, []byte(test.data), 0)
+			if errno != test.errno {
+				t.Fatalf("write: got %v, expected %v", errno, test.errno)
+			}
+			if errno == syscall.F_OK && written != uint32(len(test.data)) {
+				t.Fatalf("write: %d bytes of %d", written, len(test.data))
+			}
+			if suspends := vm.Received(11, 2); suspends != test.suspends {
+				t.Fatalf("suspended %d times, expected %d", suspends, test.suspends)
+			}
+			if resumes := vm.Received(11, 3); resumes != test.resumes {
+				t.Fatalf("resumed %d times, expected %d", resumes, test.resumes)
+			}
+			if read := readNode(t, &suspendedFile, 0); read != test.read {
+				t.Fatalf("read %q, expected %q", read, test.read)
+			}
+
+			// writing the same value again changes nothing
+			if errno != syscall.F_OK {
+				return
+			}
+			if _, errno := suspendedFile.Write(context.Background(), nil, []byte(test.data), 0); errno != syscall.F_OK {
+				t.Fatalf("write again: %v", errno)
+			}
+			if changes := vm.Received(11, 2) + vm.Received(11, 3); changes != test.suspends + test.resumes {
+				t.Fatalf("writing again changed the suspension")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+//
+// Node timestamps
+// Nodes which don't change carry the mount time; the ones backed by
+// state which changes carry the time of the last change jdwpfs made
+//
+var mountTime = time.Now()
+
+func setTimes(out *fuse.AttrOut, t time.Time) {
+	// nothing changed since the mount
+	if t.Before(mountTime) {
+		t = mountTime
+	}
+
+	out.SetTimes(&t, &t, &t)
+}
+
+func setMountTimes(out *fuse.AttrOut) {
+	setTimes(out, mountTime)
+}
+
+// staticAttr is the attribute of an in-memory file or link which keeps
+// its contents as long as it's looked up
+func staticAttr(mode uint32) fuse.Attr {
+	var attr = fuse.Attr {
+		Mode: mode,
+	}
+	attr.SetTimes(&mountTime, &mountTime, &mountTime)
+
+	return attr
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// timedControlFile is a control file carrying the time of its last change
+type timedControlFile interface {
+	fs.NodeGetattrer
+	writeToken(data []byte) syscall.Errno
+}
+
+func TestControlFileTimes(t *testing.T) {
+	var tests = []struct {
+		name string
+		file func(conn *debug.Connection) timedControlFile
+		tokens []string
+	}{
+		{
+			name: "threads control",
+			file: func(conn *debug.Connection) timedControlFile {
+				controlFile := NewThreadMasterControlFile(context.Background(), conn, JdwpFsOptions{})
+				return &controlFile
+			},
+			tokens: []string { "0\n", "1\n" },
+		},
+		{
+			name: "event control",
+			file: func(conn *debug.Connection) timedControlFile {
+				event := debug.NewStubDebuggingEvent("timed")
+				event.SetKind(jdwp.ThreadStart)
+				event.SetConn(conn)
+				controlFile := NewEventControlFile(event, JdwpFsOptions{})
+				return &controlFile
+			},
+			tokens: []string { "run\n", "cancel\n" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, nil)
+			vm.Answer(15, 1, 0, fakeInt(1))
+			file := test.file(conn)
+
+			mtime := func() time.Time {
+				var out fuse.AttrOut
+				if errno := file.Getattr(context.Background(), nil, &out); errno != syscall.F_OK {
+					t.Fatalf("getattr: %v", errno)
+				}
+				if out.Mtime == 0 || out.Ctime == 0 {
+					t.Fatalf("zero times: mtime %d, ctime %d", out.Mtime, out.Ctime)
+				}
+				return time.Unix(int64(out.Mtime), int64(out.Mtimensec))
+			}
+
+			// untouched since the mount
+			last := mtime()
+			if !last.Equal(mountTime) {
+				t.Fatalf("got %v before any write, expected the mount time %v", last, mountTime)
+			}
+
+			for _, token := range test.tokens {
+				time.Sleep(time.Millisecond)
+				if errno := file.writeToken([]byte(token)); errno != syscall.F_OK {
+					t.Fatalf("writing %q: %v", token, errno)
+				}
+
+				changed := mtime()
+				if !changed.After(last) {
+					t.Fatalf("writing %q: the time went from %v to %v", token, last, changed)
+				}
+				last = changed
+			}
+		})
+	}
+}
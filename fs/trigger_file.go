@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+//
+// Trigger file
+// Writing anything to it runs an action; it reads empty
+//
+type TriggerFile struct {
+	fs.Inode
+
+	action func() syscall.Errno
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeOpener)((*TriggerFile)(nil))
+var _ = (fs.NodeOpendirer)((*TriggerFile)(nil))
+var _ = (fs.NodeGetattrer)((*TriggerFile)(nil))
+var _ = (fs.NodeSetattrer)((*TriggerFile)(nil))
+var _ = (fs.NodeReader)((*TriggerFile)(nil))
+var _ = (fs.NodeWriter)((*TriggerFile)(nil))
+
+func NewTriggerFile(action func() syscall.Errno, options JdwpFsOptions) TriggerFile {
+	return TriggerFile {
+		action: action,
+		Options: options,
+	}
+}
+
+func (f *TriggerFile) Open(ctx context.Context, flags uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	errno = checkFileOpen(flags)
+	if errno != syscall.F_OK {
+		return nil, 0, errno
+	}
+
+	return nil, fuse.FOPEN_DIRECT_IO, 0
+}
+
+func (f *TriggerFile) Opendir(ctx context.Context) syscall.Errno {
+	return syscall.ENOTDIR
+}
+
+func (f *TriggerFile) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = f.Options.triggerMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (f *TriggerFile) Setattr(ctx context.Context, _ fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if sz, _ := in.GetSize(); sz != 0 {
+		return syscall.EBADR
+	}
+
+	return syscall.F_OK
+}
+
+func (f *TriggerFile) Read(ctx context.Context, _ fs.FileHandle, dest []byte, offset int64) (fuse.ReadResult, syscall.Errno) {
+	return fuse.ReadResultData([]byte{}), 0
+}
+
+func (f *TriggerFile) Write(ctx context.Context, _ fs.FileHandle, data []byte, off int64) (written uint32, errno syscall.Errno) {
+	errno = f.action()
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return uint32(len(data)), 0
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"log"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Value rendering
+// The values read from the VM, as the files show them; with toString,
+// objects are shown as what their toString returns, invoked on the first
+// suspended thread which can, and as their id if none can
+//
+type valueRenderer struct {
+	conn *debug.Connection
+	toString bool
+}
+
+// newValueRenderer invokes toString only if invoking is allowed too, by
+// the options and by the policy
+func newValueRenderer(conn *debug.Connection, options JdwpFsOptions) valueRenderer {
+	return valueRenderer {
+		conn: conn,
+		toString: options.ValueToString && options.AllowInvoke && options.policy.Allows(PolicyMethodInvoke),
+	}
+}
+
+func (r valueRenderer) render(value jdwp.Value) string {
+	rendered := debug.FormatValue(value)
+
+	object, ok := value.(jdwp.Object)
+	if !r.toString || !ok || object.ID() == 0 {
+		return rendered
+	}
+
+	threads, err := suspendedThreads(r.conn)
+	if err != nil {
+		log.Printf("unable to read threads from the JVM: %s\n", err)
+		return rendered
+	}
+
+	for _, thread := range threads {
+		text, err := r.conn.ToString(object.ID(), thread)
+		if err != nil {
+			log.Printf("error invoking toString on thread %d: %s\n", thread, err)
+			continue
+		}
+
+		return text
+	}
+
+	return rendered
+}
+
+// suspendedThreads lists the suspended threads, which code can be
+// invoked on; only the ones suspended by an event can, though
+func suspendedThreads(conn *debug.Connection) ([]jdwp.ThreadID, error) {
+	threads, err := conn.GetAllThreads()
+	if err != nil {
+		return nil, err
+	}
+
+	var suspended []jdwp.ThreadID
+	for _, thread := range threads {
+		_, suspendStatus, err := conn.GetThreadStatus(thread)
+		if err != nil || suspendStatus == 0 {
+			continue
+		}
+		suspended = append(suspended, thread)
+	}
+
+	return suspended, nil
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"testing"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// toStringHandler has thread 31, suspended or not, and objects whose
+// toString returns "Point(1, 2)", or throws
+func toStringHandler(suspended bool, throws bool) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 1 && cmd == 4:
+			return 0, fakeConcat(fakeInt(1), fakeLong(31))
+		case set == 11 && cmd == 4:
+			var suspendStatus uint32
+			if suspended {
+				suspendStatus = 1
+			}
+			return 0, fakeConcat(fakeInt(uint32(jdwp.ThreadRunning)), fakeInt(suspendStatus))
+		case set == 1 && cmd == 2:
+			return 0, fakeConcat(fakeInt(1), []byte{1}, fakeLong(1), fakeInt(7))
+		case set == 2 && cmd == 5:
+			return 0, fakeConcat(fakeInt(1),
+				fakeLong(3), fakeString("toString"), fakeString("()Ljava/lang/String;"), fakeInt(1))
+		case set == 9 && cmd == 6 && throws:
+			return 0, fakeConcat([]byte{'s'}, fakeLong(0), []byte{'L'}, fakeLong(99))
+		case set == 9 && cmd == 6:
+			return 0, fakeConcat([]byte{'s'}, fakeLong(77), []byte{'L'}, fakeLong(0))
+		case set == 10 && cmd == 1:
+			return 0, fakeString("Point(1, 2)")
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestValueToString(t *testing.T) {
+	var tests = []struct {
+		name string
+		options JdwpFsOptions
+		suspended bool
+		throws bool
+		value jdwp.Value
+		rendered string
+		invoked int
+	}{
+		{
+			name: "object",
+			options: JdwpFsOptions { ValueToString: true, AllowInvoke: true },
+			suspended: true,
+			value: jdwp.ObjectID(42),
+			rendered: "Point(1, 2)",
+			invoked: 1,
+		},
+		{
+			name: "invoking not allowed",
+			options: JdwpFsOptions { ValueToString: true },
+			suspended: true,
+			value: jdwp.ObjectID(42),
+			rendered: "42",
+		},
+		{
+			name: "no suspended thread",
+			options: JdwpFsOptions { ValueToString: true, AllowInvoke: true },
+			value: jdwp.ObjectID(42),
+			rendered: "42",
+		},
+		{
+			name: "toString throws",
+			options: JdwpFsOptions { ValueToString: true, AllowInvoke: true },
+			suspended: true,
+			throws: true,
+			value: jdwp.ObjectID(42),
+			rendered: "42",
+			invoked: 1,
+		},
+		{
+			name: "null",
+			options: JdwpFsOptions { ValueToString: true, AllowInvoke: true },
+			suspended: true,
+			value: jdwp.ObjectID(0),
+			rendered: "null",
+		},
+		{
+			name: "primitive",
+			options: JdwpFsOptions { ValueToString: true, AllowInvoke: true },
+			suspended: true,
+			value: int(5),
+			rendered: "5",
+		},
+		{
+			name: "off",
+			options: JdwpFsOptions { AllowInvoke: true },
+			suspended: true,
+			value: jdwp.ObjectID(42),
+			rendered: "42",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, vm := startFakeVM(t, toStringHandler(test.suspended, test.throws))
+			values := newValueRenderer(conn, test.options)
+
+			if rendered := values.render(test.value); rendered != test.rendered {
+				t.Fatalf("rendered %q, expected %q", rendered, test.rendered)
+			}
+			if invoked := vm.Received(9, 6); invoked != test.invoked {
+				t.Fatalf("invoked toString %d times, expected %d", invoked, test.invoked)
+			}
+		})
+	}
+}
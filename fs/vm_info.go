@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+// the fields of the version reply, as files of the vm directory
+var vmInfoFields = map[string]func(jdwp.Version) string {
+	"name": func(v jdwp.Version) string { return v.Name },
+	"version": func(v jdwp.Version) string { return v.Version },
+	"description": func(v jdwp.Version) string { return v.Description },
+}
+
+// the fields of the version reply, as files of the vm/jdwp directory
+var vmJdwpInfoFields = map[string]func(jdwp.Version) string {
+	"version": func(v jdwp.Version) string {
+		return fmt.Sprintf("%d.%d", v.JDWPMajor, v.JDWPMinor)
+	},
+}
+
+//
+// VM info directory
+// The VirtualMachine.Version reply, a field per file; jdwp holds the
+// JDWP version. The reply has no vendor, so there's no file for it
+//
+type VmInfoDir struct {
+	fs.Inode
+
+	JdwpConnection *debug.Connection
+
+	jdwp bool
+
+	Options JdwpFsOptions
+}
+
+var _ = (fs.NodeGetattrer)((*VmInfoDir)(nil))
+var _ = (fs.NodeReaddirer)((*VmInfoDir)(nil))
+var _ = (fs.NodeLookuper)((*VmInfoDir)(nil))
+var _ = (fs.NodeMkdirer)((*VmInfoDir)(nil))
+
+func NewVmInfoDir(conn *debug.Connection, options JdwpFsOptions) VmInfoDir {
+	return VmInfoDir {
+		JdwpConnection: conn,
+		Options: options,
+	}
+}
+
+func (d *VmInfoDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = d.Options.dirMode()
+	setMountTimes(out)
+	return 0
+}
+
+func (d *VmInfoDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return nil, syscall.EPERM
+}
+
+func (d *VmInfoDir) fields() map[string]func(jdwp.Version) string {
+	if d.jdwp {
+		return vmJdwpInfoFields
+	}
+
+	return vmInfoFields
+}
+
+func (d *VmInfoDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var entries = []fuse.DirEntry{}
+	for _, name := range []string{ "name", "version", "description" } {
+		if _, ok := d.fields()[name]; ok {
+			entries = append(entries, fuse.DirEntry {
+				Mode: fuse.S_IFREG,
+				Name: name,
+			})
+		}
+	}
+
+	if !d.jdwp {
+		entries = append(entries, fuse.DirEntry {
+			Mode: fuse.S_IFDIR,
+			Name: "jdwp",
+		})
+	}
+
+	return fs.NewListDirStream(entries), 0
+}
+
+func (d *VmInfoDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if name == "jdwp" && !d.jdwp {
+		jdwpDir := VmInfoDir {
+			JdwpConnection: d.JdwpConnection,
+			jdwp: true,
+			Options: d.Options,
+		}
+		jdwpDirInode := d.NewInode(
+			ctx,
+			&jdwpDir,
+			fs.StableAttr {
+				Mode: fuse.S_IFDIR,
+			})
+		return jdwpDirInode, 0
+	}
+
+	field, ok := d.fields()[name]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+
+	fieldFile := NewInfoFile(func() ([]byte, syscall.Errno) {
+		version, err := d.JdwpConnection.GetVersion()
+		if err != nil {
+			log.Printf("unable to get the VM version: %s\n", err)
+			return nil, syscall.EIO
+		}
+
+		return []byte(newlineTerminated(field(version))), 0
+	}, d.Options)
+	fieldInode := d.NewInode(
+		ctx,
+		&fieldFile,
+		fs.StableAttr {
+			Mode: fuse.S_IFREG,
+		})
+
+	return fieldInode, 0
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// the VirtualMachine.Version reply of a stub VM
+var stubVersionReply = fakeConcat(
+	fakeString("Java Debug Wire Protocol (Reference Implementation) version 17.0"),
+	fakeInt(17),
+	fakeInt(0),
+	fakeString("17.0.2+8"),
+	fakeString("OpenJDK 64-Bit Server VM"))
+
+// lookupPath looks a path up from dir, a component at a time
+func lookupPath(dir fs.NodeLookuper, path string) (*fs.Inode, syscall.Errno) {
+	var node *fs.Inode
+	for _, component := range strings.Split(path, "/") {
+		var errno syscall.Errno
+		node, errno = dir.Lookup(context.Background(), component, &fuse.EntryOut{})
+		if errno != syscall.F_OK {
+			return nil, errno
+		}
+		dir, _ = node.Operations().(fs.NodeLookuper)
+	}
+
+	return node, syscall.F_OK
+}
+
+func TestVmInfoDir(t *testing.T) {
+	var tests = []struct {
+		path string
+		errno syscall.Errno
+		contents string
+	}{
+		{ path: "name", contents: "OpenJDK 64-Bit Server VM\n" },
+		{ path: "version", contents: "17.0.2+8\n" },
+		{ path: "description", contents: "Java Debug Wire Protocol (Reference Implementation) version 17.0\n" },
+		{ path: "jdwp/version", contents: "17.0\n" },
+		{ path: "vendor", errno: syscall.ENOENT },
+		{ path: "jdwp/name", errno: syscall.ENOENT },
+	}
+
+	conn, vm := startFakeVM(t, nil)
+	vm.Answer(1, 1, 0, stubVersionReply)
+	vmDir := NewVmInfoDir(conn, JdwpFsOptions{})
+	fs.NewNodeFS(&vmDir, &fs.Options{})
+
+	if listed := listNames(t, &vmDir); !reflect.DeepEqual(listed, []string { "name", "version", "description", "jdwp" }) {
+		t.Fatalf("listed %v", listed)
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			node, errno := lookupPath(&vmDir, test.path)
+			if errno != test.errno {
+				t.Fatalf("lookup: got %v, expected %v", errno, test.errno)
+			}
+			if errno != syscall.F_OK {
+				return
+			}
+			if contents := readNode(t, node.Operations().(fs.NodeReader), 0); contents != test.contents {
+				t.Fatalf("read %q, expected %q", contents, test.contents)
+			}
+		})
+	}
+}
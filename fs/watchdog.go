@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"disroot.org/kitzman/jdwpfs/debug"
+)
+
+//
+// Auto-resume watchdog
+// Resumes the threads jdwpfs suspended once nothing has inspected them
+// for a while, so a forgotten suspension doesn't leave the VM frozen.
+// A nil watchdog is disabled, and all its methods do nothing
+//
+type Watchdog struct {
+	mu sync.Mutex
+
+	JdwpConnection *debug.Connection
+	timeout time.Duration
+
+	// open inspection files, and when the last one was closed
+	handles int
+	lastActivity time.Time
+}
+
+// NewWatchdog starts watching until ctx is done; with a timeout of 0
+// there is no watchdog
+func NewWatchdog(ctx context.Context, conn *debug.Connection, timeout time.Duration) *Watchdog {
+	if timeout <= 0 {
+		return nil
+	}
+
+	w := &Watchdog {
+		JdwpConnection: conn,
+		timeout: timeout,
+		lastActivity: time.Now(),
+	}
+	go w.watch(ctx)
+
+	return w
+}
+
+func newWatchdog(ctx context.Context, conn *debug.Connection, options JdwpFsOptions) *Watchdog {
+	if !options.AutoResume {
+		return nil
+	}
+
+	return NewWatchdog(ctx, conn, options.AutoResumeTimeout)
+}
+
+// Acquire marks an inspection file as open
+func (w *Watchdog) Acquire() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handles++
+	w.lastActivity = time.Now()
+}
+
+// Release marks an inspection file as closed
+func (w *Watchdog) Release() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.handles > 0 {
+		w.handles--
+	}
+	w.lastActivity = time.Now()
+}
+
+// idle tells if nothing has been inspected, and no thread suspended or
+// resumed through the connection, for the whole timeout
+func (w *Watchdog) idle(now time.Time) bool {
+	w.mu.Lock()
+	handles := w.handles
+	lastActivity := w.lastActivity
+	w.mu.Unlock()
+
+	if handles != 0 {
+		return false
+	}
+
+	// a thread suspended just now was suspended to be inspected
+	suspendChangedAt := w.JdwpConnection.GetSuspendAllChangedAt()
+	if suspendChangedAt.After(lastActivity) {
+		lastActivity = suspendChangedAt
+	}
+
+	return now.Sub(lastActivity) >= w.timeout
+}
+
+func (w *Watchdog) watch(ctx context.Context) {
+	var interval = w.timeout / 4
+	if interval <= 0 {
+		interval = w.timeout
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.check(now)
+		}
+	}
+}
+
+func (w *Watchdog) check(now time.Time) {
+	if !w.idle(now) || !w.JdwpConnection.HasOwnedSuspensions() {
+		return
+	}
+
+	log.Printf("nothing inspected for %s, resuming the threads suspended by jdwpfs\n", w.timeout)
+	err := w.JdwpConnection.ResumeOwned()
+	if err != nil {
+		log.Printf("unable to resume all the suspended threads: %s\n", err)
+	}
+
+	w.mu.Lock()
+	w.lastActivity = now
+	w.mu.Unlock()
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package fs
+
+import (
+	"testing"
+	"time"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+const (
+	testWatchdogTimeout = time.Minute
+)
+
+func TestWatchdogIdle(t *testing.T) {
+	var tests = []struct {
+		name string
+		handles int
+		activityAgo time.Duration
+		suspend bool
+		after time.Duration
+		idle bool
+	}{
+		{ name: "nothing for the timeout", activityAgo: 2 * testWatchdogTimeout, idle: true },
+		{ name: "inspected recently", activityAgo: testWatchdogTimeout / 2, idle: false },
+		{ name: "inspection file open", handles: 1, activityAgo: 2 * testWatchdogTimeout, idle: false },
+		{ name: "suspended recently", activityAgo: 2 * testWatchdogTimeout, suspend: true, idle: false },
+		{
+			name: "suspended a timeout ago",
+			activityAgo: 2 * testWatchdogTimeout,
+			suspend: true,
+			after: testWatchdogTimeout,
+			idle: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, _ := startFakeVM(t, nil)
+			w := &Watchdog {
+				JdwpConnection: conn,
+				timeout: testWatchdogTimeout,
+				handles: test.handles,
+				lastActivity: time.Now().Add(-test.activityAgo),
+			}
+
+			if test.suspend {
+				if err := conn.Suspend(jdwp.ThreadID(1)); err != nil {
+					t.Fatalf("unable to suspend: %s", err)
+				}
+			}
+
+			if idle := w.idle(time.Now().Add(test.after)); idle != test.idle {
+				t.Fatalf("got idle %v, expected %v", idle, test.idle)
+			}
+		})
+	}
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	conn, vm := startFakeVM(t, nil)
+	w := &Watchdog {
+		JdwpConnection: conn,
+		timeout: testWatchdogTimeout,
+		lastActivity: time.Now().Add(-2 * testWatchdogTimeout),
+	}
+
+	if err := conn.Suspend(jdwp.ThreadID(1)); err != nil {
+		t.Fatalf("unable to suspend: %s", err)
+	}
+
+	// the suspension itself is activity
+	w.check(time.Now())
+	if resumed := vm.Received(11, 3); resumed != 0 {
+		t.Fatalf("resumed %d times right after suspending", resumed)
+	}
+	if !conn.HasOwnedSuspensions() {
+		t.Fatalf("the suspension was forgotten")
+	}
+
+	w.check(time.Now().Add(2 * testWatchdogTimeout))
+	if resumed := vm.Received(11, 3); resumed != 1 {
+		t.Fatalf("resumed %d times once idle, expected 1", resumed)
+	}
+	if conn.HasOwnedSuspensions() {
+		t.Fatalf("the suspension is still owned once resumed")
+	}
+}
+
+func TestNilWatchdog(t *testing.T) {
+	var w *Watchdog
+	w.Acquire()
+	w.Release()
+}
@@ -6,11 +6,16 @@ package main
 import (
 	// "context"
 	"context"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
@@ -23,6 +28,205 @@ import (
 type Options struct {
 	DebuggedHost string `short:"h" long:"host" description:"host of debugged JVM process"`
 	DebuggedPort int `short:"p" long:"port" description:"port of debugged JVM process"`
+
+	ConnectRetry int `long:"connect-retry" default:"0" description:"times to retry connecting to the JVM before giving up"`
+	ConnectRetryInterval time.Duration `long:"connect-retry-interval" default:"1s" description:"time to wait between connection attempts"`
+	ConnectOnDemand bool `long:"connect-on-demand" description:"mount without connecting, and connect to the JVM the first time it's needed"`
+	Compat bool `long:"compat" description:"only use the legacy JDWP commands, for old JVMs; older JDWP versions are detected without it"`
+	IdleDisconnect time.Duration `long:"idle-disconnect" default:"0" description:"disconnect from the JVM once it wasn't used for this long, and reconnect when it's needed; 0 stays connected"`
+
+	ClassInclude []string `long:"class-include" description:"only show classes whose signature matches the glob (repeatable)"`
+	ClassExclude []string `long:"class-exclude" description:"hide classes whose signature matches the glob (repeatable)"`
+
+	ClassPrefetch []string `long:"class-prefetch" description:"fetch the methods and fields of the classes whose signature starts with the prefix in the background, e.g. Lcom/example/ (repeatable)"`
+	ClassPrefetchRate int `long:"class-prefetch-rate" default:"10" description:"classes prefetched per second, 0 for no limit"`
+
+	MaxDirEntries int `long:"max-dir-entries" default:"0" description:"list at most this many classes in classes and classes_by_signature, 0 for no limit; the others can still be looked up"`
+
+	IdRadix string `long:"id-radix" default:"dec" choice:"dec" choice:"hex" description:"radix of the thread and class ids in paths; hex ids are written 0x1f, as in the JVM logs"`
+
+	AllowInvoke bool `long:"allow-invoke" description:"allow running code inside the JVM, e.g. to rename threads"`
+	ValueToString bool `long:"value-tostring" description:"show object values as what their toString returns, invoked on a suspended thread, rather than as their id; needs --allow-invoke"`
+
+	Strict bool `long:"strict" description:"fail instead of logging and continuing"`
+
+	MaxConcurrentJdwp int `long:"max-concurrent-jdwp" default:"0" description:"maximum JDWP calls in flight at once, 0 for no limit"`
+
+	ReadRetries int `long:"read-retries" default:"1" description:"times to retry listing threads, classes, methods and fields, or reading thread names, when the JVM fails to answer"`
+
+	Force bool `long:"force" description:"detach a FUSE filesystem already mounted at the mountpoint, e.g. after a crash"`
+
+	TraceFile string `long:"trace-file" description:"append a line per FUSE operation to the file: time, operation, path, errno and latency in microseconds, separated by tabs"`
+
+	Daemonize bool `long:"daemonize" description:"run in the background once mounted"`
+	Pidfile string `long:"pidfile" description:"write the pid of jdwpfs to the file once mounted; SIGTERM unmounts it"`
+
+	Check bool `long:"check" description:"connect, check threads and classes can be listed, and exit without mounting"`
+
+	MountName string `long:"mount-name" description:"name of the mount, as shown by mount and df; defaults to jdwpfs@host:port"`
+
+	FileMode uint32 `long:"file-mode" base:"8" default:"0444" description:"permissions of the read only files"`
+	DirMode uint32 `long:"dir-mode" base:"8" default:"0755" description:"permissions of the directories"`
+	ControlMode uint32 `long:"control-mode" base:"8" default:"0660" description:"permissions of the files taking writes"`
+
+	Uid int `long:"uid" default:"-1" description:"owner of the files, defaults to the user running jdwpfs"`
+	Gid int `long:"gid" default:"-1" description:"group of the files, defaults to the group running jdwpfs"`
+
+	AutoResume bool `long:"auto-resume-on-read-error" description:"resume the threads jdwpfs suspended once nothing inspected them for --auto-resume-timeout"`
+	AutoResumeTimeout time.Duration `long:"auto-resume-timeout" default:"5m" description:"how long suspended threads may go uninspected before they are resumed"`
+
+	ResumeOnMount bool `long:"resume-on-mount" description:"resume the JVM after connecting, if it was started with suspend=y"`
+
+	SuspendOnEventInspect bool `long:"suspend-on-event-inspect" description:"when an event suspends its thread, list this and the object arguments of the frame it fired in under objects, until the thread is resumed"`
+
+	EventBufferSize int `long:"event-buffer-size" default:"1024" description:"fired events kept in the events.log and stream.bin of each event; the oldest are dropped first"`
+
+	EventsImport string `long:"events-import" description:"create the events defined in a file, a JSON array as found in export.json"`
+
+	ReconnectPreserveEvents bool `long:"reconnect-preserve-events" description:"on reconnect, run the running events again, finding their classes, methods and fields by name in the new session"`
+
+	Policy string `long:"policy" description:"allow or deny operations of the mount, as listed in a file: thread.suspend, method.invoke, event.create, event.run, class.redefine"`
+
+	NoThreads bool `long:"no-threads" description:"don't mount threads, threads_by_name, thread_groups and monitors"`
+	NoClasses bool `long:"no-classes" description:"don't mount classes and classes_by_signature"`
+	NoEvents bool `long:"no-events" description:"don't mount events, clear_events and objects"`
+
+	Version bool `long:"version" description:"print the version and the revision jdwpfs was built from, and exit"`
+}
+
+func jdwpfsOptions(opts Options) jdwpfs.JdwpFsOptions {
+	jdwpfsOptions := jdwpfs.DefaultJdwpFsOptions()
+	jdwpfsOptions.ConnectRetry = opts.ConnectRetry
+	jdwpfsOptions.ConnectRetryInterval = opts.ConnectRetryInterval
+	jdwpfsOptions.ConnectOnDemand = opts.ConnectOnDemand
+	jdwpfsOptions.IdleDisconnect = opts.IdleDisconnect
+	jdwpfsOptions.Compat = opts.Compat
+	jdwpfsOptions.ClassInclude = opts.ClassInclude
+	jdwpfsOptions.ClassExclude = opts.ClassExclude
+	jdwpfsOptions.ClassPrefetch = opts.ClassPrefetch
+	jdwpfsOptions.ClassPrefetchRate = opts.ClassPrefetchRate
+	jdwpfsOptions.MaxDirEntries = opts.MaxDirEntries
+	jdwpfsOptions.IdRadix = opts.IdRadix
+	jdwpfsOptions.AllowInvoke = opts.AllowInvoke
+	jdwpfsOptions.Strict = opts.Strict
+	jdwpfsOptions.MaxConcurrentJdwp = opts.MaxConcurrentJdwp
+	jdwpfsOptions.ReadRetries = opts.ReadRetries
+	jdwpfsOptions.NoThreads = opts.NoThreads
+	jdwpfsOptions.NoClasses = opts.NoClasses
+	jdwpfsOptions.NoEvents = opts.NoEvents
+	jdwpfsOptions.AutoResume = opts.AutoResume
+	jdwpfsOptions.AutoResumeTimeout = opts.AutoResumeTimeout
+	jdwpfsOptions.ResumeOnMount = opts.ResumeOnMount
+	jdwpfsOptions.EventsImport = opts.EventsImport
+	jdwpfsOptions.ReconnectPreserveEvents = opts.ReconnectPreserveEvents
+	jdwpfsOptions.Policy = opts.Policy
+	jdwpfsOptions.EventBufferSize = opts.EventBufferSize
+	jdwpfsOptions.SuspendOnEventInspect = opts.SuspendOnEventInspect
+	jdwpfsOptions.ValueToString = opts.ValueToString
+	jdwpfsOptions.FileMode = opts.FileMode
+	jdwpfsOptions.DirMode = opts.DirMode
+	jdwpfsOptions.ControlMode = opts.ControlMode
+
+	return jdwpfsOptions
+}
+
+// mountName tells the mounts of different JVMs apart
+func mountName(opts Options) string {
+	if opts.MountName != "" {
+		return opts.MountName
+	}
+
+	return "jdwpfs@" + net.JoinHostPort(opts.DebuggedHost, strconv.Itoa(opts.DebuggedPort))
+}
+
+// fuseOptions builds the mount options; only root may hand the files
+// over to another user or group
+func fuseOptions(opts Options) (*fs.Options, error) {
+	uid := os.Getuid()
+	gid := os.Getgid()
+	privileged := os.Geteuid() == 0
+
+	if opts.Uid >= 0 && opts.Uid != uid {
+		if !privileged {
+			return nil, fmt.Errorf("only root can serve the files as uid %d", opts.Uid)
+		}
+		uid = opts.Uid
+	}
+
+	if opts.Gid >= 0 && opts.Gid != gid {
+		if !privileged {
+			return nil, fmt.Errorf("only root can serve the files as gid %d", opts.Gid)
+		}
+		gid = opts.Gid
+	}
+
+	return &fs.Options{
+		MountOptions: fuse.MountOptions { // these should be tunable
+			AllowOther: true,
+			MaxBackground: 8,
+			FsName: mountName(opts),
+			Name: "jdwpfs",
+		},
+		
+		UID: uint32(uid),
+		GID: uint32(gid),
+	}, nil
+}
+
+// mount serves root at mountpoint, as fs.Mount does; with a trace file,
+// the operations are traced to it
+func mount(mountpoint string, root fs.InodeEmbedder, options *fs.Options, trace io.Writer) (*fuse.Server, error) {
+	var rawFs fuse.RawFileSystem = fs.NewNodeFS(root, options)
+	if trace != nil {
+		rawFs = newTracingFileSystem(rawFs, trace)
+	}
+
+	server, err := fuse.NewServer(rawFs, mountpoint, &options.MountOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	go server.Serve()
+	err = server.WaitMount()
+	if err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
+
+// check is a readiness probe: it reports on out whether the JVM can be
+// debugged, and fails if it can't
+func check(out io.Writer, opts Options) error {
+	// the probe is the connection itself
+	options := jdwpfsOptions(opts)
+	options.ConnectOnDemand = false
+	options.IdleDisconnect = 0
+
+	jdwpContext := context.Background()
+	rootFs, err := jdwpfs.NewJdwpRootfs(jdwpContext, "", opts.DebuggedHost, opts.DebuggedPort, options)
+	if err != nil {
+		fmt.Fprintf(out, "connection: failed: %s\n", err)
+		return err
+	}
+	defer rootFs.JdwpConnection.Close()
+	fmt.Fprintf(out, "connection: ok %s:%d\n", opts.DebuggedHost, opts.DebuggedPort)
+
+	threads, err := rootFs.JdwpConnection.GetAllThreads()
+	if err != nil {
+		fmt.Fprintf(out, "threads: failed: %s\n", err)
+		return err
+	}
+	fmt.Fprintf(out, "threads: ok %d\n", len(threads))
+
+	classes, err := rootFs.JdwpConnection.GetAllClasses()
+	if err != nil {
+		fmt.Fprintf(out, "classes: failed: %s\n", err)
+		return err
+	}
+	fmt.Fprintf(out, "classes: ok %d\n", len(classes))
+
+	return nil
 }
 
 func main() {
@@ -33,46 +237,99 @@ func main() {
 		os.Exit(0)
 	}
 
+	if opts.Version {
+		fmt.Print(jdwpfs.BuildInfo())
+		os.Exit(0)
+	}
+
+	if opts.Check {
+		err = check(os.Stdout, opts)
+		if err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if len(args) != 2 {
 		log.Fatalf("mountpoint not supplied: %s\n", args)
 	}
 
 	mountpoint := args[1]
 
+	if opts.Daemonize && !isDaemon() {
+		err = daemonize()
+		if err != nil {
+			log.Fatalf("unable to daemonize: %s\n", err)
+		}
+		os.Exit(0)
+	}
+
+	absoluteMountpoint, err := filepath.Abs(mountpoint)
+	if err != nil {
+		if opts.Strict {
+			log.Fatalf("unable to make the mountpoint absolute: %s\n", err)
+		}
+		log.Printf("unable to make the mountpoint absolute, links may break: %s\n", err)
+	}
+
+	// a stale mount can't even be stat'ed
+	err = guardMountpoint(absoluteMountpoint, opts.Force)
+	if err != nil {
+		log.Fatalf("refusing to mount: %s\n", err)
+	}
+
 	_, err = os.Stat(mountpoint)
 	if err != nil {
 		panic(err)
 	}
-
-	absoluteMountpoint, _ := filepath.Abs(mountpoint)
 	
 	log.Printf("mounting at %s\n", mountpoint)
 	log.Printf("debugging at %s:%d\n", opts.DebuggedHost, opts.DebuggedPort)
+	if opts.ValueToString && !opts.AllowInvoke {
+		log.Printf("--value-tostring needs --allow-invoke, showing object ids\n")
+	}
 
-	fuseOptions := &fs.Options{
-		MountOptions: fuse.MountOptions { // these should be tunable
-			AllowOther: true,
-			MaxBackground: 8,
-			FsName: "jdwpfs",
-			Name: "jdwpfs",
-		},
-		
-		UID: uint32(os.Getuid()),
-		GID: uint32(os.Getgid()),
+	mountOptions, err := fuseOptions(opts)
+	if err != nil {
+		log.Fatalf("invalid mount options: %s\n", err)
 	}
+
 	jdwpContext := context.Background()
-	rootFs, err := jdwpfs.NewJdwpRootfs(jdwpContext, absoluteMountpoint, opts.DebuggedHost, opts.DebuggedPort)
+	rootFs, err := jdwpfs.NewJdwpRootfs(jdwpContext, absoluteMountpoint, opts.DebuggedHost, opts.DebuggedPort, jdwpfsOptions(opts))
 
 	if err != nil {
 		panic(err)
 	}
 	
-	server, err := fs.Mount(mountpoint, rootFs, fuseOptions)
+	var trace io.Writer
+	if opts.TraceFile != "" {
+		traceFile, err := os.OpenFile(opts.TraceFile, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0644)
+		if err != nil {
+			log.Fatalf("unable to open the trace file: %s\n", err)
+		}
+		defer traceFile.Close()
+		trace = traceFile
+	}
+
+	server, err := mount(mountpoint, rootFs, mountOptions, trace)
 
 	if err != nil {
 		log.Fatalf("mount failed: %s\n", err)
 	}
 
+	if opts.Pidfile != "" {
+		err = writePidfile(opts.Pidfile, os.Getpid())
+		if err != nil {
+			server.Unmount()
+			log.Fatalf("unable to write the pid file: %s\n", err)
+		}
+		defer removePidfile(opts.Pidfile, os.Getpid())
+	}
+
+	if isDaemon() {
+		daemonReady()
+	}
+
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
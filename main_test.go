@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package main
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+
+	jdwp "github.com/omerye/gojdb/jdwp"
+)
+
+// checkHandler has no threads, and classes failing with errorCode
+func checkHandler(errorCode uint16) fakeHandler {
+	return func(set uint8, cmd uint8, data []byte) (uint16, []byte) {
+		switch {
+		case set == 1 && cmd == 3:
+			if errorCode != 0 {
+				return errorCode, nil
+			}
+			return 0, fakeConcat(fakeInt(1), []byte{1}, fakeLong(100), fakeString("Lcom/example/Main;"), fakeInt(7))
+		case set == 1 && cmd == 4:
+			return 0, fakeInt(0)
+		default:
+			return 0, nil
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var tests = []struct {
+		name string
+		listening bool
+		errorCode uint16
+		fails bool
+		report []string
+	}{
+		{
+			name: "ready",
+			listening: true,
+			report: []string { "connection: ok", "threads: ok 0", "classes: ok 1" },
+		},
+		{
+			name: "classes failing",
+			listening: true,
+			errorCode: uint16(jdwp.ErrInternal),
+			fails: true,
+			report: []string { "connection: ok", "threads: ok 0", "classes: failed" },
+		},
+		{
+			name: "nobody listening",
+			fails: true,
+			report: []string { "connection: failed" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var port int
+			if test.listening {
+				port = listenFakeVM(t, checkHandler(test.errorCode))
+			} else {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatalf("unable to listen: %s", err)
+				}
+				port = listener.Addr().(*net.TCPAddr).Port
+				listener.Close()
+			}
+
+			var opts Options
+			if _, err := flags.ParseArgs(&opts, []string { "jdwpfs", "--check" }); err != nil {
+				t.Fatalf("unable to parse the flags: %s", err)
+			}
+			opts.DebuggedHost = "127.0.0.1"
+			opts.DebuggedPort = port
+
+			var out strings.Builder
+			err := check(&out, opts)
+			if failed := err != nil; failed != test.fails {
+				t.Fatalf("failed: %v, error: %v", failed, err)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if len(lines) != len(test.report) {
+				t.Fatalf("reported %q", out.String())
+			}
+			for i, prefix := range test.report {
+				if !strings.HasPrefix(lines[i], prefix) {
+					t.Fatalf("line %d: %q, expected %q", i, lines[i], prefix)
+				}
+			}
+		})
+	}
+}
+
+func TestFuseOptionsOwner(t *testing.T) {
+	uid, gid := os.Getuid(), os.Getgid()
+	privileged := os.Geteuid() == 0
+
+	var tests = []struct {
+		name string
+		uid int
+		gid int
+		other bool
+		expectedUid int
+		expectedGid int
+	}{
+		{ name: "running user", uid: -1, gid: -1, expectedUid: uid, expectedGid: gid },
+		{ name: "running user, given", uid: uid, gid: gid, expectedUid: uid, expectedGid: gid },
+		{ name: "other user", uid: uid + 1, gid: -1, other: true, expectedUid: uid + 1, expectedGid: gid },
+		{ name: "other group", uid: -1, gid: gid + 1, other: true, expectedUid: uid, expectedGid: gid + 1 },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, err := fuseOptions(Options { Uid: test.uid, Gid: test.gid })
+
+			// only root hands the files over
+			if test.other && !privileged {
+				if err == nil {
+					t.Fatalf("an unprivileged user served the files as %d:%d", test.uid, test.gid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			if options.UID != uint32(test.expectedUid) || options.GID != uint32(test.expectedGid) {
+				t.Fatalf("got %d:%d, expected %d:%d", options.UID, options.GID, test.expectedUid, test.expectedGid)
+			}
+		})
+	}
+}
+
+func TestMountName(t *testing.T) {
+	var tests = []struct {
+		name string
+		opts Options
+		mountName string
+	}{
+		{ name: "host and port", opts: Options { DebuggedHost: "localhost", DebuggedPort: 5005 }, mountName: "jdwpfs@localhost:5005" },
+		{ name: "other port", opts: Options { DebuggedHost: "localhost", DebuggedPort: 5006 }, mountName: "jdwpfs@localhost:5006" },
+		{ name: "ipv6 host", opts: Options { DebuggedHost: "::1", DebuggedPort: 5005 }, mountName: "jdwpfs@[::1]:5005" },
+		{
+			name: "given name",
+			opts: Options { DebuggedHost: "localhost", DebuggedPort: 5005, MountName: "service-a" },
+			mountName: "service-a",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if mountName := mountName(test.opts); mountName != test.mountName {
+				t.Fatalf("got %q, expected %q", mountName, test.mountName)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// findFuseMount looks for a FUSE filesystem mounted at mountpoint in a
+// mountinfo table, as in /proc/self/mountinfo, and returns its source
+// (e.g. jdwpfs@host:port)
+func findFuseMount(mountinfo io.Reader, mountpoint string) (string, bool, error) {
+	mountpoint = filepath.Clean(mountpoint)
+
+	scanner := bufio.NewScanner(mountinfo)
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		var separator = -1
+		for i := 5; i < len(fields); i++ {
+			if fields[i] == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator < 0 || separator + 2 >= len(fields) {
+			continue
+		}
+
+		if unescapeMountinfo(fields[4]) != mountpoint {
+			continue
+		}
+
+		fsType := fields[separator + 1]
+		if fsType == "fuse" || strings.HasPrefix(fsType, "fuse.") {
+			return unescapeMountinfo(fields[separator + 2]), true, nil
+		}
+	}
+
+	return "", false, scanner.Err()
+}
+
+// unescapeMountinfo undoes the octal escapes of spaces, tabs, newlines
+// and backslashes in mountinfo paths
+func unescapeMountinfo(field string) string {
+	var unescaped strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i + 3 < len(field) {
+			if c, err := strconv.ParseUint(field[i + 1:i + 4], 8, 8); err == nil {
+				unescaped.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		unescaped.WriteByte(field[i])
+	}
+
+	return unescaped.String()
+}
+
+// guardMountpoint refuses a mountpoint which already has a FUSE mount,
+// e.g. left behind by a crash, unless force is set, in which case the
+// mount is detached first
+func guardMountpoint(mountpoint string, force bool) error {
+	mountinfo, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		log.Printf("unable to check for existing mounts: %s\n", err)
+		return nil
+	}
+	defer mountinfo.Close()
+
+	source, found, err := findFuseMount(mountinfo, mountpoint)
+	if err != nil {
+		log.Printf("unable to check for existing mounts: %s\n", err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+
+	if !force {
+		return fmt.Errorf("%s is already mounted (%s); unmount it with `fusermount -u %s`, or pass --force", mountpoint, source, mountpoint)
+	}
+
+	log.Printf("%s is already mounted (%s), detaching it\n", mountpoint, source)
+	err = syscall.Unmount(mountpoint, syscall.MNT_DETACH)
+	if err == nil {
+		return nil
+	}
+
+	// unprivileged users go through the setuid helper
+	output, err := exec.Command("fusermount", "-u", "-z", mountpoint).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("unable to detach %s: %s: %s", mountpoint, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
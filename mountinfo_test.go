@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMountinfo = `22 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+25 22 0:22 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+61 22 0:48 / /mnt/jvm rw,nosuid,nodev,relatime shared:30 - fuse jdwpfs@localhost:5005 rw,user_id=1000,group_id=1000
+62 22 0:49 / /mnt/other\040jvm rw,nosuid,nodev,relatime - fuse.jdwpfs service-a rw,user_id=1000,group_id=1000
+63 22 0:50 / /mnt/tmp rw,relatime - tmpfs tmpfs rw
+64 22 0:51 / /mnt/broken rw,relatime
+`
+
+func TestFindFuseMount(t *testing.T) {
+	var tests = []struct {
+		name string
+		mountpoint string
+		source string
+		found bool
+	}{
+		{ name: "fuse mount", mountpoint: "/mnt/jvm", source: "jdwpfs@localhost:5005", found: true },
+		{ name: "unclean path", mountpoint: "/mnt/jvm/", source: "jdwpfs@localhost:5005", found: true },
+		{ name: "fuse subtype, escaped path", mountpoint: "/mnt/other jvm", source: "service-a", found: true },
+		{ name: "other filesystem", mountpoint: "/mnt/tmp", found: false },
+		{ name: "line without separator", mountpoint: "/mnt/broken", found: false },
+		{ name: "below a mount", mountpoint: "/mnt/jvm/threads", found: false },
+		{ name: "not mounted", mountpoint: "/mnt/empty", found: false },
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, found, err := findFuseMount(strings.NewReader(testMountinfo), test.mountpoint)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			if found != test.found || source != test.source {
+				t.Fatalf("got %q, %v, expected %q, %v", source, found, test.source, test.found)
+			}
+		})
+	}
+}
+
+func TestUnescapeMountinfo(t *testing.T) {
+	var tests = []struct {
+		field string
+		unescaped string
+	}{
+		{ field: "/mnt/jvm", unescaped: "/mnt/jvm" },
+		{ field: "/mnt/a\\040b", unescaped: "/mnt/a b" },
+		{ field: "/mnt/a\\011b\\012c", unescaped: "/mnt/a\tb\nc" },
+		{ field: "/mnt/a\\134b", unescaped: "/mnt/a\\b" },
+		{ field: "/mnt/a\\04", unescaped: "/mnt/a\\04" },
+		{ field: "/mnt/a\\xyz", unescaped: "/mnt/a\\xyz" },
+	}
+
+	for _, test := range tests {
+		if unescaped := unescapeMountinfo(test.field); unescaped != test.unescaped {
+			t.Errorf("%s: got %q, expected %q", test.field, unescaped, test.unescaped)
+		}
+	}
+}
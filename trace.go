@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+//
+// Tracing filesystem
+// Wraps the filesystem served to the kernel, appending a line per
+// operation to a writer: time, operation, path, errno and latency in
+// microseconds, separated by tabs. Paths are learnt from the lookups,
+// and dropped once the kernel forgets the node; a node reached
+// otherwise, e.g. through a listing, shows as #<nodeid>
+//
+type tracingFileSystem struct {
+	fuse.RawFileSystem
+
+	mu sync.Mutex
+	out io.Writer
+	paths map[uint64]string
+}
+
+func newTracingFileSystem(fs fuse.RawFileSystem, out io.Writer) *tracingFileSystem {
+	return &tracingFileSystem {
+		RawFileSystem: fs,
+		out: out,
+		paths: map[uint64]string {
+			fuse.FUSE_ROOT_ID: "/",
+		},
+	}
+}
+
+func (t *tracingFileSystem) path(nodeId uint64) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	nodePath, ok := t.paths[nodeId]
+	if !ok {
+		return fmt.Sprintf("#%d", nodeId)
+	}
+	return nodePath
+}
+
+// learn remembers the path of a node created or found under a parent;
+// node ids are reused once forgotten, so a later lookup overwrites it
+func (t *tracingFileSystem) learn(parent uint64, name string, out *fuse.EntryOut, status fuse.Status) {
+	if !status.Ok() || out.NodeId == 0 {
+		return
+	}
+
+	nodePath := path.Join(t.path(parent), name)
+
+	t.mu.Lock()
+	t.paths[out.NodeId] = nodePath
+	t.mu.Unlock()
+}
+
+// forgetPath drops the path of a node the kernel forgot; its id may be
+// given to another node afterwards
+func (t *tracingFileSystem) forgetPath(nodeId uint64) {
+	if nodeId == fuse.FUSE_ROOT_ID {
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.paths, nodeId)
+	t.mu.Unlock()
+}
+
+// trace writes the line of an operation; a failed write only loses the
+// line, and never fails the operation
+func (t *tracingFileSystem) trace(op string, nodePath string, start time.Time, status fuse.Status) {
+	now := time.Now()
+	line := fmt.Sprintf("%s\t%s\t%s\t%d\t%d\n",
+		now.Format(time.RFC3339Nano), op, nodePath, int(status), now.Sub(start).Microseconds())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.out, line)
+}
+
+func (t *tracingFileSystem) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name string, out *fuse.EntryOut) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.Lookup(cancel, header, name, out)
+	t.learn(header.NodeId, name, out, status)
+	t.trace("lookup", path.Join(t.path(header.NodeId), name), start, status)
+	return status
+}
+
+// Forget isn't traced, as the kernel forgets nodes on its own
+func (t *tracingFileSystem) Forget(nodeId uint64, nlookup uint64) {
+	t.RawFileSystem.Forget(nodeId, nlookup)
+	t.forgetPath(nodeId)
+}
+
+func (t *tracingFileSystem) GetAttr(cancel <-chan struct{}, input *fuse.GetAttrIn, out *fuse.AttrOut) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.GetAttr(cancel, input, out)
+	t.trace("getattr", t.path(input.NodeId), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) SetAttr(cancel <-chan struct{}, input *fuse.SetAttrIn, out *fuse.AttrOut) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.SetAttr(cancel, input, out)
+	t.trace("setattr", t.path(input.NodeId), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) Mkdir(cancel <-chan struct{}, input *fuse.MkdirIn, name string, out *fuse.EntryOut) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.Mkdir(cancel, input, name, out)
+	t.learn(input.NodeId, name, out, status)
+	t.trace("mkdir", path.Join(t.path(input.NodeId), name), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) Unlink(cancel <-chan struct{}, header *fuse.InHeader, name string) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.Unlink(cancel, header, name)
+	t.trace("unlink", path.Join(t.path(header.NodeId), name), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) Rmdir(cancel <-chan struct{}, header *fuse.InHeader, name string) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.Rmdir(cancel, header, name)
+	t.trace("rmdir", path.Join(t.path(header.NodeId), name), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) Rename(cancel <-chan struct{}, input *fuse.RenameIn, oldName string, newName string) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.Rename(cancel, input, oldName, newName)
+	t.trace("rename", path.Join(t.path(input.NodeId), oldName), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) Symlink(cancel <-chan struct{}, header *fuse.InHeader, pointedTo string, linkName string, out *fuse.EntryOut) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.Symlink(cancel, header, pointedTo, linkName, out)
+	t.learn(header.NodeId, linkName, out, status)
+	t.trace("symlink", path.Join(t.path(header.NodeId), linkName), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) Readlink(cancel <-chan struct{}, header *fuse.InHeader) ([]byte, fuse.Status) {
+	start := time.Now()
+	out, status := t.RawFileSystem.Readlink(cancel, header)
+	t.trace("readlink", t.path(header.NodeId), start, status)
+	return out, status
+}
+
+func (t *tracingFileSystem) Open(cancel <-chan struct{}, input *fuse.OpenIn, out *fuse.OpenOut) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.Open(cancel, input, out)
+	t.trace("open", t.path(input.NodeId), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) Read(cancel <-chan struct{}, input *fuse.ReadIn, buf []byte) (fuse.ReadResult, fuse.Status) {
+	start := time.Now()
+	result, status := t.RawFileSystem.Read(cancel, input, buf)
+	t.trace("read", t.path(input.NodeId), start, status)
+	return result, status
+}
+
+func (t *tracingFileSystem) Write(cancel <-chan struct{}, input *fuse.WriteIn, data []byte) (uint32, fuse.Status) {
+	start := time.Now()
+	written, status := t.RawFileSystem.Write(cancel, input, data)
+	t.trace("write", t.path(input.NodeId), start, status)
+	return written, status
+}
+
+func (t *tracingFileSystem) OpenDir(cancel <-chan struct{}, input *fuse.OpenIn, out *fuse.OpenOut) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.OpenDir(cancel, input, out)
+	t.trace("opendir", t.path(input.NodeId), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) ReadDir(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.ReadDir(cancel, input, out)
+	t.trace("readdir", t.path(input.NodeId), start, status)
+	return status
+}
+
+func (t *tracingFileSystem) ReadDirPlus(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList) fuse.Status {
+	start := time.Now()
+	status := t.RawFileSystem.ReadDirPlus(cancel, input, out)
+	t.trace("readdirplus", t.path(input.NodeId), start, status)
+	return status
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: LGPL-3.0
+// Copyright (C) 2022 jdwpfs Authors M. G. Dan
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// lookupFileSystem finds every name, as the next node id
+type lookupFileSystem struct {
+	fuse.RawFileSystem
+
+	nextId uint64
+	forgotten []uint64
+}
+
+func (l *lookupFileSystem) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name string, out *fuse.EntryOut) fuse.Status {
+	if name == "missing" {
+		return fuse.ENOENT
+	}
+
+	l.nextId++
+	out.NodeId = l.nextId
+	return fuse.OK
+}
+
+func (l *lookupFileSystem) Forget(nodeId uint64, nlookup uint64) {
+	l.forgotten = append(l.forgotten, nodeId)
+}
+
+func TestTracingPaths(t *testing.T) {
+	type lookup struct {
+		parent uint64
+		name string
+	}
+
+	var tests = []struct {
+		name string
+		lookups []lookup
+		forgotten []uint64
+		paths map[uint64]string
+		kept int
+	}{
+		{
+			name: "learnt from lookups",
+			lookups: []lookup {
+				{ parent: fuse.FUSE_ROOT_ID, name: "threads" },
+				{ parent: 2, name: "1" },
+			},
+			paths: map[uint64]string { 2: "/threads", 3: "/threads/1", 4: "#4" },
+			kept: 3,
+		},
+		{
+			name: "failed lookup",
+			lookups: []lookup {
+				{ parent: fuse.FUSE_ROOT_ID, name: "missing" },
+			},
+			paths: map[uint64]string { 2: "#2" },
+			kept: 1,
+		},
+		{
+			name: "dropped once forgotten",
+			lookups: []lookup {
+				{ parent: fuse.FUSE_ROOT_ID, name: "threads" },
+				{ parent: 2, name: "1" },
+			},
+			forgotten: []uint64 { 3 },
+			paths: map[uint64]string { 2: "/threads", 3: "#3" },
+			kept: 2,
+		},
+		{
+			name: "root never forgotten",
+			forgotten: []uint64 { fuse.FUSE_ROOT_ID },
+			paths: map[uint64]string { fuse.FUSE_ROOT_ID: "/" },
+			kept: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			raw := &lookupFileSystem {
+				RawFileSystem: fuse.NewDefaultRawFileSystem(),
+				nextId: fuse.FUSE_ROOT_ID,
+			}
+			tracing := newTracingFileSystem(raw, &out)
+
+			for _, lookup := range test.lookups {
+				var entry fuse.EntryOut
+				tracing.Lookup(nil, &fuse.InHeader { NodeId: lookup.parent }, lookup.name, &entry)
+			}
+			for _, nodeId := range test.forgotten {
+				tracing.Forget(nodeId, 1)
+			}
+
+			if len(raw.forgotten) != len(test.forgotten) {
+				t.Fatalf("%d forgets passed on, expected %d", len(raw.forgotten), len(test.forgotten))
+			}
+			for nodeId, expected := range test.paths {
+				if nodePath := tracing.path(nodeId); nodePath != expected {
+					t.Errorf("node %d: path %q, expected %q", nodeId, nodePath, expected)
+				}
+			}
+
+			if kept := len(tracing.paths); kept != test.kept {
+				t.Errorf("%d paths kept, expected %d", kept, test.kept)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if len(test.lookups) != 0 && len(lines) != len(test.lookups) {
+				t.Fatalf("%d trace lines for %d lookups", len(lines), len(test.lookups))
+			}
+			for _, line := range lines {
+				if line != "" && len(strings.Split(line, "\t")) != 5 {
+					t.Errorf("trace line %q doesn't have 5 fields", line)
+				}
+			}
+		})
+	}
+}